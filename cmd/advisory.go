@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// First key of the advisory locks styx takes, "styx" in ASCII, so they can be
+// told apart from the application's own in pg_locks
+const advisoryLockClass = 0x73747978
+
+// How often a busy advisory lock is tried again
+const advisoryLockPoll = 500 * time.Millisecond
+
+// Takes the Postgres advisory lock guarding the migrations table, so two
+// deploy jobs can't apply migrations to the same database at once. It waits
+// up to wait for a running apply to finish. The lock is held by a connection
+// of its own until release is called
+func acquireApplyLock(ctx context.Context, dsn, table string, wait time.Duration) (release func(), err error) {
+	db, err := dbDialect.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	// Session-level advisory locks belong to the connection that took them
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	closeAll := func() {
+		conn.Close()
+		db.Close()
+	}
+	// The lock is released even once the command was interrupted
+	release = func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1, $2)", advisoryLockClass, advisoryLockKey(table)); err != nil {
+			log.Warn().Err(err).Msg("Failed to release the apply lock")
+		}
+		closeAll()
+	}
+
+	deadline := time.Now().Add(wait)
+	logged := false
+	for {
+		var locked bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1, $2)", advisoryLockClass, advisoryLockKey(table)).Scan(&locked)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to take the apply lock: %w", err)
+		}
+		if locked {
+			return release, nil
+		}
+
+		holder := applyLockHolder(ctx, conn, table)
+		if time.Now().After(deadline) {
+			closeAll()
+			return nil, fmt.Errorf("migrations are being applied by another session%s, gave up after waiting %s", holder, wait)
+		}
+		if !logged {
+			log.Info().Msgf("Waiting up to %s for the migrations applied by another session%s...", wait, holder)
+			logged = true
+		}
+		select {
+		case <-ctx.Done():
+			closeAll()
+			return nil, fmt.Errorf("stopped waiting for the apply lock: %w", ctx.Err())
+		case <-time.After(advisoryLockPoll):
+		}
+	}
+}
+
+// Describes the session holding the apply lock, e.g. " (pid 4242,
+// ci@10.0.0.7, connected since 12:03:11)", or returns an empty string if it
+// can't be found
+func applyLockHolder(ctx context.Context, conn *sql.Conn, table string) string {
+	var (
+		pid   int
+		user  string
+		addr  sql.NullString
+		since time.Time
+	)
+	err := conn.QueryRowContext(ctx, `
+		SELECT a.pid, a.usename, host(a.client_addr), a.backend_start
+		FROM pg_locks l
+		JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.granted AND l.classid = $1 AND l.objid = $2 AND l.objsubid = 2`,
+		advisoryLockClass, advisoryLockKey(table)).Scan(&pid, &user, &addr, &since)
+	if err != nil {
+		return ""
+	}
+	if addr.Valid {
+		user += "@" + addr.String
+	}
+	return fmt.Sprintf(" (pid %d, %s, connected since %s)", pid, user, since.Format(time.TimeOnly))
+}
+
+// Hashes the migrations table into the second key of the lock, so databases
+// with several migrations tables can be migrated independently. It's kept
+// positive to compare equal to the objid of pg_locks
+func advisoryLockKey(table string) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(table))
+	return int32(h.Sum32() & 0x7fffffff)
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	gomigrate "github.com/golang-migrate/migrate"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/dialect"
+	"styx/migrate"
+)
+
+var (
+	applyDsn             string
+	applyMigrationsDir   string
+	applyMigrationsTable string
+	applyLockWait        time.Duration
+	applyDryRun          bool
+)
+
+var applyCommand = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply pending migrations to a database",
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "migrations-dir", &applyMigrationsDir, cfg.MigrationsDir)
+		configString(cmd, "migrations-table", &applyMigrationsTable, cfg.MigrationsTable)
+		if !cmd.Flags().Changed("lock-wait") {
+			applyLockWait = cfg.Apply.LockWait
+		}
+		if noDocker {
+			cfg.Postgres.Embedded = true
+		}
+		configString(cmd, "pg-image", &pgImage, cfg.Postgres.Image)
+		cfg.Postgres.Image = pgImage
+
+		if applyDryRun {
+			dsn, err := resolveDSN(applyDsn)
+			if err == nil {
+				err = dryRunMigrations(cmd.Context(), dsn, applyMigrationsDir, applyMigrationsTable)
+			}
+			if err != nil {
+				log.Error().Err(err).Msgf("Dry run failed")
+				os.Exit(exitCode(err))
+			}
+			return
+		}
+
+		if err := applyMigrations(cmd.Context(), applyDsn, applyMigrationsDir, applyMigrationsTable); err != nil {
+			log.Error().Err(err).Msgf("Failed to apply migrations")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func applyMigrations(ctx context.Context, dsn, migrationsDir, table string) error {
+	dsn, err := resolveDSN(dsn)
+	if err != nil {
+		return err
+	}
+
+	// golang-migrate's own lock is shared by every migrations table of the
+	// database, and waits without saying what for
+	if dbDialect == dialect.Postgres {
+		release, err := acquireApplyLock(ctx, dsn, table, applyLockWait)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	m, err := dbDialect.Migrate(migrate.SourceURL(migrationsDir), dsn, table)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if applyLockWait > 0 {
+		m.LockTimeout = applyLockWait
+	}
+
+	if cfg.Backfill.Required {
+		if err := checkBackfills(m, migrationsDir); err != nil {
+			return err
+		}
+	}
+
+	if err := m.Up(); err != nil {
+		if err == gomigrate.ErrNoChange {
+			log.Info().Msg("Database is up to date")
+			return nil
+		}
+		return err
+	}
+
+	version, _, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("failed to read database version: %w", err)
+	}
+	log.Info().Msgf("Database migrated to version %d", version)
+
+	return nil
+}
+
+// Refuses to apply pending migrations whose backfills are still skeletons
+func checkBackfills(m *gomigrate.Migrate, migrationsDir string) error {
+	current, _, err := m.Version()
+	if err != nil && !errors.Is(err, gomigrate.ErrNilVersion) {
+		return fmt.Errorf("failed to read database version: %w", err)
+	}
+
+	files, err := migrate.ReadDir(migrationsDir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.Direction != "up" || f.Version <= uint64(current) {
+			continue
+		}
+		columns, err := migrate.Backfills(f.Path)
+		if err != nil {
+			return err
+		}
+		if len(columns) > 0 {
+			return fmt.Errorf("%s has unfinished backfills of %s: write the UPDATE and remove the %q line", f.Path, strings.Join(columns, ", "), migrate.BackfillMarker)
+		}
+	}
+	return nil
+}
+
+func init() {
+	applyCommand.Flags().StringVar(&applyDsn, "dsn", "", "Connection string of the database to migrate, instead of --env")
+	applyCommand.Flags().StringVarP(&applyMigrationsDir, "migrations-dir", "m", "migrations", "Directory containing the migrations")
+	applyCommand.Flags().StringVar(&applyMigrationsTable, "migrations-table", migrate.DefaultTable, "Table golang-migrate records the applied version in")
+	applyCommand.Flags().DurationVar(&applyLockWait, "lock-wait", time.Minute, "How long to wait for migrations applied by another session to finish")
+	applyCommand.Flags().BoolVar(&applyDryRun, "dry-run", false, "Apply the pending migrations to a scratch copy of the database's schema instead, and report their timing")
+	applyCommand.Flags().BoolVar(&noDocker, "no-docker", false, "Run the scratch copy of --dry-run in an embedded Postgres instead of a Docker container")
+	applyCommand.Flags().StringVar(&pgImage, "pg-image", "", "Docker image of the scratch Postgres of --dry-run, e.g. postgres:17 or postgis/postgis:16-3.4")
+
+	rootCmd.AddCommand(applyCommand)
+}
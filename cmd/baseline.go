@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	gomigrate "github.com/golang-migrate/migrate"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/diff"
+	"styx/hcl"
+	"styx/migrate"
+	"styx/schema"
+)
+
+var (
+	baselineDsn             string
+	baselineSchemaFile      string
+	baselineMigrationsDir   string
+	baselineMigrationsTable string
+	baselineVersioning      string
+)
+
+var baselineCommand = &cobra.Command{
+	Use:   "baseline",
+	Short: "Adopt an existing database, writing its schema as schema.sql and an initial migration",
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "schema", &baselineSchemaFile, cfg.Schema)
+		configString(cmd, "migrations-dir", &baselineMigrationsDir, cfg.MigrationsDir)
+		configString(cmd, "migrations-table", &baselineMigrationsTable, cfg.MigrationsTable)
+		configString(cmd, "versioning", &baselineVersioning, cfg.Versioning)
+
+		if err := baselineDatabase(cmd.Context(), baselineDsn, baselineSchemaFile, baselineMigrationsDir, baselineMigrationsTable); err != nil {
+			log.Error().Err(err).Msgf("Failed to baseline database")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+// Entrypoint function for the command
+func baselineDatabase(ctx context.Context, dsn, schemaFile, migrationsDir, table string) error {
+	// 1. Make sure there's no schema.sql or migration to overwrite, and that
+	//    the database has no migrations applied
+	// 2. Introspect the database
+	// 3. Write its schema to schema.sql, and as the initial migration
+	// 4. Record the migration as applied, without running it
+	dsn, err := resolveDSN(dsn)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(schemaFile); err == nil {
+		return fmt.Errorf("%s already exists", schemaFile)
+	}
+	files, err := migrate.ReadDir(migrationsDir)
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		return fmt.Errorf("%s already contains migrations", migrationsDir)
+	}
+
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", migrationsDir, err)
+	}
+	m, err := dbDialect.Migrate(migrate.SourceURL(migrationsDir), dsn, table)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if version, _, err := m.Version(); !errors.Is(err, gomigrate.ErrNilVersion) {
+		if err != nil {
+			return fmt.Errorf("failed to read database version: %w", err)
+		}
+		return fmt.Errorf("database already has migrations applied, up to version %d", version)
+	}
+
+	current, err := dumpDatabaseSchema(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to dump database schema: %w", err)
+	}
+	// Opening the migrations table created it, under a name that can differ
+	// from the configured one
+	current.Tables = slices.DeleteFunc(current.Tables, func(t *schema.Table) bool {
+		return t.Name == table
+	})
+	filterObjects(current, current)
+
+	opts := diff.Options{Dialect: dbDialect.SQL}
+	up, down := migrate.Changes(&schema.Schema{}, current, opts)
+	if len(up) == 0 {
+		return fmt.Errorf("database has no objects to baseline")
+	}
+
+	content := schemaSQL(current)
+	if isHCL(schemaFile) {
+		if content, err = hcl.Write(current); err != nil {
+			return err
+		}
+		content += "\n"
+	}
+	if err := os.WriteFile(schemaFile, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", schemaFile, err)
+	}
+	fmt.Printf("Created %s\n", schemaFile)
+
+	migration, err := migrate.New(migrationsDir, up, down, migrate.Versioning(baselineVersioning))
+	if err != nil {
+		return fmt.Errorf("failed to build migration: %w", err)
+	}
+	migration.Description = "baseline"
+	migration.Format = migrate.Format(cfg.Format)
+	if migration.Template, err = migrationTemplate(schemaFile); err != nil {
+		return err
+	}
+	paths, err := migration.Write(migrationsDir)
+	if err != nil {
+		return fmt.Errorf("failed to write migration: %w", err)
+	}
+	for _, path := range paths {
+		fmt.Printf("Created %s\n", path)
+	}
+	if err := updateLock(migrationsDir, nil, migrate.Fingerprint(current, dbDialect.SQL)); err != nil {
+		return err
+	}
+	if err := migrate.WriteSnapshot(cfg.Snapshots, migration.Version, current, dbDialect.SQL); err != nil {
+		return err
+	}
+
+	// The database already has the schema, so the migration is only marked
+	// as applied
+	if err := m.Force(int(migration.Version)); err != nil {
+		return fmt.Errorf("failed to record version %d: %w", migration.Version, err)
+	}
+	log.Info().Msgf("Database baselined at version %d", migration.Version)
+
+	return nil
+}
+
+func init() {
+	baselineCommand.Flags().StringVar(&baselineDsn, "dsn", "", "Connection string of the database to adopt, instead of --env")
+	baselineCommand.Flags().StringVar(&baselineSchemaFile, "schema", "schema.sql", "Path of the schema.sql file to write")
+	baselineCommand.Flags().StringVarP(&baselineMigrationsDir, "migrations-dir", "m", "migrations", "Directory to write the initial migration to")
+	baselineCommand.Flags().StringVar(&baselineMigrationsTable, "migrations-table", migrate.DefaultTable, "Table golang-migrate records the applied version in")
+	baselineCommand.Flags().StringVar(&baselineVersioning, "versioning", "", "Version scheme of the migration: sequential or timestamp (default sequential)")
+
+	rootCmd.AddCommand(baselineCommand)
+}
@@ -0,0 +1,341 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/dialect"
+	"styx/diff"
+	"styx/lint"
+)
+
+// Checks run by ci, in order
+var ciChecks = []string{"test", "lint", "drift"}
+
+var (
+	ciInputFile     string
+	ciMigrationsDir string
+	ciDsn           string
+	ciSummaryFile   string
+	ciSkip          []string
+)
+
+var ciCommand = &cobra.Command{
+	Use:   "ci",
+	Short: "Run the migration checks in CI, annotating problems for GitHub Actions",
+	Long: `Runs the checks a pull request should pass: test, replaying the migrations to
+check they match schema.sql, lint, checking them for unsafe statements, and
+drift, comparing a live database against schema.sql when --env or --dsn is
+passed. Problems are printed as GitHub Actions annotations, shown inline on the
+files of the pull request, and a markdown summary is written to --summary-file,
+by default the job summary of GitHub Actions. Checks are skipped with --skip.
+Exits with status 2 if test or drift finds a problem, 4 if lint does, or 1 if
+a check couldn't run.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "input", &ciInputFile, cfg.Schema)
+		configString(cmd, "migrations-dir", &ciMigrationsDir, cfg.MigrationsDir)
+		if noDocker {
+			cfg.Postgres.Embedded = true
+		}
+		configString(cmd, "pg-image", &pgImage, cfg.Postgres.Image)
+		cfg.Postgres.Image = pgImage
+		for _, name := range ciSkip {
+			if !slices.Contains(ciChecks, name) {
+				log.Error().Msgf("Unknown check %q, expected one of %s", name, strings.Join(ciChecks, ", "))
+				os.Exit(exitError)
+			}
+		}
+		if ciSummaryFile == "" {
+			ciSummaryFile = os.Getenv("GITHUB_STEP_SUMMARY")
+		}
+
+		checks := runChecks(cmd.Context(), ciInputFile, ciMigrationsDir, ciDsn)
+		if ciSummaryFile != "" {
+			if err := writeSummary(ciSummaryFile, checks); err != nil {
+				log.Error().Err(err).Msgf("Failed to write summary")
+				os.Exit(exitCode(err))
+			}
+		}
+		// Errors take precedence, then the first check finding a problem
+		code := exitOK
+		for _, check := range checks {
+			switch {
+			case check.err != nil && code != exitInterrupted:
+				code = exitCode(check.err)
+			case check.failed() && code == exitOK:
+				code = check.exitCode()
+			}
+		}
+		os.Exit(code)
+	},
+}
+
+// ciCheck is the outcome of one of the checks
+type ciCheck struct {
+	name string
+	// skipped is why the check didn't run, if it didn't
+	skipped string
+	// passed describes a check without problems, like the number of files
+	// checked
+	passed      string
+	err         error
+	annotations []annotation
+}
+
+func (c ciCheck) failed() bool {
+	for _, a := range c.annotations {
+		if a.level == "error" {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the exit code of a failed check, the same as the command it comes
+// from
+func (c ciCheck) exitCode() int {
+	if c.name == "lint" {
+		return exitLint
+	}
+	return exitDrift
+}
+
+// annotation is a GitHub Actions workflow command attaching a message to a
+// file and line
+type annotation struct {
+	// level is error, warning or notice
+	level   string
+	file    string
+	line    int
+	title   string
+	message string
+	// sql is the statement fixing the problem, shown in the summary
+	sql string
+}
+
+// Runs the checks not skipped, printing their annotations as they finish
+func runChecks(ctx context.Context, schemaFile, migrationsDir, dsn string) []ciCheck {
+	checks := make([]ciCheck, len(ciChecks))
+	for i, name := range ciChecks {
+		checks[i].name = name
+	}
+	for i := range checks {
+		check := &checks[i]
+		switch {
+		case slices.Contains(ciSkip, check.name):
+			check.skipped = "skipped with --skip"
+		case check.name == "test":
+			check.err = ciTest(ctx, check, schemaFile, migrationsDir)
+		case check.name == "lint" && dbDialect != dialect.Postgres && dbDialect != dialect.Cockroach:
+			check.skipped = fmt.Sprintf("lint isn't supported with the %s dialect", dbDialect.Name)
+		case check.name == "lint":
+			check.err = ciLint(check, migrationsDir)
+		case environment == "" && dsn == "":
+			check.skipped = "no database to check, pass --env or --dsn"
+		case dbDialect.Parse == nil:
+			check.skipped = fmt.Sprintf("drift isn't supported with the %s dialect", dbDialect.Name)
+		default:
+			check.err = ciDrift(ctx, check, schemaFile, dsn)
+		}
+
+		for _, a := range check.annotations {
+			fmt.Println(a)
+		}
+		switch {
+		case check.skipped != "":
+			fmt.Printf("%s: skipped, %s\n", check.name, check.skipped)
+		case check.err != nil:
+			fmt.Println(annotation{level: "error", title: "styx " + check.name, message: check.err.Error()})
+		case check.failed():
+			fmt.Printf("%s: failed\n", check.name)
+		default:
+			fmt.Printf("%s: passed, %s\n", check.name, check.passed)
+		}
+	}
+	return checks
+}
+
+func ciTest(ctx context.Context, check *ciCheck, schemaFile, migrationsDir string) error {
+	changes, err := replayChanges(ctx, schemaFile, migrationsDir)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		check.annotations = append(check.annotations, changeAnnotation(schemaFile, change, "Missing migration",
+			fmt.Sprintf("No migration makes this change to %s: %s. Run styx generate and commit the migration", schemaFile, change)))
+	}
+	check.passed = "the migrations match " + schemaFile
+	return nil
+}
+
+func ciLint(check *ciCheck, migrationsDir string) error {
+	paths, findings, err := lintFindings(nil, migrationsDir)
+	if err != nil {
+		return err
+	}
+	for _, finding := range findings {
+		level := "error"
+		if finding.Severity != lint.Error {
+			level = "warning"
+		}
+		check.annotations = append(check.annotations, annotation{
+			level:   level,
+			file:    finding.File,
+			line:    finding.Line,
+			title:   finding.Rule,
+			message: finding.Message,
+		})
+	}
+	check.passed = fmt.Sprintf("%d migration(s) checked", len(paths))
+	return nil
+}
+
+func ciDrift(ctx context.Context, check *ciCheck, schemaFile, dsn string) error {
+	changes, err := driftChanges(ctx, schemaFile, dsn)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		check.annotations = append(check.annotations, changeAnnotation(schemaFile, change, "Schema drift",
+			fmt.Sprintf("The database differs from %s: %s", schemaFile, change)))
+	}
+	check.passed = "the database matches " + schemaFile
+	return nil
+}
+
+// Returns the annotation of a change, on the line of schemaFile declaring the
+// object it's about when there's one
+func changeAnnotation(schemaFile string, change diff.Change, title, message string) annotation {
+	a := annotation{level: "error", title: title, message: message, sql: change.SQL}
+	if info, err := os.Stat(schemaFile); err == nil && !info.IsDir() {
+		a.file = schemaFile
+		name := change.Name
+		if change.Table != "" {
+			name = change.Table
+		}
+		a.line = declarationLine(schemaFile, name)
+	}
+	return a
+}
+
+// Returns the first line of path creating an object named name, or 0
+func declarationLine(path, name string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	pattern := regexp.MustCompile(`(?i)\bcreate\b.*[\s."` + "`" + `]` + regexp.QuoteMeta(name) + `["` + "`" + `]?(\s|\(|$)`)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if pattern.MatchString(scanner.Text()) {
+			return line
+		}
+	}
+	return 0
+}
+
+// Formats the annotation as a workflow command, like
+// ::error file=schema.sql,line=3,title=Missing migration::...
+func (a annotation) String() string {
+	var properties []string
+	if a.file != "" {
+		properties = append(properties, "file="+escapeProperty(a.file))
+	}
+	if a.line > 0 {
+		properties = append(properties, fmt.Sprintf("line=%d", a.line))
+	}
+	if a.title != "" {
+		properties = append(properties, "title="+escapeProperty(a.title))
+	}
+	command := "::" + a.level
+	if len(properties) > 0 {
+		command += " " + strings.Join(properties, ",")
+	}
+	return command + "::" + escapeData(a.message)
+}
+
+// Escapes the message of a workflow command, which ends at the line
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// Escapes a property of a workflow command, which also ends at a comma
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// Appends the markdown summary of the checks to path, which GitHub Actions
+// shows on the run's page
+func writeSummary(path string, checks []ciCheck) error {
+	var b strings.Builder
+	b.WriteString("## styx\n\n| Check | Result |\n| --- | --- |\n")
+	for _, check := range checks {
+		result := "Passed, " + check.passed
+		switch {
+		case check.skipped != "":
+			result = "Skipped, " + check.skipped
+		case check.err != nil:
+			result = "Error: " + check.err.Error()
+		case check.failed():
+			result = fmt.Sprintf("Failed, %d problem(s)", len(check.annotations))
+		}
+		fmt.Fprintf(&b, "| %s | %s |\n", check.name, strings.ReplaceAll(result, "|", `\|`))
+	}
+
+	for _, check := range checks {
+		if len(check.annotations) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", check.name)
+		for _, a := range check.annotations {
+			location := a.file
+			if a.line > 0 {
+				location = fmt.Sprintf("%s:%d", a.file, a.line)
+			}
+			if location != "" {
+				location = "`" + location + "` "
+			}
+			fmt.Fprintf(&b, "- %s**%s** %s\n", location, a.level, a.message)
+			if a.sql != "" {
+				fmt.Fprintf(&b, "\n  ```sql\n  %s\n  ```\n", strings.ReplaceAll(a.sql, "\n", "\n  "))
+			}
+		}
+	}
+
+	// Other steps append to the same summary
+	b.WriteString("\n")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(b.String()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func init() {
+	ciCommand.Flags().StringVarP(&ciInputFile, "input", "i", "schema.sql", "Path to the input schema.sql file, HCL schema, directory of .sql files, or models like gorm:./models")
+	ciCommand.Flags().StringVarP(&ciMigrationsDir, "migrations-dir", "m", "migrations", "Directory containing the migrations")
+	ciCommand.Flags().StringVar(&ciDsn, "dsn", "", "Connection string of the database to check for drift, instead of --env")
+	ciCommand.Flags().StringVar(&ciSummaryFile, "summary-file", "", "Path of the markdown summary to append to (default $GITHUB_STEP_SUMMARY)")
+	ciCommand.Flags().StringSliceVar(&ciSkip, "skip", nil, "Checks to skip: test, lint or drift")
+	ciCommand.Flags().BoolVar(&noDocker, "no-docker", false, "Replay migrations in an embedded Postgres instead of a Docker container")
+	ciCommand.Flags().StringVar(&pgImage, "pg-image", "", "Docker image of the scratch Postgres, e.g. postgres:17 or postgis/postgis:16-3.4")
+	filterFlags(ciCommand)
+
+	rootCmd.AddCommand(ciCommand)
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/internal/docker"
+)
+
+var cleanCommand = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove containers and volumes left behind by interrupted runs",
+	Run: func(cmd *cobra.Command, args []string) {
+		removed, err := docker.Clean(cmd.Context())
+		for _, name := range removed {
+			fmt.Printf("Removed %s\n", name)
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to clean up")
+			os.Exit(exitCode(err))
+		}
+		if len(removed) == 0 {
+			fmt.Println("Nothing to clean up")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanCommand)
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"styx/diff"
+	"styx/hcl"
+	"styx/orm"
+	"styx/schema"
+)
+
+var convertOutputFile string
+
+var convertCommand = &cobra.Command{
+	Use:   "convert INPUT",
+	Short: "Convert a schema between schema.sql and HCL",
+	Long: `Converts an HCL schema, like schema.hcl, into SQL, and a SQL schema, a
+directory of SQL files or ORM models, like gorm:./models, into HCL. Writing to
+a file with --output-file converts to the format of its extension instead, e.g.
+to see the SQL styx reads from ORM models. The result is printed unless
+--output-file is set. Objects HCL schemas can't describe, like functions and
+triggers, make converting to HCL fail.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := convertSchema(args[0], convertOutputFile); err != nil {
+			logFailure(err, "Failed to convert schema")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func convertSchema(inputFile, outputFile string) error {
+	toHCL := !isHCL(inputFile)
+	if outputFile != "" {
+		toHCL = isHCL(outputFile)
+	}
+	var converted string
+	var err error
+	if toHCL {
+		converted, err = convertToHCL(inputFile)
+	} else {
+		converted, err = convertToSQL(inputFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasSuffix(converted, "\n") {
+		converted += "\n"
+	}
+	if outputFile == "" {
+		fmt.Print(converted)
+		return nil
+	}
+	if err := os.WriteFile(outputFile, []byte(converted), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputFile, err)
+	}
+	fmt.Printf("Created %s\n", outputFile)
+	return nil
+}
+
+func convertToSQL(inputFile string) (string, error) {
+	sqlFile, removeSQLFile, err := desiredSchemaFile(inputFile)
+	if err != nil {
+		return "", err
+	}
+	defer removeSQLFile()
+
+	data, err := os.ReadFile(sqlFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", sqlFile, err)
+	}
+	return string(data), nil
+}
+
+func convertToHCL(inputFile string) (string, error) {
+	if dbDialect.Parse == nil {
+		return "", fmt.Errorf("converting to HCL isn't supported with the %s dialect", dbDialect.Name)
+	}
+	sqlFile, removeSQLFile, err := desiredSchemaFile(inputFile)
+	if err != nil {
+		return "", err
+	}
+	defer removeSQLFile()
+
+	s, err := dbDialect.Parse(sqlFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", inputFile, locateSchemaErrors(err, inputFile, sqlFile))
+	}
+	return hcl.Write(s)
+}
+
+// Returns the reader of the models a path like gorm:./models or
+// ent:./ent/schema points at
+func ormModels(path string) (func() (*schema.Schema, error), bool) {
+	source, dir, ok := strings.Cut(path, ":")
+	if !ok {
+		return nil, false
+	}
+	switch source {
+	case "gorm":
+		return func() (*schema.Schema, error) { return orm.GORM(dir, dbDialect.Name) }, true
+	case "ent":
+		return func() (*schema.Schema, error) { return orm.Ent(dir, dbDialect.Name) }, true
+	}
+	return nil, false
+}
+
+func isHCL(path string) bool {
+	return filepath.Ext(path) == ".hcl"
+}
+
+// Returns the SQL file describing the desired schema. HCL schemas,
+// directories of SQL files and ORM models are written to a temporary file
+// removed by cleanup
+func desiredSchemaFile(path string) (string, func(), error) {
+	var sql string
+	if models, ok := ormModels(path); ok {
+		s, err := models()
+		if err != nil {
+			return "", nil, err
+		}
+		sql = schemaSQL(s)
+	} else if info, err := os.Stat(path); err == nil && info.IsDir() {
+		if sql, err = schema.ReadDir(path); err != nil {
+			return "", nil, err
+		}
+	} else if isHCL(path) {
+		if sql, err = hcl.SQLFile(path); err != nil {
+			return "", nil, err
+		}
+	} else {
+		return path, func() {}, nil
+	}
+	f, err := os.CreateTemp("", "styx-schema-*.sql")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary schema file: %w", err)
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	_, err = f.WriteString(sql)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write temporary schema file: %w", err)
+	}
+	return f.Name(), cleanup, nil
+}
+
+// Points the *schema.SourceErrors in err, located in the SQL file
+// desiredSchemaFile returned for path, at the input they come from. Lines of
+// HCL schemas and models aren't known, only their path
+func locateSchemaErrors(err error, path, sqlFile string) error {
+	if sqlFile == path {
+		return err
+	}
+	if info, statErr := os.Stat(path); statErr == nil && info.IsDir() {
+		if sql, readErr := os.ReadFile(sqlFile); readErr == nil {
+			schema.LocateInDir(err, path, string(sql))
+		}
+		return err
+	}
+	if _, dir, ok := strings.Cut(path, ":"); ok && !isHCL(path) {
+		path = dir
+	}
+	for _, e := range schema.SourceErrors(err) {
+		*e = schema.SourceError{File: path, Err: e.Err}
+	}
+	return err
+}
+
+// Reads the desired schema from path, loading it in a scratch database for
+// dialects without a parser
+func readDesiredSchema(ctx context.Context, path string) (*schema.Schema, error) {
+	sqlFile, removeSQLFile, err := desiredSchemaFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer removeSQLFile()
+
+	if dbDialect.Parse != nil {
+		s, err := dbDialect.Parse(sqlFile)
+		if err != nil {
+			return nil, locateSchemaErrors(err, path, sqlFile)
+		}
+		if err := s.Validate(cfg.Postgres.Extensions); err != nil {
+			return nil, fmt.Errorf("invalid desired schema: %w", locateSchemaErrors(err, path, sqlFile))
+		}
+		if err := diff.CheckDependencies(s); err != nil {
+			return nil, fmt.Errorf("invalid desired schema: %w", err)
+		}
+		return s, nil
+	}
+	scratchDsn, cleanup, err := startScratchDatabase(ctx, cfg.Postgres)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return dbDialect.Load(ctx, scratchDsn, sqlFile)
+}
+
+func init() {
+	convertCommand.Flags().StringVarP(&convertOutputFile, "output-file", "o", "", "Path of the converted schema file to write (default stdout)")
+
+	rootCmd.AddCommand(convertCommand)
+}
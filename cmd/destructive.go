@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+
+	"styx/diff"
+)
+
+// Makes sure destructive changes are intended: either --allow-destructive
+// was passed, or each change is confirmed on the terminal
+func confirmDestructive(changes []diff.Change, allowed bool) error {
+	var destructive []diff.Change
+	for _, change := range changes {
+		if change.Destructive {
+			destructive = append(destructive, change)
+		}
+	}
+	if len(destructive) == 0 || allowed {
+		return nil
+	}
+
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		var names []string
+		for _, change := range destructive {
+			names = append(names, change.String())
+		}
+		return fmt.Errorf("%w (%s), pass --allow-destructive to generate it anyway",
+			errDestructive, strings.Join(names, ", "))
+	}
+
+	fmt.Println("This migration contains changes that can lose data:")
+	reader := bufio.NewReader(os.Stdin)
+	for _, change := range destructive {
+		fmt.Printf("\n  %s\n", change)
+		for _, line := range strings.Split(change.SQL, "\n") {
+			fmt.Printf("      %s\n", line)
+		}
+		fmt.Print("Generate this change? [y/N] ")
+
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read answer: %w", err)
+		}
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			return fmt.Errorf("%w, aborted as %s was not confirmed", errDestructive, change)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/diff"
+)
+
+var (
+	diffFromDsn string
+	diffToDsn   string
+)
+
+var diffCommand = &cobra.Command{
+	Use:   "diff",
+	Short: "Print the SQL that makes one live database match another",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := diffDatabases(cmd.Context(), diffFromDsn, diffToDsn); err != nil {
+			log.Error().Err(err).Msgf("Failed to diff databases")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func diffDatabases(ctx context.Context, fromDsn, toDsn string) error {
+	fromDsn, err := environmentOrDSN(fromDsn)
+	if err != nil {
+		return err
+	}
+	toDsn, err = environmentOrDSN(toDsn)
+	if err != nil {
+		return err
+	}
+
+	fromSchema, err := dumpDatabaseSchema(ctx, fromDsn)
+	if err != nil {
+		return fmt.Errorf("failed to dump schema of --from database: %w", err)
+	}
+
+	toSchema, err := dumpDatabaseSchema(ctx, toDsn)
+	if err != nil {
+		return fmt.Errorf("failed to dump schema of --to database: %w", err)
+	}
+	filterObjects(fromSchema, toSchema)
+	// Statements of objects depending on each other in a cycle can't be
+	// ordered
+	if err := diff.CheckDependencies(fromSchema); err != nil {
+		return fmt.Errorf("invalid schema of --from database: %w", err)
+	}
+	if err := diff.CheckDependencies(toSchema); err != nil {
+		return fmt.Errorf("invalid schema of --to database: %w", err)
+	}
+
+	changes := diff.Diff(fromSchema, toSchema, expandOptions(diff.Options{ConcurrentIndexes: concurrentIndexes, Dialect: dbDialect.SQL}))
+	if jsonOutput() {
+		return newReport(changes).print()
+	}
+	if len(changes) == 0 {
+		log.Info().Msg("Databases are in sync")
+		return nil
+	}
+
+	for i, change := range changes {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(change.SQL)
+	}
+
+	return nil
+}
+
+func init() {
+	diffCommand.Flags().StringVar(&diffFromDsn, "from", "", "Connection string or environment name of the database to migrate (required)")
+	diffCommand.Flags().StringVar(&diffToDsn, "to", "", "Connection string or environment name of the database to match (required)")
+	diffCommand.Flags().BoolVar(&concurrentIndexes, "concurrent-indexes", false, "Create and drop indexes on existing tables with CONCURRENTLY")
+	filterFlags(diffCommand)
+	outputFlag(diffCommand)
+
+	diffCommand.MarkFlagRequired("from")
+	diffCommand.MarkFlagRequired("to")
+
+	rootCmd.AddCommand(diffCommand)
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"styx/schemadoc"
+)
+
+var (
+	docsInputFile string
+	docsOutputDir string
+	docsFormat    string
+)
+
+var docsCommand = &cobra.Command{
+	Use:   "docs",
+	Short: "Generate a documentation site of the schema",
+	Long: `Generates a static documentation site of the schema in --output-dir, in HTML
+or Markdown: an index of the tables, views, types, sequences and functions
+with a diagram of the tables, and a page for each table with its columns,
+comments, constraints, indexes and the foreign keys to and from it. Pages of
+tables and views no longer in the schema are removed, so the site can be
+regenerated whenever schema.sql changes. Diagrams are drawn with Mermaid, which
+the HTML pages load from a CDN and GitHub renders in Markdown.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "input", &docsInputFile, cfg.Schema)
+		if noDocker {
+			cfg.Postgres.Embedded = true
+		}
+		configString(cmd, "pg-image", &pgImage, cfg.Postgres.Image)
+		cfg.Postgres.Image = pgImage
+
+		if err := generateDocs(cmd.Context(), docsInputFile, docsOutputDir, docsFormat); err != nil {
+			logFailure(err, "Failed to generate docs")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func generateDocs(ctx context.Context, inputFile, outputDir, format string) error {
+	s, err := readDesiredSchema(ctx, inputFile)
+	if err != nil {
+		return err
+	}
+	filterObjects(s, s)
+	pages, err := schemadoc.Render(s, format)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	for path, page := range pages {
+		path = filepath.Join(outputDir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(page), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+
+	// Remove the pages of objects dropped since the site was generated
+	ext := filepath.Ext(paths[0])
+	for _, dir := range []string{"tables", "views"} {
+		stale, err := filepath.Glob(filepath.Join(outputDir, dir, "*"+ext))
+		if err != nil {
+			return err
+		}
+		for _, path := range stale {
+			if slices.Contains(paths, path) {
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			fmt.Printf("Removed %s\n", path)
+		}
+	}
+	fmt.Printf("Created %d page(s) in %s\n", len(paths), outputDir)
+	return nil
+}
+
+func init() {
+	docsCommand.Flags().StringVarP(&docsInputFile, "input", "i", "schema.sql", "Path to the input schema.sql file, HCL schema, directory of .sql files, or models like gorm:./models")
+	docsCommand.Flags().StringVarP(&docsOutputDir, "output-dir", "o", "docs/schema", "Directory to write the site to")
+	docsCommand.Flags().StringVar(&docsFormat, "format", "html", "Format of the pages: html or markdown")
+	docsCommand.Flags().BoolVar(&noDocker, "no-docker", false, "Load the schema in an embedded Postgres instead of a Docker container")
+	docsCommand.Flags().StringVar(&pgImage, "pg-image", "", "Docker image of the scratch Postgres, e.g. postgres:17 or postgis/postgis:16-3.4")
+	filterFlags(docsCommand)
+
+	rootCmd.AddCommand(docsCommand)
+}
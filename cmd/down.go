@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"styx/diff"
+	"styx/migrate"
+	"styx/schema"
+)
+
+// Applies the new migrations, usually one, in the scratch database, on top
+// of the seeds and fixture if any. Unless changes were skipped on purpose,
+// which converge says, generating again must find nothing left to do, or
+// every run would write another migration. Unless skipDown is set, it then
+// rolls them back and checks that the rollback left the schema as it was
+// before
+func checkNewMigration(ctx context.Context, migrationsDir, dsn string, count int, before, desired *schema.Schema, converge, skipDown bool) error {
+	m, err := dbDialect.Migrate(migrate.SourceURL(migrationsDir), dsn, cfg.MigrationsTable)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	start := time.Now()
+	if err := m.Steps(count); err != nil {
+		return fmt.Errorf("failed to apply the up migration: %w", err)
+	}
+	log.Info().Msgf("Applied the new migration in %s", time.Since(start).Round(time.Millisecond))
+
+	if converge {
+		applied, err := dumpDatabaseSchema(ctx, dsn)
+		if err != nil {
+			return err
+		}
+		filterObjects(applied, desired)
+		if changes := diff.Diff(applied, desired, diff.Options{Dialect: dbDialect.SQL}); len(changes) > 0 {
+			err := fmt.Errorf("the new migration doesn't reach the desired schema, generating again would make %d more change(s), first: %s", len(changes), changes[0])
+			if verifyIdempotent {
+				return err
+			}
+			log.Warn().Err(err).Msg("Please report this as a bug in styx, along with the schema. Pass --verify-idempotent to fail instead")
+		}
+	}
+
+	if skipDown {
+		return nil
+	}
+
+	log.Info().Msg("Checking that the down migration undoes the up migration...")
+	if err := m.Steps(-count); err != nil {
+		return fmt.Errorf("failed to apply the down migration: %w", err)
+	}
+
+	after, err := dumpDatabaseSchema(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	filterObjects(after, desired)
+
+	if changes := diff.Diff(after, before, diff.Options{Dialect: dbDialect.SQL}); len(changes) > 0 {
+		return fmt.Errorf("the down migration doesn't undo the up migration (%d change(s) left, first: %s)", len(changes), changes[0])
+	}
+	return nil
+}
+
+// Removes the files of a migration that turned out to be broken
+func removeMigration(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil {
+			log.Error().Err(err).Msgf("Failed to remove %s", path)
+		}
+	}
+}
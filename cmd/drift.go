@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"styx/diff"
+)
+
+var errDrift = errors.New("schema drift detected")
+
+var (
+	driftInputFile string
+	driftDsn       string
+)
+
+var driftCommand = &cobra.Command{
+	Use:   "drift",
+	Short: "Check a live database against the input schema.sql file",
+	Long: `Compares a live database against the input schema.sql file and prints the
+differences. Exits with status 2 if the database has drifted, or 1 on errors.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "input", &driftInputFile, cfg.Schema)
+
+		err := checkDrift(cmd.Context(), driftInputFile, driftDsn)
+		if errors.Is(err, errDrift) {
+			os.Exit(exitDrift)
+		}
+		if err != nil {
+			logFailure(err, "Failed to check for drift")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func checkDrift(ctx context.Context, schemaFile, dsn string) error {
+	changes, err := driftChanges(ctx, schemaFile, dsn)
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if err := newReport(changes).print(); err != nil {
+			return err
+		}
+	} else if len(changes) == 0 {
+		fmt.Println("No drift detected")
+	} else {
+		printDriftReport(changes)
+	}
+
+	if len(changes) > 0 {
+		return errDrift
+	}
+	return nil
+}
+
+// Returns the changes needed to bring the database at dsn to the desired
+// schema
+func driftChanges(ctx context.Context, schemaFile, dsn string) ([]diff.Change, error) {
+	dsn, err := resolveDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a parser, schema.sql can only be loaded into a scratch
+	// database, which generate --check does
+	if dbDialect.Parse == nil {
+		return nil, fmt.Errorf("drift isn't supported with the %s dialect, use `styx generate --check` instead", dbDialect.Name)
+	}
+	sqlFile, removeSQLFile, err := desiredSchemaFile(schemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load desired schema: %w", err)
+	}
+	defer removeSQLFile()
+	desiredSchema, err := dbDialect.Parse(sqlFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load desired schema: %w", locateSchemaErrors(err, schemaFile, sqlFile))
+	}
+	if err := diff.CheckDependencies(desiredSchema); err != nil {
+		return nil, fmt.Errorf("invalid desired schema: %w", err)
+	}
+
+	currentSchema, err := dumpDatabaseSchema(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump current database schema: %w", err)
+	}
+	filterObjects(currentSchema, desiredSchema)
+
+	return diff.Diff(currentSchema, desiredSchema, diff.Options{Dialect: dbDialect.SQL}), nil
+}
+
+// Prints each change along with the SQL that would fix it
+func printDriftReport(changes []diff.Change) {
+	fmt.Printf("Schema drift detected (%d change(s) needed):\n", len(changes))
+	for _, change := range changes {
+		fmt.Printf("\n  %s\n", change)
+		for _, line := range strings.Split(change.SQL, "\n") {
+			fmt.Printf("      %s\n", line)
+		}
+	}
+}
+
+func init() {
+	driftCommand.Flags().StringVarP(&driftInputFile, "input", "i", "schema.sql", "Path to the input schema.sql file, HCL schema, directory of .sql files, or models like gorm:./models")
+	driftCommand.Flags().StringVar(&driftDsn, "dsn", "", "Connection string of the database to check, instead of --env")
+	filterFlags(driftCommand)
+	outputFlag(driftCommand)
+
+	rootCmd.AddCommand(driftCommand)
+}
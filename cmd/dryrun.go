@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"styx/migrate"
+	"styx/schema"
+)
+
+// Applies the pending migrations of the database at dsn to a scratch copy of
+// its schema, without its data, and reports how long each took. The database
+// itself is only read
+func dryRunMigrations(ctx context.Context, dsn, migrationsDir, table string) error {
+
+	target, err := dbDialect.ReadSchema(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to dump database schema: %w", err)
+	}
+	version, err := readVersion(ctx, dsn, table, target)
+	if err != nil {
+		return err
+	}
+	target.Tables = slices.DeleteFunc(target.Tables, func(t *schema.Table) bool {
+		return t.Name == table || t.Name == cfg.MigrationsTable
+	})
+	filterObjects(target, target)
+
+	files, err := migrate.ReadDir(migrationsDir)
+	if err != nil {
+		return err
+	}
+	var pending []migrate.File
+	for _, f := range files {
+		if f.Direction == "up" && f.Version > version {
+			pending = append(pending, f)
+		}
+	}
+	slices.SortFunc(pending, func(a, b migrate.File) int { return cmp.Compare(a.Version, b.Version) })
+	if len(pending) == 0 {
+		log.Info().Msg("Database is up to date")
+		return nil
+	}
+
+	scratchDsn, cleanup, err := startScratchDatabase(ctx, cfg.Postgres)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	log.Info().Msgf("Copying the schema of the database at version %d...", version)
+	if err := createRoles(ctx, scratchDsn, target); err != nil {
+		return err
+	}
+	if err := copySchema(ctx, scratchDsn, target); err != nil {
+		return err
+	}
+
+	m, err := dbDialect.Migrate(migrate.SourceURL(migrationsDir), scratchDsn, table)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if version > 0 {
+		if err := m.Force(int(version)); err != nil {
+			return fmt.Errorf("failed to set the version of the copy: %w", err)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tMIGRATION\tDURATION")
+	var total time.Duration
+	for _, f := range pending {
+		start := time.Now()
+		err := m.Steps(1)
+		took := time.Since(start).Round(time.Millisecond)
+		total += took
+		if err != nil {
+			fmt.Fprintf(w, "%d\t%s\tfailed after %s\n", f.Version, filepath.Base(f.Path), took)
+			w.Flush()
+			return fmt.Errorf("%s failed: %w", filepath.Base(f.Path), err)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", f.Version, filepath.Base(f.Path), took)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nApplied %d migration(s) to the copy in %s. Its tables are empty, so statements scanning or rewriting them take longer on the database itself\n", len(pending), total)
+	return nil
+}
+
+// Reads the version of the database from the migrations table, which is in
+// the dumped schema if there's one. Opening golang-migrate would create it
+func readVersion(ctx context.Context, dsn, table string, s *schema.Schema) (uint64, error) {
+	if s.Table(table) == nil {
+		return 0, nil
+	}
+
+	db, err := dbDialect.Open(dsn)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var (
+		version uint64
+		dirty   bool
+	)
+	err = db.QueryRowContext(ctx, fmt.Sprintf("SELECT version, dirty FROM %s LIMIT 1", dbDialect.QuoteName(table))).Scan(&version, &dirty)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	if dirty {
+		return 0, fmt.Errorf("database is dirty at version %d, a migration failed halfway", version)
+	}
+	return version, nil
+}
+
+// Creates the objects of s in the empty database at dsn
+func copySchema(ctx context.Context, dsn string, s *schema.Schema) error {
+	db, err := dbDialect.Open(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, schemaSQL(s)); err != nil {
+		return fmt.Errorf("failed to copy the schema: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/diff"
+	"styx/schema"
+)
+
+var (
+	dumpDsn            string
+	dumpOutputFile     string
+	dumpFromMigrations bool
+	dumpMigrationsDir  string
+)
+
+var dumpCommand = &cobra.Command{
+	Use:     "dump",
+	Aliases: []string{"inspect"},
+	Short:   "Write the schema of a database, or of the migrations, as a schema.sql file",
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "migrations-dir", &dumpMigrationsDir, cfg.MigrationsDir)
+		if noDocker {
+			cfg.Postgres.Embedded = true
+		}
+		configString(cmd, "pg-image", &pgImage, cfg.Postgres.Image)
+		cfg.Postgres.Image = pgImage
+
+		if err := dumpSchema(cmd.Context(), dumpDsn, dumpOutputFile); err != nil {
+			log.Error().Err(err).Msgf("Failed to dump schema")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func dumpSchema(ctx context.Context, dsn, outputFile string) error {
+	var s *schema.Schema
+	var err error
+	if dumpFromMigrations {
+		s, err = dumpMigrations(ctx, dumpMigrationsDir)
+	} else {
+		if dsn, err = resolveDSN(dsn); err != nil {
+			return err
+		}
+		s, err = dumpDatabaseSchema(ctx, dsn)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to dump database schema: %w", err)
+	}
+	filterObjects(s, s)
+
+	sql := schemaSQL(s)
+	if outputFile == "" {
+		fmt.Print(sql)
+		return nil
+	}
+	if err := os.WriteFile(outputFile, []byte(sql), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputFile, err)
+	}
+	log.Info().Msgf("Wrote %s", outputFile)
+	return nil
+}
+
+// Replays the migrations in a scratch database and introspects it
+func dumpMigrations(ctx context.Context, migrationsDir string) (*schema.Schema, error) {
+	scratchDsn, cleanup, err := startScratchDatabase(ctx, cfg.Postgres)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if err := createRoles(ctx, scratchDsn, &schema.Schema{}); err != nil {
+		return nil, err
+	}
+	if err := applyExistingMigrations(ctx, migrationsDir, scratchDsn); err != nil {
+		return nil, fmt.Errorf("failed to apply existing migrations: %w", err)
+	}
+	return dumpDatabaseSchema(ctx, scratchDsn)
+}
+
+// Renders the statements creating the schema from scratch, in the order
+// migrations would run them
+func schemaSQL(s *schema.Schema) string {
+	var b strings.Builder
+	for i, change := range diff.Diff(&schema.Schema{}, s, diff.Options{Dialect: dbDialect.SQL}) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(change.SQL + "\n")
+	}
+	return b.String()
+}
+
+func init() {
+	dumpCommand.Flags().StringVar(&dumpDsn, "dsn", "", "Connection string of the database to dump, instead of --env")
+	dumpCommand.Flags().StringVarP(&dumpOutputFile, "output-file", "o", "", "Path of the schema.sql file to write (default stdout)")
+	dumpCommand.Flags().BoolVar(&dumpFromMigrations, "from-migrations", false, "Dump the schema the migrations add up to, replayed in a scratch database")
+	dumpCommand.Flags().StringVarP(&dumpMigrationsDir, "migrations-dir", "m", "migrations", "Directory containing the migrations, with --from-migrations")
+	dumpCommand.Flags().BoolVar(&noDocker, "no-docker", false, "Replay migrations in an embedded Postgres instead of a Docker container")
+	dumpCommand.Flags().StringVar(&pgImage, "pg-image", "", "Docker image of the scratch Postgres, e.g. postgres:17 or postgis/postgis:16-3.4")
+	dumpCommand.MarkFlagsMutuallyExclusive("dsn", "from-migrations")
+
+	rootCmd.AddCommand(dumpCommand)
+}
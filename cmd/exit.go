@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+)
+
+// Exit codes of the commands, so CI pipelines can branch on the result
+const (
+	// exitOK is returned when there are no changes, or the command succeeded
+	exitOK = 0
+	// exitError is returned when the command couldn't run
+	exitError = 1
+	// exitDrift is returned when the schema differs from what it should be,
+	// e.g. a database from schema.sql, or schema.sql from the migrations
+	exitDrift = 2
+	// exitDestructive is returned when a migration would lose data and
+	// wasn't approved, with --allow-destructive or on the terminal
+	exitDestructive = 3
+	// exitLint is returned when a lint rule with error severity is violated
+	exitLint = 4
+	// exitInterrupted is returned when the command was interrupted, like
+	// shells report processes killed by SIGINT
+	exitInterrupted = 130
+)
+
+var errDestructive = errors.New("migration contains changes that can lose data")
+
+// Returns the exit code of a command that failed with err
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, errDestructive):
+		return exitDestructive
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	}
+	return exitError
+}
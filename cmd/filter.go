@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"styx/schema"
+)
+
+var (
+	includeObjects []string
+	excludeObjects []string
+)
+
+// Registers --include and --exclude, which replace the object filters of the
+// config
+func filterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&includeObjects, "include", nil, "Glob patterns of the tables, views and other objects to manage, e.g. 'app_*' (default all)")
+	cmd.Flags().StringSliceVar(&excludeObjects, "exclude", nil, "Glob patterns of the objects to ignore, e.g. 'audit_*'")
+}
+
+// Applies --include and --exclude on top of the config
+func resolveFilterFlags(cmd *cobra.Command) error {
+	if cmd.Flags().Changed("include") {
+		cfg.Objects.Include = includeObjects
+	}
+	if cmd.Flags().Changed("exclude") {
+		cfg.Objects.Exclude = excludeObjects
+	}
+	return cfg.Objects.Validate()
+}
+
+// Leaves out the Postgres schemas and objects styx doesn't manage, on both
+// sides of the diff
+func filterObjects(current, desired *schema.Schema) {
+	declared := slices.Clone(desired.Schemas)
+	keep := func(name string) bool {
+		return cfg.Schemas.Manages(name, slices.Contains(declared, name))
+	}
+	for _, s := range []*schema.Schema{current, desired} {
+		s.Filter(keep)
+		s.FilterObjects(cfg.Objects.Manages)
+		// Extensions installed outside of migrations aren't managed either
+		s.Extensions = slices.DeleteFunc(s.Extensions, func(e *schema.Extension) bool {
+			return slices.Contains(cfg.Postgres.Extensions, e.Name)
+		})
+		if !cfg.Grants.Enabled {
+			s.Grants = nil
+		}
+		if !cfg.ForeignData.Enabled {
+			s.Servers, s.UserMappings, s.ForeignTables = nil, nil, nil
+		}
+		if !cfg.Publications.Enabled {
+			s.Publications = nil
+		}
+		if cfg.Storage.Ignore {
+			for _, table := range s.Tables {
+				table.Options, table.Tablespace = nil, ""
+				for _, index := range table.Indexes {
+					index.Options, index.Tablespace = nil, ""
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"styx/dialect"
+	"styx/internal/psqlexec"
+	"styx/seed"
+)
+
+// Loads the fixture into the scratch database: either a directory of CSV and
+// SQL files, loaded like seeds, or a pg_dump archive in custom format, whose
+// data is restored with pg_restore
+func loadFixture(ctx context.Context, dsn, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read fixture: %w", err)
+	}
+	log.Info().Msgf("Loading fixture %s...", path)
+
+	if info.IsDir() {
+		db, err := dbDialect.Open(dsn)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		if _, err := seed.Load(ctx, db, dbDialect, path); err != nil {
+			return fmt.Errorf("failed to load fixture: %w", err)
+		}
+		return nil
+	}
+
+	if dbDialect != dialect.Postgres {
+		return fmt.Errorf("pg_dump fixtures are only supported with the postgres dialect, use a directory of CSV files instead")
+	}
+	return restoreDump(ctx, dsn, path)
+}
+
+// Restores the data of a pg_dump archive, leaving out the migrations table
+// since the scratch database has its own
+func restoreDump(ctx context.Context, dsn, path string) error {
+	if strings.HasPrefix(dsn, psqlexec.Scheme+"://") {
+		return fmt.Errorf("pg_dump fixtures can't be restored with the exec connection, use a directory of CSV files instead")
+	}
+	if _, err := exec.LookPath("pg_restore"); err != nil {
+		return fmt.Errorf("restoring %s needs pg_restore on the PATH: %w", path, err)
+	}
+
+	toc, err := exec.CommandContext(ctx, "pg_restore", "--list", path).Output()
+	if err != nil {
+		return fmt.Errorf("failed to list the contents of %s: %w", path, err)
+	}
+	var entries []string
+	for _, line := range strings.Split(string(toc), "\n") {
+		if strings.Contains(line, " TABLE DATA ") && strings.Contains(line, " "+cfg.MigrationsTable+" ") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+
+	list, err := os.CreateTemp("", "styx-fixture-*.list")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(list.Name())
+	if _, err := list.WriteString(strings.Join(entries, "\n")); err != nil {
+		list.Close()
+		return err
+	}
+	list.Close()
+
+	// Triggers, foreign keys included, are disabled so tables can be
+	// restored in any order
+	restore := exec.CommandContext(ctx, "pg_restore", "--data-only", "--disable-triggers", "--no-owner", "--exit-on-error",
+		"--use-list", list.Name(), "--dbname", dsn, path)
+	if out, err := restore.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to restore %s: %w\n%s", path, err, out)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"styx/erd"
+	"styx/schema"
+)
+
+var (
+	graphInputFile  string
+	graphOutputFile string
+	graphFormat     string
+)
+
+// Diagram formats by the extension of the output file
+var graphExtensions = map[string]string{
+	".dot":      erd.DOT,
+	".gv":       erd.DOT,
+	".mmd":      erd.Mermaid,
+	".mermaid":  erd.Mermaid,
+	".puml":     erd.PlantUML,
+	".plantuml": erd.PlantUML,
+	".pu":       erd.PlantUML,
+	".svg":      "svg",
+}
+
+var graphCommand = &cobra.Command{
+	Use:   "graph",
+	Short: "Render the schema as an entity-relationship diagram",
+	Long: `Renders the tables of the schema, their columns and keys and the foreign
+keys between them as an entity-relationship diagram, in Graphviz DOT, Mermaid
+or PlantUML, or as an SVG image drawn by Graphviz' dot, which has to be on the
+PATH. The format is picked with --format, or from the extension of
+--output-file, and defaults to Mermaid, which GitHub renders in markdown.
+Running it when the schema changes keeps documentation diagrams in sync with
+it. The diagram is printed unless --output-file is set.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "input", &graphInputFile, cfg.Schema)
+		if noDocker {
+			cfg.Postgres.Embedded = true
+		}
+		configString(cmd, "pg-image", &pgImage, cfg.Postgres.Image)
+		cfg.Postgres.Image = pgImage
+
+		if err := graphSchema(cmd.Context(), graphInputFile, graphOutputFile, graphFormat); err != nil {
+			logFailure(err, "Failed to render diagram")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func graphSchema(ctx context.Context, inputFile, outputFile, format string) error {
+	if format == "" {
+		format = erd.Mermaid
+		if outputFile != "" {
+			ext := strings.ToLower(filepath.Ext(outputFile))
+			if format = graphExtensions[ext]; format == "" {
+				return fmt.Errorf("can't tell the format of %s from its extension, pass --format", outputFile)
+			}
+		}
+	}
+
+	if !slices.Contains([]string{erd.Mermaid, erd.DOT, erd.PlantUML, "svg"}, format) {
+		return fmt.Errorf("unknown format %q, expected mermaid, dot, plantuml or svg", format)
+	}
+
+	s, err := readDesiredSchema(ctx, inputFile)
+	if err != nil {
+		return err
+	}
+	filterObjects(s, s)
+
+	var diagram string
+	if format == "svg" {
+		diagram, err = renderSVG(s)
+	} else {
+		diagram, err = erd.Render(s, format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if outputFile == "" {
+		fmt.Print(diagram)
+		return nil
+	}
+	if err := os.WriteFile(outputFile, []byte(diagram), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputFile, err)
+	}
+	fmt.Printf("Created %s\n", outputFile)
+	return nil
+}
+
+// Draws the DOT diagram of s as SVG with Graphviz
+func renderSVG(s *schema.Schema) (string, error) {
+	if _, err := exec.LookPath("dot"); err != nil {
+		return "", fmt.Errorf("rendering SVG needs Graphviz' dot on the PATH, or pass --format dot and render it yourself")
+	}
+	dot, err := erd.Render(s, erd.DOT)
+	if err != nil {
+		return "", err
+	}
+	var stderr bytes.Buffer
+	command := exec.Command("dot", "-Tsvg")
+	command.Stdin = strings.NewReader(dot)
+	command.Stderr = &stderr
+	output, err := command.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run dot: %s", strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
+
+func init() {
+	graphCommand.Flags().StringVarP(&graphInputFile, "input", "i", "schema.sql", "Path to the input schema.sql file, HCL schema, directory of .sql files, or models like gorm:./models")
+	graphCommand.Flags().StringVarP(&graphOutputFile, "output-file", "o", "", "Path of the diagram to write (default stdout)")
+	graphCommand.Flags().StringVar(&graphFormat, "format", "", "Format of the diagram: mermaid, dot, plantuml or svg (default from the extension of --output-file, or mermaid)")
+	graphCommand.Flags().BoolVar(&noDocker, "no-docker", false, "Load the schema in an embedded Postgres instead of a Docker container")
+	graphCommand.Flags().StringVar(&pgImage, "pg-image", "", "Docker image of the scratch Postgres, e.g. postgres:17 or postgis/postgis:16-3.4")
+	filterFlags(graphCommand)
+
+	rootCmd.AddCommand(graphCommand)
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/diff"
+	"styx/migrate"
+	"styx/schema"
+)
+
+var (
+	historyMigrationsDir string
+	historySnapshotsDir  string
+)
+
+var historyCommand = &cobra.Command{
+	Use:   "history",
+	Short: "Inspect how the schema evolved across migration versions",
+}
+
+var historyDiffCommand = &cobra.Command{
+	Use:   "diff V1 V2",
+	Short: "Print the changes between the schemas at two migration versions",
+	Long: `Prints the changes turning the schema at version V1 into the one at V2, from
+the snapshots generate saves. Versions without a snapshot are rebuilt by
+replaying the migrations in a scratch database. Version 0 is the empty schema.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "migrations-dir", &historyMigrationsDir, cfg.MigrationsDir)
+		configString(cmd, "snapshots-dir", &historySnapshotsDir, cfg.Snapshots)
+		if noDocker {
+			cfg.Postgres.Embedded = true
+		}
+		configString(cmd, "pg-image", &pgImage, cfg.Postgres.Image)
+		cfg.Postgres.Image = pgImage
+
+		if err := diffHistory(cmd.Context(), args[0], args[1]); err != nil {
+			log.Error().Err(err).Msgf("Failed to diff schema history")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func diffHistory(ctx context.Context, fromArg, toArg string) error {
+	var versions [2]uint64
+	for i, arg := range []string{fromArg, toArg} {
+		version, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", arg)
+		}
+		versions[i] = version
+	}
+
+	h := &history{migrationsDir: historyMigrationsDir, snapshotsDir: historySnapshotsDir}
+	defer h.close()
+	from, err := h.schemaAt(ctx, versions[0])
+	if err != nil {
+		return err
+	}
+	to, err := h.schemaAt(ctx, versions[1])
+	if err != nil {
+		return err
+	}
+
+	changes := diff.Diff(from, to, diff.Options{Dialect: dbDialect.SQL})
+	if jsonOutput() {
+		return newReport(changes).print()
+	}
+	if len(changes) == 0 {
+		fmt.Printf("The schema is the same at versions %d and %d\n", versions[0], versions[1])
+		return nil
+	}
+	fmt.Printf("From version %d to %d (%d change(s)):\n", versions[0], versions[1], len(changes))
+	for _, change := range changes {
+		fmt.Printf("\n  %s %s\n", planSymbols[change.Op], change)
+		for _, line := range strings.Split(change.SQL, "\n") {
+			fmt.Printf("      %s\n", line)
+		}
+	}
+	return nil
+}
+
+// Looks up the schema at past versions, starting a scratch database to
+// replay the migrations in when there's no snapshot of one
+type history struct {
+	migrationsDir string
+	snapshotsDir  string
+
+	scratchDsn string
+	cleanup    func()
+}
+
+func (h *history) schemaAt(ctx context.Context, version uint64) (*schema.Schema, error) {
+	if version == 0 {
+		return &schema.Schema{}, nil
+	}
+
+	snapshot, err := migrate.ReadSnapshot(h.snapshotsDir, version)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot != nil {
+		return snapshot.Schema, nil
+	}
+
+	files, err := migrate.ReadDir(h.migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, f := range files {
+		found = found || f.Version == version
+	}
+	if !found {
+		return nil, fmt.Errorf("there's no migration with version %d in %s", version, h.migrationsDir)
+	}
+
+	log.Info().Msgf("No snapshot of version %d, replaying the migrations", version)
+	if h.scratchDsn == "" {
+		if h.scratchDsn, h.cleanup, err = startScratchDatabase(ctx, cfg.Postgres); err != nil {
+			return nil, err
+		}
+		if err := createRoles(ctx, h.scratchDsn, &schema.Schema{}); err != nil {
+			return nil, err
+		}
+	}
+	if err := replayMigrations(ctx, h.migrationsDir, h.scratchDsn, version); err != nil {
+		return nil, err
+	}
+
+	s, err := dumpDatabaseSchema(ctx, h.scratchDsn)
+	if err != nil {
+		return nil, err
+	}
+	filterObjects(s, s)
+	return s, nil
+}
+
+func (h *history) close() {
+	if h.cleanup != nil {
+		h.cleanup()
+	}
+}
+
+func init() {
+	historyDiffCommand.Flags().StringVarP(&historyMigrationsDir, "migrations-dir", "m", "migrations", "Directory containing the migrations")
+	historyDiffCommand.Flags().StringVar(&historySnapshotsDir, "snapshots-dir", ".styx/snapshots", "Directory generate saves the schema at each version to")
+	historyDiffCommand.Flags().BoolVar(&noDocker, "no-docker", false, "Replay migrations in an embedded Postgres instead of a Docker container")
+	historyDiffCommand.Flags().StringVar(&pgImage, "pg-image", "", "Docker image of the scratch Postgres, e.g. postgres:17 or postgis/postgis:16-3.4")
+	outputFlag(historyDiffCommand)
+
+	historyCommand.AddCommand(historyDiffCommand)
+	rootCmd.AddCommand(historyCommand)
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/migrate"
+)
+
+var (
+	importFrom          string
+	importMigrationsDir string
+)
+
+var importCommand = &cobra.Command{
+	Use:   "import DIR",
+	Short: "Convert the migrations of another tool into golang-migrate ones",
+	Long: `Converts the goose, dbmate, Flyway or plain SQL migrations in DIR into
+golang-migrate migrations in the migrations directory, in the same order.
+Versioned migrations keep their version, with their up and down sections in
+separate files, while plain SQL files are numbered from 1 in name order and
+have no down migrations. DIR is left as is.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "migrations-dir", &importMigrationsDir, cfg.MigrationsDir)
+
+		if err := importMigrations(args[0], importMigrationsDir, migrate.Format(importFrom)); err != nil {
+			log.Error().Err(err).Msgf("Failed to import migrations")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func importMigrations(sourceDir, migrationsDir string, from migrate.Format) error {
+	files, err := migrate.ReadDir(migrationsDir)
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		return fmt.Errorf("%s already contains migrations", migrationsDir)
+	}
+
+	migrations, err := migrate.Import(sourceDir, from)
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		return fmt.Errorf("no %s migrations found in %s", from, sourceDir)
+	}
+
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", migrationsDir, err)
+	}
+	for _, migration := range migrations {
+		paths, err := migration.Write(migrationsDir)
+		if err != nil {
+			return fmt.Errorf("failed to write migration: %w", err)
+		}
+		for _, path := range paths {
+			fmt.Printf("Created %s\n", path)
+		}
+	}
+	if err := updateLock(migrationsDir, nil, ""); err != nil {
+		return err
+	}
+
+	latest := migrations[len(migrations)-1].Version
+	log.Info().Msgf("Imported %d migration(s). Databases already migrated need their version recorded in %s before applying new ones, e.g. with `migrate force %d` for the ones up to date", len(migrations), cfg.MigrationsTable, latest)
+	return nil
+}
+
+func init() {
+	importCommand.Flags().StringVar(&importFrom, "from", "", "Tool the migrations were written for: goose, dbmate, flyway or sql")
+	importCommand.Flags().StringVarP(&importMigrationsDir, "migrations-dir", "m", "migrations", "Directory to write the migrations to")
+	importCommand.MarkFlagRequired("from")
+
+	rootCmd.AddCommand(importCommand)
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/dialect"
+	"styx/lint"
+	"styx/migrate"
+)
+
+var errLint = errors.New("lint errors found")
+
+var lintMigrationsDir string
+
+var lintCommand = &cobra.Command{
+	Use:   "lint [files...]",
+	Short: "Check migrations for statements that are unsafe on a live database",
+	Long: `Checks the given migration files, or every up migration in the migrations
+directory, against the lint rules. Rule severities can be set in the config:
+
+  lint:
+    rules:
+      require-concurrent-index: error
+      volatile-default: off
+
+Exits with status 4 if a rule with error severity is violated, or 1 on errors.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "migrations-dir", &lintMigrationsDir, cfg.MigrationsDir)
+
+		err := lintMigrations(args, lintMigrationsDir)
+		if errors.Is(err, errLint) {
+			os.Exit(exitLint)
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to lint migrations")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func lintMigrations(paths []string, migrationsDir string) error {
+	paths, findings, err := lintFindings(paths, migrationsDir)
+	if err != nil {
+		return err
+	}
+
+	errorCount, warningCount := 0, 0
+	for _, finding := range findings {
+		fmt.Println(finding)
+		if finding.Severity == lint.Error {
+			errorCount++
+		} else {
+			warningCount++
+		}
+	}
+
+	if errorCount+warningCount == 0 {
+		fmt.Printf("%d migration(s) checked, no problems found\n", len(paths))
+		return nil
+	}
+	fmt.Printf("\n%d error(s), %d warning(s)\n", errorCount, warningCount)
+	if errorCount > 0 {
+		return errLint
+	}
+	return nil
+}
+
+// Lints the given migration files, or the up migrations in migrationsDir,
+// returning the files checked and what was found in them
+func lintFindings(paths []string, migrationsDir string) ([]string, []lint.Finding, error) {
+	// The rules read migrations with the Postgres parser
+	if dbDialect != dialect.Postgres && dbDialect != dialect.Cockroach {
+		return nil, nil, fmt.Errorf("lint isn't supported with the %s dialect", dbDialect.Name)
+	}
+
+	severities, err := lint.Severities(cfg.Lint.Rules)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(paths) == 0 {
+		files, err := migrate.ReadDir(migrationsDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, f := range files {
+			if f.Direction == "up" {
+				paths = append(paths, f.Path)
+			}
+		}
+	}
+
+	var all []lint.Finding
+	for _, path := range paths {
+		// Only the up section of goose migrations
+		sql, err := migrate.ReadUp(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		findings, err := lint.Lint(path, sql, severities)
+		if err != nil {
+			return nil, nil, err
+		}
+		all = append(all, findings...)
+	}
+	return paths, all, nil
+}
+
+func init() {
+	lintCommand.Flags().StringVarP(&lintMigrationsDir, "migrations-dir", "m", "migrations", "Directory containing the migrations")
+
+	rootCmd.AddCommand(lintCommand)
+}
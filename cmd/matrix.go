@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog/log"
+
+	"styx/diff"
+	"styx/internal/config"
+	"styx/schema"
+)
+
+// Replays the migrations on each Postgres version of the matrix and checks
+// that every version ends up with the desired schema
+func validateMatrix(ctx context.Context, pg config.Postgres, migrationsDir string, desired *schema.Schema) error {
+	type result struct {
+		version string
+		err     error
+	}
+
+	var results []result
+	failed := false
+	for _, version := range pg.Matrix {
+		log.Info().Msgf("Validating migrations against %s...", version)
+		err := validateVersion(ctx, pg.ForVersion(version), migrationsDir, desired)
+		if err != nil {
+			failed = true
+		}
+		results = append(results, result{version, err})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tRESULT")
+	for _, r := range results {
+		status := "ok"
+		if r.err != nil {
+			status = r.err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\n", r.version, status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed {
+		return fmt.Errorf("migrations failed on some Postgres versions")
+	}
+	return nil
+}
+
+func validateVersion(ctx context.Context, pg config.Postgres, migrationsDir string, desired *schema.Schema) error {
+	dsn, cleanup, err := startScratchDatabase(ctx, pg)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := createRoles(ctx, dsn, desired); err != nil {
+		return err
+	}
+	if err := applyExistingMigrations(ctx, migrationsDir, dsn); err != nil {
+		return err
+	}
+
+	current, err := dumpDatabaseSchema(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	filterObjects(current, desired)
+
+	if changes := diff.Diff(current, desired, diff.Options{}); len(changes) > 0 {
+		return fmt.Errorf("schema differs after migrating (%d change(s), first: %s)", len(changes), changes[0])
+	}
+	return nil
+}
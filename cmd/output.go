@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"styx/dialect"
+	"styx/diff"
+)
+
+// outputFormat is "text" or "json", for the commands with an --output flag
+var outputFormat string
+
+func jsonOutput() bool {
+	return outputFormat == "json"
+}
+
+func outputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&outputFormat, "output", "text", "Output format: text or json")
+}
+
+func validateOutputFormat() error {
+	if outputFormat != "text" && outputFormat != "json" {
+		return fmt.Errorf("unknown output format %q, expected text or json", outputFormat)
+	}
+	return nil
+}
+
+// Machine-readable changeset, printed with --output json. Logs go to stderr,
+// so stdout only ever holds the report
+type report struct {
+	Changes []reportChange `json:"changes"`
+	// Files are the migration files generate wrote, if any
+	Files []string `json:"files,omitempty"`
+}
+
+type reportChange struct {
+	Op       string        `json:"op"`
+	Kind     diff.Kind     `json:"kind"`
+	Table    string        `json:"table,omitempty"`
+	Name     string        `json:"name"`
+	SQL      string        `json:"sql"`
+	Severity diff.Severity `json:"severity"`
+	// Lock is the Postgres table lock the change takes, if any
+	Lock diff.Lock `json:"lock,omitempty"`
+}
+
+func newReport(changes []diff.Change) report {
+	r := report{Changes: []reportChange{}}
+	for _, change := range changes {
+		r.Changes = append(r.Changes, reportChange{
+			Op:       strings.ToLower(string(change.Op)),
+			Kind:     change.Kind,
+			Table:    change.Table,
+			Name:     change.Name,
+			SQL:      change.SQL,
+			Severity: change.Severity(),
+			Lock:     reportLock(change),
+		})
+	}
+	return r
+}
+
+// Lock modes only mean something on Postgres
+func reportLock(change diff.Change) diff.Lock {
+	if dbDialect != dialect.Postgres {
+		return diff.LockNone
+	}
+	return change.Lock()
+}
+
+func (r report) print() error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	return nil
+}
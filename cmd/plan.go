@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/dialect"
+	"styx/diff"
+)
+
+var planCommand = &cobra.Command{
+	Use:   "plan",
+	Short: "Print the changes the next migration would make, without writing it",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := resolveGenerateFlags(cmd); err != nil {
+			log.Error().Err(err).Msg("Invalid flags")
+			os.Exit(exitError)
+		}
+		// The matrix validates written migrations, there are none here
+		cfg.Postgres.Matrix = nil
+		showPlan = true
+		planOnly = true
+
+		if !jsonOutput() {
+			fmt.Printf("Planning migrations from %s to %s\n", inputFile, outputDir)
+		}
+		if err := generateMigrations(cmd.Context(), inputFile, outputDir); err != nil {
+			logFailure(err, "Failed to plan migrations")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+var planSymbols = map[diff.Op]string{
+	diff.OpCreate: "+",
+	diff.OpAlter:  "~",
+	diff.OpDrop:   "-",
+}
+
+// Prints the changes grouped by the table they apply to, like
+// terraform plan. Changes that don't belong to a table, like views and
+// enums, are grouped by kind. On Postgres each change also shows the table
+// lock it takes
+func printPlan(changes []diff.Change) {
+	showLocks := dbDialect == dialect.Postgres
+	var groups []string
+	grouped := map[string][]diff.Change{}
+	counts := map[diff.Severity]int{}
+	locks := map[string]int{}
+	for _, change := range changes {
+		group := "table " + change.Table
+		if change.Table == "" {
+			group = string(change.Kind) + "s"
+		}
+		if _, ok := grouped[group]; !ok {
+			groups = append(groups, group)
+		}
+		grouped[group] = append(grouped[group], change)
+		counts[change.Severity()]++
+		if lock := change.Lock(); lock.BlocksWrites() {
+			locks[lock.Blocks()]++
+		}
+	}
+
+	width := 0
+	for _, change := range changes {
+		width = max(width, len(change.String()))
+	}
+
+	for _, group := range groups {
+		fmt.Printf("\n%s\n", group)
+		for _, change := range grouped[group] {
+			if showLocks && change.Lock() != diff.LockNone {
+				fmt.Printf("  %s %-*s  %-11s  %s\n", planSymbols[change.Op], width, change, change.Severity(), change.Lock())
+			} else {
+				fmt.Printf("  %s %-*s  %s\n", planSymbols[change.Op], width, change, change.Severity())
+			}
+		}
+	}
+
+	fmt.Printf("\nPlan: %d safe, %d locking, %d destructive\n",
+		counts[diff.SeveritySafe], counts[diff.SeverityLocking], counts[diff.SeverityDestructive])
+	if showLocks {
+		fmt.Printf("Locks: %d block reads and writes, %d block writes\n", locks["reads and writes"], locks["writes"])
+	}
+}
+
+// Asks whether to write the planned migration. There's no one to ask
+// without a terminal, so the plan can't be accepted then
+func confirmPlan() error {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return errors.New("the plan can only be confirmed in a terminal, use `styx plan` to only print it")
+	}
+
+	fmt.Print("\nWrite this migration? [y/N] ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read answer: %w", err)
+	}
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return errors.New("aborted, the plan was not confirmed")
+	}
+	return nil
+}
+
+func init() {
+	generateFlags(planCommand)
+
+	rootCmd.AddCommand(planCommand)
+}
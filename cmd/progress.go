@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	gomigrate "github.com/golang-migrate/migrate"
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"styx/migrate"
+)
+
+// Width of the progress bar, in characters
+const progressWidth = 30
+
+// progressBar draws how far along a series of steps is on stderr, when it's a
+// terminal and info is the log level. Otherwise each step is logged at debug
+// level
+type progressBar struct {
+	label string
+	total int
+	draw  bool
+	drawn bool
+}
+
+func newProgressBar(label string, total int) *progressBar {
+	return &progressBar{
+		label: label,
+		total: total,
+		draw:  isatty.IsTerminal(os.Stderr.Fd()) && zerolog.GlobalLevel() == zerolog.InfoLevel,
+	}
+}
+
+// Shows that done steps are finished and the one named name is running
+func (b *progressBar) update(done int, name string) {
+	if !b.draw {
+		if name != "" {
+			log.Debug().Msgf("%s: %s (%d/%d)", b.label, name, done+1, b.total)
+		}
+		return
+	}
+	filled := progressWidth * done / b.total
+	fmt.Fprintf(os.Stderr, "\r\033[K%s [%s%s] %d/%d %s", b.label,
+		strings.Repeat("=", filled), strings.Repeat(" ", progressWidth-filled), done, b.total, name)
+	b.drawn = true
+}
+
+// Ends the bar's line, so what's printed next isn't drawn over it
+func (b *progressBar) end() {
+	if b.drawn {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// Applies the up migrations to m one at a time, up to version or all of them
+// when it's 0, showing their progress. It stops between migrations once ctx
+// is cancelled
+func stepMigrations(ctx context.Context, m *gomigrate.Migrate, migrationsDir string, version uint64) error {
+	files, err := migrate.ReadDir(migrationsDir)
+	if err != nil {
+		return err
+	}
+	var pending []migrate.File
+	for _, f := range files {
+		if f.Direction != "up" || (version > 0 && f.Version > version) {
+			continue
+		}
+		if !slices.ContainsFunc(pending, func(p migrate.File) bool { return p.Version == f.Version }) {
+			pending = append(pending, f)
+		}
+	}
+	slices.SortFunc(pending, func(a, b migrate.File) int { return cmp.Compare(a.Version, b.Version) })
+
+	if len(pending) > 0 {
+		bar := newProgressBar("Applying migrations", len(pending))
+		for i, f := range pending {
+			bar.update(i, filepath.Base(f.Path))
+			if err := ctx.Err(); err != nil {
+				bar.end()
+				return err
+			}
+			if err := m.Steps(1); err != nil {
+				bar.end()
+				return fmt.Errorf("%s failed: %w", filepath.Base(f.Path), err)
+			}
+		}
+		bar.update(len(pending), "")
+		bar.end()
+	}
+
+	// Catches up on anything golang-migrate reads that wasn't counted
+	if version > 0 {
+		err = m.Migrate(uint(version))
+	} else {
+		err = m.Up()
+	}
+	if err != nil && err != gomigrate.ErrNoChange {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+
+	"styx/diff"
+	"styx/schema"
+)
+
+// Reads the renames file. It's optional, so a missing file means no renames
+func loadRenames(path string) (diff.Renames, error) {
+	var renames diff.Renames
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return renames, nil
+	}
+	if err != nil {
+		return renames, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &renames); err != nil {
+		return renames, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for key := range renames.Columns {
+		if !strings.Contains(key, ".") {
+			return renames, fmt.Errorf("invalid column %q in %s, expected table.column", key, path)
+		}
+	}
+	return renames, nil
+}
+
+// Adds the renames styx can guess to the ones listed in the renames file.
+// Guesses are confirmed one by one on the terminal, otherwise they're only
+// logged, since acting on a wrong guess would be just as bad as dropping
+func resolveRenames(current, desired *schema.Schema, renames diff.Renames) (diff.Renames, error) {
+	if renames.Tables == nil {
+		renames.Tables = map[string]string{}
+	}
+	if renames.Columns == nil {
+		renames.Columns = map[string]string{}
+	}
+
+	type guess struct {
+		renames map[string]string
+		from    string
+		to      string
+	}
+	var guesses []guess
+	detected := diff.DetectRenames(current, desired)
+	for _, from := range sortedKeys(detected.Tables) {
+		if _, ok := renames.Tables[from]; !ok {
+			guesses = append(guesses, guess{renames.Tables, from, detected.Tables[from]})
+		}
+	}
+	for _, from := range sortedKeys(detected.Columns) {
+		if _, ok := renames.Columns[from]; !ok {
+			guesses = append(guesses, guess{renames.Columns, from, detected.Columns[from]})
+		}
+	}
+	if len(guesses) == 0 {
+		return renames, nil
+	}
+
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		for _, g := range guesses {
+			log.Warn().Msgf("%s looks like it was renamed to %s, add it to %s if so. Otherwise it's dropped and recreated", g.from, g.to, cfg.Renames)
+		}
+		return renames, nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for _, g := range guesses {
+		fmt.Printf("Was %s renamed to %s? [y/N] ", g.from, g.to)
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return renames, fmt.Errorf("failed to read answer: %w", err)
+		}
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer == "y" || answer == "yes" {
+			g.renames[g.from] = g.to
+		}
+	}
+
+	return renames, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/dialect"
+	"styx/diff"
+	"styx/migrate"
+)
+
+var (
+	reportMigrationsDir string
+	reportSnapshotsDir  string
+	reportFrom          string
+	reportTo            string
+	reportBase          string
+	reportFormat        string
+)
+
+var reportCommand = &cobra.Command{
+	Use:   "report",
+	Short: "Summarize the changes of migrations, e.g. as a pull request comment",
+	Long: `Summarizes the changes the migrations make to the schema: the tables added,
+dropped and altered, which changes can lose data, and on Postgres the table
+locks they take. By default it reports the latest migration. --base reports
+the migrations added since a git ref instead, like a pull request's base
+branch, and --from and --to pick versions. --format markdown writes a summary
+to post as a pull request comment, text the same listing as plan, and json a
+machine-readable report.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "migrations-dir", &reportMigrationsDir, cfg.MigrationsDir)
+		configString(cmd, "snapshots-dir", &reportSnapshotsDir, cfg.Snapshots)
+		if noDocker {
+			cfg.Postgres.Embedded = true
+		}
+		configString(cmd, "pg-image", &pgImage, cfg.Postgres.Image)
+		cfg.Postgres.Image = pgImage
+
+		if err := reportChanges(cmd.Context()); err != nil {
+			log.Error().Err(err).Msgf("Failed to report changes")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func reportChanges(ctx context.Context) error {
+	if !slices.Contains([]string{"markdown", "text", "json"}, reportFormat) {
+		return fmt.Errorf("unknown format %q, expected markdown, text or json", reportFormat)
+	}
+	from, to, err := reportVersions()
+	if err != nil {
+		return err
+	}
+
+	h := &history{migrationsDir: reportMigrationsDir, snapshotsDir: reportSnapshotsDir}
+	defer h.close()
+	fromSchema, err := h.schemaAt(ctx, from)
+	if err != nil {
+		return err
+	}
+	toSchema, err := h.schemaAt(ctx, to)
+	if err != nil {
+		return err
+	}
+	changes := diff.Diff(fromSchema, toSchema, diff.Options{Dialect: dbDialect.SQL})
+
+	switch reportFormat {
+	case "json":
+		return newReport(changes).print()
+	case "text":
+		if len(changes) == 0 {
+			fmt.Printf("The schema is the same at versions %d and %d\n", from, to)
+			return nil
+		}
+		fmt.Printf("From version %d to %d:\n", from, to)
+		printPlan(changes)
+		return nil
+	}
+	fmt.Print(markdownReport(changes, from, to))
+	return nil
+}
+
+// Returns the versions to report the changes between, from the flags
+func reportVersions() (uint64, uint64, error) {
+	files, err := migrate.ReadDir(reportMigrationsDir)
+	if err != nil {
+		return 0, 0, err
+	}
+	var versions []uint64
+	for _, f := range files {
+		if f.Direction == "up" && !slices.Contains(versions, f.Version) {
+			versions = append(versions, f.Version)
+		}
+	}
+	slices.Sort(versions)
+	if len(versions) == 0 {
+		return 0, 0, fmt.Errorf("no migrations found in %s", reportMigrationsDir)
+	}
+
+	to := versions[len(versions)-1]
+	if reportTo != "" {
+		if to, err = strconv.ParseUint(reportTo, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid version %q", reportTo)
+		}
+	}
+
+	// The version before the first one reported, 0 for the empty schema
+	previous := func(first uint64) uint64 {
+		i, _ := slices.BinarySearch(versions, first)
+		if i == 0 {
+			return 0
+		}
+		return versions[i-1]
+	}
+	switch {
+	case reportFrom != "":
+		from, err := strconv.ParseUint(reportFrom, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid version %q", reportFrom)
+		}
+		return from, to, nil
+	case reportBase != "":
+		added, err := addedMigrations(files, reportBase)
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(added) == 0 {
+			return to, to, nil
+		}
+		return previous(slices.Min(added)), to, nil
+	}
+	return previous(to), to, nil
+}
+
+// Returns the versions of the migrations in files that aren't in the git
+// ref base
+func addedMigrations(files []migrate.File, base string) ([]uint64, error) {
+	output, err := exec.Command("git", "ls-tree", "-r", "--name-only", base, "--", reportMigrationsDir).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("failed to list the migrations at %s: %s", base, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to list the migrations at %s: %w", base, err)
+	}
+	existing := map[string]bool{}
+	for _, path := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		existing[filepath.Clean(path)] = true
+	}
+
+	var added []uint64
+	for _, f := range files {
+		if !existing[filepath.Clean(f.Path)] && !slices.Contains(added, f.Version) {
+			added = append(added, f.Version)
+		}
+	}
+	return added, nil
+}
+
+// Renders the changes as markdown for a pull request comment: the tables
+// added and dropped, the changes to the other tables with their severity and
+// lock, and the other objects, followed by the SQL
+func markdownReport(changes []diff.Change, from, to uint64) string {
+	var b strings.Builder
+	b.WriteString("## Schema changes\n\n")
+	span := fmt.Sprintf("from version %d to %d", from, to)
+	if from == 0 {
+		span = fmt.Sprintf("up to version %d", to)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(&b, "The migrations make no changes to the schema %s.\n", span)
+		return b.String()
+	}
+
+	counts := map[diff.Severity]int{}
+	var destructive []string
+	created, dropped := map[string]bool{}, map[string]bool{}
+	var createdTables, droppedTables []string
+	for _, change := range changes {
+		counts[change.Severity()]++
+		if change.Destructive {
+			destructive = append(destructive, change.String())
+		}
+		if change.Kind == diff.KindTable && change.Op == diff.OpCreate {
+			created[change.Name] = true
+			createdTables = append(createdTables, "`"+change.Name+"`")
+		}
+		if change.Kind == diff.KindTable && change.Op == diff.OpDrop {
+			dropped[change.Name] = true
+			droppedTables = append(droppedTables, "`"+change.Name+"`")
+		}
+	}
+	fmt.Fprintf(&b, "The migrations %s make %d change(s): %d safe, %d locking, %d destructive.\n\n",
+		span, len(changes), counts[diff.SeveritySafe], counts[diff.SeverityLocking], counts[diff.SeverityDestructive])
+	if len(destructive) > 0 {
+		fmt.Fprintf(&b, "> [!WARNING]\n> These changes can lose data: %s\n\n", strings.Join(destructive, ", "))
+	}
+	if len(createdTables) > 0 {
+		fmt.Fprintf(&b, "**Tables added:** %s\n\n", strings.Join(createdTables, ", "))
+	}
+	if len(droppedTables) > 0 {
+		fmt.Fprintf(&b, "**Tables dropped:** %s\n\n", strings.Join(droppedTables, ", "))
+	}
+
+	// Changes to the tables added or dropped are part of them
+	showLocks := dbDialect == dialect.Postgres
+	var altered, others []string
+	for _, change := range changes {
+		switch {
+		case change.Kind == diff.KindTable && change.Op != diff.OpAlter:
+		case change.Table != "" && (created[change.Table] || dropped[change.Table]):
+		case change.Table != "":
+			severity := string(change.Severity())
+			if change.Destructive {
+				severity = "**" + severity + "**"
+			}
+			row := fmt.Sprintf("| `%s` | %s | %s |", change.Table, change, severity)
+			if showLocks {
+				lock := ""
+				if l := change.Lock(); l != diff.LockNone {
+					lock = fmt.Sprintf("%s, blocks %s", l, l.Blocks())
+				}
+				row += " " + lock + " |"
+			}
+			altered = append(altered, row)
+		default:
+			others = append(others, fmt.Sprintf("- %s (%s)", change, change.Severity()))
+		}
+	}
+	if len(altered) > 0 {
+		b.WriteString("### Tables altered\n\n| Table | Change | Severity |")
+		if showLocks {
+			b.WriteString(" Lock |\n| --- | --- | --- | --- |\n")
+		} else {
+			b.WriteString("\n| --- | --- | --- |\n")
+		}
+		b.WriteString(strings.Join(altered, "\n") + "\n\n")
+	}
+	if len(others) > 0 {
+		b.WriteString("### Other changes\n\n" + strings.Join(others, "\n") + "\n\n")
+	}
+
+	b.WriteString("<details>\n<summary>SQL</summary>\n\n```sql\n")
+	for i, change := range changes {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(change.SQL + "\n")
+	}
+	b.WriteString("```\n\n</details>\n")
+	return b.String()
+}
+
+func init() {
+	reportCommand.Flags().StringVarP(&reportMigrationsDir, "migrations-dir", "m", "migrations", "Directory containing the migrations")
+	reportCommand.Flags().StringVar(&reportSnapshotsDir, "snapshots-dir", ".styx/snapshots", "Directory generate saves the schema at each version to")
+	reportCommand.Flags().StringVar(&reportFrom, "from", "", "Version to report the changes from, 0 for the empty schema (default the one before the reported migrations)")
+	reportCommand.Flags().StringVar(&reportTo, "to", "", "Version to report the changes up to (default the latest)")
+	reportCommand.Flags().StringVar(&reportBase, "base", "", "Git ref to report the migrations added since, e.g. origin/main")
+	reportCommand.Flags().StringVar(&reportFormat, "format", "markdown", "Format of the report: markdown, text or json")
+	reportCommand.Flags().BoolVar(&noDocker, "no-docker", false, "Replay migrations in an embedded Postgres instead of a Docker container")
+	reportCommand.Flags().StringVar(&pgImage, "pg-image", "", "Docker image of the scratch Postgres, e.g. postgres:17 or postgis/postgis:16-3.4")
+	reportCommand.MarkFlagsMutuallyExclusive("from", "base")
+
+	rootCmd.AddCommand(reportCommand)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/mattn/go-isatty"
+
+	"styx/diff"
+	"styx/internal/review"
+	"styx/migrate"
+	"styx/schema"
+)
+
+var errAllSkipped = errors.New("every change was skipped")
+
+// Builds the migration from the changes the user accepts in the review UI.
+// Marking a drop as a rename changes the whole changeset, so it's computed
+// again and reviewed anew
+func reviewMigration(dir string, current, desired *schema.Schema, opts diff.Options) (*migrate.Migration, error) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return nil, errors.New("--review needs a terminal")
+	}
+
+	for {
+		up, down := migrate.Changes(current, desired, opts)
+		if len(up) == 0 {
+			return nil, nil
+		}
+
+		result, err := review.Run(up)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Renames.Tables) > 0 || len(result.Renames.Columns) > 0 {
+			opts.Renames = mergeRenames(opts.Renames, result.Renames)
+			continue
+		}
+
+		up, down = migrate.Without(up, down, result.Skipped)
+		if len(up) == 0 {
+			return nil, errAllSkipped
+		}
+		return migrate.New(dir, up, down, migrate.Versioning(cfg.Versioning))
+	}
+}
+
+func mergeRenames(renames, more diff.Renames) diff.Renames {
+	merged := diff.Renames{Tables: map[string]string{}, Columns: map[string]string{}}
+	for _, r := range []diff.Renames{renames, more} {
+		for from, to := range r.Tables {
+			merged.Tables[from] = to
+		}
+		for from, to := range r.Columns {
+			merged.Columns[from] = to
+		}
+	}
+	return merged
+}
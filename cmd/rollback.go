@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	gomigrate "github.com/golang-migrate/migrate"
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/dialect"
+	"styx/migrate"
+	"styx/schema"
+)
+
+var (
+	rollbackDsn             string
+	rollbackMigrationsDir   string
+	rollbackMigrationsTable string
+	rollbackTo              uint64
+	rollbackSteps           int
+	rollbackSnapshotDir     string
+	rollbackYes             bool
+)
+
+var rollbackCommand = &cobra.Command{
+	Use:   "rollback",
+	Short: "Apply down migrations to a database, back to a version or by a number of steps",
+	Long: `Rolls a database back by running down migrations, either down to the version
+given with --to (0 undoes every migration) or by --steps migrations, one by
+default. The schema is saved to the snapshot directory first, so it can be
+recovered if the rollback loses more than intended.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "migrations-dir", &rollbackMigrationsDir, cfg.MigrationsDir)
+		configString(cmd, "migrations-table", &rollbackMigrationsTable, cfg.MigrationsTable)
+
+		if err := rollback(cmd.Context(), rollbackDsn, rollbackMigrationsDir, rollbackMigrationsTable, cmd.Flags().Changed("to")); err != nil {
+			log.Error().Err(err).Msgf("Failed to roll back migrations")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func rollback(ctx context.Context, dsn, migrationsDir, table string, toVersion bool) error {
+	dsn, err := resolveDSN(dsn)
+	if err != nil {
+		return err
+	}
+
+	if dbDialect == dialect.Postgres {
+		release, err := acquireApplyLock(ctx, dsn, table, cfg.Apply.LockWait)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	m, err := dbDialect.Migrate(migrate.SourceURL(migrationsDir), dsn, table)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	current, dirty, err := m.Version()
+	if errors.Is(err, gomigrate.ErrNilVersion) {
+		log.Info().Msg("Database has no migrations applied, nothing to roll back")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read database version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("database is dirty at version %d, a migration failed halfway: fix the schema by hand, then force the version", current)
+	}
+
+	files, err := migrate.ReadDir(migrationsDir)
+	if err != nil {
+		return err
+	}
+	// Versions applied up to the current one, latest first
+	var applied []uint64
+	downs := map[uint64]migrate.File{}
+	for _, f := range files {
+		if f.Direction == "down" {
+			downs[f.Version] = f
+		} else if uint64(current) >= f.Version {
+			applied = append(applied, f.Version)
+		}
+	}
+	slices.Sort(applied)
+	slices.Reverse(applied)
+
+	var target uint64
+	switch {
+	case toVersion:
+		target = rollbackTo
+		if target >= uint64(current) {
+			return fmt.Errorf("can't roll back to version %d, the database is at version %d", target, current)
+		}
+		if target != 0 && !slices.Contains(applied, target) {
+			return fmt.Errorf("there's no migration with version %d in %s", target, migrationsDir)
+		}
+	case rollbackSteps < 1:
+		return fmt.Errorf("--steps must be at least 1")
+	case rollbackSteps < len(applied):
+		target = applied[rollbackSteps]
+	}
+
+	var undone []migrate.File
+	for _, version := range applied {
+		if version <= target {
+			break
+		}
+		down, ok := downs[version]
+		if !ok {
+			return fmt.Errorf("migration %d has no down migration", version)
+		}
+		undone = append(undone, down)
+	}
+	if len(undone) == 0 {
+		return fmt.Errorf("no migrations found in %s", migrationsDir)
+	}
+
+	if err := confirmRollback(undone, uint64(current), target); err != nil {
+		return err
+	}
+
+	snapshot, err := snapshotSchema(ctx, dsn, rollbackSnapshotDir, table, uint64(current))
+	if err != nil {
+		return fmt.Errorf("failed to save the schema before rolling back: %w", err)
+	}
+	log.Info().Msgf("Saved the schema at version %d to %s", current, snapshot)
+
+	if target == 0 {
+		err = m.Down()
+	} else {
+		err = m.Migrate(uint(target))
+	}
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("Database rolled back to version %d", target)
+	return nil
+}
+
+// Lists the down migrations about to run and asks whether to go ahead, unless
+// --yes was passed
+func confirmRollback(undone []migrate.File, current, target uint64) error {
+	database := "the database"
+	if environment != "" {
+		database = environment
+	}
+	fmt.Printf("Rolling back %s from version %d to %d, running:\n", database, current, target)
+	for _, f := range undone {
+		fmt.Printf("  %s\n", filepath.Base(f.Path))
+	}
+	if rollbackYes {
+		return nil
+	}
+
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return errors.New("the rollback can only be confirmed in a terminal, pass --yes to run it anyway")
+	}
+	fmt.Print("\nRoll back? [y/N] ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read answer: %w", err)
+	}
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return errors.New("aborted, the rollback was not confirmed")
+	}
+	return nil
+}
+
+// Writes the schema of the database to dir as a schema.sql file, named after
+// the time and the version it's at, and returns its path. The migrations
+// table is left out
+func snapshotSchema(ctx context.Context, dsn, dir, table string, version uint64) (string, error) {
+	s, err := dumpDatabaseSchema(ctx, dsn)
+	if err != nil {
+		return "", err
+	}
+	s.Tables = slices.DeleteFunc(s.Tables, func(t *schema.Table) bool {
+		return t.Name == table
+	})
+	filterObjects(s, s)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s_%d.sql", time.Now().UTC().Format("20060102150405"), version))
+	if err := os.WriteFile(path, []byte(schemaSQL(s)), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func init() {
+	rollbackCommand.Flags().StringVar(&rollbackDsn, "dsn", "", "Connection string of the database to roll back, instead of --env")
+	rollbackCommand.Flags().StringVarP(&rollbackMigrationsDir, "migrations-dir", "m", "migrations", "Directory containing the migrations")
+	rollbackCommand.Flags().StringVar(&rollbackMigrationsTable, "migrations-table", migrate.DefaultTable, "Table golang-migrate records the applied version in")
+	rollbackCommand.Flags().Uint64Var(&rollbackTo, "to", 0, "Version to roll back to, 0 for none")
+	rollbackCommand.Flags().IntVar(&rollbackSteps, "steps", 1, "Number of migrations to roll back")
+	rollbackCommand.Flags().StringVar(&rollbackSnapshotDir, "snapshot-dir", filepath.Join(".styx", "rollbacks"), "Directory the schema is saved to before rolling back")
+	rollbackCommand.Flags().BoolVarP(&rollbackYes, "yes", "y", false, "Roll back without asking for confirmation")
+	rollbackCommand.MarkFlagsMutuallyExclusive("to", "steps")
+
+	rootCmd.AddCommand(rollbackCommand)
+}
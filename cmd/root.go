@@ -1,23 +1,202 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+
+	"styx/dialect"
+	"styx/internal/config"
+	"styx/schema"
+)
+
+// version is set at build time with -ldflags "-X styx/cmd.version=v1.2.3"
+var version = "dev"
+
+var (
+	configFile  string
+	environment string
+	service     string
+	dialectName string
+	logLevel    string
+	quiet       bool
+	jsonLogs    bool
+	traceSQL    bool
+	pullPolicy  string
+	reuse       bool
+	scratchDSN  string
+
+	// cfg and dbDialect are loaded before any command runs
+	cfg       *config.Config
+	dbDialect *dialect.Dialect
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "styx",
-	Short: "Styx generates migrations from a schema.sql file",
+	Use:     "styx",
+	Short:   "Styx generates migrations from a schema.sql file",
+	Version: version,
 	CompletionOptions: cobra.CompletionOptions{
 		DisableDefaultCmd: true,
 	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := setupLogging(); err != nil {
+			return err
+		}
+
+		var err error
+		cfg, err = config.Load(configFile)
+		if err != nil {
+			return err
+		}
+		if service != "" {
+			if cfg, err = cfg.UseService(service); err != nil {
+				return err
+			}
+		}
+		if environment != "" {
+			if cfg, err = cfg.Use(environment); err != nil {
+				return err
+			}
+		}
+
+		if cmd.Flags().Lookup("output") != nil {
+			if err := validateOutputFormat(); err != nil {
+				return err
+			}
+		}
+		if cmd.Flags().Lookup("include") != nil {
+			if err := resolveFilterFlags(cmd); err != nil {
+				return err
+			}
+		}
+
+		configString(cmd, "pull", &pullPolicy, cfg.Pull)
+		if err := config.ValidatePull(pullPolicy); err != nil {
+			return err
+		}
+		cfg.Pull = pullPolicy
+		if cmd.Flags().Changed("reuse-container") {
+			cfg.Postgres.Reuse = reuse
+		}
+		configString(cmd, "scratch-dsn", &scratchDSN, cfg.Postgres.ScratchDSN)
+		cfg.Postgres.ScratchDSN = scratchDSN
+
+		if !cmd.Flags().Changed("dialect") {
+			dialectName = cfg.Dialect
+		}
+		dbDialect, err = dialect.Lookup(dialectName)
+		return err
+	},
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first interrupt cancels the context, so the command stops and tears
+	// down the scratch database. A second one quits right away
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupts
+		signal.Stop(interrupts)
+		log.Warn().Msg("Interrupted, cleaning up. Interrupt again to quit right away")
+		cancel()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+}
+
+// Sets the log level and format from the flags. Logs are human-readable
+// unless --json-logs is passed, and trace level logs every SQL statement
+func setupLogging() error {
+	level := zerolog.InfoLevel
+	switch {
+	case traceSQL:
+		level = zerolog.TraceLevel
+	case quiet:
+		level = zerolog.ErrorLevel
+	default:
+		var err error
+		if level, err = zerolog.ParseLevel(logLevel); err != nil || logLevel == "" {
+			return fmt.Errorf("unknown log level %q, expected trace, debug, info, warn or error", logLevel)
+		}
+	}
+	zerolog.SetGlobalLevel(level)
+
+	if !jsonLogs {
+		log.Logger = log.Output(zerolog.ConsoleWriter{
+			Out:        os.Stderr,
+			TimeFormat: time.TimeOnly,
+			NoColor:    !isatty.IsTerminal(os.Stderr.Fd()),
+		})
+	}
+	return nil
+}
+
+// Logs that the command failed with err. Errors located in the schema are
+// printed as they are, since their source line and caret don't survive
+// being a log field
+func logFailure(err error, msg string) {
+	var sourceErr *schema.SourceError
+	if errors.As(err, &sourceErr) && !jsonLogs {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return
+	}
+	log.Error().Err(err).Msg(msg)
+}
+
+// Fills in a flag's variable from the config, unless the flag was passed
+// explicitly
+func configString(cmd *cobra.Command, name string, target *string, value string) {
+	if !cmd.Flags().Changed(name) && value != "" {
+		*target = value
+	}
+}
+
+// Returns the DSN passed with --dsn, or the one from the config
+func resolveDSN(dsn string) (string, error) {
+	if dsn != "" {
+		return dsn, nil
+	}
+	if cfg.DSN != "" {
+		return cfg.DSN, nil
+	}
+	return "", fmt.Errorf("either --dsn or --env is required")
+}
+
+// Treats the value as the name of an environment if the config defines one,
+// otherwise as a DSN
+func environmentOrDSN(value string) (string, error) {
+	if _, ok := cfg.Environments[value]; ok {
+		return cfg.EnvironmentDSN(value)
 	}
+	return value, nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "Path to the config file (default styx.yaml)")
+	rootCmd.PersistentFlags().StringVarP(&environment, "env", "e", "", "Name of the environment from the config file to connect to")
+	rootCmd.PersistentFlags().StringVarP(&service, "service", "s", "", "Name of the service from the config file to work on, in a monorepo")
+	rootCmd.PersistentFlags().StringVar(&dialectName, "dialect", "postgres", "Database engine: postgres, cockroach, mysql or sqlite")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Level of the logs to print: trace, debug, info, warn or error")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only log errors")
+	rootCmd.PersistentFlags().BoolVar(&jsonLogs, "json-logs", false, "Log as JSON lines instead of text")
+	rootCmd.PersistentFlags().BoolVar(&traceSQL, "trace", false, "Log every SQL statement run, e.g. against the scratch database, same as --log-level trace")
+	rootCmd.PersistentFlags().StringVar(&pullPolicy, "pull", config.PullMissing, "When to pull the image of the scratch database: always, missing or never")
+	rootCmd.PersistentFlags().BoolVar(&reuse, "reuse-container", false, "Keep the scratch Postgres container running between runs and reset it instead of starting a new one")
+	rootCmd.PersistentFlags().StringVar(&scratchDSN, "scratch-dsn", "", "Existing Postgres database to use as the scratch database instead of starting one. It must be empty the first time, and is emptied on every run")
+	rootCmd.MarkFlagsMutuallyExclusive("log-level", "quiet", "trace")
 }
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/scaffold"
+	"styx/schema"
+)
+
+var (
+	scaffoldOutputFile string
+	scaffoldMessages   []string
+)
+
+var scaffoldCommand = &cobra.Command{
+	Use:   "scaffold FILE",
+	Short: "Draft tables from a .proto file or JSON Schema into schema.sql",
+	Long: `Drafts CREATE TABLE statements from the messages of a .proto file or the
+objects of a JSON Schema, and appends them to schema.sql as a starting point.
+Timestamps, dates and enums get their own types, repeated values arrays on
+Postgres, and nested messages, maps and objects JSON. Top-level messages are
+scaffolded besides requests and responses, pick others with --message. Tables
+already in the schema aren't overwritten, scaffolding fails instead.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "output-file", &scaffoldOutputFile, cfg.Schema)
+
+		if err := scaffoldTables(args[0], scaffoldOutputFile); err != nil {
+			log.Error().Err(err).Msgf("Failed to scaffold tables")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func scaffoldTables(inputFile, outputFile string) error {
+	var s *schema.Schema
+	var err error
+	switch filepath.Ext(inputFile) {
+	case ".proto":
+		s, err = scaffold.Proto(inputFile, dbDialect.Name, scaffoldMessages)
+	case ".json":
+		if len(scaffoldMessages) > 0 {
+			return fmt.Errorf("--message only applies to .proto files")
+		}
+		s, err = scaffold.JSONSchema(inputFile, dbDialect.Name)
+	default:
+		return fmt.Errorf("%s is neither a .proto file nor a .json JSON Schema", inputFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ormModels(outputFile); ok || isHCL(outputFile) {
+		return fmt.Errorf("tables can only be scaffolded into SQL, %s isn't a .sql file or directory", outputFile)
+	}
+	// A directory of SQL files gets a file named after the input
+	existing := ""
+	if info, err := os.Stat(outputFile); err == nil && info.IsDir() {
+		if sql, err := schema.ReadDir(outputFile); err == nil {
+			existing = sql
+		}
+		base := filepath.Base(inputFile)
+		outputFile = filepath.Join(outputFile, strings.TrimSuffix(base, filepath.Ext(base))+".sql")
+	}
+	data, err := os.ReadFile(outputFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", outputFile, err)
+	}
+	if existing == "" {
+		existing = string(data)
+	}
+
+	var names []string
+	for _, enum := range s.Enums {
+		if isCreated(existing, "type", enum.Name) {
+			return fmt.Errorf("type %s already exists in the schema", enum.Name)
+		}
+	}
+	for _, table := range s.Tables {
+		if isCreated(existing, "table", table.Name) {
+			return fmt.Errorf("table %s already exists in the schema", table.Name)
+		}
+		names = append(names, table.Name)
+	}
+
+	content := string(data)
+	if content = strings.TrimRight(content, "\n"); content != "" {
+		content += "\n\n"
+	}
+	content += schemaSQL(s)
+	if err := os.WriteFile(outputFile, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputFile, err)
+	}
+	fmt.Printf("Added %s to %s\n", strings.Join(names, ", "), outputFile)
+	return nil
+}
+
+// Reports whether sql creates the table or type named name
+func isCreated(sql, kind, name string) bool {
+	pattern := fmt.Sprintf("(?i)\\bcreate\\s+%s\\s+(?:if\\s+not\\s+exists\\s+)?[\"`]?%s[\"`]?[\\s(]", kind, regexp.QuoteMeta(name))
+	return regexp.MustCompile(pattern).MatchString(sql)
+}
+
+func init() {
+	scaffoldCommand.Flags().StringVarP(&scaffoldOutputFile, "output-file", "o", "schema.sql", "Path of the schema.sql file, or directory of .sql files, to add the tables to")
+	scaffoldCommand.Flags().StringSliceVar(&scaffoldMessages, "message", nil, "Messages of the .proto file to scaffold tables from, e.g. User,Order (default the top-level ones)")
+
+	rootCmd.AddCommand(scaffoldCommand)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/seed"
+)
+
+var (
+	seedDsn  string
+	seedsDir string
+)
+
+var seedCommand = &cobra.Command{
+	Use:   "seed",
+	Short: "Load the reference data of the seeds directory into a database",
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "seeds-dir", &seedsDir, cfg.Seeds)
+
+		dsn, err := resolveDSN(seedDsn)
+		if err == nil {
+			err = loadSeeds(cmd.Context(), dsn, seedsDir)
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to load seeds")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func loadSeeds(ctx context.Context, dsn, dir string) error {
+	db, err := dbDialect.Open(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	files, err := seed.Load(ctx, db, dbDialect, dir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		log.Info().Msgf("No seeds found in %s", dir)
+	}
+	for _, path := range files {
+		log.Info().Msgf("Loaded %s", path)
+	}
+	return nil
+}
+
+func init() {
+	seedCommand.Flags().StringVar(&seedDsn, "dsn", "", "Connection string of the database to seed, instead of --env")
+	seedCommand.Flags().StringVar(&seedsDir, "seeds-dir", "seeds", "Directory containing the seed SQL and CSV files")
+
+	rootCmd.AddCommand(seedCommand)
+}
@@ -0,0 +1,371 @@
+package cmd
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/dialect"
+	"styx/diff"
+	"styx/migrate"
+	"styx/schema"
+)
+
+//go:embed serve.html
+var serveTemplates string
+
+var (
+	serveAddr          string
+	serveInputFile     string
+	serveMigrationsDir string
+	serveSnapshotsDir  string
+)
+
+var serveCommand = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a local web dashboard of the schema, migrations and drift",
+	Long: `Serves a web dashboard showing the desired schema, the migration history
+with the changes of each migration, the drift of every environment in the
+config, and the changes the next generate would make. Pages are computed when
+they're loaded, so they're always current. The history and next migration
+pages replay migrations in a scratch database, like generate.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "input", &serveInputFile, cfg.Schema)
+		configString(cmd, "migrations-dir", &serveMigrationsDir, cfg.MigrationsDir)
+		configString(cmd, "snapshots-dir", &serveSnapshotsDir, cfg.Snapshots)
+		if noDocker {
+			cfg.Postgres.Embedded = true
+		}
+		configString(cmd, "pg-image", &pgImage, cfg.Postgres.Image)
+		cfg.Postgres.Image = pgImage
+
+		if err := serveDashboard(cmd.Context(), serveAddr); err != nil {
+			log.Error().Err(err).Msgf("Failed to serve dashboard")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+type dashboard struct {
+	pages map[string]*template.Template
+	// scratch makes the pages replaying migrations take turns, rather than
+	// each starting a scratch database at once
+	scratch sync.Mutex
+}
+
+type dashboardPage struct {
+	Title   string
+	Dialect string
+	Nav     []dashboardLink
+	Error   string
+	Data    any
+}
+
+type dashboardLink struct {
+	Name    string
+	Path    string
+	Current bool
+}
+
+var dashboardNav = []dashboardLink{
+	{Name: "Schema", Path: "/"},
+	{Name: "History", Path: "/history"},
+	{Name: "Drift", Path: "/drift"},
+	{Name: "Next migration", Path: "/next"},
+}
+
+// dashboardChange is a change as listed on the pages, with its lock on
+// Postgres
+type dashboardChange struct {
+	Symbol   string
+	Change   string
+	Severity diff.Severity
+	Lock     diff.Lock
+	Blocks   string
+	SQL      string
+}
+
+type dashboardMigration struct {
+	Version     uint64
+	Description string
+	Paths       []string
+}
+
+type dashboardEnvironment struct {
+	Name string
+	// HasState is set when the applied version could be read, which relies
+	// on Postgres' catalogs
+	HasState bool
+	Version  uint64
+	Dirty    bool
+	Changes  []dashboardChange
+	Error    string
+}
+
+func serveDashboard(ctx context.Context, addr string) error {
+	base, err := template.New("dashboard").Parse(serveTemplates)
+	if err != nil {
+		return err
+	}
+	// Each page fills in the layout's content with its own template
+	d := &dashboard{pages: map[string]*template.Template{}}
+	for _, name := range []string{"schema", "history", "migration", "drift", "next"} {
+		page, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if _, err := page.New("content").Parse(`{{template "` + name + `" .}}`); err != nil {
+			return err
+		}
+		d.pages[name] = page
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", d.schema)
+	mux.HandleFunc("GET /history", d.history)
+	mux.HandleFunc("GET /history/{version}", d.migration)
+	mux.HandleFunc("GET /drift", d.drift)
+	mux.HandleFunc("GET /next", d.next)
+
+	// Requests are cancelled along with ctx, so pages replaying migrations
+	// tear their scratch database down before the server stops
+	server := &http.Server{
+		Addr:        addr,
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	stopped := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.WithoutCancel(ctx))
+		close(stopped)
+	}()
+
+	log.Info().Msgf("Serving the dashboard on http://%s", addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	<-stopped
+	return nil
+}
+
+// Renders a page, or the error that kept its data from being loaded
+func (d *dashboard) render(w http.ResponseWriter, page, title, path string, data any, err error) {
+	p := dashboardPage{Title: title, Dialect: dbDialect.Name, Data: data}
+	for _, link := range dashboardNav {
+		link.Current = link.Path == path
+		p.Nav = append(p.Nav, link)
+	}
+	status := http.StatusOK
+	if err != nil {
+		p.Error = err.Error()
+		status = http.StatusInternalServerError
+	}
+
+	var b bytes.Buffer
+	if err := d.pages[page].ExecuteTemplate(&b, "layout", p); err != nil {
+		log.Error().Err(err).Msgf("Failed to render %s", path)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(b.Bytes())
+}
+
+func (d *dashboard) schema(w http.ResponseWriter, r *http.Request) {
+	s, err := d.desiredSchema(r.Context())
+	d.render(w, "schema", "Schema", "/", struct {
+		Source string
+		Schema *schema.Schema
+	}{serveInputFile, s}, err)
+}
+
+// Reads the desired schema, taking a turn with the scratch database for
+// dialects without a parser
+func (d *dashboard) desiredSchema(ctx context.Context) (*schema.Schema, error) {
+	if dbDialect.Parse == nil {
+		d.scratch.Lock()
+		defer d.scratch.Unlock()
+	}
+	s, err := readDesiredSchema(ctx, serveInputFile)
+	if err != nil {
+		return nil, err
+	}
+	filterObjects(s, s)
+	return s, nil
+}
+
+func (d *dashboard) history(w http.ResponseWriter, r *http.Request) {
+	migrations, err := dashboardMigrations()
+	d.render(w, "history", "History", "/history", struct {
+		Dir        string
+		Migrations []dashboardMigration
+	}{serveMigrationsDir, migrations}, err)
+}
+
+// Returns the migrations in version order, with the files of each
+func dashboardMigrations() ([]dashboardMigration, error) {
+	files, err := migrate.ReadDir(serveMigrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	var migrations []dashboardMigration
+	for _, f := range files {
+		i := slices.IndexFunc(migrations, func(m dashboardMigration) bool { return m.Version == f.Version })
+		if i < 0 {
+			migrations = append(migrations, dashboardMigration{Version: f.Version, Description: f.Description})
+			i = len(migrations) - 1
+		}
+		migrations[i].Paths = append(migrations[i].Paths, f.Path)
+	}
+	slices.SortFunc(migrations, func(a, b dashboardMigration) int { return cmp.Compare(a.Version, b.Version) })
+	return migrations, nil
+}
+
+// Shows the changes of a migration, from the schema at the version before it
+func (d *dashboard) migration(w http.ResponseWriter, r *http.Request) {
+	title := "Migration " + r.PathValue("version")
+	data := struct {
+		From    uint64
+		To      uint64
+		Changes []dashboardChange
+	}{}
+	err := func() error {
+		version, err := strconv.ParseUint(r.PathValue("version"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", r.PathValue("version"))
+		}
+		migrations, err := dashboardMigrations()
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(migrations, func(m dashboardMigration) bool { return m.Version == version })
+		if i < 0 {
+			return fmt.Errorf("there's no migration with version %d in %s", version, serveMigrationsDir)
+		}
+		title += ": " + migrations[i].Description
+		data.To = version
+		if i > 0 {
+			data.From = migrations[i-1].Version
+		}
+
+		d.scratch.Lock()
+		defer d.scratch.Unlock()
+		h := &history{migrationsDir: serveMigrationsDir, snapshotsDir: serveSnapshotsDir}
+		defer h.close()
+		from, err := h.schemaAt(r.Context(), data.From)
+		if err != nil {
+			return err
+		}
+		to, err := h.schemaAt(r.Context(), data.To)
+		if err != nil {
+			return err
+		}
+		data.Changes = dashboardChanges(diff.Diff(from, to, diff.Options{Dialect: dbDialect.SQL}))
+		return nil
+	}()
+	d.render(w, "migration", title, "/history", data, err)
+}
+
+// Checks every environment of the config for drift
+func (d *dashboard) drift(w http.ResponseWriter, r *http.Request) {
+	var names []string
+	for name := range cfg.Environments {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var environments []dashboardEnvironment
+	for _, name := range names {
+		env := dashboardEnvironment{Name: name}
+		if err := environmentDrift(r.Context(), &env); err != nil {
+			env.Error = err.Error()
+		}
+		environments = append(environments, env)
+	}
+	d.render(w, "drift", "Drift", "/drift", environments, nil)
+}
+
+func environmentDrift(ctx context.Context, env *dashboardEnvironment) error {
+	envCfg, err := cfg.Use(env.Name)
+	if err != nil {
+		return err
+	}
+	if envCfg.DSN == "" {
+		return fmt.Errorf("environment %s has no dsn", env.Name)
+	}
+	schemaFile := serveInputFile
+	if cfg.Environments[env.Name].Schema != "" {
+		schemaFile = envCfg.Schema
+	}
+
+	// The migrations table is looked up in Postgres' catalogs, which
+	// CockroachDB emulates
+	if dbDialect == dialect.Postgres || dbDialect == dialect.Cockroach {
+		db, err := dbDialect.Open(envCfg.DSN)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		state, err := migrate.ReadState(ctx, db, envCfg.MigrationsTable)
+		if err != nil {
+			return err
+		}
+		env.HasState, env.Version, env.Dirty = true, state.Version, state.Dirty
+	}
+
+	changes, err := driftChanges(ctx, schemaFile, envCfg.DSN)
+	if err != nil {
+		return err
+	}
+	env.Changes = dashboardChanges(changes)
+	return nil
+}
+
+func (d *dashboard) next(w http.ResponseWriter, r *http.Request) {
+	d.scratch.Lock()
+	defer d.scratch.Unlock()
+	changes, err := replayChanges(r.Context(), serveInputFile, serveMigrationsDir)
+	d.render(w, "next", "Next migration", "/next", dashboardChanges(changes), err)
+}
+
+func dashboardChanges(changes []diff.Change) []dashboardChange {
+	var listed []dashboardChange
+	for _, change := range changes {
+		lock := reportLock(change)
+		listed = append(listed, dashboardChange{
+			Symbol:   planSymbols[change.Op],
+			Change:   change.String(),
+			Severity: change.Severity(),
+			Lock:     lock,
+			Blocks:   lock.Blocks(),
+			SQL:      change.SQL,
+		})
+	}
+	return listed
+}
+
+func init() {
+	serveCommand.Flags().StringVar(&serveAddr, "addr", "localhost:8080", "Address to serve the dashboard on")
+	serveCommand.Flags().StringVarP(&serveInputFile, "input", "i", "schema.sql", "Path to the input schema.sql file, HCL schema, directory of .sql files, or models like gorm:./models")
+	serveCommand.Flags().StringVarP(&serveMigrationsDir, "migrations-dir", "m", "migrations", "Directory containing the migrations")
+	serveCommand.Flags().StringVar(&serveSnapshotsDir, "snapshots-dir", ".styx/snapshots", "Directory generate saves the schema at each version to")
+	serveCommand.Flags().BoolVar(&noDocker, "no-docker", false, "Replay migrations in an embedded Postgres instead of a Docker container")
+	serveCommand.Flags().StringVar(&pgImage, "pg-image", "", "Docker image of the scratch Postgres, e.g. postgres:17 or postgis/postgis:16-3.4")
+	filterFlags(serveCommand)
+
+	rootCmd.AddCommand(serveCommand)
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"styx/schema"
+)
+
+// Writes the schema for sqlc to read and runs the configured sqlc command, so
+// the queries are checked, and their code regenerated, against every new
+// migration
+func updateSqlc(ctx context.Context, s *schema.Schema) error {
+	if cfg.Sqlc.Schema == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Sqlc.Schema), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", cfg.Sqlc.Schema, err)
+	}
+	if err := os.WriteFile(cfg.Sqlc.Schema, []byte(schemaSQL(s)), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", cfg.Sqlc.Schema, err)
+	}
+	if cfg.Sqlc.Command == "" {
+		return nil
+	}
+
+	if _, err := exec.LookPath("sqlc"); err != nil {
+		return fmt.Errorf("running sqlc %s needs sqlc on the PATH: %w", cfg.Sqlc.Command, err)
+	}
+	args := []string{cfg.Sqlc.Command}
+	if cfg.Sqlc.Config != "" {
+		args = append(args, "--file", cfg.Sqlc.Config)
+	}
+	if out, err := exec.CommandContext(ctx, "sqlc", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("sqlc %s failed against the new schema: %w\n%s", cfg.Sqlc.Command, err, out)
+	}
+	return nil
+}
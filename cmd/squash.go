@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/diff"
+	"styx/migrate"
+	"styx/schema"
+)
+
+var (
+	squashMigrationsDir string
+	squashKeep          int
+)
+
+var squashCommand = &cobra.Command{
+	Use:   "squash",
+	Short: "Replace the existing migrations with a single baseline migration",
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "migrations-dir", &squashMigrationsDir, cfg.MigrationsDir)
+		if noDocker {
+			cfg.Postgres.Embedded = true
+		}
+		configString(cmd, "pg-image", &pgImage, cfg.Postgres.Image)
+		cfg.Postgres.Image = pgImage
+
+		if err := squashMigrations(cmd.Context(), squashMigrationsDir, squashKeep); err != nil {
+			log.Error().Err(err).Msgf("Failed to squash migrations")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+// Replays the migrations up to the last one squashed, dumps the schema they
+// add up to, and writes it as a baseline migration in their place. The keep
+// most recent migrations are left alone
+func squashMigrations(ctx context.Context, migrationsDir string, keep int) error {
+	if keep < 0 {
+		return fmt.Errorf("--keep must not be negative")
+	}
+
+	files, err := migrate.ReadDir(migrationsDir)
+	if err != nil {
+		return err
+	}
+	var versions []uint64
+	for _, f := range files {
+		if !slices.Contains(versions, f.Version) {
+			versions = append(versions, f.Version)
+		}
+	}
+	slices.Sort(versions)
+	if len(versions)-keep < 2 {
+		log.Info().Msg("Fewer than two migrations to squash. Nothing to do")
+		return nil
+	}
+	baseline := versions[len(versions)-keep-1]
+
+	scratchDsn, cleanup, err := startScratchDatabase(ctx, cfg.Postgres)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := createRoles(ctx, scratchDsn, &schema.Schema{}); err != nil {
+		return err
+	}
+	if err := replayMigrations(ctx, migrationsDir, scratchDsn, baseline); err != nil {
+		return err
+	}
+
+	current, err := dumpDatabaseSchema(ctx, scratchDsn)
+	if err != nil {
+		return fmt.Errorf("failed to dump database schema: %w", err)
+	}
+	// Extensions installed outside of migrations are installed again the
+	// same way
+	current.Extensions = slices.DeleteFunc(current.Extensions, func(e *schema.Extension) bool {
+		return slices.Contains(cfg.Postgres.Extensions, e.Name)
+	})
+
+	opts := diff.Options{Dialect: dbDialect.SQL}
+	up, down := migrate.Changes(&schema.Schema{}, current, opts)
+	migration, err := migrate.Baseline(migrationsDir, baseline, up, down)
+	if err != nil {
+		return fmt.Errorf("failed to build baseline migration: %w", err)
+	}
+	migration.Template, err = migrate.ParseTemplate(cfg.Templates.Filename, cfg.Templates.Header)
+	if err != nil {
+		return err
+	}
+	migration.Template.StyxVersion = version
+	migration.Format = migrate.Format(cfg.Format)
+
+	var removed []migrate.File
+	squashed := 0
+	for _, f := range files {
+		if f.Version > baseline {
+			continue
+		}
+		// Files holding both directions are listed once per direction
+		if !slices.ContainsFunc(removed, func(r migrate.File) bool { return r.Path == f.Path }) {
+			if err := os.Remove(f.Path); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", f.Path, err)
+			}
+		}
+		removed = append(removed, f)
+		if f.Direction == "up" {
+			squashed++
+		}
+	}
+
+	paths, err := migration.Write(migrationsDir)
+	if err != nil {
+		return fmt.Errorf("failed to write baseline migration: %w", err)
+	}
+	// The schema the migrations add up to doesn't change
+	if err := updateLock(migrationsDir, removed, ""); err != nil {
+		return err
+	}
+	fmt.Printf("Squashed %d migrations into:\n", squashed)
+	for _, path := range paths {
+		fmt.Printf("  %s\n", path)
+	}
+	return nil
+}
+
+// Applies the migrations in migrationsDir up to and including version
+func replayMigrations(ctx context.Context, migrationsDir, dsn string, version uint64) error {
+	log.Info().Msgf("Applying migrations up to version %d...", version)
+
+	return replayWithTemplate(ctx, dsn, migrationsDir, version, func() error {
+		m, err := dbDialect.Migrate(migrate.SourceURL(migrationsDir), dsn, cfg.MigrationsTable)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+
+		if err := stepMigrations(ctx, m, migrationsDir, version); err != nil {
+			return fmt.Errorf("failed to apply migrations to sample container: %w", err)
+		}
+		return nil
+	})
+}
+
+func init() {
+	squashCommand.Flags().StringVarP(&squashMigrationsDir, "migrations-dir", "m", "migrations", "Directory containing the migrations")
+	squashCommand.Flags().IntVar(&squashKeep, "keep", 0, "Number of the most recent migrations to leave out of the baseline")
+	squashCommand.Flags().BoolVar(&noDocker, "no-docker", false, "Replay migrations in an embedded Postgres instead of a Docker container")
+	squashCommand.Flags().StringVar(&pgImage, "pg-image", "", "Docker image of the scratch Postgres, e.g. postgres:17 or postgis/postgis:16-3.4")
+
+	rootCmd.AddCommand(squashCommand)
+}
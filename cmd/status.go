@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/dialect"
+	"styx/migrate"
+)
+
+var (
+	statusDsn             string
+	statusMigrationsDir   string
+	statusMigrationsTable string
+)
+
+var statusCommand = &cobra.Command{
+	Use:   "status",
+	Short: "Show applied and pending migrations of a database",
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "migrations-dir", &statusMigrationsDir, cfg.MigrationsDir)
+		configString(cmd, "migrations-table", &statusMigrationsTable, cfg.MigrationsTable)
+
+		if err := showStatus(cmd.Context(), statusDsn, statusMigrationsDir, statusMigrationsTable); err != nil {
+			log.Error().Err(err).Msgf("Failed to read migration status")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func showStatus(ctx context.Context, dsn, migrationsDir, table string) error {
+	dsn, err := resolveDSN(dsn)
+	if err != nil {
+		return err
+	}
+
+	files, err := migrate.ReadDir(migrationsDir)
+	if err != nil {
+		return err
+	}
+
+	// Looking up the migrations table relies on Postgres' catalogs, which
+	// CockroachDB emulates
+	if dbDialect != dialect.Postgres && dbDialect != dialect.Cockroach {
+		return fmt.Errorf("status isn't supported with the %s dialect yet", dbDialect.Name)
+	}
+	db, err := dbDialect.Open(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	state, err := migrate.ReadState(ctx, db, table)
+	if err != nil {
+		return err
+	}
+
+	statuses := migrate.Statuses(files, state)
+	if len(statuses) == 0 {
+		fmt.Println("No migrations found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+	for _, s := range statuses {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Description, s.Status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	switch {
+	case state.Version == 0:
+		fmt.Println("\nDatabase has no migrations applied")
+	case state.Dirty:
+		fmt.Printf("\nDatabase is at version %d and dirty: the last migration failed and needs fixing by hand\n", state.Version)
+	default:
+		fmt.Printf("\nDatabase is at version %d\n", state.Version)
+	}
+
+	return nil
+}
+
+func init() {
+	statusCommand.Flags().StringVar(&statusDsn, "dsn", "", "Connection string of the database to inspect, instead of --env")
+	statusCommand.Flags().StringVarP(&statusMigrationsDir, "migrations-dir", "m", "migrations", "Directory containing the migrations")
+	statusCommand.Flags().StringVar(&statusMigrationsTable, "migrations-table", migrate.DefaultTable, "Table golang-migrate records the applied version in")
+
+	rootCmd.AddCommand(statusCommand)
+}
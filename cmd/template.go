@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"styx/dialect"
+	"styx/migrate"
+	"styx/schema"
+)
+
+// Starts the names of the template databases holding replayed migrations
+const templatePrefix = "styx_template_"
+
+// Template databases only pay off in reused containers, which keep them for
+// the next runs
+func useTemplates() bool {
+	pg := cfg.Postgres
+	return dbDialect == dialect.Postgres && pg.Reuse && !pg.Embedded && pg.ScratchDSN == ""
+}
+
+// Replays the migrations in migrationsDir up to version, or all of them when
+// it's 0, with replay. In a reused container, the replayed database is saved
+// as a template, and later runs replaying the same migrations clone it with
+// CREATE DATABASE ... TEMPLATE instead of replaying them again
+func replayWithTemplate(ctx context.Context, dsn, migrationsDir string, version uint64, replay func() error) error {
+	if !useTemplates() {
+		return replay()
+	}
+	name, err := templateName(migrationsDir, version)
+	if err != nil {
+		return err
+	}
+	admin, database, err := maintenanceDB(dsn)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	var exists bool
+	err = admin.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", name).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to look up template database: %w", err)
+	}
+	if exists {
+		log.Info().Msg("Cloning the migrations replayed by an earlier run...")
+		for _, stmt := range []string{
+			fmt.Sprintf("DROP DATABASE %s;", schema.QuoteIdent(database)),
+			fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s;", schema.QuoteIdent(database), schema.QuoteIdent(name)),
+		} {
+			if _, err := admin.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("failed to clone template database %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+
+	if err := replay(); err != nil {
+		return err
+	}
+	// The template only saves time, runs go on without it
+	_, err = admin.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s;", schema.QuoteIdent(name), schema.QuoteIdent(database)))
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed to save the replayed migrations as template database %s", name)
+	}
+	return nil
+}
+
+// Returns the name of the template database of the migrations up to version,
+// which changes whenever they do, or the extensions installed before them
+func templateName(migrationsDir string, version uint64) (string, error) {
+	files, err := migrate.ReadDir(migrationsDir)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", cfg.MigrationsTable, strings.Join(cfg.Postgres.Extensions, ","))
+	var hashed []string
+	for _, f := range files {
+		if f.Direction != "up" || (version > 0 && f.Version > version) || slices.Contains(hashed, f.Path) {
+			continue
+		}
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", strconv.FormatUint(f.Version, 10), filepath.Base(f.Path), content)
+		hashed = append(hashed, f.Path)
+	}
+	return templatePrefix + hex.EncodeToString(h.Sum(nil)[:6]), nil
+}
+
+// Connects to the postgres database next to the one of dsn, since databases
+// can't be dropped or copied while connected to them, and returns the name of
+// the one of dsn
+func maintenanceDB(dsn string) (*sql.DB, string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse scratch database DSN: %w", err)
+	}
+	database := strings.TrimPrefix(u.Path, "/")
+	u.Path = "/postgres"
+	db, err := dbDialect.Open(u.String())
+	if err != nil {
+		return nil, "", err
+	}
+	return db, database, nil
+}
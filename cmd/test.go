@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"styx/diff"
+	"styx/schema"
+)
+
+var (
+	testInputFile     string
+	testMigrationsDir string
+)
+
+var testCommand = &cobra.Command{
+	Use:   "test",
+	Short: "Replay every migration in a scratch database and check the result matches schema.sql",
+	Long: `Applies all migrations to an empty scratch database and compares the result
+against the input schema.sql file. Exits with status 2 if they differ, or 1 on
+errors.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "input", &testInputFile, cfg.Schema)
+		configString(cmd, "migrations-dir", &testMigrationsDir, cfg.MigrationsDir)
+		if noDocker {
+			cfg.Postgres.Embedded = true
+		}
+		configString(cmd, "pg-image", &pgImage, cfg.Postgres.Image)
+		cfg.Postgres.Image = pgImage
+
+		err := testMigrations(cmd.Context(), testInputFile, testMigrationsDir)
+		if errors.Is(err, errDrift) {
+			os.Exit(exitDrift)
+		}
+		if err != nil {
+			logFailure(err, "Failed to test migrations")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func testMigrations(ctx context.Context, schemaFile, migrationsDir string) error {
+	changes, err := replayChanges(ctx, schemaFile, migrationsDir)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("Migrations match schema.sql")
+		return nil
+	}
+	printDriftReport(changes)
+	return errDrift
+}
+
+// Replays the migrations in a scratch database and returns the changes
+// still needed to reach the desired schema
+func replayChanges(ctx context.Context, schemaFile, migrationsDir string) ([]diff.Change, error) {
+	sqlFile, removeSQLFile, err := desiredSchemaFile(schemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load desired schema: %w", err)
+	}
+	defer removeSQLFile()
+
+	var desired *schema.Schema
+	if dbDialect.Parse != nil {
+		if desired, err = dbDialect.Parse(sqlFile); err != nil {
+			return nil, fmt.Errorf("failed to load desired schema: %w", locateSchemaErrors(err, schemaFile, sqlFile))
+		}
+	}
+
+	scratchDsn, cleanup, err := startScratchDatabase(ctx, cfg.Postgres)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if desired == nil {
+		if desired, err = dbDialect.Load(ctx, scratchDsn, sqlFile); err != nil {
+			return nil, fmt.Errorf("failed to load desired schema: %w", err)
+		}
+	}
+	if err := createRoles(ctx, scratchDsn, desired); err != nil {
+		return nil, err
+	}
+	if err := applyExistingMigrations(ctx, migrationsDir, scratchDsn); err != nil {
+		return nil, fmt.Errorf("failed to apply existing migrations: %w", err)
+	}
+
+	current, err := dumpDatabaseSchema(ctx, scratchDsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump current database schema: %w", err)
+	}
+	filterObjects(current, desired)
+
+	return diff.Diff(current, desired, diff.Options{Dialect: dbDialect.SQL}), nil
+}
+
+func init() {
+	testCommand.Flags().StringVarP(&testInputFile, "input", "i", "schema.sql", "Path to the input schema.sql file, HCL schema, directory of .sql files, or models like gorm:./models")
+	testCommand.Flags().StringVarP(&testMigrationsDir, "migrations-dir", "m", "migrations", "Directory containing the migrations")
+	testCommand.Flags().BoolVar(&noDocker, "no-docker", false, "Replay migrations in an embedded Postgres instead of a Docker container")
+	testCommand.Flags().StringVar(&pgImage, "pg-image", "", "Docker image of the scratch Postgres, e.g. postgres:17 or postgis/postgis:16-3.4")
+	filterFlags(testCommand)
+
+	rootCmd.AddCommand(testCommand)
+}
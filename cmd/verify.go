@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"styx/migrate"
+)
+
+var errUnverified = errors.New("migrations don't match the lock file")
+
+var (
+	verifyMigrationsDir string
+	verifyUpdate        bool
+)
+
+var verifyCommand = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that generated migrations weren't edited since, using the lock file",
+	Long: `Compares the migrations against the hashes recorded in the lock file when
+they were generated. Exits with status 2 if a migration was edited, removed or
+never locked, or 1 on errors.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configString(cmd, "migrations-dir", &verifyMigrationsDir, cfg.MigrationsDir)
+
+		err := verifyMigrations(verifyMigrationsDir, verifyUpdate)
+		if errors.Is(err, errUnverified) {
+			os.Exit(exitDrift)
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to verify migrations")
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func verifyMigrations(migrationsDir string, update bool) error {
+	files, err := migrate.ReadDir(migrationsDir)
+	if err != nil {
+		return err
+	}
+	lock, err := migrate.ReadLock(cfg.Lock)
+	if err != nil {
+		return err
+	}
+
+	if update {
+		// Edits made on purpose are accepted by hashing every file again
+		lock.Migrations = map[string]string{}
+		if err := lock.Add(files); err != nil {
+			return err
+		}
+		if err := lock.Write(cfg.Lock); err != nil {
+			return err
+		}
+		fmt.Printf("Updated %s\n", cfg.Lock)
+		return nil
+	}
+
+	problems, err := lock.Verify(files)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		fmt.Println("Migrations match the lock file")
+		return nil
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	return errUnverified
+}
+
+// Records the new migrations in the lock file and forgets the removed ones.
+// An empty fingerprint keeps the one already recorded
+func updateLock(migrationsDir string, removed []migrate.File, fingerprint string) error {
+	files, err := migrate.ReadDir(migrationsDir)
+	if err != nil {
+		return err
+	}
+	lock, err := migrate.ReadLock(cfg.Lock)
+	if err != nil {
+		return err
+	}
+
+	lock.Remove(removed)
+	if err := lock.Add(files); err != nil {
+		return err
+	}
+	if fingerprint != "" {
+		lock.Fingerprint = fingerprint
+	}
+	return lock.Write(cfg.Lock)
+}
+
+func init() {
+	verifyCommand.Flags().StringVarP(&verifyMigrationsDir, "migrations-dir", "m", "migrations", "Directory containing the migrations")
+	verifyCommand.Flags().BoolVar(&verifyUpdate, "update", false, "Record the current contents of every migration, accepting the edits")
+
+	rootCmd.AddCommand(verifyCommand)
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Flags running the command for all services, which the runs of the
+// services don't get
+var workspaceFlags = []string{"all", "jobs"}
+
+// serviceRun is the outcome of running a command for one service
+type serviceRun struct {
+	name   string
+	output []byte
+	code   int
+}
+
+// Runs the command for every service of the config, jobs at a time. Each
+// service runs in a styx process of its own, with --service set, so they
+// don't share the config and each starts its own scratch database. The
+// output of each run is printed once it's done so runs don't interleave, and
+// the exit code is the one of the first service that failed
+func runAllServices(cmd *cobra.Command, jobs int) int {
+	names := cfg.ServiceNames()
+	if len(names) == 0 {
+		log.Error().Msg("No services are defined in the config")
+		return exitError
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to find the styx executable")
+		return exitError
+	}
+
+	runs := make([]serviceRun, len(names))
+	sem := make(chan struct{}, max(jobs, 1))
+	var wg sync.WaitGroup
+	var printing sync.Mutex
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			run := serviceRun{name: name}
+			c := exec.Command(executable, serviceArgs(cmd, name)...)
+			var output bytes.Buffer
+			c.Stdout = &output
+			c.Stderr = &output
+			err := c.Run()
+			run.output = output.Bytes()
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				run.code = exitErr.ExitCode()
+			} else if err != nil {
+				run.code = exitError
+				run.output = fmt.Appendf(run.output, "Failed to run styx: %v\n", err)
+			}
+			runs[i] = run
+
+			printing.Lock()
+			defer printing.Unlock()
+			fmt.Printf("==> %s\n", name)
+			os.Stdout.Write(run.output)
+		}()
+	}
+	wg.Wait()
+
+	code := exitOK
+	var failed []string
+	for _, run := range runs {
+		if run.code == exitOK {
+			continue
+		}
+		failed = append(failed, run.name)
+		if code == exitOK {
+			code = run.code
+		}
+	}
+	if len(failed) > 0 {
+		log.Error().Msgf("Failed for %d of %d service(s): %s", len(failed), len(names), strings.Join(failed, ", "))
+	}
+	return code
+}
+
+// Returns the arguments running cmd again for the service, with the flags
+// that were set except the ones selecting the services
+func serviceArgs(cmd *cobra.Command, service string) []string {
+	args := strings.Fields(cmd.CommandPath())[1:]
+	args = append(args, "--service", service)
+	// The paths of the service are the defaults of its run, but commands
+	// requiring them as flags get them explicitly
+	svc, _ := cfg.UseService(service)
+	for flag, value := range map[string]string{"input": svc.Schema, "output-dir": svc.MigrationsDir} {
+		if cmd.Flags().Lookup(flag) != nil {
+			args = append(args, "--"+flag+"="+value)
+		}
+	}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if slices.Contains(workspaceFlags, f.Name) {
+			return
+		}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			for _, value := range slice.GetSlice() {
+				args = append(args, "--"+f.Name+"="+value)
+			}
+			return
+		}
+		args = append(args, "--"+f.Name+"="+f.Value.String())
+	})
+	return args
+}
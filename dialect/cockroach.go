@@ -0,0 +1,52 @@
+package dialect
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+
+	"styx/internal/sqltrace"
+	"styx/introspect"
+	"styx/schema"
+)
+
+func loadCockroach(ctx context.Context, dsn, path string) (*schema.Schema, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+
+	server, err := sqltrace.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer server.Close()
+
+	for _, stmt := range []string{
+		"DROP DATABASE IF EXISTS " + desiredDatabase + " CASCADE",
+		"CREATE DATABASE " + desiredDatabase,
+	} {
+		if _, err := server.ExecContext(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("failed to create %s database: %w", desiredDatabase, err)
+		}
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dsn: %w", err)
+	}
+	u.Path = "/" + desiredDatabase
+
+	db, err := sqltrace.Open("postgres", u.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, string(contents)); err != nil {
+		return nil, fmt.Errorf("failed to execute %s: %w", path, err)
+	}
+
+	return introspect.Cockroach(ctx, db)
+}
@@ -0,0 +1,240 @@
+// Package dialect ties together everything styx needs to know about a
+// database engine: how to connect to it, how to read its schema, how to
+// render DDL for it and how golang-migrate applies migrations to it.
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	gomigrate "github.com/golang-migrate/migrate"
+	"github.com/golang-migrate/migrate/database"
+	migratecockroach "github.com/golang-migrate/migrate/database/cockroachdb"
+	migratemysql "github.com/golang-migrate/migrate/database/mysql"
+	migratepostgres "github.com/golang-migrate/migrate/database/postgres"
+	migratesqlite "github.com/golang-migrate/migrate/database/sqlite3"
+	_ "github.com/lib/pq"
+
+	"styx/diff"
+	"styx/internal/psqlexec"
+	"styx/internal/sqltrace"
+	"styx/introspect"
+	"styx/schema"
+)
+
+type Dialect struct {
+	Name string
+	// Driver is the database/sql driver name
+	Driver string
+	// SQL renders the DDL of the changes
+	SQL diff.Dialect
+	// Introspect reads the schema of a live database
+	Introspect func(context.Context, *sql.DB) (*schema.Schema, error)
+	// Parse reads the desired schema from schema.sql. It's nil for dialects
+	// without a SQL parser, whose schema.sql is loaded into a scratch database
+	// instead
+	Parse func(path string) (*schema.Schema, error)
+	// Load executes schema.sql in a fresh database on the server at dsn and
+	// introspects the result
+	Load func(ctx context.Context, dsn, path string) (*schema.Schema, error)
+	// MigrateDriver wraps a connection for golang-migrate, recording the
+	// applied version in table
+	MigrateDriver func(db *sql.DB, table string) (database.Driver, error)
+
+	// Adjusts DSNs before connecting
+	prepareDSN func(dsn string) (string, error)
+}
+
+var Postgres = &Dialect{
+	Name:       "postgres",
+	Driver:     "postgres",
+	SQL:        diff.Postgres,
+	Introspect: introspect.Introspect,
+	Parse:      schema.ParseFile,
+	MigrateDriver: func(db *sql.DB, table string) (database.Driver, error) {
+		return migratepostgres.WithInstance(db, &migratepostgres.Config{MigrationsTable: table})
+	},
+}
+
+// Cockroach loads schema.sql into the scratch cluster rather than parsing it,
+// as CockroachDB resolves types differently, e.g. integer is 64-bit
+var Cockroach = &Dialect{
+	Name:       "cockroachdb",
+	Driver:     "postgres",
+	SQL:        diff.Cockroach,
+	Introspect: introspect.Cockroach,
+	Load:       loadCockroach,
+	MigrateDriver: func(db *sql.DB, table string) (database.Driver, error) {
+		return migratecockroach.WithInstance(db, &migratecockroach.Config{MigrationsTable: table})
+	},
+}
+
+var MySQL = &Dialect{
+	Name:       "mysql",
+	Driver:     "mysql",
+	SQL:        diff.MySQL,
+	Introspect: introspect.MySQL,
+	Load:       loadMySQL,
+	MigrateDriver: func(db *sql.DB, table string) (database.Driver, error) {
+		return migratemysql.WithInstance(db, &migratemysql.Config{MigrationsTable: table})
+	},
+	prepareDSN: func(dsn string) (string, error) {
+		config, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse dsn: %w", err)
+		}
+		// Migrations and schema.sql usually hold more than one statement
+		config.MultiStatements = true
+		return config.FormatDSN(), nil
+	},
+}
+
+var SQLite = &Dialect{
+	Name:       "sqlite",
+	Driver:     "sqlite3",
+	SQL:        diff.SQLite,
+	Introspect: introspect.SQLite,
+	Load:       loadSQLite,
+	MigrateDriver: func(db *sql.DB, table string) (database.Driver, error) {
+		return migratesqlite.WithInstance(db, &migratesqlite.Config{MigrationsTable: table})
+	},
+}
+
+var dialects = map[string]*Dialect{
+	"postgres":    Postgres,
+	"postgresql":  Postgres,
+	"cockroach":   Cockroach,
+	"cockroachdb": Cockroach,
+	"mysql":       MySQL,
+	"mariadb":     MySQL,
+	"sqlite":      SQLite,
+	"sqlite3":     SQLite,
+}
+
+// Lookup returns the dialect with the given name
+func Lookup(name string) (*Dialect, error) {
+	d, ok := dialects[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported dialect %s", name)
+	}
+	return d, nil
+}
+
+// Open connects to the database at dsn
+func (d *Dialect) Open(dsn string) (*sql.DB, error) {
+	if d.prepareDSN != nil {
+		var err error
+		if dsn, err = d.prepareDSN(dsn); err != nil {
+			return nil, err
+		}
+	}
+
+	driverName := d.Driver
+	if strings.HasPrefix(dsn, psqlexec.Scheme+"://") {
+		driverName = psqlexec.DriverName
+	}
+	db, err := sqltrace.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return db, nil
+}
+
+// Placeholder returns the bind parameter of the nth argument of a query,
+// counting from 1
+func (d *Dialect) Placeholder(n int) string {
+	if d.Driver == "postgres" {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// QuoteName quotes a table or column name, which may be schema-qualified
+func (d *Dialect) QuoteName(name string) string {
+	if d == MySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return schema.QuoteName(name)
+}
+
+// ReadSchema introspects the database at dsn
+func (d *Dialect) ReadSchema(ctx context.Context, dsn string) (*schema.Schema, error) {
+	db, err := d.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return d.Introspect(ctx, db)
+}
+
+// Migrate returns a golang-migrate instance applying the migrations at
+// sourceURL to the database at dsn. Closing it closes the connection
+func (d *Dialect) Migrate(sourceURL, dsn, table string) (*gomigrate.Migrate, error) {
+	db, err := d.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := d.MigrateDriver(db, table)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize `golang-migrate`: %w", err)
+	}
+
+	m, err := gomigrate.NewWithDatabaseInstance(sourceURL, d.Name, driver)
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("failed to initialize `golang-migrate`: %w", err)
+	}
+	return m, nil
+}
+
+// Name of the database schema.sql is loaded into, next to the one the
+// migrations are replayed in
+const desiredDatabase = "styx_desired"
+
+func loadMySQL(ctx context.Context, dsn, path string) (*schema.Schema, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dsn: %w", err)
+	}
+
+	server, err := sqltrace.Open("mysql", config.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer server.Close()
+
+	for _, stmt := range []string{
+		"DROP DATABASE IF EXISTS " + desiredDatabase,
+		"CREATE DATABASE " + desiredDatabase,
+	} {
+		if _, err := server.ExecContext(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("failed to create %s database: %w", desiredDatabase, err)
+		}
+	}
+
+	config.DBName = desiredDatabase
+	config.MultiStatements = true
+	db, err := sqltrace.Open("mysql", config.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, string(contents)); err != nil {
+		return nil, fmt.Errorf("failed to execute %s: %w", path, err)
+	}
+
+	return introspect.MySQL(ctx, db)
+}
@@ -0,0 +1,58 @@
+package dialect
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"styx/internal/sqltrace"
+	"styx/introspect"
+	"styx/schema"
+)
+
+// MemoryDatabase creates an in-memory SQLite database to replay migrations
+// in. The database lives until the returned function is called
+func MemoryDatabase() (string, func(), error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", nil, fmt.Errorf("failed to generate database name: %w", err)
+	}
+	// A shared cache lets every connection see the same database, which is
+	// dropped once the last connection closes
+	dsn := fmt.Sprintf("file:styx-%s?mode=memory&cache=shared", hex.EncodeToString(suffix))
+
+	db, err := sqltrace.Open("sqlite3", dsn)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return "", nil, fmt.Errorf("failed to create database: %w", err)
+	}
+
+	return dsn, func() { db.Close() }, nil
+}
+
+// Executes schema.sql in a throwaway in-memory database, dsn is unused
+func loadSQLite(ctx context.Context, dsn, path string) (*schema.Schema, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+
+	db, err := sqltrace.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database: %w", err)
+	}
+	defer db.Close()
+	// Every connection to :memory: gets its own database
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.ExecContext(ctx, string(contents)); err != nil {
+		return nil, fmt.Errorf("failed to execute %s: %w", path, err)
+	}
+
+	return introspect.SQLite(ctx, db)
+}
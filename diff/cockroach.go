@@ -0,0 +1,35 @@
+package diff
+
+import (
+	"fmt"
+
+	"styx/schema"
+)
+
+// Cockroach renders statements for CockroachDB, which speaks the Postgres
+// dialect with a few differences
+var Cockroach Dialect = cockroach{}
+
+type cockroach struct {
+	postgres
+}
+
+// CockroachDB builds indexes online, without a CONCURRENTLY option
+func (cockroach) CreateIndex(table *schema.Table, index *schema.Index, concurrently bool) string {
+	return postgres{}.CreateIndex(table, index, false)
+}
+
+// Index names are only unique per table in CockroachDB
+func (cockroach) DropIndex(table *schema.Table, index *schema.Index, concurrently bool) string {
+	return fmt.Sprintf("DROP INDEX %s@%s;", schema.QuoteName(table.Name), schema.QuoteIdent(index.Name))
+}
+
+// Type changes that rewrite the column are still experimental in
+// CockroachDB, and have to be enabled for the session first
+func (cockroach) AlterColumn(table *schema.Table, current, desired *schema.Column) []string {
+	statements := postgres{}.AlterColumn(table, current, desired)
+	if current.Type != desired.Type {
+		statements[0] = "SET enable_experimental_alter_column_type_general = true;\n" + statements[0]
+	}
+	return statements
+}
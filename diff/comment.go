@@ -0,0 +1,54 @@
+package diff
+
+import (
+	"fmt"
+
+	"styx/schema"
+)
+
+// Returns the statements setting or removing the comments of tables and
+// columns that changed, including the ones of new tables
+func diffComments(current, desired *schema.Schema) []Change {
+	var changes []Change
+	for _, table := range desired.Tables {
+		existing := current.Table(table.Name)
+		if existing == nil {
+			existing = &schema.Table{}
+		}
+
+		if table.Comment != existing.Comment {
+			changes = append(changes, Change{
+				Op:    OpAlter,
+				Kind:  KindTable,
+				Table: table.Name,
+				Name:  table.Name,
+				SQL:   fmt.Sprintf("COMMENT ON TABLE %s IS %s;", schema.QuoteName(table.Name), commentLiteral(table.Comment)),
+			})
+		}
+
+		for _, column := range table.Columns {
+			previous := ""
+			if other := existing.Column(column.Name); other != nil {
+				previous = other.Comment
+			}
+			if column.Comment != previous {
+				changes = append(changes, Change{
+					Op:    OpAlter,
+					Kind:  KindColumn,
+					Table: table.Name,
+					Name:  column.Name,
+					SQL:   fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s;", schema.QuoteName(table.Name), schema.QuoteIdent(column.Name), commentLiteral(column.Comment)),
+				})
+			}
+		}
+	}
+	return changes
+}
+
+// Empty comments are removed rather than set to an empty string
+func commentLiteral(comment string) string {
+	if comment == "" {
+		return "NULL"
+	}
+	return schema.QuoteLiteral(comment)
+}
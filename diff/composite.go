@@ -0,0 +1,208 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"styx/schema"
+)
+
+// Returns the statements creating new composite types and altering existing
+// ones in place, and the ones replacing the types ALTER TYPE can't reshape.
+// The latter convert the columns storing them, so they have to wait for the
+// views reading those columns to be dropped. Functions taking or returning
+// a replaced type are dropped along with it, and created again by
+// diffFunctions
+func diffCompositeTypes(current, desired *schema.Schema) (changes, recreates []Change) {
+	recreated := recreatedTypes(current, desired)
+	for _, function := range current.Functions {
+		if usesType(function, recreated) {
+			recreates = append(recreates, dropFunctionChange(function))
+		}
+	}
+
+	for _, typ := range desired.CompositeTypes {
+		existing := current.CompositeType(typ.Name)
+		switch {
+		case existing == nil:
+			changes = append(changes, Change{
+				Op:   OpCreate,
+				Kind: KindType,
+				Name: typ.Name,
+				SQL:  createCompositeTypeSQL(typ),
+			})
+		case slices.EqualFunc(existing.Attributes, typ.Attributes, func(a, b *schema.Attribute) bool { return *a == *b }):
+			continue
+		case recreated[typ.Name]:
+			recreates = append(recreates, Change{
+				Op:          OpAlter,
+				Kind:        KindType,
+				Name:        typ.Name,
+				SQL:         recreateCompositeTypeSQL(current, existing, typ),
+				Destructive: attributesLost(current, existing, typ),
+				Locking:     storesType(current, typ.Name),
+			})
+		default:
+			changes = append(changes, Change{
+				Op:          OpAlter,
+				Kind:        KindType,
+				Name:        typ.Name,
+				SQL:         alterCompositeTypeSQL(existing, typ),
+				Destructive: attributesLost(current, existing, typ),
+			})
+		}
+	}
+
+	return changes, recreates
+}
+
+func dropCompositeTypes(current, desired *schema.Schema) []Change {
+	var changes []Change
+	for _, typ := range current.CompositeTypes {
+		if desired.CompositeType(typ.Name) == nil {
+			changes = append(changes, Change{
+				Op:   OpDrop,
+				Kind: KindType,
+				Name: typ.Name,
+				SQL:  fmt.Sprintf("DROP TYPE %s;", schema.QuoteName(typ.Name)),
+			})
+		}
+	}
+	return changes
+}
+
+func createCompositeTypeSQL(typ *schema.CompositeType) string {
+	attributes := make([]string, len(typ.Attributes))
+	for i, attribute := range typ.Attributes {
+		attributes[i] = schema.QuoteIdent(attribute.Name) + " " + attribute.Type
+	}
+	return fmt.Sprintf("CREATE TYPE %s AS (%s);", schema.QuoteName(typ.Name), strings.Join(attributes, ", "))
+}
+
+// Lists the composite types that can't be altered in place. ALTER TYPE only
+// adds attributes at the end, and can't change the type of an attribute
+// while a column stores the type
+func recreatedTypes(current, desired *schema.Schema) map[string]bool {
+	recreated := map[string]bool{}
+	for _, typ := range desired.CompositeTypes {
+		existing := current.CompositeType(typ.Name)
+		if existing == nil {
+			continue
+		}
+
+		var kept []*schema.Attribute
+		for _, attribute := range existing.Attributes {
+			if typ.Attribute(attribute.Name) != nil {
+				kept = append(kept, attribute)
+			}
+		}
+		for i, attribute := range kept {
+			other := typ.Attributes[i]
+			if other.Name != attribute.Name || other.Type != attribute.Type && storesType(current, typ.Name) {
+				recreated[typ.Name] = true
+			}
+		}
+	}
+	return recreated
+}
+
+// Drops, retypes and adds attributes in a single statement
+func alterCompositeTypeSQL(current, desired *schema.CompositeType) string {
+	var actions []string
+	for _, attribute := range current.Attributes {
+		if desired.Attribute(attribute.Name) == nil {
+			actions = append(actions, "DROP ATTRIBUTE "+schema.QuoteIdent(attribute.Name))
+		}
+	}
+	for _, attribute := range desired.Attributes {
+		existing := current.Attribute(attribute.Name)
+		switch {
+		case existing == nil:
+			actions = append(actions, fmt.Sprintf("ADD ATTRIBUTE %s %s", schema.QuoteIdent(attribute.Name), attribute.Type))
+		case existing.Type != attribute.Type:
+			actions = append(actions, fmt.Sprintf("ALTER ATTRIBUTE %s TYPE %s", schema.QuoteIdent(attribute.Name), attribute.Type))
+		}
+	}
+	return fmt.Sprintf("ALTER TYPE %s %s;", schema.QuoteName(desired.Name), strings.Join(actions, ", "))
+}
+
+// Swaps the type out the same way enums are. Stored values are rebuilt
+// attribute by attribute, new attributes being NULL. Arrays of the type
+// can't be converted this way, and block the old type from being dropped
+func recreateCompositeTypeSQL(current *schema.Schema, existing, desired *schema.CompositeType) string {
+	name := schema.QuoteName(desired.Name)
+	statements := []string{
+		renameSQL("TYPE", desired.Name, desired.Name+"_old"),
+		createCompositeTypeSQL(desired),
+	}
+	statements = append(statements, convertColumnsSQL(current, desired.Name, func(column string, array bool) string {
+		if array {
+			return ""
+		}
+		fields := make([]string, len(desired.Attributes))
+		for i, attribute := range desired.Attributes {
+			old := existing.Attribute(attribute.Name)
+			switch {
+			case old == nil:
+				fields[i] = "NULL"
+			case old.Type != attribute.Type:
+				fields[i] = fmt.Sprintf("(%s).%s::%s", column, schema.QuoteIdent(attribute.Name), attribute.Type)
+			default:
+				fields[i] = fmt.Sprintf("(%s).%s", column, schema.QuoteIdent(attribute.Name))
+			}
+		}
+		return fmt.Sprintf("ROW(%s)::%s", strings.Join(fields, ", "), name)
+	})...)
+	statements = append(statements, fmt.Sprintf("DROP TYPE %s;", schema.QuoteName(desired.Name+"_old")))
+	return strings.Join(statements, "\n")
+}
+
+// Reports whether a column of a table stores the type, or arrays of it
+func storesType(s *schema.Schema, typeName string) bool {
+	return slices.ContainsFunc(s.Tables, func(table *schema.Table) bool {
+		return storesTypes(table, map[string]bool{typeName: true})
+	})
+}
+
+// Reports whether a column of the table stores one of the types, or arrays
+// of them. Views reading such a column are in the way of converting it
+func storesTypes(table *schema.Table, types map[string]bool) bool {
+	for typeName := range types {
+		name := schema.QuoteName(typeName)
+		for _, column := range table.Columns {
+			if column.Type == name || column.Type == name+"[]" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Reports whether reshaping the type loses stored values, by dropping or
+// narrowing one of its attributes
+func attributesLost(current *schema.Schema, existing, desired *schema.CompositeType) bool {
+	if !storesType(current, desired.Name) {
+		return false
+	}
+	for _, attribute := range existing.Attributes {
+		other := desired.Attribute(attribute.Name)
+		if other == nil || narrowsType(attribute.Type, other.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether one of the types appears in the function's arguments or
+// result
+func usesType(function *schema.Function, types map[string]bool) bool {
+	for typeName := range types {
+		pattern := regexp.MustCompile(`(^|[^\w".])` + regexp.QuoteMeta(schema.QuoteName(typeName)) + `($|[^\w".])`)
+		if pattern.MatchString(function.Args) || pattern.MatchString(function.Returns) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,654 @@
+// Package diff compares two schema models and computes the DDL statements
+// needed to turn one into the other.
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"styx/schema"
+)
+
+type Op string
+
+const (
+	OpCreate Op = "CREATE"
+	OpDrop   Op = "DROP"
+	OpAlter  Op = "ALTER"
+)
+
+type Kind string
+
+const (
+	KindTable      Kind = "table"
+	KindColumn     Kind = "column"
+	KindConstraint Kind = "constraint"
+	KindIndex      Kind = "index"
+	KindEnum       Kind = "enum"
+	KindDomain     Kind = "domain"
+	KindType       Kind = "type"
+	KindView       Kind = "view"
+	KindFunction   Kind = "function"
+	KindTrigger    Kind = "trigger"
+	KindSequence   Kind = "sequence"
+	KindSchema     Kind = "schema"
+	KindExtension  Kind = "extension"
+	KindPolicy     Kind = "policy"
+	KindGrant      Kind = "grant"
+	// KindServer, KindUserMapping and KindForeignTable are the objects of
+	// foreign data wrappers
+	KindServer       Kind = "server"
+	KindUserMapping  Kind = "user mapping"
+	KindForeignTable Kind = "foreign table"
+	KindPublication  Kind = "publication"
+)
+
+// Options tweak the generated statements
+type Options struct {
+	// ConcurrentIndexes builds and drops indexes on existing tables with
+	// CONCURRENTLY, so writes aren't blocked while the index is built
+	ConcurrentIndexes bool
+	// NotValidForeignKeys adds foreign keys to existing tables NOT VALID and
+	// validates them in a separate change, so the rows are checked without
+	// blocking writes. Only for dialects implementing ConstraintValidator
+	NotValidForeignKeys bool
+	// NotValidChecks does the same for CHECK constraints
+	NotValidChecks bool
+	// Dialect renders the statements. It defaults to Postgres
+	Dialect Dialect
+	// Renames lists the tables and columns that were renamed rather than
+	// dropped and recreated
+	Renames Renames
+	// Backfill adds NOT NULL columns without a default to existing tables as
+	// nullable, and makes them NOT NULL in a separate change, so their rows
+	// can be filled in between. Changes making a column NOT NULL are marked
+	Backfill bool
+	// RewriteColumnOrder rewrites existing tables whose columns are declared
+	// in another order, copying their rows into a new table with the columns
+	// in that order. Only for dialects implementing TableRewriter
+	RewriteColumnOrder bool
+}
+
+func (o Options) dialect() Dialect {
+	if o.Dialect == nil {
+		return Postgres
+	}
+	return o.Dialect
+}
+
+// Change is a single DDL operation
+type Change struct {
+	Op   Op
+	Kind Kind
+	// Table is the table the change applies to
+	Table string
+	// Name is the name of the changed object. For tables this is the same as Table
+	Name string
+	SQL  string
+	// Destructive is set for changes that can lose data, like dropping a
+	// column or narrowing its type
+	Destructive bool
+	// Locking is set for changes that block writes to an existing table
+	// while they run, like building an index or rewriting a column
+	Locking bool
+	// Backfill is set for changes making a column of an existing table NOT
+	// NULL, which fail until its rows are filled in. Only with
+	// Options.Backfill
+	Backfill bool
+}
+
+type Severity string
+
+const (
+	SeveritySafe        Severity = "safe"
+	SeverityLocking     Severity = "locking"
+	SeverityDestructive Severity = "destructive"
+)
+
+// Severity tells how risky applying the change is. Losing data trumps locking
+func (c Change) Severity() Severity {
+	switch {
+	case c.Destructive:
+		return SeverityDestructive
+	case c.Locking:
+		return SeverityLocking
+	}
+	return SeveritySafe
+}
+
+// String describes the change in a few words, e.g. "create column users.email"
+func (c Change) String() string {
+	name := c.Name
+	if c.Table != "" && c.Kind != KindTable {
+		name = c.Table + "." + c.Name
+	}
+	return fmt.Sprintf("%s %s %s", strings.ToLower(string(c.Op)), c.Kind, name)
+}
+
+// Diff computes the changes required to migrate the current schema to the
+// desired one. The returned changes are ordered so they can be applied as-is:
+//
+//  1. Postgres schemas are created, so objects can be created or moved in them
+//  2. Extensions are created, so the types and functions they provide can
+//     be used
+//  3. Tables and columns are renamed, so everything below sees the new names
+//  4. Enums, domains and composite types are created or altered, so
+//     columns and functions can use them, the types they're made of first
+//  5. Views that changed, went away, or depend on something that's about to
+//     change are dropped
+//  6. Triggers and row-level security policies that changed or went away
+//     are dropped
+//  7. Composite types that can't be altered in place are replaced, along
+//     with the functions using them, then functions are created or
+//     replaced, so defaults, checks and triggers can use them
+//  8. Sequences are created, renamed or altered, so defaults can use them,
+//     then foreign servers, user mappings and foreign tables
+//  9. Foreign keys that changed or went away are dropped, so nothing below
+//     trips over them
+//  10. Existing tables are altered, or rebuilt for dialects that can't alter
+//     them in place
+//  11. New tables are created, referenced tables first
+//  12. Foreign keys are added to existing tables, now that every table they
+//     could reference exists
+//  13. Sequences are tied to the columns of new tables
+//  14. Views are (re)created, dependencies first
+//  15. Triggers and policies are created, row-level security is toggled,
+//     and comments on tables and columns are set
+//  16. Privileges on tables and views are granted and revoked, and
+//     publications are created, altered and dropped, before the tables
+//     they drop go away
+//  17. Tables that went away are dropped, referencing tables first, then
+//     foreign tables, user mappings and servers
+//  18. Functions, sequences, types and extensions that went away are
+//     dropped, now that nothing uses them, types using other types first
+//  19. Postgres schemas that went away are dropped, now that they're empty
+//
+// Changes creating an object that depends on an object of another kind,
+// like a function returning the rows of a new table, are held back until
+// that object is created. Changes dropping one wait the same way for the
+// objects depending on it to be dropped
+//
+// Objects depending on each other in a cycle can't be ordered this way, so
+// callers check both schemas with CheckDependencies first
+func Diff(current, desired *schema.Schema, opts Options) []Change {
+	d := opts.dialect()
+	changes, dropSchemas := diffSchemas(current, desired)
+	extensions, dropExtensions := diffExtensions(current, desired)
+	changes = append(changes, extensions...)
+
+	renames, current := applyRenames(d, current, desired, opts.Renames)
+	changes = append(changes, renames...)
+
+	// Sequences are diffed against the tables as they are before any is
+	// rewritten, so the ones owned by new columns are tied to them once the
+	// columns exist
+	sequences, sequenceOwners, dropSequences := diffSequences(current, desired)
+	var rewrites []Change
+	if opts.RewriteColumnOrder {
+		rewrites, current = rewriteColumnOrder(d, current, desired)
+	}
+
+	// Types can be made of each other, like a domain over a composite type
+	compositeTypes, replacedTypes := diffCompositeTypes(current, desired)
+	types := append(diffEnums(current, desired), diffDomains(current, desired)...)
+	types = append(types, compositeTypes...)
+	changes = append(changes, types...)
+
+	dropViews, createViews := diffViews(d, current, desired)
+	changes = append(changes, dropViews...)
+
+	dropTriggers, createTriggers := diffTriggers(current, desired)
+	changes = append(changes, dropTriggers...)
+
+	dropPolicies, createPolicies := diffPolicies(current, desired)
+	changes = append(changes, dropPolicies...)
+
+	changes = append(changes, replacedTypes...)
+	functions, dropFunctions := diffFunctions(current, desired)
+	changes = append(changes, functions...)
+
+	changes = append(changes, sequences...)
+	changes = append(changes, rewrites...)
+	foreignData, dropForeignData := diffForeignData(current, desired)
+	changes = append(changes, foreignData...)
+
+	for _, table := range desired.Tables {
+		if existing := current.Table(table.Name); existing != nil && !rebuilds(d, existing, table) {
+			changes = append(changes, dropForeignKeys(d, existing, table)...)
+		}
+	}
+
+	for _, table := range desired.Tables {
+		existing := current.Table(table.Name)
+		switch {
+		case existing == nil:
+		case rebuilds(d, existing, table):
+			changes = append(changes, Change{
+				Op:    OpAlter,
+				Kind:  KindTable,
+				Table: table.Name,
+				Name:  table.Name,
+				SQL:   d.(TableRebuilder).RebuildTable(existing, table),
+				// The rebuild leaves out dropped columns, and converts the
+				// kept ones to their new type
+				Destructive: columnsLost(existing, table),
+				Locking:     true,
+			})
+		default:
+			changes = append(changes, diffTable(existing, table, opts)...)
+		}
+	}
+
+	var newTables []*schema.Table
+	for _, table := range desired.Tables {
+		if current.Table(table.Name) == nil {
+			newTables = append(newTables, table)
+		}
+	}
+	newTables, deferred := sortTables(newTables)
+	if _, ok := d.(TableRebuilder); ok {
+		// Foreign keys are always declared inline
+		deferred = nil
+	}
+	for _, table := range newTables {
+		changes = append(changes, Change{
+			Op:    OpCreate,
+			Kind:  KindTable,
+			Table: table.Name,
+			Name:  table.Name,
+			SQL:   d.CreateTable(table, deferred),
+		})
+		// There's nothing to lock on a new table, so its indexes are
+		// never built concurrently
+		for _, index := range table.Indexes {
+			changes = append(changes, Change{
+				Op:    OpCreate,
+				Kind:  KindIndex,
+				Table: table.Name,
+				Name:  index.Name,
+				SQL:   d.CreateIndex(table, index, false),
+			})
+		}
+	}
+	// Foreign keys that are part of a reference cycle can only be added once
+	// all tables in the cycle exist
+	for _, table := range newTables {
+		for _, constraint := range table.Constraints {
+			if deferred[constraint] {
+				changes = append(changes, addConstraintChange(d, table, constraint))
+			}
+		}
+	}
+
+	for _, table := range desired.Tables {
+		if existing := current.Table(table.Name); existing != nil && !rebuilds(d, existing, table) {
+			changes = append(changes, addForeignKeys(existing, table, opts)...)
+		}
+	}
+
+	changes = append(changes, sequenceOwners...)
+	changes = append(changes, createViews...)
+	changes = append(changes, createTriggers...)
+	changes = append(changes, createPolicies...)
+	changes = append(changes, diffComments(current, desired)...)
+
+	recreated := map[string]bool{}
+	for _, change := range dropViews {
+		recreated[change.Name] = true
+	}
+	changes = append(changes, diffGrants(current, desired, recreated)...)
+	changes = append(changes, diffPublications(current, desired)...)
+
+	var dropped []*schema.Table
+	for _, table := range current.Tables {
+		if desired.Table(table.Name) == nil {
+			dropped = append(dropped, table)
+		}
+	}
+	dropped, deferred = sortTables(dropped)
+	if _, ok := d.(TableRebuilder); ok {
+		deferred = nil
+	}
+	for _, table := range dropped {
+		for _, constraint := range table.Constraints {
+			if deferred[constraint] {
+				changes = append(changes, dropConstraintChange(d, table, constraint))
+			}
+		}
+	}
+	for i := len(dropped) - 1; i >= 0; i-- {
+		changes = append(changes, Change{
+			Op:          OpDrop,
+			Kind:        KindTable,
+			Table:       dropped[i].Name,
+			Name:        dropped[i].Name,
+			SQL:         d.DropTable(dropped[i]),
+			Destructive: true,
+		})
+	}
+	changes = append(changes, dropForeignData...)
+
+	changes = append(changes, dropFunctions...)
+	changes = append(changes, dropSequences...)
+	dropTypes := append(dropCompositeTypes(current, desired), dropDomains(current, desired)...)
+	dropTypes = append(dropTypes, dropEnums(current, desired)...)
+	changes = append(changes, dropTypes...)
+	changes = append(changes, dropExtensions...)
+	changes = append(changes, dropSchemas...)
+
+	// Objects depending on objects of another kind, like functions taking
+	// the rows of a table, or types made of others, wait for them
+	changes = orderChanges(changes, NewGraph(desired), OpCreate)
+	return orderChanges(changes, NewGraph(current), OpDrop)
+}
+
+// Reports whether the dialect recreates the table rather than altering it
+func rebuilds(d Dialect, current, desired *schema.Table) bool {
+	rebuilder, ok := d.(TableRebuilder)
+	return ok && rebuilder.NeedsRebuild(current, desired)
+}
+
+// Reports whether the column can't be added to a table with rows as is,
+// being NOT NULL without a value to fill them with
+func needsBackfill(column *schema.Column) bool {
+	return column.NotNull && column.Default == "" && column.Identity == "" && column.Generated == "" && !column.AutoIncrement
+}
+
+// Reports whether turning current into desired drops a column or narrows
+// its type
+func columnsLost(current, desired *schema.Table) bool {
+	for _, column := range current.Columns {
+		other := desired.Column(column.Name)
+		if other == nil || narrowsType(column.Type, other.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// Changes within a table are ordered so indexes and constraints are dropped
+// before the columns they depend on, and added once the columns exist.
+// Foreign keys are handled separately by dropForeignKeys and addForeignKeys
+func diffTable(current, desired *schema.Table, opts Options) []Change {
+	d := opts.dialect()
+	var changes []Change
+
+	// Indexes and constraints go away along with the regenerated columns
+	// they use, so they're dropped and created again explicitly
+	regenerated := regeneratedColumns(current, desired)
+
+	for _, index := range current.Indexes {
+		if other := desired.Index(index.Name); other == nil || !equalIndexes(index, other) || indexUses(index, regenerated) {
+			changes = append(changes, Change{
+				Op:      OpDrop,
+				Kind:    KindIndex,
+				Table:   desired.Name,
+				Name:    index.Name,
+				SQL:     d.DropIndex(desired, index, opts.ConcurrentIndexes),
+				Locking: !opts.ConcurrentIndexes,
+			})
+		}
+	}
+
+	for _, constraint := range current.Constraints {
+		if constraint.Type != schema.ForeignKey && (constraintChanged(constraint, desired) || constraintUses(constraint, regenerated)) {
+			changes = append(changes, dropConstraintChange(d, desired, constraint))
+		}
+	}
+
+	for _, column := range desired.Columns {
+		existing := current.Column(column.Name)
+		if regenerated[column.Name] {
+			changes = append(changes, Change{
+				Op:          OpDrop,
+				Kind:        KindColumn,
+				Table:       desired.Name,
+				Name:        column.Name,
+				SQL:         d.DropColumn(desired, existing),
+				Destructive: true,
+			}, Change{
+				Op:    OpCreate,
+				Kind:  KindColumn,
+				Table: desired.Name,
+				Name:  column.Name,
+				SQL:   d.AddColumn(desired, column),
+				// Generated values are computed for every row right away
+				Locking: column.Generated != "",
+			})
+			continue
+		}
+		if existing == nil {
+			added := column
+			var notNull []string
+			if opts.Backfill && needsBackfill(column) {
+				nullable := *column
+				nullable.NotNull = false
+				// Dialects that can't alter columns keep adding them NOT NULL
+				if notNull = d.AlterColumn(desired, &nullable, column); len(notNull) > 0 {
+					added = &nullable
+				}
+			}
+			changes = append(changes, Change{
+				Op:    OpCreate,
+				Kind:  KindColumn,
+				Table: desired.Name,
+				Name:  column.Name,
+				SQL:   d.AddColumn(desired, added),
+			})
+			for _, sql := range notNull {
+				changes = append(changes, Change{
+					Op:       OpAlter,
+					Kind:     KindColumn,
+					Table:    desired.Name,
+					Name:     column.Name,
+					SQL:      sql,
+					Locking:  true,
+					Backfill: true,
+				})
+			}
+			continue
+		}
+
+		for _, sql := range d.AlterColumn(desired, existing, column) {
+			changes = append(changes, Change{
+				Op:          OpAlter,
+				Kind:        KindColumn,
+				Table:       desired.Name,
+				Name:        column.Name,
+				SQL:         sql,
+				Destructive: narrowsType(existing.Type, column.Type),
+				// Changing the type rewrites the table, and SET NOT NULL
+				// scans it
+				Locking:  existing.Type != column.Type || column.NotNull && !existing.NotNull,
+				Backfill: opts.Backfill && column.NotNull && !existing.NotNull,
+			})
+		}
+	}
+
+	for _, constraint := range desired.Constraints {
+		if constraint.Type != schema.ForeignKey && (constraintChanged(constraint, current) || constraintUses(constraint, regenerated)) {
+			changes = append(changes, addExistingConstraint(d, desired, constraint, opts.NotValidChecks && constraint.Type == schema.Check)...)
+		}
+	}
+
+	for _, index := range desired.Indexes {
+		if other := current.Index(index.Name); other == nil || !equalIndexes(index, other) || indexUses(index, regenerated) {
+			changes = append(changes, Change{
+				Op:      OpCreate,
+				Kind:    KindIndex,
+				Table:   desired.Name,
+				Name:    index.Name,
+				SQL:     d.CreateIndex(desired, index, opts.ConcurrentIndexes),
+				Locking: !opts.ConcurrentIndexes,
+			})
+		}
+	}
+
+	for _, column := range current.Columns {
+		if desired.Column(column.Name) == nil {
+			changes = append(changes, Change{
+				Op:          OpDrop,
+				Kind:        KindColumn,
+				Table:       desired.Name,
+				Name:        column.Name,
+				SQL:         d.DropColumn(desired, column),
+				Destructive: true,
+			})
+		}
+	}
+
+	if current.Unlogged != desired.Unlogged {
+		changes = append(changes, persistenceChange(desired))
+	}
+	changes = append(changes, diffStorage(current, desired)...)
+
+	return changes
+}
+
+// Returns the columns that are generated from a different expression, or
+// stopped or started being generated. They can't be altered in place, so
+// they're dropped and added again
+func regeneratedColumns(current, desired *schema.Table) map[string]bool {
+	columns := map[string]bool{}
+	for _, column := range desired.Columns {
+		existing := current.Column(column.Name)
+		if existing != nil && schema.NormalizeExpr(existing.Generated) != schema.NormalizeExpr(column.Generated) {
+			columns[column.Name] = true
+		}
+	}
+	return columns
+}
+
+func indexUses(index *schema.Index, columns map[string]bool) bool {
+	return mentionsColumn(columns, slices.Concat(index.Keys, index.Include, []string{index.Where}))
+}
+
+func constraintUses(constraint *schema.Constraint, columns map[string]bool) bool {
+	return mentionsColumn(columns, slices.Concat(constraint.Columns, []string{constraint.Expression, constraint.Definition}))
+}
+
+// Reports whether one of the columns appears in the column names or
+// expressions, bare or quoted
+func mentionsColumn(columns map[string]bool, exprs []string) bool {
+	for name := range columns {
+		pattern := regexp.MustCompile(`(^|[^\w"])` + regexp.QuoteMeta(name) + `($|[^\w"])|` + regexp.QuoteMeta(schema.QuoteIdent(name)))
+		for _, expr := range exprs {
+			if pattern.MatchString(expr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func dropForeignKeys(d Dialect, current, desired *schema.Table) []Change {
+	var changes []Change
+	for _, constraint := range current.Constraints {
+		if constraint.Type == schema.ForeignKey && constraintChanged(constraint, desired) {
+			changes = append(changes, dropConstraintChange(d, desired, constraint))
+		}
+	}
+	return changes
+}
+
+func addForeignKeys(current, desired *schema.Table, opts Options) []Change {
+	d := opts.dialect()
+	var changes []Change
+	for _, constraint := range desired.Constraints {
+		if constraint.Type == schema.ForeignKey && constraintChanged(constraint, current) {
+			changes = append(changes, addExistingConstraint(d, desired, constraint, opts.NotValidForeignKeys)...)
+		}
+	}
+	return changes
+}
+
+// Adds a constraint to an existing table. With notValid, dialects
+// implementing ConstraintValidator add it NOT VALID and validate it in a
+// separate change, so the rows are checked without blocking writes
+func addExistingConstraint(d Dialect, table *schema.Table, constraint *schema.Constraint, notValid bool) []Change {
+	validator, ok := d.(ConstraintValidator)
+	if !notValid || !ok {
+		return []Change{validatedConstraintChange(d, table, constraint)}
+	}
+	return []Change{{
+		Op:    OpCreate,
+		Kind:  KindConstraint,
+		Table: table.Name,
+		Name:  constraint.Name,
+		SQL:   validator.AddConstraintNotValid(table, constraint),
+	}, {
+		Op:    OpAlter,
+		Kind:  KindConstraint,
+		Table: table.Name,
+		Name:  constraint.Name,
+		SQL:   validator.ValidateConstraint(table, constraint),
+	}}
+}
+
+// Reports whether the constraint is missing from the other table, or defined differently
+func constraintChanged(constraint *schema.Constraint, other *schema.Table) bool {
+	existing := other.Constraint(constraint.Name)
+	return existing == nil || !equalConstraints(constraint, existing)
+}
+
+func addConstraintChange(d Dialect, table *schema.Table, constraint *schema.Constraint) Change {
+	return Change{
+		Op:    OpCreate,
+		Kind:  KindConstraint,
+		Table: table.Name,
+		Name:  constraint.Name,
+		SQL:   d.AddConstraint(table, constraint),
+	}
+}
+
+// Adding a constraint to an existing table checks its rows, blocking writes
+// in the meantime
+func validatedConstraintChange(d Dialect, table *schema.Table, constraint *schema.Constraint) Change {
+	change := addConstraintChange(d, table, constraint)
+	change.Locking = true
+	return change
+}
+
+func dropConstraintChange(d Dialect, table *schema.Table, constraint *schema.Constraint) Change {
+	return Change{
+		Op:    OpDrop,
+		Kind:  KindConstraint,
+		Table: table.Name,
+		Name:  constraint.Name,
+		SQL:   d.DropConstraint(table, constraint),
+	}
+}
+
+func equalConstraints(a, b *schema.Constraint) bool {
+	if a.Type != b.Type || a.Deferrable != b.Deferrable || a.InitiallyDeferred != b.InitiallyDeferred {
+		return false
+	}
+
+	switch a.Type {
+	case schema.PrimaryKey, schema.Unique:
+		return slices.Equal(a.Columns, b.Columns)
+	case schema.ForeignKey:
+		return slices.Equal(a.Columns, b.Columns) &&
+			a.RefTable == b.RefTable &&
+			slices.Equal(a.RefColumns, b.RefColumns) &&
+			a.OnUpdate == b.OnUpdate &&
+			a.OnDelete == b.OnDelete &&
+			a.Match == b.Match
+	case schema.Check:
+		return schema.NormalizeExpr(a.Expression) == schema.NormalizeExpr(b.Expression)
+	case schema.Exclusion:
+		return schema.NormalizeConstraint(a.Definition) == schema.NormalizeConstraint(b.Definition)
+	}
+
+	return a.Definition == b.Definition
+}
+
+func equalIndexes(a, b *schema.Index) bool {
+	return a.Unique == b.Unique &&
+		a.Method == b.Method &&
+		slices.Equal(a.Keys, b.Keys) &&
+		slices.Equal(a.Include, b.Include) &&
+		schema.NormalizeExpr(a.Where) == schema.NormalizeExpr(b.Where)
+}
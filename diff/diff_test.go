@@ -0,0 +1,205 @@
+package diff
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"styx/schema"
+)
+
+func parse(t *testing.T, sql string) *schema.Schema {
+	t.Helper()
+	s, err := schema.Parse(sql)
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+	return s
+}
+
+func statements(changes []Change) []string {
+	var sql []string
+	for _, change := range changes {
+		sql = append(sql, change.SQL)
+	}
+	return sql
+}
+
+// Returns the index of the first statement starting with prefix
+func statementIndex(t *testing.T, sql []string, prefix string) int {
+	t.Helper()
+	i := slices.IndexFunc(sql, func(stmt string) bool { return strings.HasPrefix(stmt, prefix) })
+	if i < 0 {
+		t.Fatalf("no statement starting with %q in:\n%s", prefix, strings.Join(sql, "\n"))
+	}
+	return i
+}
+
+func TestDiffTablesWithTriggersAndPolicies(t *testing.T) {
+	s := `
+CREATE TABLE t (a int);
+CREATE FUNCTION audit() RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN RETURN NEW; END $$;
+CREATE TRIGGER t_audit BEFORE INSERT ON t FOR EACH ROW EXECUTE FUNCTION audit();
+CREATE POLICY p ON t USING (a = 1);
+`
+	tests := []struct {
+		name             string
+		current, desired string
+		want             []string
+	}{
+		{
+			name:    "create",
+			desired: s,
+			want:    []string{"CREATE OR REPLACE FUNCTION audit", "CREATE TABLE t", "CREATE TRIGGER", "CREATE POLICY"},
+		},
+		{
+			// Triggers and policies go away with their table
+			name:    "drop",
+			current: s,
+			want:    []string{"DROP TABLE t", "DROP FUNCTION audit"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := statements(Diff(parse(t, tt.current), parse(t, tt.desired), Options{}))
+			last := -1
+			for _, prefix := range tt.want {
+				i := statementIndex(t, sql, prefix)
+				if i < last {
+					t.Errorf("%s comes too early in:\n%s", prefix, strings.Join(sql, "\n"))
+				}
+				last = i
+			}
+		})
+	}
+}
+
+func TestDiffOrdersAcrossKinds(t *testing.T) {
+	s := `
+CREATE TABLE users (id int PRIMARY KEY, name text);
+CREATE FUNCTION active() RETURNS SETOF users LANGUAGE sql AS $$ SELECT * FROM users $$;
+CREATE FUNCTION greet(u users) RETURNS text LANGUAGE sql AS $$ SELECT 'hi ' || u.name $$;
+CREATE TYPE pair AS (a users, b users);
+CREATE DOMAIN positive AS integer CHECK (VALUE > 0);
+CREATE TYPE wrapper AS (p positive);
+`
+	tests := []struct {
+		name             string
+		current, desired string
+		want             [][2]string
+	}{
+		{
+			name:    "create",
+			desired: s,
+			want: [][2]string{
+				{"CREATE TABLE users", "CREATE OR REPLACE FUNCTION active"},
+				{"CREATE TABLE users", "CREATE OR REPLACE FUNCTION greet"},
+				{"CREATE TABLE users", "CREATE TYPE pair"},
+				{"CREATE DOMAIN positive", "CREATE TYPE wrapper"},
+			},
+		},
+		{
+			name:    "drop",
+			current: s,
+			want: [][2]string{
+				{"DROP FUNCTION active", "DROP TABLE users"},
+				{"DROP FUNCTION greet", "DROP TABLE users"},
+				{"DROP TYPE pair", "DROP TABLE users"},
+				{"DROP TYPE wrapper", "DROP DOMAIN positive"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := statements(Diff(parse(t, tt.current), parse(t, tt.desired), Options{}))
+			for _, pair := range tt.want {
+				if statementIndex(t, sql, pair[0]) > statementIndex(t, sql, pair[1]) {
+					t.Errorf("%s comes after %s in:\n%s", pair[0], pair[1], strings.Join(sql, "\n"))
+				}
+			}
+		})
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "tables referencing each other",
+			sql:  "CREATE TABLE a (id int PRIMARY KEY, b_id int); CREATE TABLE b (id int PRIMARY KEY, a_id int REFERENCES a); ALTER TABLE a ADD FOREIGN KEY (b_id) REFERENCES b;",
+		},
+		{
+			name: "types containing each other",
+			sql:  "CREATE TYPE a AS (b b); CREATE TYPE b AS (a a);",
+			want: "dependency cycle: type a -> type b -> type a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckDependencies(parse(t, tt.sql))
+			var cycle *CycleError
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.want != "" && !errors.As(err, &cycle):
+				t.Errorf("got %v, want a *CycleError", err)
+			case tt.want != "" && err.Error() != tt.want:
+				t.Errorf("got %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffIdentityColumns(t *testing.T) {
+	tests := []struct {
+		name             string
+		current, desired string
+		want             []string
+	}{
+		{
+			name:    "add identity",
+			current: "CREATE TABLE t (a int);",
+			desired: "CREATE TABLE t (a int GENERATED ALWAYS AS IDENTITY);",
+			want: []string{
+				"ALTER TABLE t ALTER COLUMN a SET NOT NULL;",
+				"ALTER TABLE t ALTER COLUMN a ADD GENERATED ALWAYS AS IDENTITY;",
+			},
+		},
+		{
+			name:    "drop identity",
+			current: "CREATE TABLE t (a int GENERATED ALWAYS AS IDENTITY);",
+			desired: "CREATE TABLE t (a int);",
+			want: []string{
+				"ALTER TABLE t ALTER COLUMN a DROP IDENTITY;",
+				"ALTER TABLE t ALTER COLUMN a DROP NOT NULL;",
+			},
+		},
+		{
+			name:    "replace default with identity",
+			current: "CREATE TABLE t (a int NOT NULL DEFAULT 0);",
+			desired: "CREATE TABLE t (a int GENERATED BY DEFAULT AS IDENTITY);",
+			want: []string{
+				"ALTER TABLE t ALTER COLUMN a DROP DEFAULT;",
+				"ALTER TABLE t ALTER COLUMN a ADD GENERATED BY DEFAULT AS IDENTITY;",
+			},
+		},
+		{
+			name:    "change identity",
+			current: "CREATE TABLE t (a int GENERATED ALWAYS AS IDENTITY);",
+			desired: "CREATE TABLE t (a int GENERATED BY DEFAULT AS IDENTITY);",
+			want:    []string{"ALTER TABLE t ALTER COLUMN a SET GENERATED BY DEFAULT;"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := statements(Diff(parse(t, tt.current), parse(t, tt.desired), Options{}))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
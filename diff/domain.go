@@ -0,0 +1,120 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"styx/schema"
+)
+
+// Creates new domains and brings existing ones up to date
+func diffDomains(current, desired *schema.Schema) []Change {
+	var changes []Change
+
+	for _, domain := range desired.Domains {
+		existing := current.Domain(domain.Name)
+		switch {
+		case existing == nil:
+			changes = append(changes, Change{
+				Op:   OpCreate,
+				Kind: KindDomain,
+				Name: domain.Name,
+				SQL:  createDomainSQL(domain),
+			})
+		case existing.Type != domain.Type:
+			changes = append(changes, Change{
+				Op:          OpAlter,
+				Kind:        KindDomain,
+				Name:        domain.Name,
+				SQL:         recreateDomainSQL(current, domain),
+				Destructive: narrowsType(existing.Type, domain.Type),
+				Locking:     true,
+			})
+		default:
+			changes = append(changes, alterDomain(existing, domain)...)
+		}
+	}
+
+	return changes
+}
+
+func dropDomains(current, desired *schema.Schema) []Change {
+	var changes []Change
+	for _, domain := range current.Domains {
+		if desired.Domain(domain.Name) == nil {
+			changes = append(changes, Change{
+				Op:   OpDrop,
+				Kind: KindDomain,
+				Name: domain.Name,
+				SQL:  fmt.Sprintf("DROP DOMAIN %s;", schema.QuoteName(domain.Name)),
+			})
+		}
+	}
+	return changes
+}
+
+func createDomainSQL(domain *schema.Domain) string {
+	sql := fmt.Sprintf("CREATE DOMAIN %s AS %s", schema.QuoteName(domain.Name), domain.Type)
+	if domain.Default != "" {
+		sql += " DEFAULT " + domain.Default
+	}
+	if domain.NotNull {
+		sql += " NOT NULL"
+	}
+	for _, check := range domain.Checks {
+		sql += fmt.Sprintf(" CONSTRAINT %s CHECK (%s)", schema.QuoteIdent(check.Name), check.Expression)
+	}
+	return sql + ";"
+}
+
+// Checks that changed are dropped and added again. Adding a check or NOT NULL
+// validates every column using the domain, blocking writes to their tables
+func alterDomain(current, desired *schema.Domain) []Change {
+	prefix := "ALTER DOMAIN " + schema.QuoteName(desired.Name)
+	alter := func(sql string, locking bool) Change {
+		return Change{Op: OpAlter, Kind: KindDomain, Name: desired.Name, SQL: prefix + " " + sql + ";", Locking: locking}
+	}
+
+	var changes []Change
+	for _, check := range current.Checks {
+		if other := desired.Check(check.Name); other == nil || !equalConstraints(check, other) {
+			changes = append(changes, alter("DROP CONSTRAINT "+schema.QuoteIdent(check.Name), false))
+		}
+	}
+
+	if schema.NormalizeExpr(current.Default) != schema.NormalizeExpr(desired.Default) {
+		if desired.Default == "" {
+			changes = append(changes, alter("DROP DEFAULT", false))
+		} else {
+			changes = append(changes, alter("SET DEFAULT "+desired.Default, false))
+		}
+	}
+
+	if current.NotNull != desired.NotNull {
+		if desired.NotNull {
+			changes = append(changes, alter("SET NOT NULL", true))
+		} else {
+			changes = append(changes, alter("DROP NOT NULL", false))
+		}
+	}
+
+	for _, check := range desired.Checks {
+		if other := current.Check(check.Name); other == nil || !equalConstraints(check, other) {
+			changes = append(changes, alter(fmt.Sprintf("ADD CONSTRAINT %s CHECK (%s)", schema.QuoteIdent(check.Name), check.Expression), true))
+		}
+	}
+
+	return changes
+}
+
+// Postgres can't change the base type of a domain, so it's swapped out the
+// same way enums are, converting the columns through the new base type
+func recreateDomainSQL(current *schema.Schema, desired *schema.Domain) string {
+	statements := []string{
+		renameSQL("DOMAIN", desired.Name, desired.Name+"_old"),
+		createDomainSQL(desired),
+	}
+	statements = append(statements, convertColumnsSQL(current, desired.Name, castThrough(desired.Name, desired.Type))...)
+	statements = append(statements, fmt.Sprintf("DROP DOMAIN %s;", schema.QuoteName(desired.Name+"_old")))
+	return strings.Join(statements, "\n")
+}
@@ -0,0 +1,158 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"styx/schema"
+)
+
+// Creates new enums and brings existing ones up to date
+func diffEnums(current, desired *schema.Schema) []Change {
+	var changes []Change
+
+	for _, enum := range desired.Enums {
+		existing := current.Enum(enum.Name)
+		switch {
+		case existing == nil:
+			changes = append(changes, Change{
+				Op:   OpCreate,
+				Kind: KindEnum,
+				Name: enum.Name,
+				SQL:  createEnumSQL(enum.Name, enum.Values),
+			})
+		case slices.Equal(existing.Values, enum.Values):
+			continue
+		case isSubsequence(existing.Values, enum.Values):
+			for _, sql := range addEnumValuesSQL(existing, enum) {
+				changes = append(changes, Change{
+					Op:   OpAlter,
+					Kind: KindEnum,
+					Name: enum.Name,
+					SQL:  sql,
+				})
+			}
+		default:
+			changes = append(changes, Change{
+				Op:   OpAlter,
+				Kind: KindEnum,
+				Name: enum.Name,
+				SQL:  recreateEnumSQL(current, enum),
+			})
+		}
+	}
+
+	return changes
+}
+
+func dropEnums(current, desired *schema.Schema) []Change {
+	var changes []Change
+	for _, enum := range current.Enums {
+		if desired.Enum(enum.Name) == nil {
+			changes = append(changes, Change{
+				Op:   OpDrop,
+				Kind: KindEnum,
+				Name: enum.Name,
+				SQL:  fmt.Sprintf("DROP TYPE %s;", schema.QuoteName(enum.Name)),
+			})
+		}
+	}
+	return changes
+}
+
+func createEnumSQL(name string, values []string) string {
+	literals := make([]string, len(values))
+	for i, value := range values {
+		literals[i] = schema.QuoteLiteral(value)
+	}
+	return fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", schema.QuoteName(name), strings.Join(literals, ", "))
+}
+
+// Adds the missing values, each positioned relative to its neighbour
+func addEnumValuesSQL(current, desired *schema.Enum) []string {
+	var statements []string
+	for i, value := range desired.Values {
+		if slices.Contains(current.Values, value) {
+			continue
+		}
+
+		sql := fmt.Sprintf("ALTER TYPE %s ADD VALUE %s", schema.QuoteName(desired.Name), schema.QuoteLiteral(value))
+		switch {
+		case i == len(desired.Values)-1:
+			// New values go last by default
+		case i > 0:
+			sql += " AFTER " + schema.QuoteLiteral(desired.Values[i-1])
+		default:
+			sql += " BEFORE " + schema.QuoteLiteral(desired.Values[i+1])
+		}
+		statements = append(statements, sql+";")
+	}
+	return statements
+}
+
+// Postgres can't remove or reorder enum values, so the type is swapped out:
+// the old type is renamed, the new one created under the original name, and
+// every column using it is converted through text
+func recreateEnumSQL(current *schema.Schema, desired *schema.Enum) string {
+	statements := []string{
+		renameSQL("TYPE", desired.Name, desired.Name+"_old"),
+		createEnumSQL(desired.Name, desired.Values),
+	}
+	statements = append(statements, convertColumnsSQL(current, desired.Name, castThrough(desired.Name, "text"))...)
+	statements = append(statements, fmt.Sprintf("DROP TYPE %s;", schema.QuoteName(desired.Name+"_old")))
+	return strings.Join(statements, "\n")
+}
+
+// Converts the columns using the type, or arrays of it, to the type that
+// replaced it under the same name. using renders the expression converting
+// a column's values, or returns an empty string if they can't be
+func convertColumnsSQL(current *schema.Schema, typeName string, using func(column string, array bool) string) []string {
+	name := schema.QuoteName(typeName)
+
+	var statements []string
+	for _, table := range current.Tables {
+		for _, column := range table.Columns {
+			if column.Type != name && column.Type != name+"[]" {
+				continue
+			}
+			expr := using(schema.QuoteIdent(column.Name), column.Type != name)
+			if expr == "" {
+				continue
+			}
+
+			prefix := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s", schema.QuoteName(table.Name), schema.QuoteIdent(column.Name))
+			// Defaults are bound to the old type and would block the conversion
+			if column.Default != "" {
+				statements = append(statements, prefix+" DROP DEFAULT;")
+			}
+			statements = append(statements, fmt.Sprintf("%s TYPE %s USING %s;", prefix, column.Type, expr))
+			if column.Default != "" {
+				statements = append(statements, fmt.Sprintf("%s SET DEFAULT %s;", prefix, column.Default))
+			}
+		}
+	}
+	return statements
+}
+
+// Converts values by casting them to via, and from there to the new type
+func castThrough(typeName, via string) func(column string, array bool) string {
+	name := schema.QuoteName(typeName)
+	return func(column string, array bool) string {
+		if array {
+			return column + "::" + via + "[]::" + name + "[]"
+		}
+		return column + "::" + via + "::" + name
+	}
+}
+
+// Reports whether all elements of a appear in b, in the same order
+func isSubsequence(a, b []string) bool {
+	i := 0
+	for _, value := range b {
+		if i < len(a) && a[i] == value {
+			i++
+		}
+	}
+	return i == len(a)
+}
@@ -0,0 +1,60 @@
+package diff
+
+import (
+	"fmt"
+
+	"styx/schema"
+)
+
+// Creates the extensions that are new, moves the ones whose schema changed,
+// and drops the ones that went away. Drops come last, once nothing uses them
+func diffExtensions(current, desired *schema.Schema) (changes, drops []Change) {
+	for _, extension := range desired.Extensions {
+		existing := current.Extension(extension.Name)
+		switch {
+		case existing == nil:
+			changes = append(changes, Change{
+				Op:   OpCreate,
+				Kind: KindExtension,
+				Name: extension.Name,
+				SQL:  createExtensionSQL(extension),
+			})
+		case existing.Schema != extension.Schema:
+			changes = append(changes, Change{
+				Op:   OpAlter,
+				Kind: KindExtension,
+				Name: extension.Name,
+				SQL:  fmt.Sprintf("ALTER EXTENSION %s SET SCHEMA %s;", schema.QuoteIdent(extension.Name), schema.QuoteIdent(extensionSchema(extension))),
+			})
+		}
+	}
+
+	for _, extension := range current.Extensions {
+		if desired.Extension(extension.Name) == nil {
+			drops = append(drops, Change{
+				Op:   OpDrop,
+				Kind: KindExtension,
+				Name: extension.Name,
+				SQL:  fmt.Sprintf("DROP EXTENSION %s;", schema.QuoteIdent(extension.Name)),
+				// Extensions like postgis keep data in their own tables
+				Destructive: true,
+			})
+		}
+	}
+	return changes, drops
+}
+
+func createExtensionSQL(extension *schema.Extension) string {
+	sql := "CREATE EXTENSION " + schema.QuoteIdent(extension.Name)
+	if extension.Schema != "" {
+		sql += " WITH SCHEMA " + schema.QuoteIdent(extension.Schema)
+	}
+	return sql + ";"
+}
+
+func extensionSchema(extension *schema.Extension) string {
+	if extension.Schema == "" {
+		return "public"
+	}
+	return extension.Schema
+}
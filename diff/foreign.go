@@ -0,0 +1,266 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"styx/schema"
+)
+
+// Creates and alters foreign servers, user mappings and foreign tables.
+// Servers whose wrapper or type changed are dropped and created again along
+// with their user mappings and foreign tables, as are foreign tables moved
+// to another server. Foreign tables hold no rows, so nothing is lost. The
+// objects that went away are dropped in drops, once nothing uses them
+func diffForeignData(current, desired *schema.Schema) (changes, drops []Change) {
+	recreated := map[string]bool{}
+	for _, server := range desired.Servers {
+		if existing := current.Server(server.Name); existing != nil && recreatesServer(existing, server) {
+			recreated[server.Name] = true
+		}
+	}
+
+	for _, server := range current.Servers {
+		if !recreated[server.Name] {
+			continue
+		}
+		for _, table := range current.ForeignTables {
+			if table.Server == server.Name {
+				changes = append(changes, dropForeignTable(table))
+			}
+		}
+		for _, mapping := range current.UserMappings {
+			if mapping.Server == server.Name {
+				changes = append(changes, dropUserMapping(mapping))
+			}
+		}
+		changes = append(changes, dropServer(server))
+	}
+
+	for _, server := range desired.Servers {
+		existing := current.Server(server.Name)
+		if existing == nil || recreated[server.Name] {
+			changes = append(changes, serverChange(OpCreate, server.Name, createServerSQL(server)))
+			continue
+		}
+		if existing.Version != server.Version {
+			changes = append(changes, serverChange(OpAlter, server.Name,
+				fmt.Sprintf("ALTER SERVER %s VERSION %s;", schema.QuoteIdent(server.Name), schema.QuoteLiteral(server.Version))))
+		}
+		if options := alterOptions(existing.Options, server.Options); options != "" {
+			changes = append(changes, serverChange(OpAlter, server.Name,
+				fmt.Sprintf("ALTER SERVER %s %s;", schema.QuoteIdent(server.Name), options)))
+		}
+	}
+
+	for _, mapping := range desired.UserMappings {
+		existing := current.UserMapping(mapping.Server, mapping.User)
+		if existing == nil || recreated[mapping.Server] {
+			changes = append(changes, userMappingChange(OpCreate, mapping,
+				fmt.Sprintf("CREATE USER MAPPING FOR %s SERVER %s%s;", schema.QuoteRole(mapping.User), schema.QuoteIdent(mapping.Server), createOptions(mapping.Options))))
+			continue
+		}
+		if options := alterOptions(existing.Options, mapping.Options); options != "" {
+			changes = append(changes, userMappingChange(OpAlter, mapping,
+				fmt.Sprintf("ALTER USER MAPPING FOR %s SERVER %s %s;", schema.QuoteRole(mapping.User), schema.QuoteIdent(mapping.Server), options)))
+		}
+	}
+
+	for _, table := range desired.ForeignTables {
+		existing := current.ForeignTable(table.Name)
+		switch {
+		case existing == nil || recreated[table.Server]:
+			changes = append(changes, createForeignTable(table))
+		case existing.Server != table.Server:
+			changes = append(changes, dropForeignTable(existing), createForeignTable(table))
+		default:
+			changes = append(changes, alterForeignTable(existing, table)...)
+		}
+	}
+
+	for _, table := range current.ForeignTables {
+		if desired.ForeignTable(table.Name) == nil && !recreated[table.Server] {
+			drops = append(drops, dropForeignTable(table))
+		}
+	}
+	for _, mapping := range current.UserMappings {
+		if desired.UserMapping(mapping.Server, mapping.User) == nil && !recreated[mapping.Server] {
+			drops = append(drops, dropUserMapping(mapping))
+		}
+	}
+	for _, server := range current.Servers {
+		if desired.Server(server.Name) == nil {
+			drops = append(drops, dropServer(server))
+		}
+	}
+	return changes, drops
+}
+
+// Reports whether the server can't be altered into desired. The version can
+// be changed but not removed
+func recreatesServer(current, desired *schema.Server) bool {
+	return current.Wrapper != desired.Wrapper || current.Type != desired.Type ||
+		(desired.Version == "" && current.Version != "")
+}
+
+// Reports whether the foreign table is dropped, or loses or retypes a
+// column, which Postgres refuses to do while a view reads from it
+func foreignTableChanged(current, desired *schema.Schema, table *schema.ForeignTable) bool {
+	other := desired.ForeignTable(table.Name)
+	if other == nil || other.Server != table.Server {
+		return true
+	}
+	if server, existing := desired.Server(table.Server), current.Server(table.Server); server != nil && existing != nil && recreatesServer(existing, server) {
+		return true
+	}
+	for _, column := range table.Columns {
+		if otherColumn := other.Column(column.Name); otherColumn == nil || otherColumn.Type != column.Type {
+			return true
+		}
+	}
+	return false
+}
+
+func alterForeignTable(current, desired *schema.ForeignTable) []Change {
+	var changes []Change
+	alter := func(action string) {
+		changes = append(changes, foreignTableChange(OpAlter, desired.Name,
+			fmt.Sprintf("ALTER FOREIGN TABLE %s %s;", schema.QuoteName(desired.Name), action)))
+	}
+
+	for _, column := range current.Columns {
+		if desired.Column(column.Name) == nil {
+			alter("DROP COLUMN " + schema.QuoteIdent(column.Name))
+		}
+	}
+	for _, column := range desired.Columns {
+		existing := current.Column(column.Name)
+		if existing == nil {
+			alter("ADD COLUMN " + foreignColumnDefinition(column))
+			continue
+		}
+		name := schema.QuoteIdent(column.Name)
+		if existing.Type != column.Type {
+			alter(fmt.Sprintf("ALTER COLUMN %s TYPE %s", name, column.Type))
+		}
+		if existing.NotNull != column.NotNull {
+			if column.NotNull {
+				alter(fmt.Sprintf("ALTER COLUMN %s SET NOT NULL", name))
+			} else {
+				alter(fmt.Sprintf("ALTER COLUMN %s DROP NOT NULL", name))
+			}
+		}
+		if options := alterOptions(existing.Options, column.Options); options != "" {
+			alter(fmt.Sprintf("ALTER COLUMN %s %s", name, options))
+		}
+	}
+	if options := alterOptions(current.Options, desired.Options); options != "" {
+		alter(options)
+	}
+	return changes
+}
+
+func createForeignTable(table *schema.ForeignTable) Change {
+	var lines []string
+	for _, column := range table.Columns {
+		lines = append(lines, "    "+foreignColumnDefinition(column))
+	}
+	return foreignTableChange(OpCreate, table.Name, fmt.Sprintf("CREATE FOREIGN TABLE %s (\n%s\n) SERVER %s%s;",
+		schema.QuoteName(table.Name), strings.Join(lines, ",\n"), schema.QuoteIdent(table.Server), createOptions(table.Options)))
+}
+
+func foreignColumnDefinition(column *schema.ForeignColumn) string {
+	definition := schema.QuoteIdent(column.Name) + " " + column.Type
+	if len(column.Options) > 0 {
+		definition += createOptions(column.Options)
+	}
+	if column.NotNull {
+		definition += " NOT NULL"
+	}
+	return definition
+}
+
+func dropForeignTable(table *schema.ForeignTable) Change {
+	return foreignTableChange(OpDrop, table.Name, fmt.Sprintf("DROP FOREIGN TABLE %s;", schema.QuoteName(table.Name)))
+}
+
+func dropUserMapping(mapping *schema.UserMapping) Change {
+	return userMappingChange(OpDrop, mapping,
+		fmt.Sprintf("DROP USER MAPPING FOR %s SERVER %s;", schema.QuoteRole(mapping.User), schema.QuoteIdent(mapping.Server)))
+}
+
+func dropServer(server *schema.Server) Change {
+	return serverChange(OpDrop, server.Name, fmt.Sprintf("DROP SERVER %s;", schema.QuoteIdent(server.Name)))
+}
+
+func createServerSQL(server *schema.Server) string {
+	sql := "CREATE SERVER " + schema.QuoteIdent(server.Name)
+	if server.Type != "" {
+		sql += " TYPE " + schema.QuoteLiteral(server.Type)
+	}
+	if server.Version != "" {
+		sql += " VERSION " + schema.QuoteLiteral(server.Version)
+	}
+	return sql + " FOREIGN DATA WRAPPER " + schema.QuoteIdent(server.Wrapper) + createOptions(server.Options) + ";"
+}
+
+func serverChange(op Op, name, sql string) Change {
+	return Change{Op: op, Kind: KindServer, Name: name, SQL: sql}
+}
+
+func userMappingChange(op Op, mapping *schema.UserMapping, sql string) Change {
+	return Change{Op: op, Kind: KindUserMapping, Name: mapping.User + " on " + mapping.Server, SQL: sql}
+}
+
+func foreignTableChange(op Op, name, sql string) Change {
+	return Change{Op: op, Kind: KindForeignTable, Name: name, SQL: sql}
+}
+
+// Renders the OPTIONS clause creating an object with the name=value options
+func createOptions(options []string) string {
+	if len(options) == 0 {
+		return ""
+	}
+	rendered := make([]string, len(options))
+	for i, option := range options {
+		name, value, _ := strings.Cut(option, "=")
+		rendered[i] = schema.QuoteIdent(name) + " " + schema.QuoteLiteral(value)
+	}
+	return " OPTIONS (" + strings.Join(rendered, ", ") + ")"
+}
+
+// Renders the OPTIONS clause turning the options of an object from current
+// into desired, empty if they're the same
+func alterOptions(current, desired []string) string {
+	values := func(options []string) map[string]string {
+		m := map[string]string{}
+		for _, option := range options {
+			name, value, _ := strings.Cut(option, "=")
+			m[name] = value
+		}
+		return m
+	}
+	currentValues, desiredValues := values(current), values(desired)
+
+	var actions []string
+	for _, option := range desired {
+		name, value, _ := strings.Cut(option, "=")
+		existing, ok := currentValues[name]
+		switch {
+		case !ok:
+			actions = append(actions, "ADD "+schema.QuoteIdent(name)+" "+schema.QuoteLiteral(value))
+		case existing != value:
+			actions = append(actions, "SET "+schema.QuoteIdent(name)+" "+schema.QuoteLiteral(value))
+		}
+	}
+	for _, option := range current {
+		name, _, _ := strings.Cut(option, "=")
+		if _, ok := desiredValues[name]; !ok {
+			actions = append(actions, "DROP "+schema.QuoteIdent(name))
+		}
+	}
+	if len(actions) == 0 {
+		return ""
+	}
+	return "OPTIONS (" + strings.Join(actions, ", ") + ")"
+}
@@ -0,0 +1,126 @@
+package diff
+
+import (
+	"fmt"
+
+	"styx/schema"
+)
+
+// Returns the statements creating or replacing the desired functions, and
+// the ones dropping the functions that went away. Functions using a
+// composite type that's replaced were dropped along with it
+func diffFunctions(current, desired *schema.Schema) (changes, drops []Change) {
+	retyped := recreatedTypes(current, desired)
+
+	for _, function := range desired.Functions {
+		existing := current.Function(function.Name, function.Args)
+		if existing != nil && usesType(existing, retyped) {
+			existing = nil
+		}
+		if existing != nil && existing.Definition == function.Definition {
+			continue
+		}
+
+		if existing != nil && !replaceable(existing, function) {
+			changes = append(changes, dropFunctionChange(existing))
+		}
+		op := OpCreate
+		if existing != nil {
+			op = OpAlter
+		}
+		changes = append(changes, Change{
+			Op:   op,
+			Kind: KindFunction,
+			Name: function.Name,
+			SQL:  function.Definition + ";",
+		})
+	}
+
+	for _, function := range current.Functions {
+		if desired.Function(function.Name, function.Args) == nil && !usesType(function, retyped) {
+			drops = append(drops, dropFunctionChange(function))
+		}
+	}
+
+	return changes, drops
+}
+
+// CREATE OR REPLACE can't change what a function returns, nor turn it into
+// a procedure
+func replaceable(current, desired *schema.Function) bool {
+	return current.Procedure == desired.Procedure && current.Returns == desired.Returns
+}
+
+// Lists the functions that are dropped and created again, rather than replaced
+func recreatedFunctions(current, desired *schema.Schema) map[string]bool {
+	retyped := recreatedTypes(current, desired)
+	recreated := map[string]bool{}
+	for _, function := range current.Functions {
+		other := desired.Function(function.Name, function.Args)
+		if other == nil || !replaceable(function, other) || usesType(function, retyped) {
+			recreated[function.Name] = true
+		}
+	}
+	return recreated
+}
+
+func dropFunctionChange(function *schema.Function) Change {
+	kind := "FUNCTION"
+	if function.Procedure {
+		kind = "PROCEDURE"
+	}
+	return Change{
+		Op:   OpDrop,
+		Kind: KindFunction,
+		Name: function.Name,
+		SQL:  fmt.Sprintf("DROP %s %s(%s);", kind, schema.QuoteName(function.Name), function.Args),
+	}
+}
+
+// Returns the statements dropping the triggers that changed or went away, and
+// the ones creating the triggers that are new or changed. Triggers executing a
+// function that is dropped are recreated along with it
+func diffTriggers(current, desired *schema.Schema) (drops, creates []Change) {
+	recreated := recreatedFunctions(current, desired)
+
+	for _, table := range current.Tables {
+		other := desired.Table(table.Name)
+		if other == nil {
+			continue
+		}
+		for _, trigger := range table.Triggers {
+			if triggerChanged(trigger, other) || recreated[trigger.Function] {
+				drops = append(drops, Change{
+					Op:    OpDrop,
+					Kind:  KindTrigger,
+					Table: table.Name,
+					Name:  trigger.Name,
+					SQL:   fmt.Sprintf("DROP TRIGGER %s ON %s;", schema.QuoteIdent(trigger.Name), schema.QuoteName(table.Name)),
+				})
+			}
+		}
+	}
+
+	for _, table := range desired.Tables {
+		existing := current.Table(table.Name)
+		for _, trigger := range table.Triggers {
+			if existing == nil || triggerChanged(trigger, existing) || recreated[trigger.Function] {
+				creates = append(creates, Change{
+					Op:    OpCreate,
+					Kind:  KindTrigger,
+					Table: table.Name,
+					Name:  trigger.Name,
+					SQL:   trigger.Definition + ";",
+				})
+			}
+		}
+	}
+
+	return drops, creates
+}
+
+// Reports whether the trigger is missing from the other table, or defined differently
+func triggerChanged(trigger *schema.Trigger, other *schema.Table) bool {
+	existing := other.Trigger(trigger.Name)
+	return existing == nil || existing.Definition != trigger.Definition
+}
@@ -0,0 +1,65 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"styx/schema"
+)
+
+// Grants the privileges roles are missing, and revokes the ones they
+// shouldn't have. Views in recreated lose their privileges when they're
+// dropped, so they're granted again
+func diffGrants(current, desired *schema.Schema, recreated map[string]bool) []Change {
+	var changes []Change
+
+	for _, grant := range desired.Grants {
+		var existing []string
+		if g := current.Grant(grant.Object, grant.Role); g != nil && !recreated[grant.Object] {
+			existing = g.Privileges
+		}
+		if missing := subtract(grant.Privileges, existing); len(missing) > 0 {
+			changes = append(changes, Change{
+				Op:    OpCreate,
+				Kind:  KindGrant,
+				Table: grant.Object,
+				Name:  grant.Role,
+				SQL:   fmt.Sprintf("GRANT %s ON %s TO %s;", strings.Join(missing, ", "), schema.QuoteName(grant.Object), schema.QuoteRole(grant.Role)),
+			})
+		}
+	}
+
+	for _, grant := range current.Grants {
+		// Dropped objects take their privileges with them
+		if recreated[grant.Object] || desired.Table(grant.Object) == nil && desired.View(grant.Object) == nil {
+			continue
+		}
+		var kept []string
+		if g := desired.Grant(grant.Object, grant.Role); g != nil {
+			kept = g.Privileges
+		}
+		if extra := subtract(grant.Privileges, kept); len(extra) > 0 {
+			changes = append(changes, Change{
+				Op:    OpDrop,
+				Kind:  KindGrant,
+				Table: grant.Object,
+				Name:  grant.Role,
+				SQL:   fmt.Sprintf("REVOKE %s ON %s FROM %s;", strings.Join(extra, ", "), schema.QuoteName(grant.Object), schema.QuoteRole(grant.Role)),
+			})
+		}
+	}
+
+	return changes
+}
+
+// Returns the values of a missing from b
+func subtract(a, b []string) []string {
+	var missing []string
+	for _, value := range a {
+		if !slices.Contains(b, value) {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
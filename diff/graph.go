@@ -0,0 +1,362 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"styx/schema"
+)
+
+// Object identifies a node of a dependency graph. Triggers and policies are
+// named after their table, like "users.audit"
+type Object struct {
+	Kind Kind
+	Name string
+}
+
+func (o Object) String() string {
+	return string(o.Kind) + " " + o.Name
+}
+
+// Graph holds the dependencies between the objects of a schema: tables on
+// the tables their foreign keys reference and the types of their columns,
+// views on the relations they read from, types on the types they're made
+// of, functions on the types of their arguments and results, triggers on
+// their table and function, and foreign tables and user mappings on their
+// server. Function bodies aren't followed
+type Graph struct {
+	objects []Object
+	edges   map[Object][]edge
+}
+
+type edge struct {
+	to Object
+	// foreignKey is set for the edges of foreign keys, which can be added
+	// once both tables exist to break a cycle
+	foreignKey *schema.Constraint
+}
+
+// CycleError reports objects that depend on each other in a cycle, so they
+// can't be created in any order
+type CycleError struct {
+	// Cycle lists the objects of the cycle, starting and ending with the
+	// same one
+	Cycle []Object
+}
+
+func (e *CycleError) Error() string {
+	names := make([]string, len(e.Cycle))
+	for i, o := range e.Cycle {
+		names[i] = o.String()
+	}
+	return "dependency cycle: " + strings.Join(names, " -> ")
+}
+
+// CheckDependencies returns a *CycleError if objects of the schema depend
+// on each other in a cycle that no statement order can satisfy
+func CheckDependencies(s *schema.Schema) error {
+	_, _, err := NewGraph(s).Sort()
+	return err
+}
+
+// NewGraph builds the dependency graph of the objects of s. Dependencies on
+// objects outside of s are left out
+func NewGraph(s *schema.Schema) *Graph {
+	g := &Graph{edges: map[Object][]edge{}}
+
+	// Tables double as the row type of their rows
+	typeObject := func(typeName string) (Object, bool) {
+		typeName = strings.TrimPrefix(typeName, "SETOF ")
+		typeName = strings.TrimPrefix(typeName, "VARIADIC ")
+		if i := strings.IndexAny(typeName, "(["); i >= 0 {
+			typeName = typeName[:i]
+		}
+		typeName = strings.TrimSpace(typeName)
+		switch {
+		case s.Enum(typeName) != nil:
+			return Object{KindEnum, typeName}, true
+		case s.Domain(typeName) != nil:
+			return Object{KindDomain, typeName}, true
+		case s.CompositeType(typeName) != nil:
+			return Object{KindType, typeName}, true
+		case s.Table(typeName) != nil:
+			return Object{KindTable, typeName}, true
+		}
+		return Object{}, false
+	}
+	dependsOnType := func(o Object, typeName string) {
+		if to, ok := typeObject(typeName); ok {
+			g.add(o, edge{to: to})
+		}
+	}
+
+	for _, enum := range s.Enums {
+		g.node(Object{KindEnum, enum.Name})
+	}
+	for _, domain := range s.Domains {
+		o := g.node(Object{KindDomain, domain.Name})
+		dependsOnType(o, domain.Type)
+	}
+	for _, typ := range s.CompositeTypes {
+		o := g.node(Object{KindType, typ.Name})
+		for _, attribute := range typ.Attributes {
+			dependsOnType(o, attribute.Type)
+		}
+	}
+	for _, function := range s.Functions {
+		o := g.node(Object{KindFunction, function.Name})
+		for _, arg := range strings.Split(function.Args, ",") {
+			dependsOnType(o, arg)
+		}
+		dependsOnType(o, function.Returns)
+	}
+	for _, server := range s.Servers {
+		g.node(Object{KindServer, server.Name})
+	}
+	for _, table := range s.Tables {
+		o := g.node(Object{KindTable, table.Name})
+		for _, column := range table.Columns {
+			dependsOnType(o, column.Type)
+		}
+		for _, constraint := range table.Constraints {
+			if constraint.Type == schema.ForeignKey && s.Table(constraint.RefTable) != nil {
+				g.add(o, edge{to: Object{KindTable, constraint.RefTable}, foreignKey: constraint})
+			}
+		}
+	}
+	for _, table := range s.ForeignTables {
+		o := g.node(Object{KindForeignTable, table.Name})
+		if s.Server(table.Server) != nil {
+			g.add(o, edge{to: Object{KindServer, table.Server}})
+		}
+		for _, column := range table.Columns {
+			dependsOnType(o, column.Type)
+		}
+	}
+	for _, mapping := range s.UserMappings {
+		o := g.node(Object{KindUserMapping, mapping.User + " on " + mapping.Server})
+		if s.Server(mapping.Server) != nil {
+			g.add(o, edge{to: Object{KindServer, mapping.Server}})
+		}
+	}
+	for _, view := range s.Views {
+		g.node(Object{KindView, view.Name})
+	}
+	for _, view := range s.Views {
+		o := Object{KindView, view.Name}
+		for _, ref := range view.References() {
+			switch {
+			case s.View(ref) != nil:
+				g.add(o, edge{to: Object{KindView, ref}})
+			case s.Table(ref) != nil:
+				g.add(o, edge{to: Object{KindTable, ref}})
+			case s.ForeignTable(ref) != nil:
+				g.add(o, edge{to: Object{KindForeignTable, ref}})
+			}
+		}
+	}
+	for _, table := range s.Tables {
+		for _, trigger := range table.Triggers {
+			o := g.node(Object{KindTrigger, table.Name + "." + trigger.Name})
+			g.add(o, edge{to: Object{KindTable, table.Name}})
+			if g.has(Object{KindFunction, trigger.Function}) {
+				g.add(o, edge{to: Object{KindFunction, trigger.Function}})
+			}
+		}
+		for _, policy := range table.Policies {
+			o := g.node(Object{KindPolicy, table.Name + "." + policy.Name})
+			g.add(o, edge{to: Object{KindTable, table.Name}})
+		}
+	}
+	return g
+}
+
+func (g *Graph) node(o Object) Object {
+	if !g.has(o) {
+		g.objects = append(g.objects, o)
+		g.edges[o] = nil
+	}
+	return o
+}
+
+func (g *Graph) has(o Object) bool {
+	_, ok := g.edges[o]
+	return ok
+}
+
+// Adds a dependency of from, leaving out the ones of objects on themselves,
+// like a table whose foreign key references it
+func (g *Graph) add(from Object, e edge) {
+	if e.to != from {
+		g.edges[from] = append(g.edges[from], e)
+	}
+}
+
+// Sort orders the objects so each comes after the objects it depends on,
+// keeping the order they were added in where there's no dependency. Creating
+// them in that order, and dropping them in the reverse one, always
+// succeeds.
+//
+// Foreign keys of tables that reference each other in a cycle are returned
+// in deferred, to be added once all tables exist (or dropped before any of
+// them is). Any other cycle is a *CycleError, along with the objects that
+// could be sorted
+func (g *Graph) Sort() (sorted []Object, deferred map[*schema.Constraint]bool, err error) {
+	placed := map[Object]bool{}
+	deferred = map[*schema.Constraint]bool{}
+	ready := func(o Object) bool {
+		for _, e := range g.edges[o] {
+			if !placed[e.to] && !deferred[e.foreignKey] {
+				return false
+			}
+		}
+		return true
+	}
+
+	for len(sorted) < len(g.objects) {
+		progress := false
+		for _, o := range g.objects {
+			if !placed[o] && ready(o) {
+				sorted = append(sorted, o)
+				placed[o] = true
+				progress = true
+			}
+		}
+		if progress {
+			continue
+		}
+
+		// Everything left is part of, or depends on, a cycle. Defer the
+		// foreign keys of the first remaining table that point at other
+		// remaining tables so it can be placed
+		broken := false
+		for _, o := range g.objects {
+			if placed[o] || o.Kind != KindTable {
+				continue
+			}
+			for _, e := range g.edges[o] {
+				if e.foreignKey != nil && !placed[e.to] && !deferred[e.foreignKey] {
+					deferred[e.foreignKey] = true
+					broken = true
+				}
+			}
+			if broken {
+				break
+			}
+		}
+		if !broken {
+			return sorted, deferred, &CycleError{Cycle: g.cycle(placed, deferred)}
+		}
+	}
+	return sorted, deferred, nil
+}
+
+// Finds a cycle among the objects that aren't placed, following the
+// dependencies that aren't deferred
+func (g *Graph) cycle(placed map[Object]bool, deferred map[*schema.Constraint]bool) []Object {
+	var path []Object
+	onPath := map[Object]int{}
+	visited := map[Object]bool{}
+	var visit func(o Object) []Object
+	visit = func(o Object) []Object {
+		if i, ok := onPath[o]; ok {
+			return append(append([]Object(nil), path[i:]...), o)
+		}
+		if visited[o] {
+			return nil
+		}
+		visited[o] = true
+		onPath[o] = len(path)
+		path = append(path, o)
+		for _, e := range g.edges[o] {
+			if placed[e.to] || deferred[e.foreignKey] {
+				continue
+			}
+			if cycle := visit(e.to); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		delete(onPath, o)
+		return nil
+	}
+
+	for _, o := range g.objects {
+		if placed[o] {
+			continue
+		}
+		if cycle := visit(o); cycle != nil {
+			return cycle
+		}
+	}
+	panic(fmt.Sprintf("no cycle among %d unsorted objects", len(g.objects)-len(placed)))
+}
+
+// Moves the changes with op, creating or dropping objects, after the ones
+// they have to wait for: those creating the objects they depend on, or
+// dropping the objects depending on them for OpDrop. Everything else keeps
+// its place, so each kind of change stays where Diff puts it unless a
+// dependency on another kind holds it back, like a function returning the
+// rows of a table that's created later. Foreign keys are left to
+// sortTables, and changes waiting on each other in a cycle come last
+func orderChanges(changes []Change, g *Graph, op Op) []Change {
+	waitsFor := map[Object][]Object{}
+	for from, edges := range g.edges {
+		for _, e := range edges {
+			switch {
+			case e.foreignKey != nil:
+			case op == OpDrop:
+				waitsFor[e.to] = append(waitsFor[e.to], from)
+			default:
+				waitsFor[from] = append(waitsFor[from], e.to)
+			}
+		}
+	}
+
+	// Changes with op that aren't placed yet, per object
+	pending := map[Object]int{}
+	for _, change := range changes {
+		if change.Op == op {
+			pending[Object{change.Kind, change.Name}]++
+		}
+	}
+	ready := func(change Change) bool {
+		if change.Op != op {
+			return true
+		}
+		for _, o := range waitsFor[Object{change.Kind, change.Name}] {
+			if pending[o] > 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	ordered := make([]Change, 0, len(changes))
+	var held []Change
+	place := func(change Change) {
+		ordered = append(ordered, change)
+		if change.Op == op {
+			pending[Object{change.Kind, change.Name}]--
+		}
+	}
+	for _, change := range changes {
+		if !ready(change) {
+			held = append(held, change)
+			continue
+		}
+		place(change)
+		// Held changes go right after the last change they waited for
+		for i := 0; i < len(held); {
+			if ready(held[i]) {
+				place(held[i])
+				held = slices.Delete(held, i, i+1)
+				i = 0
+				continue
+			}
+			i++
+		}
+	}
+	return append(ordered, held...)
+}
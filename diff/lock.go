@@ -0,0 +1,137 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Lock is a Postgres table lock mode, from the table-level locks DDL takes
+type Lock string
+
+const (
+	LockNone                 Lock = ""
+	LockShareUpdateExclusive Lock = "SHARE UPDATE EXCLUSIVE"
+	LockShare                Lock = "SHARE"
+	LockShareRowExclusive    Lock = "SHARE ROW EXCLUSIVE"
+	LockAccessExclusive      Lock = "ACCESS EXCLUSIVE"
+)
+
+var lockStrength = map[Lock]int{
+	LockNone:                 0,
+	LockShareUpdateExclusive: 1,
+	LockShare:                2,
+	LockShareRowExclusive:    3,
+	LockAccessExclusive:      4,
+}
+
+// Blocks tells what the lock keeps other sessions from doing while it's held
+func (l Lock) Blocks() string {
+	switch l {
+	case LockNone:
+		return "nothing"
+	case LockShareUpdateExclusive:
+		return "schema changes"
+	case LockShare, LockShareRowExclusive:
+		return "writes"
+	}
+	return "reads and writes"
+}
+
+// BlocksWrites is set for locks that make inserts, updates and deletes wait
+func (l Lock) BlocksWrites() bool {
+	return lockStrength[l] >= lockStrength[LockShare]
+}
+
+var (
+	statementEnd    = regexp.MustCompile(`;\s*\n`)
+	concurrently    = regexp.MustCompile(`^(CREATE (UNIQUE )?INDEX|DROP INDEX) CONCURRENTLY `)
+	createIndex     = regexp.MustCompile(`^CREATE (UNIQUE )?INDEX `)
+	foreignKey      = regexp.MustCompile(`^ALTER TABLE .* ADD CONSTRAINT .* FOREIGN KEY `)
+	createTrigger   = regexp.MustCompile(`^CREATE (OR REPLACE )?(CONSTRAINT )?TRIGGER `)
+	domainCheck     = regexp.MustCompile(`^ALTER DOMAIN .* (ADD CONSTRAINT|SET NOT NULL)`)
+	accessExclusive = regexp.MustCompile(`^(ALTER TABLE|DROP TABLE|DROP INDEX|ALTER INDEX|DROP TRIGGER|TRUNCATE|` +
+		`(CREATE|ALTER|DROP) POLICY|CREATE OR REPLACE VIEW|(ALTER|DROP) (MATERIALIZED )?VIEW) `)
+	validateConstraint = regexp.MustCompile(`^ALTER TABLE .* VALIDATE CONSTRAINT `)
+	commentOn          = regexp.MustCompile(`^COMMENT ON (TABLE|COLUMN|(MATERIALIZED )?VIEW|INDEX|CONSTRAINT|TRIGGER|POLICY) `)
+	// Statements Postgres refuses to run inside a transaction block
+	noTransaction = regexp.MustCompile(`^((CREATE (UNIQUE )?INDEX|DROP INDEX|REINDEX( \w+)?) CONCURRENTLY|VACUUM|ALTER SYSTEM|(CREATE|DROP) DATABASE)\b`)
+)
+
+// Lock estimates the strongest lock the change takes on an existing table or
+// view when applied to Postgres, from the statements in its SQL. Creating a
+// table locks nothing that's in use, unless its foreign keys reference other
+// tables. Functions, types and other objects that aren't tables take no
+// table lock
+func (c Change) Lock() Lock {
+	if c.Kind == KindFunction {
+		return LockNone
+	}
+
+	lock := LockNone
+	for _, stmt := range statementEnd.Split(c.SQL, -1) {
+		if l := statementLock(stmt); lockStrength[l] > lockStrength[lock] {
+			lock = l
+		}
+	}
+	return lock
+}
+
+// NonTransactional is set for changes with a statement that can't run inside
+// a transaction, like CREATE INDEX CONCURRENTLY. Postgres runs the statements
+// of a migration file in a single implicit transaction, so such a change
+// needs a migration of its own
+func (c Change) NonTransactional() bool {
+	if c.Kind == KindFunction {
+		return false
+	}
+	for _, stmt := range statementEnd.Split(c.SQL, -1) {
+		if noTransaction.MatchString(normalizeStatement(stmt)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validation is set for changes checking the rows of a table against a
+// constraint added NOT VALID. Validating only blocks schema changes, but in
+// the transaction that added the constraint the lock taken adding it is held
+// while the rows are checked, so they need a migration of their own
+func (c Change) Validation() bool {
+	for _, stmt := range statementEnd.Split(c.SQL, -1) {
+		if validateConstraint.MatchString(normalizeStatement(stmt)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Uppercases the statement and collapses its whitespace, so it can be matched
+// against the patterns above
+func normalizeStatement(stmt string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(stmt), " "))
+}
+
+func statementLock(stmt string) Lock {
+	stmt = normalizeStatement(stmt)
+	switch {
+	case concurrently.MatchString(stmt):
+		return LockShareUpdateExclusive
+	case createIndex.MatchString(stmt):
+		return LockShare
+	case validateConstraint.MatchString(stmt):
+		return LockShareUpdateExclusive
+	case foreignKey.MatchString(stmt), createTrigger.MatchString(stmt):
+		return LockShareRowExclusive
+	case strings.HasPrefix(stmt, "CREATE TABLE ") && strings.Contains(stmt, " REFERENCES "):
+		// Taken on the referenced tables
+		return LockShareRowExclusive
+	case domainCheck.MatchString(stmt) && !strings.Contains(stmt, " NOT VALID"):
+		// Taken on the tables using the domain, while their rows are checked
+		return LockShare
+	case accessExclusive.MatchString(stmt):
+		return LockAccessExclusive
+	case commentOn.MatchString(stmt):
+		return LockShareUpdateExclusive
+	}
+	return LockNone
+}
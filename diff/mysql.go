@@ -0,0 +1,143 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"styx/schema"
+)
+
+// MySQL renders statements for MySQL 8 and MariaDB
+var MySQL Dialect = mysql{}
+
+type mysql struct{}
+
+func mysqlQuote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func mysqlQuoteAll(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = mysqlQuote(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func mysqlColumnDefinition(column *schema.Column) string {
+	def := mysqlQuote(column.Name) + " " + column.Type
+	if column.Generated != "" {
+		def += " GENERATED ALWAYS AS (" + column.Generated + ") STORED"
+	}
+	if column.NotNull {
+		def += " NOT NULL"
+	}
+	if column.Default != "" {
+		def += " DEFAULT " + column.Default
+	}
+	if column.AutoIncrement {
+		def += " AUTO_INCREMENT"
+	}
+	return def
+}
+
+func mysqlConstraintDefinition(c *schema.Constraint) string {
+	switch c.Type {
+	case schema.PrimaryKey:
+		// MySQL always names the primary key PRIMARY
+		return fmt.Sprintf("PRIMARY KEY (%s)", mysqlQuoteAll(c.Columns))
+	case schema.Unique:
+		return fmt.Sprintf("CONSTRAINT %s UNIQUE (%s)", mysqlQuote(c.Name), mysqlQuoteAll(c.Columns))
+	case schema.ForeignKey:
+		def := fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+			mysqlQuote(c.Name), mysqlQuoteAll(c.Columns), mysqlQuote(c.RefTable), mysqlQuoteAll(c.RefColumns))
+		if c.OnUpdate != "" {
+			def += " ON UPDATE " + c.OnUpdate
+		}
+		if c.OnDelete != "" {
+			def += " ON DELETE " + c.OnDelete
+		}
+		return def
+	case schema.Check:
+		return fmt.Sprintf("CONSTRAINT %s CHECK (%s)", mysqlQuote(c.Name), c.Expression)
+	}
+	return fmt.Sprintf("CONSTRAINT %s %s", mysqlQuote(c.Name), c.Definition)
+}
+
+func (mysql) CreateTable(table *schema.Table, skip map[*schema.Constraint]bool) string {
+	var lines []string
+	for _, column := range table.Columns {
+		lines = append(lines, "    "+mysqlColumnDefinition(column))
+	}
+	for _, constraint := range table.Constraints {
+		if !skip[constraint] {
+			lines = append(lines, "    "+mysqlConstraintDefinition(constraint))
+		}
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n);", mysqlQuote(table.Name), strings.Join(lines, ",\n"))
+}
+
+func (mysql) DropTable(table *schema.Table) string {
+	return fmt.Sprintf("DROP TABLE %s;", mysqlQuote(table.Name))
+}
+
+func (mysql) AddColumn(table *schema.Table, column *schema.Column) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", mysqlQuote(table.Name), mysqlColumnDefinition(column))
+}
+
+func (mysql) DropColumn(table *schema.Table, column *schema.Column) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", mysqlQuote(table.Name), mysqlQuote(column.Name))
+}
+
+// MySQL can't alter a single attribute of a column, the whole definition is
+// restated instead
+func (mysql) AlterColumn(table *schema.Table, current, desired *schema.Column) []string {
+	if mysqlColumnDefinition(current) == mysqlColumnDefinition(desired) {
+		return nil
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s;", mysqlQuote(table.Name), mysqlColumnDefinition(desired))}
+}
+
+func (mysql) AddConstraint(table *schema.Table, constraint *schema.Constraint) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s;", mysqlQuote(table.Name), mysqlConstraintDefinition(constraint))
+}
+
+func (mysql) DropConstraint(table *schema.Table, constraint *schema.Constraint) string {
+	var clause string
+	switch constraint.Type {
+	case schema.PrimaryKey:
+		clause = "DROP PRIMARY KEY"
+	case schema.Unique:
+		clause = "DROP INDEX " + mysqlQuote(constraint.Name)
+	case schema.ForeignKey:
+		clause = "DROP FOREIGN KEY " + mysqlQuote(constraint.Name)
+	default:
+		clause = "DROP CHECK " + mysqlQuote(constraint.Name)
+	}
+	return fmt.Sprintf("ALTER TABLE %s %s;", mysqlQuote(table.Name), clause)
+}
+
+// Index builds don't block writes by default in MySQL, so concurrently is ignored
+func (mysql) CreateIndex(table *schema.Table, index *schema.Index, concurrently bool) string {
+	sql := "CREATE "
+	switch {
+	case index.Unique:
+		sql += "UNIQUE "
+	case index.Method == "FULLTEXT" || index.Method == "SPATIAL":
+		sql += index.Method + " "
+	}
+	return sql + fmt.Sprintf("INDEX %s ON %s (%s);", mysqlQuote(index.Name), mysqlQuote(table.Name), strings.Join(index.Keys, ", "))
+}
+
+func (mysql) DropIndex(table *schema.Table, index *schema.Index, concurrently bool) string {
+	return fmt.Sprintf("DROP INDEX %s ON %s;", mysqlQuote(index.Name), mysqlQuote(table.Name))
+}
+
+func (mysql) CreateView(view *schema.View) string {
+	return fmt.Sprintf("CREATE VIEW %s AS %s;", mysqlQuote(view.Name), view.Query)
+}
+
+func (mysql) DropView(view *schema.View) string {
+	return fmt.Sprintf("DROP VIEW %s;", mysqlQuote(view.Name))
+}
@@ -0,0 +1,103 @@
+package diff
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Integer types by size. Postgres and MySQL spellings share a rank
+var integerRanks = map[string]int{
+	"tinyint":   1,
+	"smallint":  2,
+	"int2":      2,
+	"mediumint": 3,
+	"integer":   4,
+	"int":       4,
+	"int4":      4,
+	"bigint":    5,
+	"int8":      5,
+}
+
+var floatRanks = map[string]int{
+	"real":             1,
+	"float4":           1,
+	"float":            1,
+	"double precision": 2,
+	"float8":           2,
+	"double":           2,
+}
+
+// Types any value can be converted to without losing it
+var unboundedTypes = map[string]bool{
+	"text":     true,
+	"longtext": true,
+}
+
+var typeModifierPattern = regexp.MustCompile(`^(.*?)\s*\(([0-9, ]+)\)(.*)$`)
+
+// Splits a type like "numeric(10,2)" into its base name and modifiers
+func splitType(typ string) (string, []int) {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	match := typeModifierPattern.FindStringSubmatch(typ)
+	if match == nil {
+		return typ, nil
+	}
+
+	var modifiers []int
+	for _, m := range strings.Split(match[2], ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(m))
+		if err != nil {
+			return typ, nil
+		}
+		modifiers = append(modifiers, n)
+	}
+	return strings.TrimSpace(match[1] + match[3]), modifiers
+}
+
+// Reports whether changing a column from one type to another can lose or
+// reject existing values. Only changes known to be widening are considered
+// safe, e.g. integer to bigint or varchar(50) to varchar(100)
+func narrowsType(from, to string) bool {
+	fromBase, fromMods := splitType(from)
+	toBase, toMods := splitType(to)
+
+	if unboundedTypes[toBase] && toMods == nil {
+		return false
+	}
+
+	if fromRank, ok := integerRanks[fromBase]; ok {
+		if toRank, ok := integerRanks[toBase]; ok {
+			return toRank < fromRank
+		}
+		// Integers fit in numeric types that have no fractional digits to
+		// make room for
+		return !(toBase == "numeric" || toBase == "decimal") || toMods != nil
+	}
+	if fromRank, ok := floatRanks[fromBase]; ok {
+		toRank, ok := floatRanks[toBase]
+		return !ok || toRank < fromRank
+	}
+
+	if fromBase != toBase {
+		return true
+	}
+	// Without modifiers the type is unbounded
+	if toMods == nil {
+		return false
+	}
+	if fromMods == nil || len(fromMods) != len(toMods) {
+		return true
+	}
+	for i := range toMods {
+		if toMods[i] < fromMods[i] {
+			return true
+		}
+	}
+	// numeric(p, s) also loses integer digits when the scale grows faster
+	// than the precision
+	if len(toMods) == 2 && toMods[0]-toMods[1] < fromMods[0]-fromMods[1] {
+		return true
+	}
+	return false
+}
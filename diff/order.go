@@ -0,0 +1,54 @@
+package diff
+
+import (
+	"slices"
+
+	"styx/schema"
+)
+
+// Sorts tables so every table comes after the tables its foreign keys
+// reference. Only references between the given tables are considered, and
+// the original order is kept where there's no dependency.
+//
+// Tables that reference each other in a cycle can't be ordered. The cycle is
+// broken by returning the foreign keys that have to be handled separately,
+// after all tables exist (or before any of them is dropped)
+func sortTables(tables []*schema.Table) ([]*schema.Table, map[*schema.Constraint]bool) {
+	s := &schema.Schema{Tables: tables}
+	// Tables whose columns store each other's rows can't exist, they're
+	// left at the end
+	objects, deferred, _ := NewGraph(s).Sort()
+	// The graph has the triggers and policies of the tables too
+	var sorted []*schema.Table
+	for _, o := range objects {
+		if o.Kind == KindTable {
+			sorted = append(sorted, s.Table(o.Name))
+		}
+	}
+	for _, table := range tables {
+		if !slices.Contains(sorted, table) {
+			sorted = append(sorted, table)
+		}
+	}
+	return sorted, deferred
+}
+
+// Sorts views so every view comes after the views it reads from
+func sortViews(views []*schema.View) []*schema.View {
+	s := &schema.Schema{Views: views}
+	// Views can't reference each other in a cycle, but the ones of a broken
+	// input are left at the end
+	objects, _, _ := NewGraph(s).Sort()
+	var sorted []*schema.View
+	for _, o := range objects {
+		if o.Kind == KindView {
+			sorted = append(sorted, s.View(o.Name))
+		}
+	}
+	for _, view := range views {
+		if !slices.Contains(sorted, view) {
+			sorted = append(sorted, view)
+		}
+	}
+	return sorted
+}
@@ -0,0 +1,77 @@
+package diff
+
+import (
+	"fmt"
+
+	"styx/schema"
+)
+
+// Returns the statements dropping the row-level security policies that
+// changed or went away, and the ones creating the new or changed policies
+// and toggling row-level security. Policies are dropped early, since they
+// block changes to the columns they use
+func diffPolicies(current, desired *schema.Schema) (drops, creates []Change) {
+	for _, table := range current.Tables {
+		other := desired.Table(table.Name)
+		if other == nil {
+			continue
+		}
+		for _, policy := range table.Policies {
+			if policyChanged(policy, other) {
+				drops = append(drops, Change{
+					Op:    OpDrop,
+					Kind:  KindPolicy,
+					Table: table.Name,
+					Name:  policy.Name,
+					SQL:   fmt.Sprintf("DROP POLICY %s ON %s;", schema.QuoteIdent(policy.Name), schema.QuoteName(table.Name)),
+				})
+			}
+		}
+	}
+
+	for _, table := range desired.Tables {
+		existing := current.Table(table.Name)
+		if existing == nil {
+			existing = &schema.Table{}
+		}
+		if table.RowSecurity != existing.RowSecurity {
+			creates = append(creates, rowSecurityChange(table, "ENABLE", "DISABLE", table.RowSecurity))
+		}
+		if table.ForceRowSecurity != existing.ForceRowSecurity {
+			creates = append(creates, rowSecurityChange(table, "FORCE", "NO FORCE", table.ForceRowSecurity))
+		}
+		for _, policy := range table.Policies {
+			if policyChanged(policy, existing) {
+				creates = append(creates, Change{
+					Op:    OpCreate,
+					Kind:  KindPolicy,
+					Table: table.Name,
+					Name:  policy.Name,
+					SQL:   policy.Definition + ";",
+				})
+			}
+		}
+	}
+
+	return drops, creates
+}
+
+func rowSecurityChange(table *schema.Table, on, off string, enabled bool) Change {
+	action := off
+	if enabled {
+		action = on
+	}
+	return Change{
+		Op:    OpAlter,
+		Kind:  KindTable,
+		Table: table.Name,
+		Name:  table.Name,
+		SQL:   fmt.Sprintf("ALTER TABLE %s %s ROW LEVEL SECURITY;", schema.QuoteName(table.Name), action),
+	}
+}
+
+// Reports whether the policy is missing from the other table, or defined differently
+func policyChanged(policy *schema.Policy, other *schema.Table) bool {
+	existing := other.Policy(policy.Name)
+	return existing == nil || existing.Definition != policy.Definition
+}
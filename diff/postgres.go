@@ -0,0 +1,329 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"styx/schema"
+)
+
+// Dialect renders the DDL statements of a database engine. The diff engine
+// decides what changes and in which order, the dialect how it's spelled
+type Dialect interface {
+	// CreateTable renders a CREATE TABLE statement, leaving out the
+	// constraints in skip
+	CreateTable(table *schema.Table, skip map[*schema.Constraint]bool) string
+	DropTable(table *schema.Table) string
+	RenameTable(from, to string) string
+	AddColumn(table *schema.Table, column *schema.Column) string
+	DropColumn(table *schema.Table, column *schema.Column) string
+	RenameColumn(table *schema.Table, from, to string) string
+	// AlterColumn returns one statement per attribute that differs between
+	// the two columns
+	AlterColumn(table *schema.Table, current, desired *schema.Column) []string
+	AddConstraint(table *schema.Table, constraint *schema.Constraint) string
+	DropConstraint(table *schema.Table, constraint *schema.Constraint) string
+	// RenameConstraint returns an empty string if the database renames the
+	// constraint by itself
+	RenameConstraint(table *schema.Table, constraint *schema.Constraint, to string) string
+	CreateIndex(table *schema.Table, index *schema.Index, concurrently bool) string
+	DropIndex(table *schema.Table, index *schema.Index, concurrently bool) string
+	CreateView(view *schema.View) string
+	DropView(view *schema.View) string
+}
+
+// ConstraintValidator is implemented by dialects that can add a constraint
+// without checking the existing rows, and check them afterwards while only
+// blocking other schema changes
+type ConstraintValidator interface {
+	AddConstraintNotValid(table *schema.Table, constraint *schema.Constraint) string
+	ValidateConstraint(table *schema.Table, constraint *schema.Constraint) string
+}
+
+// TableRewriter is implemented by dialects that can copy a table into a new
+// one with its columns in another order, which is the only way to reorder
+// them
+type TableRewriter interface {
+	// RewriteTable renders the statements copying the rows of current into a
+	// new table shaped like rewritten, which takes its name, and dropping
+	// current along with its constraints, indexes, triggers and policies
+	RewriteTable(current, rewritten *schema.Table) string
+}
+
+// Postgres is the default dialect
+var Postgres Dialect = postgres{}
+
+type postgres struct{}
+
+// Prefix of the table a rewritten table is renamed to while its rows are
+// copied out of it
+const rewritePrefix = "_styx_old_"
+
+func columnDefinition(column *schema.Column) string {
+	def := schema.QuoteIdent(column.Name) + " " + column.Type
+	if column.Default != "" {
+		def += " DEFAULT " + column.Default
+	}
+	if column.Identity != "" {
+		def += " GENERATED " + column.Identity + " AS IDENTITY"
+	}
+	if column.Generated != "" {
+		def += " GENERATED ALWAYS AS (" + column.Generated + ") STORED"
+	}
+	if column.NotNull {
+		def += " NOT NULL"
+	}
+
+	return def
+}
+
+// Renders the statements moving an object, like a table or sequence, to a new
+// schema and name. RENAME TO takes the name without its schema
+func renameSQL(kind, from, to string) string {
+	fromSchema, fromName := schema.SplitName(from)
+	toSchema, toName := schema.SplitName(to)
+
+	var statements []string
+	if fromSchema != toSchema {
+		statements = append(statements, fmt.Sprintf("ALTER %s %s SET SCHEMA %s;", kind, schema.QuoteName(from), schema.QuoteIdent(toSchema)))
+	}
+	if fromName != toName {
+		statements = append(statements, fmt.Sprintf("ALTER %s %s RENAME TO %s;", kind, schema.QuoteName(schema.QualifiedName(toSchema, fromName)), schema.QuoteIdent(toName)))
+	}
+	return strings.Join(statements, "\n")
+}
+
+func quoteIdents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = schema.QuoteIdent(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func constraintDefinition(c *schema.Constraint) string {
+	var def string
+	switch c.Type {
+	case schema.PrimaryKey, schema.Unique:
+		def = fmt.Sprintf("%s (%s)", c.Type, quoteIdents(c.Columns))
+	case schema.ForeignKey:
+		def = fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s", quoteIdents(c.Columns), schema.QuoteName(c.RefTable))
+		if len(c.RefColumns) > 0 {
+			def += fmt.Sprintf(" (%s)", quoteIdents(c.RefColumns))
+		}
+		if c.Match != "" {
+			def += " MATCH " + c.Match
+		}
+		if c.OnUpdate != "" {
+			def += " ON UPDATE " + c.OnUpdate
+		}
+		if c.OnDelete != "" {
+			def += " ON DELETE " + c.OnDelete
+		}
+	case schema.Check:
+		def = fmt.Sprintf("CHECK (%s)", c.Expression)
+	default:
+		// pg_get_constraintdef() already renders the deferrable clauses
+		return c.Definition
+	}
+
+	if c.Deferrable {
+		def += " DEFERRABLE"
+		if c.InitiallyDeferred {
+			def += " INITIALLY DEFERRED"
+		}
+	}
+
+	return def
+}
+
+func (postgres) CreateTable(table *schema.Table, skip map[*schema.Constraint]bool) string {
+	var lines []string
+	for _, column := range table.Columns {
+		lines = append(lines, "    "+columnDefinition(column))
+	}
+	for _, constraint := range table.Constraints {
+		if skip[constraint] {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("    CONSTRAINT %s %s", schema.QuoteIdent(constraint.Name), constraintDefinition(constraint)))
+	}
+
+	create := "CREATE TABLE"
+	if table.Unlogged {
+		create = "CREATE UNLOGGED TABLE"
+	}
+	sql := fmt.Sprintf("%s %s (\n%s\n)", create, schema.QuoteName(table.Name), strings.Join(lines, ",\n"))
+	if len(table.Options) > 0 {
+		sql += " WITH (" + storageOptions(table.Options) + ")"
+	}
+	if table.Tablespace != "" {
+		sql += " TABLESPACE " + schema.QuoteIdent(table.Tablespace)
+	}
+	return sql + ";"
+}
+
+func (postgres) DropTable(table *schema.Table) string {
+	return fmt.Sprintf("DROP TABLE %s;", schema.QuoteName(table.Name))
+}
+
+// The old table is renamed out of the way first, and its identities dropped,
+// so the new table gets the names of its row type and identity sequences.
+// The identity sequences of the new table start over, so they're moved past
+// the copied values
+func (postgres) RewriteTable(current, rewritten *schema.Table) string {
+	tableSchema, name := schema.SplitName(current.Name)
+	old := schema.QualifiedName(tableSchema, rewritePrefix+name)
+	statements := []string{renameSQL("TABLE", current.Name, old)}
+
+	var columns, identities []string
+	for _, column := range rewritten.Columns {
+		existing := current.Column(column.Name)
+		// Generated columns are computed again in the new table
+		if existing == nil || existing.Generated != "" {
+			continue
+		}
+		columns = append(columns, column.Name)
+		if existing.Identity != "" {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP IDENTITY;", schema.QuoteName(old), schema.QuoteIdent(column.Name)))
+			identities = append(identities, column.Name)
+		}
+	}
+
+	statements = append(statements, postgres{}.CreateTable(rewritten, nil))
+	insert := fmt.Sprintf("INSERT INTO %s (%s)", schema.QuoteName(current.Name), quoteIdents(columns))
+	if len(identities) > 0 {
+		insert += " OVERRIDING SYSTEM VALUE"
+	}
+	statements = append(statements,
+		fmt.Sprintf("%s SELECT %s FROM %s;", insert, quoteIdents(columns), schema.QuoteName(old)),
+		fmt.Sprintf("DROP TABLE %s;", schema.QuoteName(old)),
+	)
+	for _, column := range identities {
+		statements = append(statements, fmt.Sprintf("SELECT setval(pg_get_serial_sequence(%s, %s), max(%s)) FROM %s;",
+			schema.QuoteLiteral(schema.QuoteName(current.Name)), schema.QuoteLiteral(column), schema.QuoteIdent(column), schema.QuoteName(current.Name)))
+	}
+
+	return strings.Join(statements, "\n")
+}
+
+func (postgres) AddColumn(table *schema.Table, column *schema.Column) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", schema.QuoteName(table.Name), columnDefinition(column))
+}
+
+func (postgres) DropColumn(table *schema.Table, column *schema.Column) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", schema.QuoteName(table.Name), schema.QuoteIdent(column.Name))
+}
+
+func (postgres) AddConstraint(table *schema.Table, constraint *schema.Constraint) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s;", schema.QuoteName(table.Name), schema.QuoteIdent(constraint.Name), constraintDefinition(constraint))
+}
+
+func (postgres) AddConstraintNotValid(table *schema.Table, constraint *schema.Constraint) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s NOT VALID;", schema.QuoteName(table.Name), schema.QuoteIdent(constraint.Name), constraintDefinition(constraint))
+}
+
+func (postgres) ValidateConstraint(table *schema.Table, constraint *schema.Constraint) string {
+	return fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s;", schema.QuoteName(table.Name), schema.QuoteIdent(constraint.Name))
+}
+
+func (postgres) DropConstraint(table *schema.Table, constraint *schema.Constraint) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", schema.QuoteName(table.Name), schema.QuoteIdent(constraint.Name))
+}
+
+func (postgres) CreateIndex(table *schema.Table, index *schema.Index, concurrently bool) string {
+	sql := "CREATE "
+	if index.Unique {
+		sql += "UNIQUE "
+	}
+	sql += "INDEX "
+	if concurrently {
+		sql += "CONCURRENTLY "
+	}
+	sql += schema.QuoteIdent(index.Name) + " ON " + schema.QuoteName(table.Name)
+	if index.Method != "" && index.Method != "btree" {
+		sql += " USING " + index.Method
+	}
+	sql += " (" + strings.Join(index.Keys, ", ") + ")"
+	if len(index.Include) > 0 {
+		sql += " INCLUDE (" + quoteIdents(index.Include) + ")"
+	}
+	if len(index.Options) > 0 {
+		sql += " WITH (" + storageOptions(index.Options) + ")"
+	}
+	if index.Tablespace != "" {
+		sql += " TABLESPACE " + schema.QuoteIdent(index.Tablespace)
+	}
+	if index.Where != "" {
+		sql += " WHERE " + index.Where
+	}
+
+	return sql + ";"
+}
+
+// Indexes live in the schema of their table
+func (postgres) DropIndex(table *schema.Table, index *schema.Index, concurrently bool) string {
+	schemaName, _ := schema.SplitName(table.Name)
+	name := schema.QuoteName(schema.QualifiedName(schemaName, index.Name))
+	if concurrently {
+		return fmt.Sprintf("DROP INDEX CONCURRENTLY %s;", name)
+	}
+	return fmt.Sprintf("DROP INDEX %s;", name)
+}
+
+func (postgres) AlterColumn(table *schema.Table, current, desired *schema.Column) []string {
+	prefix := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s", schema.QuoteName(table.Name), schema.QuoteIdent(desired.Name))
+
+	var statements []string
+	if current.Type != desired.Type {
+		statements = append(statements, fmt.Sprintf("%s TYPE %s;", prefix, desired.Type))
+	}
+
+	// Identities can only be added to NOT NULL columns, and keep theirs
+	// until they're dropped
+	if desired.NotNull && !current.NotNull {
+		statements = append(statements, prefix+" SET NOT NULL;")
+	}
+
+	// A column can't have a default and be an identity at once, so a default
+	// is dropped before an identity is added, and set after one is dropped
+	defaultChanged := schema.NormalizeExpr(current.Default) != schema.NormalizeExpr(desired.Default)
+	if defaultChanged && desired.Default == "" {
+		statements = append(statements, prefix+" DROP DEFAULT;")
+	}
+
+	if current.Identity != desired.Identity {
+		switch {
+		case current.Identity == "":
+			statements = append(statements, fmt.Sprintf("%s ADD GENERATED %s AS IDENTITY;", prefix, desired.Identity))
+		case desired.Identity == "":
+			statements = append(statements, prefix+" DROP IDENTITY;")
+		default:
+			statements = append(statements, fmt.Sprintf("%s SET GENERATED %s;", prefix, desired.Identity))
+		}
+	}
+
+	if defaultChanged && desired.Default != "" {
+		statements = append(statements, fmt.Sprintf("%s SET DEFAULT %s;", prefix, desired.Default))
+	}
+
+	if current.NotNull && !desired.NotNull {
+		statements = append(statements, prefix+" DROP NOT NULL;")
+	}
+
+	return statements
+}
+
+func (postgres) CreateView(view *schema.View) string {
+	if view.Materialized {
+		return fmt.Sprintf("CREATE MATERIALIZED VIEW %s AS %s;", schema.QuoteName(view.Name), view.Query)
+	}
+	return fmt.Sprintf("CREATE VIEW %s AS %s;", schema.QuoteName(view.Name), view.Query)
+}
+
+func (postgres) DropView(view *schema.View) string {
+	if view.Materialized {
+		return fmt.Sprintf("DROP MATERIALIZED VIEW %s;", schema.QuoteName(view.Name))
+	}
+	return fmt.Sprintf("DROP VIEW %s;", schema.QuoteName(view.Name))
+}
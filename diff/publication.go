@@ -0,0 +1,115 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"styx/schema"
+)
+
+// Creates, alters and drops publications. Tables whose column list or row
+// filter changed are dropped from the publication and added back, and a
+// publication switching to or from FOR ALL TABLES is created again, since
+// neither can be altered in place
+func diffPublications(current, desired *schema.Schema) []Change {
+	var changes []Change
+	for _, pub := range current.Publications {
+		if other := desired.Publication(pub.Name); other == nil || other.AllTables != pub.AllTables {
+			changes = append(changes, publicationChange(OpDrop, pub.Name, fmt.Sprintf("DROP PUBLICATION %s;", schema.QuoteIdent(pub.Name))))
+		}
+	}
+
+	for _, pub := range desired.Publications {
+		existing := current.Publication(pub.Name)
+		if existing == nil || existing.AllTables != pub.AllTables {
+			changes = append(changes, publicationChange(OpCreate, pub.Name, createPublicationSQL(pub)))
+			continue
+		}
+		name := schema.QuoteIdent(pub.Name)
+
+		var dropped, added []string
+		for _, table := range existing.Tables {
+			if other := pub.Table(table.Table); other == nil || !equalPublicationTables(table, other) {
+				dropped = append(dropped, schema.QuoteName(table.Table))
+			}
+		}
+		for _, schemaName := range existing.Schemas {
+			if !slices.Contains(pub.Schemas, schemaName) {
+				dropped = append(dropped, "TABLES IN SCHEMA "+schema.QuoteIdent(schemaName))
+			}
+		}
+		for _, table := range pub.Tables {
+			if other := existing.Table(table.Table); other == nil || !equalPublicationTables(other, table) {
+				added = append(added, publicationTableSQL(table))
+			}
+		}
+		for _, schemaName := range pub.Schemas {
+			if !slices.Contains(existing.Schemas, schemaName) {
+				added = append(added, "TABLES IN SCHEMA "+schema.QuoteIdent(schemaName))
+			}
+		}
+		if len(dropped) > 0 {
+			changes = append(changes, publicationChange(OpAlter, pub.Name, fmt.Sprintf("ALTER PUBLICATION %s DROP %s;", name, publicationObjects(dropped))))
+		}
+		if len(added) > 0 {
+			changes = append(changes, publicationChange(OpAlter, pub.Name, fmt.Sprintf("ALTER PUBLICATION %s ADD %s;", name, publicationObjects(added))))
+		}
+
+		if !slices.Equal(existing.Publish, pub.Publish) || existing.ViaPartitionRoot != pub.ViaPartitionRoot {
+			changes = append(changes, publicationChange(OpAlter, pub.Name, fmt.Sprintf("ALTER PUBLICATION %s SET (%s);", name, publicationOptions(pub))))
+		}
+	}
+	return changes
+}
+
+func equalPublicationTables(a, b *schema.PublicationTable) bool {
+	return slices.Equal(a.Columns, b.Columns) && schema.NormalizeExpr(a.Where) == schema.NormalizeExpr(b.Where)
+}
+
+func createPublicationSQL(pub *schema.Publication) string {
+	sql := "CREATE PUBLICATION " + schema.QuoteIdent(pub.Name)
+	if pub.AllTables {
+		sql += " FOR ALL TABLES"
+	} else {
+		var objects []string
+		for _, table := range pub.Tables {
+			objects = append(objects, publicationTableSQL(table))
+		}
+		for _, schemaName := range pub.Schemas {
+			objects = append(objects, "TABLES IN SCHEMA "+schema.QuoteIdent(schemaName))
+		}
+		if len(objects) > 0 {
+			sql += " FOR " + publicationObjects(objects)
+		}
+	}
+	return sql + " WITH (" + publicationOptions(pub) + ");"
+}
+
+// Joins the objects of a publication, starting the list of tables with
+// TABLE, which the following tables share
+func publicationObjects(objects []string) string {
+	if !strings.HasPrefix(objects[0], "TABLES IN SCHEMA ") {
+		objects[0] = "TABLE " + objects[0]
+	}
+	return strings.Join(objects, ", ")
+}
+
+func publicationTableSQL(table *schema.PublicationTable) string {
+	sql := schema.QuoteName(table.Table)
+	if len(table.Columns) > 0 {
+		sql += " (" + quoteIdents(table.Columns) + ")"
+	}
+	if table.Where != "" {
+		sql += " WHERE (" + table.Where + ")"
+	}
+	return sql
+}
+
+func publicationOptions(pub *schema.Publication) string {
+	return fmt.Sprintf("publish = %s, publish_via_partition_root = %t", schema.QuoteLiteral(strings.Join(pub.Publish, ", ")), pub.ViaPartitionRoot)
+}
+
+func publicationChange(op Op, name, sql string) Change {
+	return Change{Op: op, Kind: KindPublication, Name: name, SQL: sql}
+}
@@ -0,0 +1,479 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"styx/schema"
+)
+
+// Renames tells the diff which tables and columns were renamed, so they're
+// renamed instead of dropped and recreated, which would lose their data
+type Renames struct {
+	// Tables maps old table names to new ones
+	Tables map[string]string `yaml:"tables"`
+	// Columns maps "table.old_column" to the new column name. The table is
+	// the new name of the table, if it was renamed too, and may be
+	// schema-qualified
+	Columns map[string]string `yaml:"columns"`
+}
+
+// Invert returns the renames undoing these ones
+func (r Renames) Invert() Renames {
+	inverted := Renames{Tables: map[string]string{}, Columns: map[string]string{}}
+	for from, to := range r.Tables {
+		inverted.Tables[to] = from
+	}
+	for from, to := range r.Columns {
+		table, column := splitColumnKey(from)
+		// Columns are keyed by the new table name, which is the old one
+		// going backwards
+		inverted.Columns[inverted.tableName(table)+"."+to] = column
+	}
+	return inverted
+}
+
+// Returns the name the table is renamed to, or its own name
+func (r Renames) tableName(table string) string {
+	if to, ok := r.Tables[table]; ok {
+		return to
+	}
+	return table
+}
+
+// Returns the statements renaming tables and columns, and a copy of current
+// with the renames applied, to diff against desired.
+//
+// Renames that don't apply, because the old name is gone or the new one is
+// taken, are ignored. This way the renames can be kept around after the
+// migration doing them
+func applyRenames(d Dialect, current, desired *schema.Schema, renames Renames) ([]Change, *schema.Schema) {
+	if len(renames.Tables) == 0 && len(renames.Columns) == 0 {
+		return nil, current
+	}
+
+	renamed := cloneSchema(current)
+	var changes []Change
+
+	for _, table := range renamed.Tables {
+		to, ok := renames.Tables[table.Name]
+		if !ok || desired.Table(table.Name) != nil || desired.Table(to) == nil || current.Table(to) != nil {
+			continue
+		}
+		from := table.Name
+		changes = append(changes, Change{
+			Op:    OpAlter,
+			Kind:  KindTable,
+			Table: to,
+			Name:  to,
+			SQL:   d.RenameTable(from, to),
+		})
+
+		table.Name = to
+		for _, other := range renamed.Tables {
+			for _, constraint := range other.Constraints {
+				if constraint.RefTable == from {
+					constraint.RefTable = to
+				}
+			}
+		}
+		for _, grant := range renamed.Grants {
+			if grant.Object == from {
+				grant.Object = to
+			}
+		}
+		for _, pub := range renamed.Publications {
+			if entry := pub.Table(from); entry != nil {
+				entry.Table = to
+			}
+		}
+		for _, seq := range renamed.Sequences {
+			if table, column := splitColumnKey(seq.OwnedBy); table == from {
+				seq.OwnedBy = to + "." + column
+				// Moving a table to another schema moves its sequences along
+				toSchema, _ := schema.SplitName(to)
+				_, name := schema.SplitName(seq.Name)
+				seq.Name = schema.QualifiedName(toSchema, name)
+			}
+		}
+	}
+
+	for _, table := range renamed.Tables {
+		other := desired.Table(table.Name)
+		if other == nil {
+			continue
+		}
+		for _, column := range table.Columns {
+			to, ok := renames.Columns[table.Name+"."+column.Name]
+			if !ok || other.Column(column.Name) != nil || other.Column(to) == nil || table.Column(to) != nil {
+				continue
+			}
+			from := column.Name
+			changes = append(changes, Change{
+				Op:    OpAlter,
+				Kind:  KindColumn,
+				Table: table.Name,
+				Name:  to,
+				SQL:   d.RenameColumn(table, from, to),
+			})
+
+			column.Name = to
+			renameColumnReferences(renamed, table.Name, from, to)
+		}
+	}
+
+	for _, table := range renamed.Tables {
+		if other := desired.Table(table.Name); other != nil {
+			changes = append(changes, renameConstraints(d, table, other, renames)...)
+		}
+	}
+
+	return changes, renamed
+}
+
+// Constraint names usually derive from the table and column names, e.g.
+// users_email_key. Constraints named after a renamed table or column are
+// renamed along with it when desired names them the new way, instead of
+// being dropped and recreated
+func renameConstraints(d Dialect, current, desired *schema.Table, renames Renames) []Change {
+	var changes []Change
+	for _, constraint := range current.Constraints {
+		if desired.Constraint(constraint.Name) != nil {
+			continue
+		}
+
+		name := constraint.Name
+		for from, to := range renames.Tables {
+			// Generated names are based on the table name without its schema
+			_, fromName := schema.SplitName(from)
+			_, toName := schema.SplitName(to)
+			if to == current.Name && strings.HasPrefix(name, fromName+"_") {
+				name = toName + strings.TrimPrefix(name, fromName)
+			}
+		}
+		// Sorted, so a name mentioning several renamed columns always comes
+		// out the same
+		for _, key := range sortedKeys(renames.Columns) {
+			to := renames.Columns[key]
+			table, from := splitColumnKey(key)
+			if table == current.Name {
+				name = strings.Replace(name, "_"+from+"_", "_"+to+"_", 1)
+			}
+		}
+
+		other := desired.Constraint(name)
+		if name == constraint.Name || other == nil || current.Constraint(name) != nil || !equalConstraints(constraint, other) {
+			continue
+		}
+		if sql := d.RenameConstraint(current, constraint, name); sql != "" {
+			changes = append(changes, Change{
+				Op:    OpAlter,
+				Kind:  KindConstraint,
+				Table: current.Name,
+				Name:  name,
+				SQL:   sql,
+			})
+		}
+		constraint.Name = name
+	}
+	return changes
+}
+
+// Updates the constraints, indexes and sequences referring to a renamed
+// column, the way the database does
+func renameColumnReferences(s *schema.Schema, tableName, from, to string) {
+	rename := func(columns []string) {
+		for i, column := range columns {
+			if column == from {
+				columns[i] = to
+			}
+		}
+	}
+
+	for _, table := range s.Tables {
+		for _, constraint := range table.Constraints {
+			if table.Name == tableName {
+				rename(constraint.Columns)
+			}
+			if constraint.RefTable == tableName {
+				rename(constraint.RefColumns)
+			}
+		}
+	}
+
+	table := s.Table(tableName)
+	for _, index := range table.Indexes {
+		rename(index.Include)
+		for i, key := range index.Keys {
+			// Keys are quoted, and may be followed by a collation, operator
+			// class or ordering. Expressions are left alone
+			quoted := schema.QuoteIdent(from)
+			if key == quoted || strings.HasPrefix(key, quoted+" ") {
+				index.Keys[i] = schema.QuoteIdent(to) + strings.TrimPrefix(key, quoted)
+			}
+		}
+	}
+
+	for _, seq := range s.Sequences {
+		if seq.OwnedBy == tableName+"."+from {
+			seq.OwnedBy = tableName + "." + to
+		}
+	}
+
+	for _, pub := range s.Publications {
+		if entry := pub.Table(tableName); entry != nil {
+			rename(entry.Columns)
+			slices.Sort(entry.Columns)
+		}
+	}
+}
+
+// Copies the parts of the schema renames modify
+func cloneSchema(s *schema.Schema) *schema.Schema {
+	clone := *s
+	clone.Tables = make([]*schema.Table, len(s.Tables))
+	for i, table := range s.Tables {
+		t := *table
+		t.Columns = make([]*schema.Column, len(table.Columns))
+		for j, column := range table.Columns {
+			c := *column
+			t.Columns[j] = &c
+		}
+		t.Constraints = make([]*schema.Constraint, len(table.Constraints))
+		for j, constraint := range table.Constraints {
+			c := *constraint
+			c.Columns = append([]string(nil), constraint.Columns...)
+			c.RefColumns = append([]string(nil), constraint.RefColumns...)
+			t.Constraints[j] = &c
+		}
+		t.Indexes = make([]*schema.Index, len(table.Indexes))
+		for j, index := range table.Indexes {
+			idx := *index
+			idx.Keys = append([]string(nil), index.Keys...)
+			idx.Include = append([]string(nil), index.Include...)
+			t.Indexes[j] = &idx
+		}
+		clone.Tables[i] = &t
+	}
+
+	clone.Sequences = make([]*schema.Sequence, len(s.Sequences))
+	for i, seq := range s.Sequences {
+		copied := *seq
+		clone.Sequences[i] = &copied
+	}
+
+	clone.Grants = make([]*schema.Grant, len(s.Grants))
+	for i, grant := range s.Grants {
+		copied := *grant
+		clone.Grants[i] = &copied
+	}
+
+	clone.Publications = make([]*schema.Publication, len(s.Publications))
+	for i, pub := range s.Publications {
+		copied := *pub
+		copied.Tables = make([]*schema.PublicationTable, len(pub.Tables))
+		for j, table := range pub.Tables {
+			t := *table
+			t.Columns = append([]string(nil), table.Columns...)
+			copied.Tables[j] = &t
+		}
+		clone.Publications[i] = &copied
+	}
+	return &clone
+}
+
+// DetectRenames guesses which of the tables and columns that went away were
+// renamed to ones that appeared. A table is taken to be renamed when its
+// columns have the same types as those of exactly one new table, in order. A
+// column is when it matches the type and nullability of the added column at
+// its position, or of the only added column matching it whose name has a
+// word in common with its own, like name and full_name, and with no other
+// dropped column's
+func DetectRenames(current, desired *schema.Schema) Renames {
+	renames := Renames{Tables: map[string]string{}, Columns: map[string]string{}}
+
+	for _, old := range current.Tables {
+		if desired.Table(old.Name) != nil {
+			continue
+		}
+		var matches []string
+		for _, table := range desired.Tables {
+			if current.Table(table.Name) == nil && sameColumns(old, table) {
+				matches = append(matches, table.Name)
+			}
+		}
+		if len(matches) == 1 {
+			renames.Tables[old.Name] = matches[0]
+		}
+	}
+
+	for _, table := range desired.Tables {
+		old := current.Table(table.Name)
+		for _, from := range sortedKeys(renames.Tables) {
+			if renames.Tables[from] == table.Name {
+				old = current.Table(from)
+				break
+			}
+		}
+		if old == nil {
+			continue
+		}
+
+		var dropped, added []*schema.Column
+		for _, column := range old.Columns {
+			if table.Column(column.Name) == nil {
+				dropped = append(dropped, column)
+			}
+		}
+		for _, column := range table.Columns {
+			if old.Column(column.Name) == nil {
+				added = append(added, column)
+			}
+		}
+
+		for _, column := range dropped {
+			var candidates []*schema.Column
+			for _, other := range added {
+				if similarColumns(column, other) {
+					candidates = append(candidates, other)
+				}
+			}
+
+			var match *schema.Column
+			for _, candidate := range candidates {
+				if columnIndex(old, column.Name) == columnIndex(table, candidate.Name) {
+					match = candidate
+				}
+			}
+			// Columns of the same type are common, so one added elsewhere
+			// is only a rename if its name is related, and no other dropped
+			// column's is
+			if match == nil && len(candidates) == 1 && shareWord(column.Name, candidates[0].Name) {
+				related := 0
+				for _, other := range dropped {
+					if similarColumns(other, candidates[0]) && shareWord(other.Name, candidates[0].Name) {
+						related++
+					}
+				}
+				if related == 1 {
+					match = candidates[0]
+				}
+			}
+			if match != nil && !renamesTo(renames.Columns, table.Name, match.Name) {
+				renames.Columns[table.Name+"."+column.Name] = match.Name
+			}
+		}
+	}
+
+	return renames
+}
+
+func sameColumns(a, b *schema.Table) bool {
+	if len(a.Columns) != len(b.Columns) {
+		return false
+	}
+	for i, column := range a.Columns {
+		if !similarColumns(column, b.Columns[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func similarColumns(a, b *schema.Column) bool {
+	return a.Type == b.Type && a.NotNull == b.NotNull
+}
+
+// Reports whether the names have a word in common, splitting them at
+// underscores
+func shareWord(a, b string) bool {
+	words := strings.Split(strings.ToLower(a), "_")
+	for _, word := range strings.Split(strings.ToLower(b), "_") {
+		if word != "" && slices.Contains(words, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func columnIndex(table *schema.Table, name string) int {
+	for i, column := range table.Columns {
+		if column.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Reports whether a column of the table is already renamed to name
+func renamesTo(columns map[string]string, table, name string) bool {
+	for from, to := range columns {
+		if fromTable, _ := splitColumnKey(from); to == name && fromTable == table {
+			return true
+		}
+	}
+	return false
+}
+
+// Splits a "table.column" key of Renames.Columns. The table may be
+// schema-qualified, so the column is what follows the last dot
+func splitColumnKey(key string) (table, column string) {
+	i := strings.LastIndex(key, ".")
+	if i < 0 {
+		return "", key
+	}
+	return key[:i], key[i+1:]
+}
+
+func (postgres) RenameTable(from, to string) string {
+	return renameSQL("TABLE", from, to)
+}
+
+func (postgres) RenameColumn(table *schema.Table, from, to string) string {
+	return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", schema.QuoteName(table.Name), schema.QuoteIdent(from), schema.QuoteIdent(to))
+}
+
+func (postgres) RenameConstraint(table *schema.Table, constraint *schema.Constraint, to string) string {
+	return fmt.Sprintf("ALTER TABLE %s RENAME CONSTRAINT %s TO %s;", schema.QuoteName(table.Name), schema.QuoteIdent(constraint.Name), schema.QuoteIdent(to))
+}
+
+func (sqlite) RenameTable(from, to string) string {
+	return postgres{}.RenameTable(from, to)
+}
+
+func (sqlite) RenameColumn(table *schema.Table, from, to string) string {
+	return postgres{}.RenameColumn(table, from, to)
+}
+
+// SQLite constraints have no names of their own, they're derived from the
+// table and columns, so there's nothing to rename
+func (sqlite) RenameConstraint(table *schema.Table, constraint *schema.Constraint, to string) string {
+	return ""
+}
+
+func (mysql) RenameTable(from, to string) string {
+	return fmt.Sprintf("RENAME TABLE %s TO %s;", mysqlQuote(from), mysqlQuote(to))
+}
+
+func (mysql) RenameColumn(table *schema.Table, from, to string) string {
+	return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", mysqlQuote(table.Name), mysqlQuote(from), mysqlQuote(to))
+}
+
+// Only unique constraints, which are indexes in MySQL, can be renamed.
+// Generated foreign key and check names follow the table on their own
+func (mysql) RenameConstraint(table *schema.Table, constraint *schema.Constraint, to string) string {
+	if constraint.Type != schema.Unique {
+		return ""
+	}
+	return fmt.Sprintf("ALTER TABLE %s RENAME INDEX %s TO %s;", mysqlQuote(table.Name), mysqlQuote(constraint.Name), mysqlQuote(to))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,130 @@
+package diff
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDetectRenames(t *testing.T) {
+	tests := []struct {
+		name             string
+		current, desired string
+		tables, columns  map[string]string
+	}{
+		{
+			name:    "table with the same columns",
+			current: "CREATE TABLE users (id int PRIMARY KEY, name text);",
+			desired: "CREATE TABLE accounts (id int PRIMARY KEY, name text);",
+			tables:  map[string]string{"users": "accounts"},
+		},
+		{
+			name:    "table with other columns",
+			current: "CREATE TABLE users (id int PRIMARY KEY, name text);",
+			desired: "CREATE TABLE accounts (id int PRIMARY KEY, balance numeric);",
+		},
+		{
+			name:    "table matching two new ones",
+			current: "CREATE TABLE users (id int PRIMARY KEY);",
+			desired: "CREATE TABLE accounts (id int PRIMARY KEY); CREATE TABLE members (id int PRIMARY KEY);",
+		},
+		{
+			name:    "column at the same position",
+			current: "CREATE TABLE users (id int, nickname text, email text NOT NULL);",
+			desired: "CREATE TABLE users (id int, handle text, email text NOT NULL);",
+			columns: map[string]string{"users.nickname": "handle"},
+		},
+		{
+			name:    "column moved, with a related name",
+			current: "CREATE TABLE users (id int, name text, email text NOT NULL);",
+			desired: "CREATE TABLE users (id int, email text NOT NULL, full_name text);",
+			columns: map[string]string{"users.name": "full_name"},
+		},
+		{
+			name:    "column dropped and an unrelated one of the same type added",
+			current: "CREATE TABLE users (id int, nickname text, email text NOT NULL);",
+			desired: "CREATE TABLE users (id int, email text NOT NULL, bio text);",
+		},
+		{
+			name:    "column of another type",
+			current: "CREATE TABLE users (id int, age int);",
+			desired: "CREATE TABLE users (id int, born date);",
+		},
+		{
+			name:    "column of other nullability",
+			current: "CREATE TABLE users (id int, name text);",
+			desired: "CREATE TABLE users (id int, full_name text NOT NULL);",
+		},
+		{
+			name:    "columns of a renamed table",
+			current: "CREATE TABLE users (id int, name text);",
+			desired: "CREATE TABLE accounts (id int, name text); CREATE TABLE users_archive (id int, name text, at date);",
+			tables:  map[string]string{"users": "accounts"},
+		},
+		{
+			name:    "two columns matching one added",
+			current: "CREATE TABLE users (id int, first_name text, last_name text, email text NOT NULL);",
+			desired: "CREATE TABLE users (id int, email text NOT NULL, phone int, name text);",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectRenames(parse(t, tt.current), parse(t, tt.desired))
+			if !maps.Equal(got.Tables, tt.tables) {
+				t.Errorf("got table renames %v, want %v", got.Tables, tt.tables)
+			}
+			if !maps.Equal(got.Columns, tt.columns) {
+				t.Errorf("got column renames %v, want %v", got.Columns, tt.columns)
+			}
+		})
+	}
+}
+
+func TestDiffRenames(t *testing.T) {
+	tests := []struct {
+		name             string
+		current, desired string
+		renames          Renames
+		want             []string
+	}{
+		{
+			name:    "table",
+			current: "CREATE TABLE users (id int PRIMARY KEY);",
+			desired: "CREATE TABLE accounts (id int PRIMARY KEY);",
+			renames: Renames{Tables: map[string]string{"users": "accounts"}},
+			want: []string{
+				"ALTER TABLE users RENAME TO accounts;",
+				"ALTER TABLE accounts RENAME CONSTRAINT users_pkey TO accounts_pkey;",
+			},
+		},
+		{
+			name:    "column",
+			current: "CREATE TABLE users (id int PRIMARY KEY, name text);",
+			desired: "CREATE TABLE users (id int PRIMARY KEY, full_name text);",
+			renames: Renames{Columns: map[string]string{"users.name": "full_name"}},
+			want:    []string{"ALTER TABLE users RENAME COLUMN name TO full_name;"},
+		},
+		{
+			name:    "column whose old name is still there",
+			current: "CREATE TABLE users (id int PRIMARY KEY, name text);",
+			desired: "CREATE TABLE users (id int PRIMARY KEY, name text, full_name text);",
+			renames: Renames{Columns: map[string]string{"users.name": "full_name"}},
+			want:    []string{"ALTER TABLE users ADD COLUMN full_name text;"},
+		},
+		{
+			name:    "without renames",
+			current: "CREATE TABLE users (id int PRIMARY KEY, name text);",
+			desired: "CREATE TABLE users (id int PRIMARY KEY, full_name text);",
+			want:    []string{"ALTER TABLE users ADD COLUMN full_name text;", "ALTER TABLE users DROP COLUMN name;"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := statements(Diff(parse(t, tt.current), parse(t, tt.desired), Options{Renames: tt.renames}))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"styx/schema"
+)
+
+// ReorderedTables returns the tables of desired whose columns are declared in
+// another order than the one current has them in, once the renames are
+// applied. Columns can't be moved in place, and added ones come last, so
+// these tables only match the declared order when they're rewritten
+func ReorderedTables(current, desired *schema.Schema, renames Renames) []string {
+	_, current = applyRenames(Postgres, current, desired, renames)
+	var names []string
+	for _, table := range desired.Tables {
+		if existing := current.Table(table.Name); existing != nil && reordered(existing, table) {
+			names = append(names, table.Name)
+		}
+	}
+	return names
+}
+
+// Reports whether the columns of desired come in another order than current
+// will have them in after the diff, with its dropped columns gone and the new
+// ones added at the end
+func reordered(current, desired *schema.Table) bool {
+	var order []string
+	for _, column := range current.Columns {
+		if desired.Column(column.Name) != nil {
+			order = append(order, column.Name)
+		}
+	}
+	for _, column := range desired.Columns {
+		if current.Column(column.Name) == nil {
+			order = append(order, column.Name)
+		}
+	}
+	for i, column := range desired.Columns {
+		if order[i] != column.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the changes rewriting the tables whose columns are declared in
+// another order, and a copy of current where they're bare tables with their
+// columns in that order, to diff against desired. Everything the rewrite
+// drops along with the old table, like its constraints, indexes, triggers,
+// policies, comments, grants and publications, the foreign keys referencing
+// it and the views reading from it, is left out of the copy so the rest of
+// the diff creates it again
+func rewriteColumnOrder(d Dialect, current, desired *schema.Schema) ([]Change, *schema.Schema) {
+	rewriter, ok := d.(TableRewriter)
+	if !ok {
+		return nil, current
+	}
+	var tables []*schema.Table
+	for _, table := range desired.Tables {
+		if existing := current.Table(table.Name); existing != nil && reordered(existing, table) {
+			tables = append(tables, table)
+		}
+	}
+	if len(tables) == 0 {
+		return nil, current
+	}
+
+	rewritten := cloneSchema(current)
+	rewritten.Views = slices.Clone(current.Views)
+	var changes []Change
+	for _, table := range tables {
+		changes = append(changes, rewriteTable(d, rewriter, rewritten, table, desired))
+	}
+	return changes, rewritten
+}
+
+// Rewrites the table of s named like desired, replacing it with a bare table
+func rewriteTable(d Dialect, rewriter TableRewriter, s *schema.Schema, desired *schema.Table, desiredSchema *schema.Schema) Change {
+	i := slices.IndexFunc(s.Tables, func(t *schema.Table) bool { return t.Name == desired.Name })
+	current := s.Tables[i]
+
+	// Kept columns keep their definition and new ones get theirs, so only the
+	// order changes. Columns that go away come last, to be dropped afterwards
+	bare := &schema.Table{Name: desired.Name, Options: current.Options, Tablespace: current.Tablespace, Unlogged: current.Unlogged}
+	for _, column := range desired.Columns {
+		if existing := current.Column(column.Name); existing != nil {
+			column = existing
+		}
+		copied := *column
+		copied.Comment = ""
+		bare.Columns = append(bare.Columns, &copied)
+	}
+	for _, column := range current.Columns {
+		if desired.Column(column.Name) == nil {
+			copied := *column
+			copied.Comment = ""
+			bare.Columns = append(bare.Columns, &copied)
+		}
+	}
+
+	var statements []string
+
+	// Views reading from the table, directly or through other views
+	dependents := map[string]bool{desired.Name: true}
+	for changed := true; changed; {
+		changed = false
+		for _, view := range s.Views {
+			if !dependents[view.Name] && slices.ContainsFunc(view.References(), func(ref string) bool { return dependents[ref] }) {
+				dependents[view.Name] = true
+				changed = true
+			}
+		}
+	}
+	sorted := sortViews(s.Views)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if view := sorted[i]; dependents[view.Name] {
+			statements = append(statements, d.DropView(view))
+		}
+	}
+	s.Views = slices.DeleteFunc(s.Views, func(view *schema.View) bool { return dependents[view.Name] })
+	s.Grants = slices.DeleteFunc(s.Grants, func(grant *schema.Grant) bool { return dependents[grant.Object] })
+	for _, pub := range s.Publications {
+		pub.Tables = slices.DeleteFunc(pub.Tables, func(table *schema.PublicationTable) bool { return table.Table == desired.Name })
+	}
+
+	for _, other := range s.Tables {
+		if other == current {
+			continue
+		}
+		var kept []*schema.Constraint
+		for _, constraint := range other.Constraints {
+			if constraint.Type == schema.ForeignKey && constraint.RefTable == desired.Name {
+				statements = append(statements, d.DropConstraint(other, constraint))
+				continue
+			}
+			kept = append(kept, constraint)
+		}
+		other.Constraints = kept
+	}
+
+	// Sequences owned by the columns would be dropped with the old table, so
+	// they're released and owned by the new table's columns again
+	var owned []*schema.Sequence
+	for _, seq := range desiredSchema.Sequences {
+		if tableName, columnName := splitColumnKey(seq.OwnedBy); tableName == desired.Name && current.Column(columnName) != nil {
+			owned = append(owned, seq)
+			statements = append(statements, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY NONE;", schema.QuoteName(seq.Name)))
+		}
+	}
+
+	statements = append(statements, rewriter.RewriteTable(current, bare))
+	for _, seq := range owned {
+		statements = append(statements, sequenceOwnerSQL(seq))
+	}
+
+	s.Tables[i] = bare
+	return Change{
+		Op:      OpAlter,
+		Kind:    KindTable,
+		Table:   desired.Name,
+		Name:    desired.Name,
+		SQL:     strings.Join(statements, "\n"),
+		Locking: true,
+	}
+}
@@ -0,0 +1,127 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"styx/schema"
+)
+
+// Schemas created from scratch and dropped again. The statements creating
+// them have to parse back into the same schema, in the order they come in
+var roundTrips = []struct {
+	name string
+	sql  string
+}{
+	{
+		name: "tables and indexes",
+		sql: `
+CREATE TABLE users (
+	id integer PRIMARY KEY,
+	email text NOT NULL UNIQUE,
+	name text DEFAULT 'anonymous'
+);
+CREATE INDEX users_name_idx ON users (name);
+`,
+	},
+	{
+		name: "foreign keys",
+		sql: `
+CREATE TABLE a (id int PRIMARY KEY, b_id int);
+CREATE TABLE b (id int PRIMARY KEY, a_id int REFERENCES a ON DELETE CASCADE);
+ALTER TABLE a ADD FOREIGN KEY (b_id) REFERENCES b;
+CREATE INDEX a_b_id_idx ON a (b_id);
+`,
+	},
+	{
+		name: "enums",
+		sql: `
+CREATE TYPE mood AS ENUM ('happy', 'sad');
+CREATE TABLE people (id int PRIMARY KEY, mood mood NOT NULL DEFAULT 'happy');
+`,
+	},
+	{
+		name: "functions and triggers",
+		sql: `
+CREATE TABLE accounts (id int PRIMARY KEY, updated_at timestamptz);
+CREATE FUNCTION touch() RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN NEW.updated_at = now(); RETURN NEW; END $$;
+CREATE TRIGGER accounts_touch BEFORE UPDATE ON accounts FOR EACH ROW EXECUTE FUNCTION touch();
+`,
+	},
+	{
+		name: "row-level security policies",
+		sql: `
+CREATE TABLE accounts (id int PRIMARY KEY, owner text NOT NULL);
+ALTER TABLE accounts ENABLE ROW LEVEL SECURITY;
+CREATE POLICY owner_only ON accounts USING (owner = current_user);
+`,
+	},
+	{
+		name: "domains",
+		sql: `
+CREATE DOMAIN positive AS integer CHECK (VALUE > 0);
+CREATE TABLE orders (id int PRIMARY KEY, quantity positive NOT NULL);
+`,
+	},
+	{
+		name: "composite types",
+		sql: `
+CREATE DOMAIN positive AS integer CHECK (VALUE > 0);
+CREATE TYPE mood AS ENUM ('happy', 'sad');
+CREATE TYPE score AS (value positive, mood mood);
+CREATE TABLE results (id int PRIMARY KEY, score score);
+`,
+	},
+	{
+		name: "identity columns",
+		sql: `
+CREATE TABLE events (
+	id integer GENERATED BY DEFAULT AS IDENTITY,
+	seq bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	name text
+);
+`,
+	},
+	{
+		name: "functions over table rows",
+		sql: `
+CREATE TABLE users (id int PRIMARY KEY, name text);
+CREATE FUNCTION active_users() RETURNS SETOF users LANGUAGE sql AS $$ SELECT * FROM users $$;
+CREATE FUNCTION greeting(u users) RETURNS text LANGUAGE sql AS $$ SELECT 'hi ' || u.name $$;
+CREATE VIEW names AS SELECT name FROM users;
+`,
+	},
+}
+
+func TestDiffRoundTrip(t *testing.T) {
+	for _, tt := range roundTrips {
+		t.Run(tt.name, func(t *testing.T) {
+			desired := parse(t, tt.sql)
+			if err := CheckDependencies(desired); err != nil {
+				t.Fatal(err)
+			}
+
+			up := statements(Diff(&schema.Schema{}, desired, Options{}))
+			created, err := schema.Parse(strings.Join(up, "\n"))
+			if err != nil {
+				t.Fatalf("failed to parse the created schema: %v\n%s", err, strings.Join(up, "\n"))
+			}
+			if changes := Diff(created, desired, Options{}); len(changes) > 0 {
+				t.Errorf("created schema differs, first: %s\n%s", changes[0], changes[0].SQL)
+			}
+			if changes := Diff(desired, desired, Options{}); len(changes) > 0 {
+				t.Errorf("schema differs from itself, first: %s", changes[0])
+			}
+
+			down := Diff(desired, &schema.Schema{}, Options{})
+			if len(down) == 0 {
+				t.Fatal("nothing dropped")
+			}
+			for _, change := range down {
+				if change.Op != OpDrop && change.Kind != KindConstraint {
+					t.Errorf("unexpected change dropping the schema: %s", change)
+				}
+			}
+		})
+	}
+}
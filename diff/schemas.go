@@ -0,0 +1,34 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+
+	"styx/schema"
+)
+
+// Creates the Postgres schemas that are new, and drops the ones that went
+// away. Drops come last, once everything inside them is gone
+func diffSchemas(current, desired *schema.Schema) (creates, drops []Change) {
+	for _, name := range desired.Schemas {
+		if !slices.Contains(current.Schemas, name) {
+			creates = append(creates, Change{
+				Op:   OpCreate,
+				Kind: KindSchema,
+				Name: name,
+				SQL:  fmt.Sprintf("CREATE SCHEMA %s;", schema.QuoteIdent(name)),
+			})
+		}
+	}
+	for _, name := range current.Schemas {
+		if !slices.Contains(desired.Schemas, name) {
+			drops = append(drops, Change{
+				Op:   OpDrop,
+				Kind: KindSchema,
+				Name: name,
+				SQL:  fmt.Sprintf("DROP SCHEMA %s;", schema.QuoteIdent(name)),
+			})
+		}
+	}
+	return creates, drops
+}
@@ -0,0 +1,156 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"styx/schema"
+)
+
+// Returns the statements creating, renaming and altering sequences, the ones
+// setting ownership on columns of tables that don't exist yet, and the ones
+// dropping the sequences that went away.
+//
+// A sequence that went away while another one appeared owned by the same
+// column is taken to be a rename
+func diffSequences(current, desired *schema.Schema) (changes, owners, drops []Change) {
+	renamed := map[string]*schema.Sequence{}
+	for _, seq := range desired.Sequences {
+		if seq.OwnedBy == "" || current.Sequence(seq.Name) != nil {
+			continue
+		}
+		for _, old := range current.Sequences {
+			if old.OwnedBy == seq.OwnedBy && desired.Sequence(old.Name) == nil && renamed[old.Name] == nil {
+				renamed[old.Name] = seq
+				break
+			}
+		}
+	}
+
+	for _, seq := range desired.Sequences {
+		existing := current.Sequence(seq.Name)
+		for name, target := range renamed {
+			if target == seq {
+				existing = current.Sequence(name)
+				changes = append(changes, Change{
+					Op:   OpAlter,
+					Kind: KindSequence,
+					Name: seq.Name,
+					SQL:  renameSQL("SEQUENCE", name, seq.Name),
+				})
+			}
+		}
+
+		if existing == nil {
+			changes = append(changes, Change{
+				Op:   OpCreate,
+				Kind: KindSequence,
+				Name: seq.Name,
+				SQL:  createSequenceSQL(seq),
+			})
+		} else if sql := alterSequenceSQL(existing, seq); sql != "" {
+			changes = append(changes, Change{
+				Op:   OpAlter,
+				Kind: KindSequence,
+				Name: seq.Name,
+				SQL:  sql,
+			})
+		}
+
+		if existing != nil && existing.OwnedBy == seq.OwnedBy || existing == nil && seq.OwnedBy == "" {
+			continue
+		}
+		change := Change{
+			Op:   OpAlter,
+			Kind: KindSequence,
+			Name: seq.Name,
+			SQL:  sequenceOwnerSQL(seq),
+		}
+		// The owning column has to exist before the sequence can be tied to it
+		if seq.OwnedBy == "" || columnExists(current, seq.OwnedBy) {
+			changes = append(changes, change)
+		} else {
+			owners = append(owners, change)
+		}
+	}
+
+	for _, seq := range current.Sequences {
+		if desired.Sequence(seq.Name) != nil || renamed[seq.Name] != nil {
+			continue
+		}
+		// Owned sequences are dropped along with their column
+		if seq.OwnedBy != "" && !columnExists(desired, seq.OwnedBy) {
+			continue
+		}
+		drops = append(drops, Change{
+			Op:   OpDrop,
+			Kind: KindSequence,
+			Name: seq.Name,
+			SQL:  fmt.Sprintf("DROP SEQUENCE %s;", schema.QuoteName(seq.Name)),
+		})
+	}
+
+	return changes, owners, drops
+}
+
+// Reports whether the "table.column" exists in the schema
+func columnExists(s *schema.Schema, column string) bool {
+	tableName, columnName := splitColumnKey(column)
+	table := s.Table(tableName)
+	return table != nil && table.Column(columnName) != nil
+}
+
+func createSequenceSQL(seq *schema.Sequence) string {
+	sql := fmt.Sprintf("CREATE SEQUENCE %s AS %s START WITH %d INCREMENT BY %d MINVALUE %d MAXVALUE %d CACHE %d",
+		schema.QuoteName(seq.Name), seq.Type, seq.Start, seq.Increment, seq.MinValue, seq.MaxValue, seq.Cache)
+	if seq.Cycle {
+		sql += " CYCLE"
+	}
+	return sql + ";"
+}
+
+// Renders a single ALTER SEQUENCE statement covering every setting that
+// differs, or an empty string if none do
+func alterSequenceSQL(current, desired *schema.Sequence) string {
+	var clauses []string
+	if current.Type != desired.Type {
+		clauses = append(clauses, "AS "+desired.Type)
+	}
+	if current.Increment != desired.Increment {
+		clauses = append(clauses, fmt.Sprintf("INCREMENT BY %d", desired.Increment))
+	}
+	if current.MinValue != desired.MinValue {
+		clauses = append(clauses, fmt.Sprintf("MINVALUE %d", desired.MinValue))
+	}
+	if current.MaxValue != desired.MaxValue {
+		clauses = append(clauses, fmt.Sprintf("MAXVALUE %d", desired.MaxValue))
+	}
+	if current.Start != desired.Start {
+		clauses = append(clauses, fmt.Sprintf("START WITH %d", desired.Start))
+	}
+	if current.Cache != desired.Cache {
+		clauses = append(clauses, fmt.Sprintf("CACHE %d", desired.Cache))
+	}
+	if current.Cycle != desired.Cycle {
+		if desired.Cycle {
+			clauses = append(clauses, "CYCLE")
+		} else {
+			clauses = append(clauses, "NO CYCLE")
+		}
+	}
+	if len(clauses) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("ALTER SEQUENCE %s %s;", schema.QuoteName(desired.Name), strings.Join(clauses, " "))
+}
+
+func sequenceOwnerSQL(seq *schema.Sequence) string {
+	if seq.OwnedBy == "" {
+		return fmt.Sprintf("ALTER SEQUENCE %s OWNED BY NONE;", schema.QuoteName(seq.Name))
+	}
+	// The table may be schema-qualified, the column comes last
+	i := strings.LastIndex(seq.OwnedBy, ".")
+	tableName, columnName := seq.OwnedBy[:i], seq.OwnedBy[i+1:]
+	return fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s.%s;", schema.QuoteName(seq.Name), schema.QuoteName(tableName), schema.QuoteIdent(columnName))
+}
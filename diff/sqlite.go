@@ -0,0 +1,208 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"styx/schema"
+)
+
+// TableRebuilder is implemented by dialects that can't alter columns or
+// constraints in place. Tables needing such changes are recreated instead,
+// and foreign keys are always declared inline
+type TableRebuilder interface {
+	// NeedsRebuild reports whether turning current into desired takes more
+	// than adding columns and indexes
+	NeedsRebuild(current, desired *schema.Table) bool
+	// RebuildTable renders the statements copying current into a new table
+	// shaped like desired, along with its indexes
+	RebuildTable(current, desired *schema.Table) string
+}
+
+// SQLite renders statements for SQLite 3.35 and later
+var SQLite Dialect = sqlite{}
+
+type sqlite struct{}
+
+// Prefix of the table rows are copied into while rebuilding
+const rebuildPrefix = "_styx_new_"
+
+func sqliteColumnDefinition(column *schema.Column) string {
+	def := schema.QuoteIdent(column.Name)
+	if column.Type != "" {
+		def += " " + column.Type
+	}
+	// AUTOINCREMENT is only allowed on an inline INTEGER PRIMARY KEY
+	if column.AutoIncrement {
+		def += " PRIMARY KEY AUTOINCREMENT"
+	}
+	if column.NotNull {
+		def += " NOT NULL"
+	}
+	if column.Default != "" {
+		def += " DEFAULT " + column.Default
+	}
+	return def
+}
+
+func sqliteConstraintDefinition(c *schema.Constraint) string {
+	switch c.Type {
+	case schema.PrimaryKey:
+		return fmt.Sprintf("PRIMARY KEY (%s)", quoteIdents(c.Columns))
+	case schema.Unique:
+		return fmt.Sprintf("UNIQUE (%s)", quoteIdents(c.Columns))
+	case schema.ForeignKey:
+		def := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)",
+			quoteIdents(c.Columns), schema.QuoteIdent(c.RefTable), quoteIdents(c.RefColumns))
+		if c.OnUpdate != "" {
+			def += " ON UPDATE " + c.OnUpdate
+		}
+		if c.OnDelete != "" {
+			def += " ON DELETE " + c.OnDelete
+		}
+		if c.Deferrable {
+			def += " DEFERRABLE"
+			if c.InitiallyDeferred {
+				def += " INITIALLY DEFERRED"
+			}
+		}
+		return def
+	case schema.Check:
+		return fmt.Sprintf("CONSTRAINT %s CHECK (%s)", schema.QuoteIdent(c.Name), c.Expression)
+	}
+	return c.Definition
+}
+
+func sqliteCreateTable(name string, table *schema.Table) string {
+	var lines []string
+	autoIncrement := false
+	for _, column := range table.Columns {
+		lines = append(lines, "    "+sqliteColumnDefinition(column))
+		autoIncrement = autoIncrement || column.AutoIncrement
+	}
+	for _, constraint := range table.Constraints {
+		// The primary key is declared on the column along with AUTOINCREMENT
+		if constraint.Type == schema.PrimaryKey && autoIncrement {
+			continue
+		}
+		lines = append(lines, "    "+sqliteConstraintDefinition(constraint))
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n);", schema.QuoteIdent(name), strings.Join(lines, ",\n"))
+}
+
+// SQLite resolves foreign keys when they're used rather than when they're
+// declared, so nothing has to be skipped for reference cycles
+func (sqlite) CreateTable(table *schema.Table, skip map[*schema.Constraint]bool) string {
+	return sqliteCreateTable(table.Name, table)
+}
+
+func (sqlite) DropTable(table *schema.Table) string {
+	return fmt.Sprintf("DROP TABLE %s;", schema.QuoteIdent(table.Name))
+}
+
+func (sqlite) AddColumn(table *schema.Table, column *schema.Column) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", schema.QuoteIdent(table.Name), sqliteColumnDefinition(column))
+}
+
+func (sqlite) DropColumn(table *schema.Table, column *schema.Column) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", schema.QuoteIdent(table.Name), schema.QuoteIdent(column.Name))
+}
+
+// Columns can't be altered in SQLite, the table is rebuilt instead
+func (sqlite) AlterColumn(table *schema.Table, current, desired *schema.Column) []string {
+	return nil
+}
+
+// Constraints can't be added or dropped in SQLite, the table is rebuilt
+// instead. These only render what a rebuild would achieve, for reference
+func (sqlite) AddConstraint(table *schema.Table, constraint *schema.Constraint) string {
+	return fmt.Sprintf("-- SQLite can't add %s to %s without rebuilding it", sqliteConstraintDefinition(constraint), table.Name)
+}
+
+func (sqlite) DropConstraint(table *schema.Table, constraint *schema.Constraint) string {
+	return fmt.Sprintf("-- SQLite can't drop %s from %s without rebuilding it", sqliteConstraintDefinition(constraint), table.Name)
+}
+
+// SQLite has no concurrent index builds, so concurrently is ignored
+func (sqlite) CreateIndex(table *schema.Table, index *schema.Index, concurrently bool) string {
+	sql := "CREATE "
+	if index.Unique {
+		sql += "UNIQUE "
+	}
+	sql += fmt.Sprintf("INDEX %s ON %s (%s)", schema.QuoteIdent(index.Name), schema.QuoteIdent(table.Name), strings.Join(index.Keys, ", "))
+	if index.Where != "" {
+		sql += " WHERE " + index.Where
+	}
+	return sql + ";"
+}
+
+func (sqlite) DropIndex(table *schema.Table, index *schema.Index, concurrently bool) string {
+	return fmt.Sprintf("DROP INDEX %s;", schema.QuoteIdent(index.Name))
+}
+
+func (sqlite) CreateView(view *schema.View) string {
+	return fmt.Sprintf("CREATE VIEW %s AS %s;", schema.QuoteIdent(view.Name), view.Query)
+}
+
+func (sqlite) DropView(view *schema.View) string {
+	return fmt.Sprintf("DROP VIEW %s;", schema.QuoteIdent(view.Name))
+}
+
+func (sqlite) NeedsRebuild(current, desired *schema.Table) bool {
+	for _, column := range current.Columns {
+		other := desired.Column(column.Name)
+		// DROP COLUMN refuses columns that are indexed or part of a
+		// constraint, so dropped columns always rebuild to keep things simple
+		if other == nil || sqliteColumnDefinition(column) != sqliteColumnDefinition(other) {
+			return true
+		}
+	}
+	for _, column := range desired.Columns {
+		// ADD COLUMN can't add primary keys, nor NOT NULL columns without a
+		// default
+		if current.Column(column.Name) == nil && (column.AutoIncrement || column.NotNull && column.Default == "") {
+			return true
+		}
+	}
+
+	for _, constraint := range current.Constraints {
+		if constraintChanged(constraint, desired) {
+			return true
+		}
+	}
+	for _, constraint := range desired.Constraints {
+		if constraintChanged(constraint, current) {
+			return true
+		}
+	}
+	return false
+}
+
+// Follows https://www.sqlite.org/lang_altertable.html#otheralter. Migrations
+// run in a transaction, where foreign_keys can't be switched off, so checks
+// are deferred to the commit instead
+func (sqlite) RebuildTable(current, desired *schema.Table) string {
+	var columns []string
+	for _, column := range desired.Columns {
+		if current.Column(column.Name) != nil {
+			columns = append(columns, column.Name)
+		}
+	}
+
+	temporary := rebuildPrefix + desired.Name
+	statements := []string{
+		"PRAGMA defer_foreign_keys = ON;",
+		sqliteCreateTable(temporary, desired),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s;",
+			schema.QuoteIdent(temporary), quoteIdents(columns), quoteIdents(columns), schema.QuoteIdent(current.Name)),
+		fmt.Sprintf("DROP TABLE %s;", schema.QuoteIdent(current.Name)),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", schema.QuoteIdent(temporary), schema.QuoteIdent(desired.Name)),
+	}
+	// Dropping the table dropped its indexes too
+	for _, index := range desired.Indexes {
+		statements = append(statements, SQLite.CreateIndex(desired, index, false))
+	}
+
+	return strings.Join(statements, "\n")
+}
@@ -0,0 +1,129 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"styx/schema"
+)
+
+// Returns the statements moving a table and its indexes to another
+// tablespace, and setting and resetting their storage parameters. Indexes
+// that are created again already get them
+func diffStorage(current, desired *schema.Table) []Change {
+	var changes []Change
+	table := schema.QuoteName(desired.Name)
+	for _, sql := range alterStorage("TABLE", table, current.Options, desired.Options) {
+		changes = append(changes, Change{
+			Op:    OpAlter,
+			Kind:  KindTable,
+			Table: desired.Name,
+			Name:  desired.Name,
+			SQL:   sql,
+		})
+	}
+	if current.Tablespace != desired.Tablespace {
+		changes = append(changes, Change{
+			Op:    OpAlter,
+			Kind:  KindTable,
+			Table: desired.Name,
+			Name:  desired.Name,
+			SQL:   setTablespace("TABLE", table, desired.Tablespace),
+			// The table is copied to the new tablespace
+			Locking: true,
+		})
+	}
+
+	// Indexes live in the schema of their table
+	schemaName, _ := schema.SplitName(desired.Name)
+	for _, index := range desired.Indexes {
+		existing := current.Index(index.Name)
+		if existing == nil || !equalIndexes(existing, index) {
+			continue
+		}
+		name := schema.QuoteName(schema.QualifiedName(schemaName, index.Name))
+		for _, sql := range alterStorage("INDEX", name, existing.Options, index.Options) {
+			changes = append(changes, Change{
+				Op:    OpAlter,
+				Kind:  KindIndex,
+				Table: desired.Name,
+				Name:  index.Name,
+				SQL:   sql,
+			})
+		}
+		if existing.Tablespace != index.Tablespace {
+			changes = append(changes, Change{
+				Op:      OpAlter,
+				Kind:    KindIndex,
+				Table:   desired.Name,
+				Name:    index.Name,
+				SQL:     setTablespace("INDEX", name, index.Tablespace),
+				Locking: true,
+			})
+		}
+	}
+	return changes
+}
+
+// Returns the change making a table logged or unlogged like desired. Either
+// way the table is rewritten, and made logged it's written to the
+// write-ahead log in full
+func persistenceChange(desired *schema.Table) Change {
+	persistence := "LOGGED"
+	if desired.Unlogged {
+		persistence = "UNLOGGED"
+	}
+	return Change{
+		Op:      OpAlter,
+		Kind:    KindTable,
+		Table:   desired.Name,
+		Name:    desired.Name,
+		SQL:     fmt.Sprintf("ALTER TABLE %s SET %s;", schema.QuoteName(desired.Name), persistence),
+		Locking: true,
+	}
+}
+
+// Returns the SET and RESET statements turning the storage parameters of a
+// table or index from current into desired
+func alterStorage(kind, name string, current, desired []string) []string {
+	var set, reset []string
+	for _, option := range desired {
+		if !slices.Contains(current, option) {
+			set = append(set, option)
+		}
+	}
+	for _, option := range current {
+		optionName, _, _ := strings.Cut(option, "=")
+		if !slices.ContainsFunc(desired, func(other string) bool { return strings.HasPrefix(other, optionName+"=") }) {
+			reset = append(reset, optionName)
+		}
+	}
+
+	var statements []string
+	if len(reset) > 0 {
+		statements = append(statements, fmt.Sprintf("ALTER %s %s RESET (%s);", kind, name, strings.Join(reset, ", ")))
+	}
+	if len(set) > 0 {
+		statements = append(statements, fmt.Sprintf("ALTER %s %s SET (%s);", kind, name, storageOptions(set)))
+	}
+	return statements
+}
+
+func setTablespace(kind, name, tablespace string) string {
+	if tablespace == "" {
+		tablespace = "pg_default"
+	}
+	return fmt.Sprintf("ALTER %s %s SET TABLESPACE %s;", kind, name, schema.QuoteIdent(tablespace))
+}
+
+// Renders name=value pairs as storage parameters, quoting the values since
+// they're stored as text
+func storageOptions(options []string) string {
+	rendered := make([]string, len(options))
+	for i, option := range options {
+		name, value, _ := strings.Cut(option, "=")
+		rendered[i] = name + " = " + schema.QuoteLiteral(value)
+	}
+	return strings.Join(rendered, ", ")
+}
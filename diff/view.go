@@ -0,0 +1,86 @@
+package diff
+
+import (
+	"styx/schema"
+)
+
+// Returns the statements dropping the views that need to go away or be
+// recreated, and the ones (re)creating the desired views.
+//
+// Postgres won't drop a view other views depend on, nor alter a column a
+// view reads, so dependent views are recreated along with whatever they
+// depend on
+func diffViews(d Dialect, current, desired *schema.Schema) (drops, creates []Change) {
+	recreate := map[string]bool{}
+	for _, view := range current.Views {
+		other := desired.View(view.Name)
+		if other == nil || other.Materialized != view.Materialized ||
+			schema.NormalizeQuery(other.Query) != schema.NormalizeQuery(view.Query) {
+			recreate[view.Name] = true
+		}
+	}
+	for _, table := range current.ForeignTables {
+		if foreignTableChanged(current, desired, table) {
+			recreate[table.Name] = true
+		}
+	}
+	retyped := recreatedTypes(current, desired)
+	for _, table := range current.Tables {
+		if other := desired.Table(table.Name); other == nil || columnsChanged(table, other) || rebuilds(d, table, other) || storesTypes(table, retyped) {
+			recreate[table.Name] = true
+		}
+	}
+
+	// Anything reading from a recreated object has to be recreated too
+	for changed := true; changed; {
+		changed = false
+		for _, view := range current.Views {
+			if recreate[view.Name] {
+				continue
+			}
+			for _, ref := range view.References() {
+				if recreate[ref] {
+					recreate[view.Name] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	sorted := sortViews(current.Views)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if view := sorted[i]; recreate[view.Name] {
+			drops = append(drops, Change{
+				Op:   OpDrop,
+				Kind: KindView,
+				Name: view.Name,
+				SQL:  d.DropView(view),
+			})
+		}
+	}
+
+	for _, view := range sortViews(desired.Views) {
+		if current.View(view.Name) == nil || recreate[view.Name] {
+			creates = append(creates, Change{
+				Op:   OpCreate,
+				Kind: KindView,
+				Name: view.Name,
+				SQL:  d.CreateView(view),
+			})
+		}
+	}
+
+	return drops, creates
+}
+
+// Reports whether a column was dropped or changed type, which Postgres
+// refuses to do while a view reads from the table
+func columnsChanged(current, desired *schema.Table) bool {
+	for _, column := range current.Columns {
+		if other := desired.Column(column.Name); other == nil || other.Type != column.Type {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,277 @@
+// Package erd renders the tables of a schema as an entity-relationship
+// diagram, with their columns, keys and the foreign keys between them, in
+// the text formats of diagramming tools: Graphviz DOT, Mermaid and PlantUML.
+package erd
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+
+	"styx/schema"
+)
+
+// Formats the diagrams can be rendered in
+const (
+	DOT      = "dot"
+	Mermaid  = "mermaid"
+	PlantUML = "plantuml"
+)
+
+var nonWord = regexp.MustCompile(`\W`)
+
+// Render renders the tables of s in format
+func Render(s *schema.Schema, format string) (string, error) {
+	switch format {
+	case DOT:
+		return renderDOT(s), nil
+	case Mermaid:
+		return renderMermaid(s), nil
+	case PlantUML:
+		return renderPlantUML(s), nil
+	}
+	return "", fmt.Errorf("unknown diagram format %q, expected dot, mermaid or plantuml", format)
+}
+
+// column is a column with the keys it's part of
+type column struct {
+	*schema.Column
+	primary bool
+	foreign bool
+	unique  bool
+}
+
+func (c column) keys() []string {
+	var keys []string
+	if c.primary {
+		keys = append(keys, "PK")
+	}
+	if c.foreign {
+		keys = append(keys, "FK")
+	}
+	if c.unique {
+		keys = append(keys, "UK")
+	}
+	return keys
+}
+
+func columns(table *schema.Table) []column {
+	cols := make([]column, len(table.Columns))
+	for i, c := range table.Columns {
+		cols[i] = column{Column: c}
+		for _, con := range table.Constraints {
+			if !slices.Contains(con.Columns, c.Name) {
+				continue
+			}
+			switch con.Type {
+			case schema.PrimaryKey:
+				cols[i].primary = true
+			case schema.ForeignKey:
+				cols[i].foreign = true
+			case schema.Unique:
+				cols[i].unique = cols[i].unique || len(con.Columns) == 1
+			}
+		}
+		for _, index := range table.Indexes {
+			if index.Unique && index.Where == "" && len(index.Keys) == 1 && index.Keys[0] == c.Name {
+				cols[i].unique = true
+			}
+		}
+	}
+	return cols
+}
+
+// relation is a foreign key, from the referencing table to the referenced one
+type relation struct {
+	name string
+	from *schema.Table
+	to   string
+	// optional is set when the referencing columns can be null, and one when
+	// they're unique, so each row is referenced at most once
+	optional bool
+	one      bool
+	columns  []string
+	refs     []string
+}
+
+// Returns the foreign keys between the tables of s, leaving out those to
+// tables that aren't in it
+func relations(s *schema.Schema) []relation {
+	var found []relation
+	for _, table := range s.Tables {
+		for _, con := range table.Constraints {
+			if con.Type != schema.ForeignKey || s.Table(con.RefTable) == nil {
+				continue
+			}
+			rel := relation{name: con.Name, from: table, to: con.RefTable, columns: con.Columns, refs: con.RefColumns}
+			for _, name := range con.Columns {
+				if c := table.Column(name); c != nil && !c.NotNull {
+					rel.optional = true
+				}
+			}
+			rel.one = isUnique(table, con.Columns)
+			// Foreign keys without columns reference the primary key
+			if pk := s.Table(con.RefTable).PrimaryKey(); len(rel.refs) == 0 && pk != nil {
+				rel.refs = pk.Columns
+			}
+			found = append(found, rel)
+		}
+	}
+	return found
+}
+
+// Reports whether columns are the primary key or a unique key of table
+func isUnique(table *schema.Table, columns []string) bool {
+	same := func(other []string) bool {
+		return len(other) == len(columns) && !slices.ContainsFunc(other, func(c string) bool { return !slices.Contains(columns, c) })
+	}
+	for _, con := range table.Constraints {
+		if (con.Type == schema.PrimaryKey || con.Type == schema.Unique) && same(con.Columns) {
+			return true
+		}
+	}
+	for _, index := range table.Indexes {
+		if index.Unique && index.Where == "" && same(index.Keys) {
+			return true
+		}
+	}
+	return false
+}
+
+func renderDOT(s *schema.Schema) string {
+	var b strings.Builder
+	b.WriteString("digraph schema {\n")
+	b.WriteString("  graph [rankdir=LR];\n")
+	b.WriteString("  node [shape=plaintext, fontname=\"Helvetica\", fontsize=10];\n")
+	b.WriteString("  edge [dir=both];\n")
+	for _, table := range s.Tables {
+		fmt.Fprintf(&b, "\n  %q [label=<\n", table.Name)
+		b.WriteString("    <table border=\"0\" cellborder=\"1\" cellspacing=\"0\" cellpadding=\"4\">\n")
+		fmt.Fprintf(&b, "      <tr><td colspan=\"3\" bgcolor=\"#d0d7de\"><b>%s</b></td></tr>\n", html.EscapeString(table.Name))
+		for _, c := range columns(table) {
+			name := html.EscapeString(c.Name)
+			if c.primary {
+				name = "<u>" + name + "</u>"
+			}
+			typ := html.EscapeString(c.Type)
+			if c.NotNull {
+				typ += " not null"
+			}
+			fmt.Fprintf(&b, "      <tr><td port=%q align=\"left\">%s</td><td align=\"left\">%s</td><td align=\"left\">%s</td></tr>\n",
+				c.Name, name, typ, strings.Join(c.keys(), ", "))
+		}
+		b.WriteString("    </table>\n  >];\n")
+	}
+	if rels := relations(s); len(rels) > 0 {
+		b.WriteString("\n")
+		for _, rel := range rels {
+			// Crow's feet: zero or many referencing rows, unless the foreign
+			// key is unique, and one referenced row, unless it's optional
+			tail, head := "crowodot", "tee"
+			if rel.one {
+				tail = "teeodot"
+			}
+			if rel.optional {
+				head = "teeodot"
+			}
+			to := fmt.Sprintf("%q", rel.to)
+			if len(rel.refs) > 0 {
+				to += fmt.Sprintf(":%q", rel.refs[0])
+			}
+			fmt.Fprintf(&b, "  %q:%q -> %s [arrowtail=%s, arrowhead=%s, tooltip=%q];\n", rel.from.Name, rel.columns[0], to, tail, head, rel.name)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Returns the crow's foot notation of both ends of a relation, shared by
+// Mermaid and PlantUML: the referenced row is there unless the foreign key
+// is optional, and referenced by many rows unless it's unique
+func (rel relation) ends() (string, string) {
+	to, from := "||", "o{"
+	if rel.optional {
+		to = "|o"
+	}
+	if rel.one {
+		from = "o|"
+	}
+	return to, from
+}
+
+func renderMermaid(s *schema.Schema) string {
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+	for _, table := range s.Tables {
+		fmt.Fprintf(&b, "    %s {\n", entityName(table.Name))
+		for _, c := range columns(table) {
+			// Types are single words in Mermaid
+			line := fmt.Sprintf("        %s %s", strings.NewReplacer(" ", "_", ",", "_").Replace(c.Type), nonWord.ReplaceAllString(c.Name, "_"))
+			if keys := c.keys(); len(keys) > 0 {
+				line += " " + strings.Join(keys, ", ")
+			}
+			if c.Comment != "" {
+				line += fmt.Sprintf(" %q", strings.ReplaceAll(c.Comment, `"`, "'"))
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("    }\n")
+	}
+	for _, rel := range relations(s) {
+		to, from := rel.ends()
+		fmt.Fprintf(&b, "    %s %s--%s %s : %q\n", entityName(rel.to), to, from, entityName(rel.from.Name), rel.name)
+	}
+	return b.String()
+}
+
+// Returns a table name Mermaid and PlantUML accept as an entity, with the
+// dot of schema-qualified names replaced
+func entityName(name string) string {
+	return nonWord.ReplaceAllString(name, "_")
+}
+
+func renderPlantUML(s *schema.Schema) string {
+	var b strings.Builder
+	b.WriteString("@startuml\nhide circle\nskinparam linetype ortho\n")
+	for _, table := range s.Tables {
+		fmt.Fprintf(&b, "\nentity %q as %s {\n", table.Name, entityName(table.Name))
+		cols := columns(table)
+		// Primary key columns go above the line
+		for _, c := range cols {
+			if c.primary {
+				b.WriteString(plantUMLColumn(c))
+			}
+		}
+		b.WriteString("  --\n")
+		for _, c := range cols {
+			if !c.primary {
+				b.WriteString(plantUMLColumn(c))
+			}
+		}
+		b.WriteString("}\n")
+	}
+	if rels := relations(s); len(rels) > 0 {
+		b.WriteString("\n")
+		for _, rel := range rels {
+			to, from := rel.ends()
+			fmt.Fprintf(&b, "%s %s--%s %s : %s\n", entityName(rel.to), to, from, entityName(rel.from.Name), rel.name)
+		}
+	}
+	b.WriteString("@enduml\n")
+	return b.String()
+}
+
+// Renders a column, marked with * when it's NOT NULL
+func plantUMLColumn(c column) string {
+	mandatory := ""
+	if c.NotNull {
+		mandatory = "* "
+	}
+	line := fmt.Sprintf("  %s%s : %s", mandatory, c.Name, c.Type)
+	for _, key := range c.keys() {
+		line += " <<" + key + ">>"
+	}
+	return line + "\n"
+}
@@ -0,0 +1,257 @@
+package hcl
+
+import (
+	"strings"
+	"testing"
+
+	"styx/diff"
+	"styx/schema"
+)
+
+func parse(t *testing.T, sql string) *schema.Schema {
+	t.Helper()
+	s, err := schema.Parse(sql)
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v\n%s", err, sql)
+	}
+	return s
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		hcl  string
+		// sql is the schema the HCL stands for
+		sql string
+	}{
+		{
+			name: "tables and indexes",
+			hcl: `
+schema "public" {}
+
+table "users" {
+  schema = schema.public
+  column "id" {
+    type = bigint
+    identity {
+      generated = ALWAYS
+    }
+  }
+  column "email" {
+    type = character_varying(255)
+  }
+  column "name" {
+    type = text
+    null = true
+  }
+  column "created_at" {
+    type    = timestamptz
+    default = sql("now()")
+  }
+  primary_key {
+    columns = [column.id]
+  }
+  index "users_email_key" {
+    columns = [column.email]
+    unique  = true
+  }
+  index "users_name_idx" {
+    columns = [column.name, column.created_at]
+  }
+}
+`,
+			sql: `
+CREATE TABLE users (
+  id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  email varchar(255) NOT NULL,
+  name text,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX users_email_key ON users (email);
+CREATE INDEX users_name_idx ON users (name, created_at);
+`,
+		},
+		{
+			name: "foreign keys",
+			hcl: `
+schema "public" {}
+
+table "posts" {
+  schema = schema.public
+  column "id" {
+    type = integer
+  }
+  column "author_id" {
+    type = integer
+    null = true
+  }
+  primary_key {
+    columns = [column.id]
+  }
+  foreign_key "posts_author_id_fkey" {
+    columns     = [column.author_id]
+    ref_columns = [table.authors.column.id]
+    on_delete   = SET_NULL
+  }
+}
+
+table "authors" {
+  schema = schema.public
+  column "id" {
+    type = integer
+  }
+  primary_key {
+    columns = [column.id]
+  }
+}
+`,
+			sql: `
+CREATE TABLE authors (id integer PRIMARY KEY);
+CREATE TABLE posts (
+  id integer PRIMARY KEY,
+  author_id integer REFERENCES authors (id) ON DELETE SET NULL
+);
+`,
+		},
+		{
+			name: "enums",
+			hcl: `
+schema "public" {}
+
+enum "status" {
+  schema = schema.public
+  values = ["draft", "published"]
+}
+
+table "posts" {
+  schema = schema.public
+  column "status" {
+    type    = enum.status
+    default = "draft"
+  }
+  column "tags" {
+    type = sql("text[]")
+    null = true
+  }
+}
+`,
+			sql: `
+CREATE TYPE status AS ENUM ('draft', 'published');
+CREATE TABLE posts (status status NOT NULL DEFAULT 'draft', tags text[]);
+`,
+		},
+		{
+			name: "schemas, checks and views",
+			hcl: `
+schema "public" {}
+schema "app" {}
+
+table "accounts" {
+  schema = schema.app
+  column "id" {
+    type = integer
+  }
+  column "balance" {
+    type = numeric(10, 2)
+  }
+  primary_key {
+    columns = [column.id]
+  }
+  check "positive_balance" {
+    expr = "balance >= 0"
+  }
+}
+
+view "rich" {
+  schema = schema.public
+  as     = "SELECT id FROM app.accounts WHERE balance > 1000"
+}
+`,
+			sql: `
+CREATE SCHEMA app;
+CREATE TABLE app.accounts (
+  id integer PRIMARY KEY,
+  balance numeric(10,2) NOT NULL,
+  CONSTRAINT positive_balance CHECK (balance >= 0)
+);
+CREATE VIEW rich AS SELECT id FROM app.accounts WHERE balance > 1000;
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, err := SQL("schema.hcl", tt.hcl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			converted := parse(t, sql)
+			if changes := diff.Diff(parse(t, tt.sql), converted, diff.Options{}); len(changes) > 0 {
+				t.Errorf("the HCL doesn't stand for the schema, changes:\n%s\nSQL:\n%s", changes[0].SQL, sql)
+			}
+
+			written, err := Write(converted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sql, err = SQL("written.hcl", written)
+			if err != nil {
+				t.Fatalf("failed to convert the written HCL: %v\n%s", err, written)
+			}
+			if changes := diff.Diff(converted, parse(t, sql), diff.Options{}); len(changes) > 0 {
+				t.Errorf("the written HCL doesn't stand for the schema, changes:\n%s\nHCL:\n%s", changes[0].SQL, written)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		hcl  string
+		want string
+	}{
+		{
+			name: "unterminated block",
+			hcl:  "table \"t\" {\n  column \"a\" {\n    type = integer\n  }\n",
+			want: "schema.hcl:5: expected an attribute or a block, found end of file",
+		},
+		{
+			name: "unsupported block",
+			hcl:  "schema \"public\" {}\n\nfunction \"f\" {}\n",
+			want: "schema.hcl:3: unsupported block function",
+		},
+		{
+			name: "unsupported attribute",
+			hcl:  "table \"t\" {\n  column \"a\" {\n    type = integer\n    size = 4\n  }\n}\n",
+			want: "schema.hcl:4: unsupported attribute size in column block",
+		},
+		{
+			name: "column without a type",
+			hcl:  "table \"t\" {\n  column \"a\" {\n    null = true\n  }\n}\n",
+			want: "schema.hcl:2: column a has no type",
+		},
+		{
+			name: "table without columns",
+			hcl:  "table \"t\" {\n}\n",
+			want: "schema.hcl:1: table t has no columns",
+		},
+		{
+			name: "undeclared schema",
+			hcl:  "table \"t\" {\n  schema = schema.app\n  column \"a\" {\n    type = integer\n  }\n}\n",
+			want: "schema.hcl:2: schema app isn't declared",
+		},
+		{
+			name: "foreign key without ref_columns",
+			hcl:  "table \"t\" {\n  column \"a\" {\n    type = integer\n  }\n  foreign_key \"t_a_fkey\" {\n    columns = [column.a]\n  }\n}\n",
+			want: "schema.hcl:5: foreign key t_a_fkey needs ref_columns",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SQL("schema.hcl", tt.hcl)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,417 @@
+// Package hcl reads and writes schemas described in HCL, the way Atlas does,
+// as an alternative to schema.sql. Only the syntax Atlas schemas use is
+// supported: blocks, attributes, and values that are strings, heredocs,
+// numbers, booleans, lists, references like column.id and calls like
+// varchar(255).
+package hcl
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Block is a block like table "users" { ... }
+type Block struct {
+	Type   string
+	Labels []string
+	Attrs  []*Attribute
+	Blocks []*Block
+	Line   int
+}
+
+// Attribute is an attribute like null = false
+type Attribute struct {
+	Name  string
+	Value *Value
+	Line  int
+}
+
+// Kind is the type of a value
+type Kind int
+
+const (
+	String Kind = iota
+	Number
+	Bool
+	Null
+	List
+	// Ref is a reference like column.id or CASCADE
+	Ref
+	// Call is a function call like varchar(255) or sql("now()")
+	Call
+)
+
+// Value is the value of an attribute
+type Value struct {
+	Kind Kind
+	// Text is the string, or the number or boolean as written
+	Text string
+	// Path is the traversal of a reference, or the name of a called function
+	Path []string
+	// Items are the items of a list, or the arguments of a call
+	Items []*Value
+	Line  int
+}
+
+// Attr returns the attribute of b with the given name, or nil if it's not set
+func (b *Block) Attr(name string) *Attribute {
+	for _, a := range b.Attrs {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// Parse reads the blocks and attributes of an HCL file. name is used in
+// errors
+func Parse(name, src string) (*Block, error) {
+	p := &parser{name: name, lexer: lexer{src: src, line: 1}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	body := &Block{Line: 1}
+	if err := p.body(body, tokenEOF); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type token struct {
+	typ  tokenType
+	text string
+	line int
+}
+
+type parser struct {
+	name  string
+	lexer lexer
+	tok   token
+}
+
+func (p *parser) errorf(line int, format string, args ...any) error {
+	return fmt.Errorf("%s:%d: %s", p.name, line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) next() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return p.errorf(p.lexer.line, "%s", err)
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) expect(punct string) error {
+	if p.tok.typ != tokenPunct || p.tok.text != punct {
+		return p.errorf(p.tok.line, "expected %q, found %s", punct, p.describe())
+	}
+	return p.next()
+}
+
+func (p *parser) describe() string {
+	if p.tok.typ == tokenEOF {
+		return "end of file"
+	}
+	return fmt.Sprintf("%q", p.tok.text)
+}
+
+// Parses attributes and blocks until end, a closing brace or the end of file
+func (p *parser) body(b *Block, end tokenType) error {
+	for {
+		if p.tok.typ == end && (end == tokenEOF || p.tok.text == "}") {
+			return nil
+		}
+		if p.tok.typ != tokenIdent {
+			return p.errorf(p.tok.line, "expected an attribute or a block, found %s", p.describe())
+		}
+		name, line := p.tok.text, p.tok.line
+		if err := p.next(); err != nil {
+			return err
+		}
+
+		if p.tok.typ == tokenPunct && p.tok.text == "=" {
+			if err := p.next(); err != nil {
+				return err
+			}
+			value, err := p.value()
+			if err != nil {
+				return err
+			}
+			b.Attrs = append(b.Attrs, &Attribute{Name: name, Value: value, Line: line})
+			continue
+		}
+
+		block := &Block{Type: name, Line: line}
+		for p.tok.typ == tokenString || p.tok.typ == tokenIdent {
+			block.Labels = append(block.Labels, p.tok.text)
+			if err := p.next(); err != nil {
+				return err
+			}
+		}
+		if err := p.expect("{"); err != nil {
+			return err
+		}
+		if err := p.body(block, tokenPunct); err != nil {
+			return err
+		}
+		if err := p.expect("}"); err != nil {
+			return err
+		}
+		b.Blocks = append(b.Blocks, block)
+	}
+}
+
+func (p *parser) value() (*Value, error) {
+	v := &Value{Line: p.tok.line}
+	switch {
+	case p.tok.typ == tokenString:
+		v.Kind, v.Text = String, p.tok.text
+		return v, p.next()
+	case p.tok.typ == tokenNumber:
+		v.Kind, v.Text = Number, p.tok.text
+		return v, p.next()
+	case p.tok.typ == tokenPunct && p.tok.text == "[":
+		v.Kind = List
+		items, err := p.values("]")
+		v.Items = items
+		return v, err
+	case p.tok.typ != tokenIdent:
+		return nil, p.errorf(p.tok.line, "expected a value, found %s", p.describe())
+	}
+
+	switch p.tok.text {
+	case "true", "false":
+		v.Kind, v.Text = Bool, p.tok.text
+		return v, p.next()
+	case "null":
+		v.Kind = Null
+		return v, p.next()
+	}
+
+	v.Kind = Ref
+	v.Path = []string{p.tok.text}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	for p.tok.typ == tokenPunct && p.tok.text == "." {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.typ != tokenIdent && p.tok.typ != tokenString {
+			return nil, p.errorf(p.tok.line, "expected a name after \".\", found %s", p.describe())
+		}
+		v.Path = append(v.Path, p.tok.text)
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if p.tok.typ == tokenPunct && p.tok.text == "(" {
+		v.Kind = Call
+		args, err := p.values(")")
+		v.Items = args
+		return v, err
+	}
+	return v, nil
+}
+
+// Parses the comma separated values of a list or call, from the opening
+// bracket to close
+func (p *parser) values(close string) ([]*Value, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	var values []*Value
+	for !(p.tok.typ == tokenPunct && p.tok.text == close) {
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if p.tok.typ == tokenPunct && p.tok.text == "," {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		} else if !(p.tok.typ == tokenPunct && p.tok.text == close) {
+			return nil, p.errorf(p.tok.line, "expected \",\" or %q, found %s", close, p.describe())
+		}
+	}
+	return values, p.next()
+}
+
+type lexer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skip(); err != nil {
+		return token{}, err
+	}
+	if l.pos >= len(l.src) {
+		return token{typ: tokenEOF, line: l.line}, nil
+	}
+
+	line, rest := l.line, l.src[l.pos:]
+	c := rune(rest[0])
+	switch {
+	case c == '"':
+		text, err := l.string()
+		return token{typ: tokenString, text: text, line: line}, err
+	case strings.HasPrefix(rest, "<<"):
+		text, err := l.heredoc()
+		return token{typ: tokenString, text: text, line: line}, err
+	case unicode.IsDigit(c) || c == '-' && len(rest) > 1 && unicode.IsDigit(rune(rest[1])):
+		end := 1
+		for end < len(rest) && (unicode.IsDigit(rune(rest[end])) || rest[end] == '.') {
+			end++
+		}
+		l.pos += end
+		return token{typ: tokenNumber, text: rest[:end], line: line}, nil
+	case unicode.IsLetter(c) || c == '_':
+		end := 1
+		for end < len(rest) && (unicode.IsLetter(rune(rest[end])) || unicode.IsDigit(rune(rest[end])) || rest[end] == '_' || rest[end] == '-') {
+			end++
+		}
+		l.pos += end
+		return token{typ: tokenIdent, text: rest[:end], line: line}, nil
+	case strings.ContainsRune("{}[]()=,.", c):
+		l.pos++
+		return token{typ: tokenPunct, text: string(c), line: line}, nil
+	}
+	return token{}, fmt.Errorf("unexpected character %q", c)
+}
+
+// Skips whitespace and comments
+func (l *lexer) skip() error {
+	for l.pos < len(l.src) {
+		rest := l.src[l.pos:]
+		switch {
+		case rest[0] == '\n':
+			l.line++
+			l.pos++
+		case rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r':
+			l.pos++
+		case rest[0] == '#' || strings.HasPrefix(rest, "//"):
+			end := strings.IndexByte(rest, '\n')
+			if end == -1 {
+				end = len(rest)
+			}
+			l.pos += end
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest, "*/")
+			if end == -1 {
+				return fmt.Errorf("unterminated comment")
+			}
+			l.line += strings.Count(rest[:end], "\n")
+			l.pos += end + 2
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) string() (string, error) {
+	var b strings.Builder
+	for i := l.pos + 1; i < len(l.src); i++ {
+		switch c := l.src[i]; c {
+		case '"':
+			l.pos = i + 1
+			return b.String(), nil
+		case '\n':
+			return "", fmt.Errorf("unterminated string")
+		case '\\':
+			i++
+			if i == len(l.src) {
+				return "", fmt.Errorf("unterminated string")
+			}
+			switch e := l.src[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\':
+				b.WriteByte(e)
+			default:
+				return "", fmt.Errorf("unknown escape sequence \\%c", e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// Reads a heredoc like <<-SQL ... SQL. With the dash, the indentation common
+// to its lines is removed
+func (l *lexer) heredoc() (string, error) {
+	rest := l.src[l.pos+2:]
+	indent := strings.HasPrefix(rest, "-")
+	rest = strings.TrimPrefix(rest, "-")
+	newline := strings.IndexByte(rest, '\n')
+	if newline == -1 {
+		return "", fmt.Errorf("unterminated heredoc")
+	}
+	marker := strings.TrimSpace(rest[:newline])
+	if marker == "" {
+		return "", fmt.Errorf("heredoc without a marker")
+	}
+
+	lines := strings.SplitAfter(rest[newline+1:], "\n")
+	consumed := len(l.src) - len(rest) + newline + 1
+	for i, line := range lines {
+		if strings.TrimSpace(line) != marker {
+			continue
+		}
+		body := lines[:i]
+		if indent {
+			body = dedent(body)
+		}
+		for _, line := range lines[:i+1] {
+			consumed += len(line)
+		}
+		l.line += i + 2
+		l.pos = consumed
+		// The newline after the marker is whitespace
+		if strings.HasSuffix(lines[i], "\n") {
+			l.pos--
+			l.line--
+		}
+		return strings.Join(body, ""), nil
+	}
+	return "", fmt.Errorf("heredoc isn't closed with %s", marker)
+}
+
+func dedent(lines []string) []string {
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		width := len(line) - len(strings.TrimLeft(line, " \t"))
+		if common == -1 || width < common {
+			common = width
+		}
+	}
+	dedented := make([]string, len(lines))
+	for i, line := range lines {
+		if common > 0 {
+			line = line[min(common, len(line)-len(strings.TrimLeft(line, " \t"))):]
+		}
+		dedented[i] = line
+	}
+	return dedented
+}
@@ -0,0 +1,621 @@
+package hcl
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"styx/schema"
+)
+
+// SQLFile renders the schema described by the HCL file at path as the
+// statements of a schema.sql
+func SQLFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return SQL(path, string(content))
+}
+
+// SQL renders the schema described by src as the statements of a
+// schema.sql. name is used in errors
+func SQL(name, src string) (string, error) {
+	body, err := Parse(name, src)
+	if err != nil {
+		return "", err
+	}
+	c := &converter{name: name, schemas: map[string]bool{"public": true}, enums: map[string][]string{}, tables: map[string][]string{}}
+	return c.convert(body)
+}
+
+type converter struct {
+	name string
+	// schemas are the declared schemas, enums and tables the qualified
+	// names of the declared ones by bare name
+	schemas map[string]bool
+	enums   map[string][]string
+	tables  map[string][]string
+
+	statements []string
+	// Foreign keys are added once every table exists
+	foreignKeys []string
+}
+
+func (c *converter) errorf(line int, format string, args ...any) error {
+	return fmt.Errorf("%s:%d: %s", c.name, line, fmt.Sprintf(format, args...))
+}
+
+func (c *converter) convert(body *Block) (string, error) {
+	if len(body.Attrs) > 0 {
+		return "", c.errorf(body.Attrs[0].Line, "unexpected attribute %s outside of a block", body.Attrs[0].Name)
+	}
+
+	// Objects are declared before any is converted, since they can be
+	// referenced before their block
+	for _, b := range body.Blocks {
+		if len(b.Labels) != 1 {
+			return "", c.errorf(b.Line, "%s block must have a single name", b.Type)
+		}
+		if b.Type == "schema" {
+			c.schemas[b.Labels[0]] = true
+		}
+	}
+	for _, b := range body.Blocks {
+		name, err := c.qualifiedName(b)
+		if err != nil {
+			return "", err
+		}
+		switch b.Type {
+		case "enum":
+			c.enums[b.Labels[0]] = append(c.enums[b.Labels[0]], name)
+		case "table":
+			c.tables[b.Labels[0]] = append(c.tables[b.Labels[0]], name)
+		}
+	}
+
+	order := []string{"schema", "extension", "enum", "table", "view", "materialized"}
+	for _, b := range body.Blocks {
+		if !slices.Contains(order, b.Type) {
+			return "", c.errorf(b.Line, "unsupported block %s, expected one of %s", b.Type, strings.Join(order, ", "))
+		}
+	}
+	for _, blockType := range order {
+		for _, b := range body.Blocks {
+			if b.Type != blockType {
+				continue
+			}
+			var err error
+			switch b.Type {
+			case "schema":
+				err = c.schema(b)
+			case "extension":
+				err = c.extension(b)
+			case "enum":
+				err = c.enum(b)
+			case "table":
+				err = c.table(b)
+			case "view", "materialized":
+				err = c.view(b)
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+		if blockType == "table" {
+			c.statements = append(c.statements, c.foreignKeys...)
+		}
+	}
+
+	return strings.Join(c.statements, "\n\n") + "\n", nil
+}
+
+// Returns the model name of the object declared by b, in the schema its
+// schema attribute references
+func (c *converter) qualifiedName(b *Block) (string, error) {
+	attr := b.Attr("schema")
+	if b.Type == "schema" || attr == nil {
+		return b.Labels[0], nil
+	}
+	if attr.Value.Kind != Ref || len(attr.Value.Path) != 2 || attr.Value.Path[0] != "schema" {
+		return "", c.errorf(attr.Line, "schema must reference a schema, e.g. schema.public")
+	}
+	schemaName := attr.Value.Path[1]
+	if !c.schemas[schemaName] {
+		return "", c.errorf(attr.Line, "schema %s isn't declared", schemaName)
+	}
+	return schema.QualifiedName(schemaName, b.Labels[0]), nil
+}
+
+// Checks that b only holds the given attributes and blocks
+func (c *converter) allow(b *Block, attrs []string, blocks ...string) error {
+	for _, a := range b.Attrs {
+		if !slices.Contains(attrs, a.Name) {
+			return c.errorf(a.Line, "unsupported attribute %s in %s block", a.Name, b.Type)
+		}
+	}
+	for _, child := range b.Blocks {
+		if !slices.Contains(blocks, child.Type) {
+			return c.errorf(child.Line, "unsupported block %s in %s block", child.Type, b.Type)
+		}
+	}
+	return nil
+}
+
+func (c *converter) schema(b *Block) error {
+	if err := c.allow(b, []string{"comment"}); err != nil {
+		return err
+	}
+	if b.Labels[0] != "public" {
+		c.statements = append(c.statements, fmt.Sprintf("CREATE SCHEMA %s;", schema.QuoteIdent(b.Labels[0])))
+	}
+	return nil
+}
+
+func (c *converter) extension(b *Block) error {
+	if err := c.allow(b, []string{"schema", "version"}); err != nil {
+		return err
+	}
+	c.statements = append(c.statements, fmt.Sprintf("CREATE EXTENSION %s;", schema.QuoteIdent(b.Labels[0])))
+	return nil
+}
+
+func (c *converter) enum(b *Block) error {
+	if err := c.allow(b, []string{"schema", "values"}); err != nil {
+		return err
+	}
+	name, err := c.qualifiedName(b)
+	if err != nil {
+		return err
+	}
+	values, err := c.strings(b, "values")
+	if err != nil {
+		return err
+	}
+	for i, value := range values {
+		values[i] = schema.QuoteLiteral(value)
+	}
+	c.statements = append(c.statements, fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", schema.QuoteName(name), strings.Join(values, ", ")))
+	return nil
+}
+
+func (c *converter) view(b *Block) error {
+	if err := c.allow(b, []string{"schema", "as", "comment"}); err != nil {
+		return err
+	}
+	name, err := c.qualifiedName(b)
+	if err != nil {
+		return err
+	}
+	query, err := c.string(b, "as", true)
+	if err != nil {
+		return err
+	}
+	kind := "VIEW"
+	if b.Type == "materialized" {
+		kind = "MATERIALIZED VIEW"
+	}
+	c.statements = append(c.statements, fmt.Sprintf("CREATE %s %s AS\n%s;", kind, schema.QuoteName(name), strings.TrimRight(strings.TrimSpace(query), ";")))
+	return nil
+}
+
+func (c *converter) table(b *Block) error {
+	if err := c.allow(b, []string{"schema", "comment"}, "column", "primary_key", "foreign_key", "index", "unique", "check"); err != nil {
+		return err
+	}
+	name, err := c.qualifiedName(b)
+	if err != nil {
+		return err
+	}
+	table := schema.QuoteName(name)
+
+	var definitions, after []string
+	for _, child := range b.Blocks {
+		switch child.Type {
+		case "column":
+			column, comment, err := c.column(child)
+			if err != nil {
+				return err
+			}
+			definitions = append(definitions, column)
+			if comment != "" {
+				after = append(after, fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s;", table, schema.QuoteIdent(child.Labels[0]), schema.QuoteLiteral(comment)))
+			}
+		case "primary_key", "unique", "check":
+			constraint, err := c.constraint(child)
+			if err != nil {
+				return err
+			}
+			definitions = append(definitions, constraint)
+		case "foreign_key":
+			foreignKey, err := c.foreignKey(child)
+			if err != nil {
+				return err
+			}
+			c.foreignKeys = append(c.foreignKeys, fmt.Sprintf("ALTER TABLE %s ADD %s;", table, foreignKey))
+		case "index":
+			index, err := c.index(child, table)
+			if err != nil {
+				return err
+			}
+			after = append(after, index)
+		}
+	}
+	if len(definitions) == 0 {
+		return c.errorf(b.Line, "table %s has no columns", name)
+	}
+
+	if comment, err := c.string(b, "comment", false); err != nil {
+		return err
+	} else if comment != "" {
+		after = append([]string{fmt.Sprintf("COMMENT ON TABLE %s IS %s;", table, schema.QuoteLiteral(comment))}, after...)
+	}
+	c.statements = append(c.statements, fmt.Sprintf("CREATE TABLE %s (\n    %s\n);", table, strings.Join(definitions, ",\n    ")))
+	c.statements = append(c.statements, after...)
+	return nil
+}
+
+// Returns the definition of the column in CREATE TABLE, and its comment
+func (c *converter) column(b *Block) (string, string, error) {
+	if err := c.allow(b, []string{"type", "null", "default", "comment"}, "identity", "as"); err != nil {
+		return "", "", err
+	}
+	if len(b.Labels) != 1 {
+		return "", "", c.errorf(b.Line, "column block must have a single name")
+	}
+	attr := b.Attr("type")
+	if attr == nil {
+		return "", "", c.errorf(b.Line, "column %s has no type", b.Labels[0])
+	}
+	columnType, err := c.columnType(attr.Value)
+	if err != nil {
+		return "", "", err
+	}
+
+	definition := schema.QuoteIdent(b.Labels[0]) + " " + columnType
+	// Columns are NOT NULL unless they say otherwise, like in Atlas
+	nullable, err := c.bool(b, "null")
+	if err != nil {
+		return "", "", err
+	}
+	if !nullable {
+		definition += " NOT NULL"
+	}
+	if attr := b.Attr("default"); attr != nil {
+		value, err := c.expression(attr.Value)
+		if err != nil {
+			return "", "", err
+		}
+		definition += " DEFAULT " + value
+	}
+	for _, child := range b.Blocks {
+		switch child.Type {
+		case "identity":
+			if err := c.allow(child, []string{"generated", "start", "increment"}); err != nil {
+				return "", "", err
+			}
+			generated := "BY DEFAULT"
+			if attr := child.Attr("generated"); attr != nil {
+				if generated, err = c.keyword(attr.Value); err != nil {
+					return "", "", err
+				}
+			}
+			definition += " GENERATED " + generated + " AS IDENTITY"
+			var options []string
+			for _, option := range []string{"start", "increment"} {
+				if attr := child.Attr(option); attr != nil {
+					if attr.Value.Kind != Number {
+						return "", "", c.errorf(attr.Line, "%s must be a number", option)
+					}
+					keyword := map[string]string{"start": "START WITH", "increment": "INCREMENT BY"}[option]
+					options = append(options, keyword+" "+attr.Value.Text)
+				}
+			}
+			if len(options) > 0 {
+				definition += " (" + strings.Join(options, " ") + ")"
+			}
+		case "as":
+			if err := c.allow(child, []string{"expr", "type"}); err != nil {
+				return "", "", err
+			}
+			expr, err := c.string(child, "expr", true)
+			if err != nil {
+				return "", "", err
+			}
+			definition += " GENERATED ALWAYS AS (" + expr + ") STORED"
+		}
+	}
+
+	comment, err := c.string(b, "comment", false)
+	return definition, comment, err
+}
+
+// Renders a type like varchar(255), double_precision, enum.status or
+// sql("integer[]")
+func (c *converter) columnType(v *Value) (string, error) {
+	switch {
+	case v.Kind == String:
+		return v.Text, nil
+	case v.Kind == Call && len(v.Path) == 1 && v.Path[0] == "sql":
+		return c.sqlCall(v)
+	case v.Kind == Ref && len(v.Path) == 2 && v.Path[0] == "enum":
+		names := c.enums[v.Path[1]]
+		if len(names) != 1 {
+			return "", c.errorf(v.Line, "enum %s isn't declared once", v.Path[1])
+		}
+		return schema.QuoteName(names[0]), nil
+	case v.Kind == Ref && len(v.Path) == 1:
+		return strings.ReplaceAll(v.Path[0], "_", " "), nil
+	case v.Kind == Call && len(v.Path) == 1:
+		var args []string
+		for _, arg := range v.Items {
+			if arg.Kind != Number {
+				return "", c.errorf(arg.Line, "arguments of type %s must be numbers", v.Path[0])
+			}
+			args = append(args, arg.Text)
+		}
+		return strings.ReplaceAll(v.Path[0], "_", " ") + "(" + strings.Join(args, ",") + ")", nil
+	}
+	return "", c.errorf(v.Line, "invalid type, expected e.g. integer, varchar(255), enum.name or sql(\"...\")")
+}
+
+// Renders a default expression: strings are literals, and sql("...") holds
+// any SQL expression
+func (c *converter) expression(v *Value) (string, error) {
+	switch v.Kind {
+	case String:
+		return schema.QuoteLiteral(v.Text), nil
+	case Number, Bool:
+		return v.Text, nil
+	case Null:
+		return "NULL", nil
+	case Call:
+		if len(v.Path) == 1 && v.Path[0] == "sql" {
+			return c.sqlCall(v)
+		}
+	}
+	return "", c.errorf(v.Line, "invalid default, expected a string, a number, a boolean or sql(\"...\")")
+}
+
+func (c *converter) sqlCall(v *Value) (string, error) {
+	if len(v.Items) != 1 || v.Items[0].Kind != String {
+		return "", c.errorf(v.Line, "sql() takes a single string")
+	}
+	return v.Items[0].Text, nil
+}
+
+// Renders a keyword written as a reference, like SET_NULL
+func (c *converter) keyword(v *Value) (string, error) {
+	if v.Kind != Ref || len(v.Path) != 1 {
+		return "", c.errorf(v.Line, "expected a keyword, e.g. CASCADE")
+	}
+	return strings.ToUpper(strings.ReplaceAll(v.Path[0], "_", " ")), nil
+}
+
+func (c *converter) constraint(b *Block) (string, error) {
+	prefix := ""
+	if len(b.Labels) == 1 {
+		prefix = "CONSTRAINT " + schema.QuoteIdent(b.Labels[0]) + " "
+	} else if len(b.Labels) > 1 || b.Type != "primary_key" {
+		return "", c.errorf(b.Line, "%s block must have a single name", b.Type)
+	}
+
+	switch b.Type {
+	case "check":
+		if err := c.allow(b, []string{"expr"}); err != nil {
+			return "", err
+		}
+		expr, err := c.string(b, "expr", true)
+		if err != nil {
+			return "", err
+		}
+		return prefix + "CHECK (" + expr + ")", nil
+	}
+
+	if err := c.allow(b, []string{"columns"}); err != nil {
+		return "", err
+	}
+	columns, err := c.columns(b, "columns", true)
+	if err != nil {
+		return "", err
+	}
+	keyword := "PRIMARY KEY"
+	if b.Type == "unique" {
+		keyword = "UNIQUE"
+	}
+	return prefix + keyword + " (" + strings.Join(columns, ", ") + ")", nil
+}
+
+func (c *converter) foreignKey(b *Block) (string, error) {
+	if err := c.allow(b, []string{"columns", "ref_columns", "on_update", "on_delete"}); err != nil {
+		return "", err
+	}
+	if len(b.Labels) != 1 {
+		return "", c.errorf(b.Line, "foreign_key block must have a single name")
+	}
+	columns, err := c.columns(b, "columns", true)
+	if err != nil {
+		return "", err
+	}
+
+	attr := b.Attr("ref_columns")
+	if attr == nil || attr.Value.Kind != List || len(attr.Value.Items) == 0 {
+		return "", c.errorf(b.Line, "foreign key %s needs ref_columns, e.g. [table.users.column.id]", b.Labels[0])
+	}
+	var refTable string
+	var refColumns []string
+	for _, item := range attr.Value.Items {
+		table, column, err := c.tableColumn(item)
+		if err != nil {
+			return "", err
+		}
+		if refTable != "" && table != refTable {
+			return "", c.errorf(item.Line, "ref_columns must be columns of the same table")
+		}
+		refTable = table
+		refColumns = append(refColumns, schema.QuoteIdent(column))
+	}
+
+	definition := fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)", schema.QuoteIdent(b.Labels[0]), strings.Join(columns, ", "), schema.QuoteName(refTable), strings.Join(refColumns, ", "))
+	for _, action := range []string{"on_update", "on_delete"} {
+		if attr := b.Attr(action); attr != nil {
+			keyword, err := c.keyword(attr.Value)
+			if err != nil {
+				return "", err
+			}
+			definition += " " + strings.ToUpper(strings.ReplaceAll(action, "_", " ")) + " " + keyword
+		}
+	}
+	return definition, nil
+}
+
+// Resolves a reference like table.users.column.id, or
+// table.app.users.column.id for a table outside of the public schema
+func (c *converter) tableColumn(v *Value) (string, string, error) {
+	path := v.Path
+	if v.Kind != Ref || len(path) < 4 || len(path) > 5 || path[0] != "table" || path[len(path)-2] != "column" {
+		return "", "", c.errorf(v.Line, "expected a column reference like table.users.column.id")
+	}
+	if len(path) == 5 {
+		return schema.QualifiedName(path[1], path[2]), path[4], nil
+	}
+	names := c.tables[path[1]]
+	if len(names) != 1 {
+		return "", "", c.errorf(v.Line, "table %s isn't declared once, qualify it like table.<schema>.%s", path[1], path[1])
+	}
+	return names[0], path[3], nil
+}
+
+func (c *converter) index(b *Block, table string) (string, error) {
+	if err := c.allow(b, []string{"columns", "unique", "type", "where", "include"}, "on"); err != nil {
+		return "", err
+	}
+	if len(b.Labels) != 1 {
+		return "", c.errorf(b.Line, "index block must have a single name")
+	}
+	keys, err := c.columns(b, "columns", false)
+	if err != nil {
+		return "", err
+	}
+	for _, on := range b.Blocks {
+		if err := c.allow(on, []string{"column", "expr", "desc"}); err != nil {
+			return "", err
+		}
+		var key string
+		if attr := on.Attr("column"); attr != nil {
+			if attr.Value.Kind != Ref || len(attr.Value.Path) != 2 || attr.Value.Path[0] != "column" {
+				return "", c.errorf(attr.Line, "expected a column reference like column.id")
+			}
+			key = schema.QuoteIdent(attr.Value.Path[1])
+		} else if key, err = c.string(on, "expr", true); err != nil {
+			return "", err
+		}
+		desc, err := c.bool(on, "desc")
+		if err != nil {
+			return "", err
+		}
+		if desc {
+			key += " DESC"
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return "", c.errorf(b.Line, "index %s has no columns", b.Labels[0])
+	}
+
+	unique, err := c.bool(b, "unique")
+	if err != nil {
+		return "", err
+	}
+	statement := "CREATE INDEX "
+	if unique {
+		statement = "CREATE UNIQUE INDEX "
+	}
+	statement += schema.QuoteIdent(b.Labels[0]) + " ON " + table
+	if attr := b.Attr("type"); attr != nil {
+		method, err := c.keyword(attr.Value)
+		if err != nil {
+			return "", err
+		}
+		statement += " USING " + strings.ToLower(method)
+	}
+	statement += " (" + strings.Join(keys, ", ") + ")"
+	include, err := c.columns(b, "include", false)
+	if err != nil {
+		return "", err
+	}
+	if len(include) > 0 {
+		statement += " INCLUDE (" + strings.Join(include, ", ") + ")"
+	}
+	where, err := c.string(b, "where", false)
+	if err != nil {
+		return "", err
+	}
+	if where != "" {
+		statement += " WHERE " + where
+	}
+	return statement + ";", nil
+}
+
+// Returns the quoted names of the columns a list attribute references
+func (c *converter) columns(b *Block, name string, required bool) ([]string, error) {
+	attr := b.Attr(name)
+	if attr == nil {
+		if required {
+			return nil, c.errorf(b.Line, "%s block needs %s", b.Type, name)
+		}
+		return nil, nil
+	}
+	if attr.Value.Kind != List {
+		return nil, c.errorf(attr.Line, "%s must be a list of columns, e.g. [column.id]", name)
+	}
+	var columns []string
+	for _, item := range attr.Value.Items {
+		if item.Kind != Ref || len(item.Path) != 2 || item.Path[0] != "column" {
+			return nil, c.errorf(item.Line, "expected a column reference like column.id")
+		}
+		columns = append(columns, schema.QuoteIdent(item.Path[1]))
+	}
+	return columns, nil
+}
+
+func (c *converter) string(b *Block, name string, required bool) (string, error) {
+	attr := b.Attr(name)
+	if attr == nil {
+		if required {
+			return "", c.errorf(b.Line, "%s block needs %s", b.Type, name)
+		}
+		return "", nil
+	}
+	if attr.Value.Kind != String {
+		return "", c.errorf(attr.Line, "%s must be a string", name)
+	}
+	return attr.Value.Text, nil
+}
+
+func (c *converter) strings(b *Block, name string) ([]string, error) {
+	attr := b.Attr(name)
+	if attr == nil || attr.Value.Kind != List {
+		return nil, c.errorf(b.Line, "%s block needs %s, a list of strings", b.Type, name)
+	}
+	var values []string
+	for _, item := range attr.Value.Items {
+		if item.Kind != String {
+			return nil, c.errorf(item.Line, "%s must be a list of strings", name)
+		}
+		values = append(values, item.Text)
+	}
+	return values, nil
+}
+
+func (c *converter) bool(b *Block, name string) (bool, error) {
+	attr := b.Attr(name)
+	if attr == nil {
+		return false, nil
+	}
+	if attr.Value.Kind != Bool {
+		return false, c.errorf(attr.Line, "%s must be true or false", name)
+	}
+	return attr.Value.Text == "true", nil
+}
@@ -0,0 +1,315 @@
+package hcl
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"styx/schema"
+)
+
+// Types written as references or calls, like character_varying(255), rather
+// than sql("...")
+var plainType = regexp.MustCompile(`^([a-z][a-z0-9 ]*[a-z0-9])(\(([0-9]+(, ?[0-9]+)?)\))?$`)
+
+var plainIdent = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+var number = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// Write describes s in HCL. Objects HCL schemas can't describe, like
+// functions and triggers, are an error rather than left out
+func Write(s *schema.Schema) (string, error) {
+	if err := describable(s); err != nil {
+		return "", err
+	}
+
+	w := &writer{schema: s}
+	w.emptyBlock("schema", "public")
+	for _, name := range s.Schemas {
+		w.emptyBlock("schema", name)
+	}
+	for _, e := range s.Extensions {
+		w.emptyBlock("extension", e.Name)
+	}
+	for _, e := range s.Enums {
+		schemaName, name := schema.SplitName(e.Name)
+		w.block(0, "enum", name)
+		w.attr(1, "schema", "schema."+ident(schemaName))
+		var values []string
+		for _, value := range e.Values {
+			values = append(values, quote(value))
+		}
+		w.attr(1, "values", "["+strings.Join(values, ", ")+"]")
+		w.end(0)
+	}
+	for _, t := range s.Tables {
+		w.table(t)
+	}
+	for _, v := range s.Views {
+		schemaName, name := schema.SplitName(v.Name)
+		blockType := "view"
+		if v.Materialized {
+			blockType = "materialized"
+		}
+		w.block(0, blockType, name)
+		w.attr(1, "schema", "schema."+ident(schemaName))
+		w.attr(1, "as", heredoc(1, v.Query))
+		w.end(0)
+	}
+	return strings.TrimSuffix(w.b.String(), "\n"), nil
+}
+
+// Returns an error listing the objects of s HCL schemas can't describe
+func describable(s *schema.Schema) error {
+	var unsupported []string
+	add := func(kind string, names []string) {
+		if len(names) > 0 {
+			unsupported = append(unsupported, fmt.Sprintf("%s (%s)", kind, strings.Join(names, ", ")))
+		}
+	}
+	names := func(count int, name func(int) string) []string {
+		var list []string
+		for i := range count {
+			list = append(list, name(i))
+		}
+		return list
+	}
+
+	add("domains", names(len(s.Domains), func(i int) string { return s.Domains[i].Name }))
+	add("composite types", names(len(s.CompositeTypes), func(i int) string { return s.CompositeTypes[i].Name }))
+	add("sequences", names(len(s.Sequences), func(i int) string { return s.Sequences[i].Name }))
+	add("functions", names(len(s.Functions), func(i int) string { return s.Functions[i].Name }))
+	add("privileges", names(len(s.Grants), func(i int) string { return s.Grants[i].Object }))
+	var triggers, policies, constraints []string
+	for _, t := range s.Tables {
+		for _, tr := range t.Triggers {
+			triggers = append(triggers, t.Name+"."+tr.Name)
+		}
+		for _, p := range t.Policies {
+			policies = append(policies, t.Name+"."+p.Name)
+		}
+		if t.RowSecurity && len(t.Policies) == 0 {
+			policies = append(policies, t.Name)
+		}
+		for _, c := range t.Constraints {
+			if c.Type == schema.Exclusion || c.Deferrable || c.Match != "" {
+				constraints = append(constraints, t.Name+"."+c.Name)
+			}
+		}
+	}
+	add("triggers", triggers)
+	add("row-level security", policies)
+	add("exclusion, deferrable or MATCH constraints", constraints)
+	if len(unsupported) > 0 {
+		return fmt.Errorf("HCL schemas can't describe %s, keep them in schema.sql", strings.Join(unsupported, "; "))
+	}
+	return nil
+}
+
+type writer struct {
+	schema *schema.Schema
+	b      strings.Builder
+}
+
+func (w *writer) block(depth int, blockType string, labels ...string) {
+	w.b.WriteString(strings.Repeat("  ", depth) + blockType)
+	for _, label := range labels {
+		w.b.WriteString(" " + quote(label))
+	}
+	w.b.WriteString(" {\n")
+}
+
+func (w *writer) end(depth int) {
+	w.b.WriteString(strings.Repeat("  ", depth) + "}\n")
+	if depth == 0 {
+		w.b.WriteString("\n")
+	}
+}
+
+// Writes a block without attributes, like schema "public" {}
+func (w *writer) emptyBlock(blockType, label string) {
+	w.b.WriteString(fmt.Sprintf("%s %s {}\n\n", blockType, quote(label)))
+}
+
+func (w *writer) attr(depth int, name, value string) {
+	w.b.WriteString(fmt.Sprintf("%s%s = %s\n", strings.Repeat("  ", depth), name, value))
+}
+
+func (w *writer) table(t *schema.Table) {
+	schemaName, name := schema.SplitName(t.Name)
+	w.block(0, "table", name)
+	w.attr(1, "schema", "schema."+ident(schemaName))
+	if t.Comment != "" {
+		w.attr(1, "comment", quote(t.Comment))
+	}
+
+	for _, c := range t.Columns {
+		w.block(1, "column", c.Name)
+		w.attr(2, "null", strconv.FormatBool(!c.NotNull))
+		w.attr(2, "type", w.columnType(c.Type))
+		if c.Default != "" {
+			w.attr(2, "default", expression(c.Default))
+		}
+		if c.Comment != "" {
+			w.attr(2, "comment", quote(c.Comment))
+		}
+		if c.Identity != "" {
+			w.block(2, "identity")
+			w.attr(3, "generated", strings.ReplaceAll(c.Identity, " ", "_"))
+			w.end(2)
+		}
+		if c.Generated != "" {
+			w.block(2, "as")
+			w.attr(3, "expr", quote(c.Generated))
+			w.attr(3, "type", "STORED")
+			w.end(2)
+		}
+		w.end(1)
+	}
+
+	for _, c := range t.Constraints {
+		switch c.Type {
+		case schema.PrimaryKey:
+			// The default name is left out, like in Atlas
+			if _, name := schema.SplitName(t.Name); c.Name == name+"_pkey" {
+				w.block(1, "primary_key")
+			} else {
+				w.block(1, "primary_key", c.Name)
+			}
+			w.attr(2, "columns", columnList(c.Columns))
+		case schema.Unique:
+			w.block(1, "unique", c.Name)
+			w.attr(2, "columns", columnList(c.Columns))
+		case schema.Check:
+			w.block(1, "check", c.Name)
+			w.attr(2, "expr", quote(c.Expression))
+		case schema.ForeignKey:
+			w.block(1, "foreign_key", c.Name)
+			w.attr(2, "columns", columnList(c.Columns))
+			var refs []string
+			for _, column := range c.RefColumns {
+				refs = append(refs, w.tableRef(c.RefTable)+".column."+ident(column))
+			}
+			w.attr(2, "ref_columns", "["+strings.Join(refs, ", ")+"]")
+			if c.OnUpdate != "" {
+				w.attr(2, "on_update", strings.ReplaceAll(c.OnUpdate, " ", "_"))
+			}
+			if c.OnDelete != "" {
+				w.attr(2, "on_delete", strings.ReplaceAll(c.OnDelete, " ", "_"))
+			}
+		default:
+			continue
+		}
+		w.end(1)
+	}
+
+	for _, i := range t.Indexes {
+		w.block(1, "index", i.Name)
+		if i.Unique {
+			w.attr(2, "unique", "true")
+		}
+		if i.Method != "" && i.Method != "btree" {
+			w.attr(2, "type", strings.ToUpper(i.Method))
+		}
+		if slices.IndexFunc(i.Keys, func(key string) bool { return !plainIdent.MatchString(key) }) == -1 {
+			w.attr(2, "columns", columnList(i.Keys))
+		} else {
+			for _, key := range i.Keys {
+				w.block(2, "on")
+				if plainIdent.MatchString(key) {
+					w.attr(3, "column", "column."+key)
+				} else {
+					w.attr(3, "expr", quote(key))
+				}
+				w.end(2)
+			}
+		}
+		if len(i.Include) > 0 {
+			w.attr(2, "include", columnList(i.Include))
+		}
+		if i.Where != "" {
+			w.attr(2, "where", quote(i.Where))
+		}
+		w.end(1)
+	}
+	w.end(0)
+}
+
+func (w *writer) columnType(t string) string {
+	for _, e := range w.schema.Enums {
+		if e.Name == t {
+			_, name := schema.SplitName(e.Name)
+			return "enum." + ident(name)
+		}
+	}
+	match := plainType.FindStringSubmatch(t)
+	if match == nil {
+		return "sql(" + quote(t) + ")"
+	}
+	rendered := strings.ReplaceAll(match[1], " ", "_")
+	if match[2] != "" {
+		rendered += "(" + strings.ReplaceAll(match[3], " ", "") + ")"
+	}
+	return rendered
+}
+
+// Returns the reference to a table, qualified with its schema if its bare
+// name is used in several
+func (w *writer) tableRef(table string) string {
+	schemaName, name := schema.SplitName(table)
+	count := 0
+	for _, t := range w.schema.Tables {
+		if _, other := schema.SplitName(t.Name); other == name {
+			count++
+		}
+	}
+	if count > 1 {
+		return "table." + ident(schemaName) + "." + ident(name)
+	}
+	return "table." + ident(name)
+}
+
+func expression(value string) string {
+	if number.MatchString(value) || value == "true" || value == "false" {
+		return value
+	}
+	return "sql(" + quote(value) + ")"
+}
+
+func columnList(columns []string) string {
+	refs := make([]string, len(columns))
+	for i, column := range columns {
+		refs[i] = "column." + ident(column)
+	}
+	return "[" + strings.Join(refs, ", ") + "]"
+}
+
+// Names that aren't identifiers are written as strings in references
+func ident(name string) string {
+	if plainIdent.MatchString(name) {
+		return name
+	}
+	return quote(name)
+}
+
+func quote(s string) string {
+	return strconv.Quote(s)
+}
+
+// Writes multi-line SQL as a heredoc, indented under depth
+func heredoc(depth int, sql string) string {
+	sql = strings.TrimSpace(sql)
+	if !strings.Contains(sql, "\n") {
+		return quote(sql)
+	}
+	indent := strings.Repeat("  ", depth+1)
+	var b strings.Builder
+	b.WriteString("<<-SQL\n")
+	for _, line := range strings.Split(sql, "\n") {
+		b.WriteString(indent + line + "\n")
+	}
+	b.WriteString(strings.Repeat("  ", depth) + "SQL")
+	return b.String()
+}
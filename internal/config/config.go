@@ -0,0 +1,674 @@
+// Package config loads the project configuration from styx.yaml. Every
+// setting has a default, so the file is optional.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Name of the config file looked up in the working directory, without extension
+const defaultName = "styx"
+
+type Config struct {
+	// DSN is the database commands like status and drift connect to. It's
+	// usually set per environment
+	DSN string `mapstructure:"dsn"`
+	// Schema is the path to the desired schema.sql file
+	Schema        string `mapstructure:"schema"`
+	MigrationsDir string `mapstructure:"migrations_dir"`
+	// MigrationsTable is the table golang-migrate records the version in
+	MigrationsTable string `mapstructure:"migrations_table"`
+	// Dialect is the database engine: postgres, cockroach, mysql or sqlite
+	Dialect string `mapstructure:"dialect"`
+	// Renames is the path to the file listing renamed tables and columns
+	Renames string `mapstructure:"renames"`
+	// Versioning is the version scheme of new migrations, sequential or
+	// timestamp. When it's empty, the scheme already in use is followed
+	Versioning string `mapstructure:"versioning"`
+	// Format is the layout of new migrations, after the tool applying them:
+	// golang-migrate, goose, dbmate or flyway
+	Format string `mapstructure:"format"`
+	// Lock is the path of the lock file recording the hash of every
+	// generated migration
+	Lock string `mapstructure:"lock"`
+	// Seeds is the directory of the SQL and CSV files holding reference
+	// data, like the rows of lookup tables
+	Seeds string `mapstructure:"seeds"`
+	// Fixture is realistic data the new migration is applied on top of in the
+	// scratch database: a directory of CSV and SQL files, or a pg_dump
+	// archive in custom format
+	Fixture string `mapstructure:"fixture"`
+	// Snapshots is the directory generate saves the schema at each new
+	// version to, for `styx history diff`
+	Snapshots string `mapstructure:"snapshots"`
+	// Pull is when the image of the scratch database container is pulled:
+	// always, missing or never
+	Pull string `mapstructure:"pull"`
+	// ColumnOrder is what generate does about tables whose columns are
+	// declared in another order than the database has them in: ignore, warn
+	// or rewrite
+	ColumnOrder string `mapstructure:"column_order"`
+
+	Postgres     Postgres               `mapstructure:"postgres"`
+	MySQL        MySQL                  `mapstructure:"mysql"`
+	Cockroach    Cockroach              `mapstructure:"cockroach"`
+	Environments map[string]Environment `mapstructure:"environments"`
+	Services     map[string]Service     `mapstructure:"services"`
+	Lint         Lint                   `mapstructure:"lint"`
+	Templates    Templates              `mapstructure:"templates"`
+	Schemas      Schemas                `mapstructure:"schemas"`
+	Objects      Objects                `mapstructure:"objects"`
+	Grants       Grants                 `mapstructure:"grants"`
+	Storage      Storage                `mapstructure:"storage"`
+	ForeignData  ForeignData            `mapstructure:"foreign_data"`
+	Publications Publications           `mapstructure:"publications"`
+	Backfill     Backfill               `mapstructure:"backfill"`
+	Expand       Expand                 `mapstructure:"expand"`
+	Timeouts     Timeouts               `mapstructure:"timeouts"`
+	Apply        Apply                  `mapstructure:"apply"`
+	Sqlc         Sqlc                   `mapstructure:"sqlc"`
+}
+
+// Postgres configures the throwaway database migrations are replayed in
+type Postgres struct {
+	// Embedded runs Postgres from downloaded binaries instead of Docker
+	Embedded bool `mapstructure:"embedded"`
+	// Version is the major version of the embedded Postgres
+	Version string `mapstructure:"version"`
+
+	Image string `mapstructure:"image"`
+	// Matrix lists Postgres versions, or images, the migrations are
+	// validated against after generating
+	Matrix []string `mapstructure:"matrix"`
+	// Extensions are installed in the scratch database before migrations
+	// are replayed, for the ones databases got outside of migrations. They
+	// aren't diffed
+	Extensions []string `mapstructure:"extensions"`
+	// ContainerPrefix starts the container names, which end with a random
+	// suffix so concurrent runs don't collide
+	ContainerPrefix string `mapstructure:"container_prefix"`
+	// Port is the host port the container's 5432 is published on. When it's
+	// 0, Docker picks a free port
+	Port int `mapstructure:"port"`
+	// Connection is how the container is reached: port publishes a port on
+	// the host, network connects to the container's address on its Docker
+	// network, for styx running in a container itself, and socket connects
+	// to its unix socket in a directory mounted from the host, for hosts that
+	// block published ports. exec doesn't connect at all, it runs statements
+	// with psql in the container through docker exec
+	Connection string `mapstructure:"connection"`
+	// Network is the Docker network the container is attached to, instead
+	// of the default bridge
+	Network  string `mapstructure:"network"`
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password"`
+	Database string `mapstructure:"database"`
+	// StartupTimeout is how long to wait for Postgres to accept connections
+	StartupTimeout time.Duration `mapstructure:"startup_timeout"`
+	// Reuse keeps the container running between runs, dropping its schemas
+	// instead of starting a new one every time
+	Reuse bool `mapstructure:"reuse"`
+	// Tmpfs keeps the data directory of the container in memory
+	Tmpfs bool `mapstructure:"tmpfs"`
+	// ScratchDSN is an existing database used as the scratch one instead of
+	// starting Postgres. It must be empty the first time, and is emptied on
+	// every run after that
+	ScratchDSN string `mapstructure:"scratch_dsn"`
+	// Settings are name=value server settings, applied after the scratch
+	// ones so they can override them, e.g. shared_preload_libraries to mimic
+	// production
+	Settings []string `mapstructure:"settings"`
+	// Env lists KEY=value variables added to the container's environment,
+	// e.g. POSTGRES_INITDB_ARGS=--locale=de_DE.UTF-8
+	Env []string `mapstructure:"env"`
+	// InitScripts is a directory of .sql and .sh files the image runs when
+	// it initializes the database
+	InitScripts string `mapstructure:"init_scripts"`
+	// Memory limits the memory of the container, e.g. 2g, and CPUs how many
+	// CPUs it can use, e.g. 1.5
+	Memory string  `mapstructure:"memory"`
+	CPUs   float64 `mapstructure:"cpus"`
+}
+
+// ScratchSettings are the settings scratch Postgres instances run with,
+// trading the durability a throwaway database doesn't need for speed
+var ScratchSettings = []string{
+	"fsync=off",
+	"synchronous_commit=off",
+	"full_page_writes=off",
+}
+
+// ServerSettings returns the settings the scratch Postgres runs with, the
+// scratch ones followed by the configured ones
+func (p Postgres) ServerSettings() []string {
+	return slices.Concat(ScratchSettings, p.Settings)
+}
+
+// MySQL configures the throwaway MySQL or MariaDB container used with the
+// mysql dialect
+type MySQL struct {
+	// Image can be a mysql or mariadb image
+	Image           string `mapstructure:"image"`
+	ContainerPrefix string `mapstructure:"container_prefix"`
+	// Port is the host port the container's 3306 is published on. When it's
+	// 0, Docker picks a free port
+	Port int `mapstructure:"port"`
+	// Password is the root password
+	Password string `mapstructure:"password"`
+	Database string `mapstructure:"database"`
+	// StartupTimeout is how long to wait for MySQL to accept connections.
+	// MySQL initializes its data directory on first start, which is slow
+	StartupTimeout time.Duration `mapstructure:"startup_timeout"`
+}
+
+// Cockroach configures the throwaway single-node CockroachDB cluster used
+// with the cockroach dialect
+type Cockroach struct {
+	Image           string `mapstructure:"image"`
+	ContainerPrefix string `mapstructure:"container_prefix"`
+	// Port is the host port the container's 26257 is published on. When
+	// it's 0, Docker picks a free port
+	Port     int    `mapstructure:"port"`
+	Database string `mapstructure:"database"`
+	// StartupTimeout is how long to wait for the cluster to accept
+	// connections
+	StartupTimeout time.Duration `mapstructure:"startup_timeout"`
+}
+
+// Environment is a database styx can be pointed at by name, e.g. staging.
+// Settings left empty fall back to the top-level ones
+type Environment struct {
+	DSN             string `mapstructure:"dsn"`
+	Schema          string `mapstructure:"schema"`
+	MigrationsDir   string `mapstructure:"migrations_dir"`
+	MigrationsTable string `mapstructure:"migrations_table"`
+	// Timeouts that are set override the top-level ones
+	Timeouts Timeouts `mapstructure:"timeouts"`
+}
+
+// Service is one of the databases of a monorepo, with its own schema and
+// migrations
+type Service struct {
+	// Dir is where the service's files are, relative to the config file.
+	// The paths of the config that aren't set for the service are taken to be
+	// relative to it, e.g. <dir>/schema.sql
+	Dir           string `mapstructure:"dir"`
+	Dialect       string `mapstructure:"dialect"`
+	Schema        string `mapstructure:"schema"`
+	MigrationsDir string `mapstructure:"migrations_dir"`
+	Renames       string `mapstructure:"renames"`
+	Lock          string `mapstructure:"lock"`
+	// Environments of the service override the top-level ones with the same
+	// name
+	Environments map[string]Environment `mapstructure:"environments"`
+}
+
+type Lint struct {
+	// Rules maps rule names to a severity: "error", "warning" or "off"
+	Rules map[string]string `mapstructure:"rules"`
+}
+
+// Templates customize the generated migration files, see migrate.TemplateData
+// for what they're rendered with
+type Templates struct {
+	Filename string `mapstructure:"filename"`
+	Header   string `mapstructure:"header"`
+}
+
+// Schemas selects the Postgres schemas styx manages. Objects in the other
+// ones, like schemas owned by extensions or other tools, are ignored
+type Schemas struct {
+	// Include lists glob patterns of the managed schemas. When it's empty,
+	// public and the schemas created in schema.sql are managed
+	Include []string `mapstructure:"include"`
+	// Exclude lists glob patterns of schemas ignored even if they're included
+	Exclude []string `mapstructure:"exclude"`
+}
+
+// Manages reports whether the objects of the named schema are diffed.
+// declared tells whether schema.sql creates the schema
+func (s Schemas) Manages(name string, declared bool) bool {
+	if matchAny(s.Exclude, name) {
+		return false
+	}
+	if len(s.Include) == 0 {
+		return name == "public" || declared
+	}
+	return matchAny(s.Include, name)
+}
+
+func (s Schemas) validate() error {
+	return validatePatterns("schema", append(slices.Clone(s.Include), s.Exclude...))
+}
+
+// Objects selects the tables, views, sequences, enums and functions styx
+// manages, so the ones owned by other tools can be left alone. Patterns are
+// matched against the name, which is schema-qualified outside public
+type Objects struct {
+	// Include lists glob patterns of the managed objects. When it's empty,
+	// every object is
+	Include []string `mapstructure:"include"`
+	// Exclude lists glob patterns of objects ignored even if they're included
+	Exclude []string `mapstructure:"exclude"`
+}
+
+// Manages reports whether the named object is diffed
+func (o Objects) Manages(name string) bool {
+	if matchAny(o.Exclude, name) {
+		return false
+	}
+	return len(o.Include) == 0 || matchAny(o.Include, name)
+}
+
+// Validate checks that the patterns are well-formed
+func (o Objects) Validate() error {
+	return validatePatterns("object", append(slices.Clone(o.Include), o.Exclude...))
+}
+
+func validatePatterns(kind string, patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid %s pattern %q: %w", kind, pattern, err)
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	})
+}
+
+// Grants configures the diffing of privileges on tables and views
+type Grants struct {
+	// Enabled turns on GRANT and REVOKE migrations. It's off by default,
+	// since many teams manage privileges elsewhere
+	Enabled bool `mapstructure:"enabled"`
+	// Roles are created in the scratch database, besides the ones schema.sql
+	// grants privileges to, so migrations granting to them can be replayed
+	Roles []string `mapstructure:"roles"`
+}
+
+// Storage configures the diffing of storage parameters and tablespaces
+type Storage struct {
+	// Ignore leaves the storage parameters and tablespaces of tables and
+	// indexes out of the diff, for databases tuned outside of migrations
+	Ignore bool `mapstructure:"ignore"`
+}
+
+// ForeignData configures the diffing of foreign servers, user mappings and
+// foreign tables
+type ForeignData struct {
+	// Enabled turns on migrations of the objects of foreign data wrappers.
+	// It's off by default, since user mappings hold credentials
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// Publications configures the diffing of logical replication publications
+type Publications struct {
+	// Enabled turns on CREATE and ALTER PUBLICATION migrations. It's off by
+	// default, since replication is often set up outside of migrations
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// Backfill configures the data migrations filling in columns that become NOT
+// NULL
+type Backfill struct {
+	// Enabled adds such columns as nullable and writes the skeleton of an
+	// UPDATE filling them in before they're made NOT NULL
+	Enabled bool `mapstructure:"enabled"`
+	// Required makes apply refuse migrations whose skeletons weren't
+	// completed
+	Required bool `mapstructure:"required"`
+}
+
+// Expand turns changes that block an existing table into safe multi-step
+// equivalents
+type Expand struct {
+	// Columns adds NOT NULL columns as nullable, backfills them and then
+	// makes them NOT NULL, like backfill.enabled
+	Columns bool `mapstructure:"columns"`
+	// Indexes builds and drops indexes on existing tables CONCURRENTLY, like
+	// --concurrent-indexes
+	Indexes bool `mapstructure:"indexes"`
+	// ForeignKeys adds foreign keys to existing tables NOT VALID, then
+	// validates them
+	ForeignKeys bool `mapstructure:"foreign_keys"`
+	// Checks adds CHECK constraints to existing tables NOT VALID, then
+	// validates them
+	Checks bool `mapstructure:"checks"`
+}
+
+// Timeouts are set at the top of generated Postgres migrations, so applying
+// them fails fast instead of queueing behind long transactions. Zero leaves
+// the server's setting alone
+type Timeouts struct {
+	// Lock is the lock_timeout, how long a statement waits for a lock
+	Lock time.Duration `mapstructure:"lock"`
+	// Statement is the statement_timeout, how long a statement may run
+	Statement time.Duration `mapstructure:"statement"`
+}
+
+// Apply configures how migrations are applied
+type Apply struct {
+	// LockWait is how long apply waits for another one running against the
+	// same Postgres database to finish
+	LockWait time.Duration `mapstructure:"lock_wait"`
+}
+
+// Sqlc keeps the code sqlc generates from queries in step with the schema
+type Sqlc struct {
+	// Schema is the file generate writes the schema the migrations add up to
+	// to, after writing a migration, for sqlc to read. Empty turns it off
+	Schema string `mapstructure:"schema"`
+	// Command is the sqlc command run after writing the schema: generate,
+	// compile or vet to only check the queries against it, or empty for none
+	Command string `mapstructure:"command"`
+	// Config is the sqlc config file. When it's empty, sqlc looks for
+	// sqlc.yaml in the working directory
+	Config string `mapstructure:"config"`
+}
+
+// Load reads the config file at path, or styx.yaml in the working directory
+// if path is empty. Settings can also be overridden with STYX_ environment
+// variables, e.g. STYX_MIGRATIONS_DIR
+func Load(path string) (*Config, error) {
+	v := viper.New()
+
+	v.SetDefault("schema", "schema.sql")
+	v.SetDefault("migrations_dir", "migrations")
+	v.SetDefault("migrations_table", "schema_migrations")
+	v.SetDefault("dialect", "postgres")
+	v.SetDefault("renames", "renames.yaml")
+	v.SetDefault("format", "golang-migrate")
+	v.SetDefault("lock", "styx.lock")
+	v.SetDefault("seeds", "seeds")
+	v.SetDefault("snapshots", ".styx/snapshots")
+	v.SetDefault("pull", PullMissing)
+	v.SetDefault("column_order", ColumnOrderIgnore)
+	v.SetDefault("apply.lock_wait", "1m")
+	v.SetDefault("postgres.version", "16")
+	v.SetDefault("postgres.image", "postgres:16-bookworm")
+	v.SetDefault("postgres.container_prefix", "styx")
+	v.SetDefault("postgres.user", "postgres")
+	v.SetDefault("postgres.password", "postgres")
+	v.SetDefault("postgres.database", "styx")
+	v.SetDefault("postgres.startup_timeout", "30s")
+	v.SetDefault("postgres.tmpfs", true)
+	v.SetDefault("postgres.connection", ConnectPort)
+	v.SetDefault("mysql.image", "mysql:8.4")
+	v.SetDefault("mysql.container_prefix", "styx")
+	v.SetDefault("mysql.password", "styx")
+	v.SetDefault("mysql.database", "styx")
+	v.SetDefault("mysql.startup_timeout", "90s")
+	v.SetDefault("cockroach.image", "cockroachdb/cockroach:v24.1.0")
+	v.SetDefault("cockroach.container_prefix", "styx")
+	v.SetDefault("cockroach.database", "styx")
+	v.SetDefault("cockroach.startup_timeout", "60s")
+
+	v.SetEnvPrefix("STYX")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.SetConfigName(defaultName)
+		v.AddConfigPath(".")
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if path != "" || !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+	}
+
+	cfg := &Config{}
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	// DSNs may reference environment variables, e.g. ${PROD_PASSWORD}, so
+	// secrets don't have to be committed
+	cfg.DSN = os.ExpandEnv(cfg.DSN)
+	cfg.Postgres.ScratchDSN = os.ExpandEnv(cfg.Postgres.ScratchDSN)
+
+	if err := cfg.Schemas.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Objects.Validate(); err != nil {
+		return nil, err
+	}
+	if err := ValidatePull(cfg.Pull); err != nil {
+		return nil, err
+	}
+	if !slices.Contains([]string{ColumnOrderIgnore, ColumnOrderWarn, ColumnOrderRewrite}, cfg.ColumnOrder) {
+		return nil, fmt.Errorf("invalid column order %q, expected ignore, warn or rewrite", cfg.ColumnOrder)
+	}
+	if !slices.Contains([]string{ConnectPort, ConnectNetwork, ConnectSocket, ConnectExec}, cfg.Postgres.Connection) {
+		return nil, fmt.Errorf("invalid postgres connection %q, expected port, network, socket or exec", cfg.Postgres.Connection)
+	}
+	for _, setting := range cfg.Postgres.Settings {
+		if !strings.Contains(setting, "=") {
+			return nil, fmt.Errorf("invalid postgres setting %q, expected name=value", setting)
+		}
+	}
+	for _, env := range cfg.Postgres.Env {
+		if !strings.Contains(env, "=") {
+			return nil, fmt.Errorf("invalid postgres env %q, expected KEY=value", env)
+		}
+	}
+	if !slices.Contains([]string{"", "generate", "compile", "vet"}, cfg.Sqlc.Command) {
+		return nil, fmt.Errorf("invalid sqlc command %q, expected generate, compile or vet", cfg.Sqlc.Command)
+	}
+
+	return cfg, nil
+}
+
+// Pull policies of the scratch database's image
+const (
+	// PullAlways pulls the image every time, to pick up new builds of its tag
+	PullAlways = "always"
+	// PullMissing only pulls images that aren't available locally
+	PullMissing = "missing"
+	// PullNever never pulls, for airgapped machines with the image loaded
+	// beforehand, e.g. with docker load
+	PullNever = "never"
+)
+
+// Policies for columns declared in another order than the database has
+// them in, which Postgres can't reorder in place
+const (
+	// ColumnOrderIgnore leaves the columns in the order they're in
+	ColumnOrderIgnore = "ignore"
+	// ColumnOrderWarn leaves them too, but logs the tables they differ in
+	ColumnOrderWarn = "warn"
+	// ColumnOrderRewrite copies the rows of such tables into new tables
+	// with the columns in the declared order
+	ColumnOrderRewrite = "rewrite"
+)
+
+// Ways of connecting to the scratch Postgres container
+const (
+	ConnectPort    = "port"
+	ConnectNetwork = "network"
+	ConnectSocket  = "socket"
+	ConnectExec    = "exec"
+)
+
+// ValidatePull checks that pull is one of the pull policies
+func ValidatePull(pull string) error {
+	if !slices.Contains([]string{PullAlways, PullMissing, PullNever}, pull) {
+		return fmt.Errorf("invalid pull policy %q, expected always, missing or never", pull)
+	}
+	return nil
+}
+
+// Use returns a copy of the config with the settings of the named
+// environment applied on top
+func (c *Config) Use(environment string) (*Config, error) {
+	env, ok := c.Environments[environment]
+	if !ok {
+		return nil, fmt.Errorf("environment %s is not defined in the config", environment)
+	}
+
+	merged := *c
+	for _, setting := range []struct {
+		target *string
+		value  string
+	}{
+		{&merged.DSN, os.ExpandEnv(env.DSN)},
+		{&merged.Schema, env.Schema},
+		{&merged.MigrationsDir, env.MigrationsDir},
+		{&merged.MigrationsTable, env.MigrationsTable},
+	} {
+		if setting.value != "" {
+			*setting.target = setting.value
+		}
+	}
+	if env.Timeouts.Lock != 0 {
+		merged.Timeouts.Lock = env.Timeouts.Lock
+	}
+	if env.Timeouts.Statement != 0 {
+		merged.Timeouts.Statement = env.Timeouts.Statement
+	}
+
+	return &merged, nil
+}
+
+// UseService returns a copy of the config with the settings of the named
+// service applied on top
+func (c *Config) UseService(service string) (*Config, error) {
+	svc, ok := c.Services[service]
+	if !ok {
+		return nil, fmt.Errorf("service %s is not defined in the config", service)
+	}
+
+	merged := *c
+	if svc.Dir != "" {
+		for _, p := range []*string{&merged.Schema, &merged.MigrationsDir, &merged.Renames, &merged.Lock, &merged.Seeds, &merged.Snapshots, &merged.Fixture} {
+			if *p != "" && !filepath.IsAbs(*p) {
+				*p = filepath.Join(svc.Dir, *p)
+			}
+		}
+	}
+	for _, setting := range []struct {
+		target *string
+		value  string
+	}{
+		{&merged.Dialect, svc.Dialect},
+		{&merged.Schema, svc.Schema},
+		{&merged.MigrationsDir, svc.MigrationsDir},
+		{&merged.Renames, svc.Renames},
+		{&merged.Lock, svc.Lock},
+	} {
+		if setting.value != "" {
+			*setting.target = setting.value
+		}
+	}
+	if len(svc.Environments) > 0 {
+		merged.Environments = maps.Clone(c.Environments)
+		if merged.Environments == nil {
+			merged.Environments = map[string]Environment{}
+		}
+		maps.Copy(merged.Environments, svc.Environments)
+	}
+
+	// Services get containers of their own, even when they're kept for reuse
+	merged.Postgres.ContainerPrefix += "-" + service
+	merged.MySQL.ContainerPrefix += "-" + service
+	merged.Cockroach.ContainerPrefix += "-" + service
+
+	return &merged, nil
+}
+
+// ServiceNames returns the names of the services, sorted
+func (c *Config) ServiceNames() []string {
+	names := make([]string, 0, len(c.Services))
+	for name := range c.Services {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// EnvironmentDSN returns the connection string of the named environment
+func (c *Config) EnvironmentDSN(environment string) (string, error) {
+	env, err := c.Use(environment)
+	if err != nil {
+		return "", err
+	}
+	if env.DSN == "" {
+		return "", fmt.Errorf("environment %s has no dsn", environment)
+	}
+	return env.DSN, nil
+}
+
+// ForVersion returns a copy of the settings running the given Postgres
+// version instead. Anything that isn't a plain version is taken to be an image
+func (p Postgres) ForVersion(version string) Postgres {
+	// Each version needs a database of its own
+	p.ScratchDSN = ""
+	if strings.ContainsAny(version, ":/") {
+		p.Image = version
+		p.Embedded = false
+		return p
+	}
+
+	p.Version = version
+	p.Image = "postgres:" + version
+	return p
+}
+
+// ContainerDSN returns the connection string of the throwaway database,
+// given the host and port it listens on. A host starting with / is the
+// directory of its unix socket
+func (p Postgres) ContainerDSN(host, port string) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(p.User, p.Password),
+		Path:     "/" + p.Database,
+		RawQuery: "sslmode=disable",
+	}
+	if strings.HasPrefix(host, "/") {
+		u.RawQuery += "&" + url.Values{"host": {host}, "port": {port}}.Encode()
+	} else {
+		u.Host = net.JoinHostPort(host, port)
+	}
+	return u.String()
+}
+
+// ContainerDSN returns the connection string of the throwaway database,
+// given the host and port it listens on. Multiple statements are allowed, as
+// migrations usually contain more than one
+func (m MySQL) ContainerDSN(host, port string) string {
+	return fmt.Sprintf("root:%s@tcp(%s)/%s?multiStatements=true", m.Password, net.JoinHostPort(host, port), m.Database)
+}
+
+// ContainerDSN returns the connection string of the throwaway cluster, given
+// the host and port it listens on. The cluster runs in insecure mode, so root
+// has no password
+func (c Cockroach) ContainerDSN(host, port string) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.User("root"),
+		Host:     net.JoinHostPort(host, port),
+		Path:     "/" + c.Database,
+		RawQuery: "sslmode=disable",
+	}
+	return u.String()
+}
@@ -0,0 +1,485 @@
+// Package docker runs the throwaway database containers migrations are
+// replayed in.
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+
+	"styx/internal/config"
+	"styx/internal/psqlexec"
+)
+
+// Label marks the containers and volumes created by styx, so leftovers can
+// be found and cleaned up
+const Label = "io.styx.managed"
+
+const (
+	postgresPort  = nat.Port("5432/tcp")
+	mysqlPort     = nat.Port("3306/tcp")
+	cockroachPort = nat.Port("26257/tcp")
+)
+
+// Data directory of Postgres in the container
+const postgresData = "/var/lib/postgresql/data"
+
+// Directory of the unix socket of Postgres in the container
+const postgresSocketDir = "/var/run/postgresql"
+
+// Directory the postgres image runs initialization scripts from
+const postgresInitScripts = "/docker-entrypoint-initdb.d"
+
+// Starts the names of the host directories sockets are mounted from
+const socketDirPrefix = "styx-socket-"
+
+// Container is a running database container
+type Container struct {
+	ID   string
+	Name string
+	// DSN connects to the container from the host
+	DSN string
+	// Reusable is set when the container is kept running for the next runs,
+	// and Reused when it was started by an earlier one, so its database
+	// holds what that run left
+	Reusable bool
+	Reused   bool
+
+	// database/sql driver used to wait for the database
+	driver string
+	// Product name used in logs and errors
+	product string
+	client  *client.Client
+	// socketDir is the host directory mounted for the unix socket, removed
+	// along with the container
+	socketDir string
+}
+
+// What to run, and how to connect to it once it's up
+type spec struct {
+	product string
+	driver  string
+	image   string
+	prefix  string
+	cmd     []string
+	env     []string
+	port    nat.Port
+	// tmpfs mounts by path in the container, and binds the host directories
+	// mounted in it as host:container:mode
+	tmpfs map[string]string
+	binds []string
+	// resources limit the memory and CPUs of the container
+	resources container.Resources
+	// Port to publish on, or 0 for a free one
+	hostPort int
+	// connection is how the container is reached, e.g. config.ConnectPort
+	// when it's empty, and network the Docker network it's attached to
+	connection string
+	network    string
+	// socketDir is the directory of the unix socket in the container
+	socketDir string
+	dsn       func(host, port string) string
+	// pull is the pull policy of the image
+	pull string
+	// reuse keeps the container for the next runs
+	reuse bool
+}
+
+// Start pulls the image, as the pull policy says, and starts a uniquely
+// named Postgres container. With pg.Reuse, the container an earlier run left
+// running is returned instead when there's one
+func Start(ctx context.Context, pg config.Postgres, pull string) (*Container, error) {
+	cmd := []string{"postgres"}
+	for _, setting := range pg.ServerSettings() {
+		cmd = append(cmd, "-c", setting)
+	}
+	var tmpfs map[string]string
+	if pg.Tmpfs {
+		tmpfs = map[string]string{postgresData: "rw"}
+	}
+	var binds []string
+	if pg.InitScripts != "" {
+		dir, err := filepath.Abs(pg.InitScripts)
+		if err != nil {
+			return nil, err
+		}
+		binds = append(binds, dir+":"+postgresInitScripts+":ro")
+	}
+	resources := container.Resources{NanoCPUs: int64(pg.CPUs * 1e9)}
+	if pg.Memory != "" {
+		memory, err := units.RAMInBytes(pg.Memory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid postgres memory %q: %w", pg.Memory, err)
+		}
+		resources.Memory = memory
+	}
+	return run(ctx, spec{
+		product: "PostgreSQL",
+		driver:  "postgres",
+		image:   pg.Image,
+		prefix:  pg.ContainerPrefix,
+		cmd:     cmd,
+		env: append([]string{
+			"POSTGRES_USER=" + pg.User,
+			"POSTGRES_PASSWORD=" + pg.Password,
+			"POSTGRES_DB=" + pg.Database,
+			// The default moved to /var/lib/postgresql/18/docker in Postgres
+			// 18 images, so it's set for the tmpfs to be mounted over it
+			"PGDATA=" + postgresData,
+		}, pg.Env...),
+		tmpfs:      tmpfs,
+		binds:      binds,
+		resources:  resources,
+		port:       postgresPort,
+		hostPort:   pg.Port,
+		connection: pg.Connection,
+		network:    pg.Network,
+		socketDir:  postgresSocketDir,
+		pull:       pull,
+		dsn:        pg.ContainerDSN,
+		reuse:      pg.Reuse,
+	})
+}
+
+// StartMySQL pulls the image, as the pull policy says, and starts a uniquely
+// named MySQL or MariaDB container
+func StartMySQL(ctx context.Context, my config.MySQL, pull string) (*Container, error) {
+	return run(ctx, spec{
+		product: "MySQL",
+		driver:  "mysql",
+		image:   my.Image,
+		prefix:  my.ContainerPrefix,
+		// The mariadb image reads the MYSQL_ variables too
+		env: []string{
+			"MYSQL_ROOT_PASSWORD=" + my.Password,
+			"MYSQL_DATABASE=" + my.Database,
+		},
+		port:     mysqlPort,
+		hostPort: my.Port,
+		pull:     pull,
+		dsn:      my.ContainerDSN,
+	})
+}
+
+// StartCockroach pulls the image, as the pull policy says, and starts a
+// uniquely named single-node CockroachDB cluster
+func StartCockroach(ctx context.Context, crdb config.Cockroach, pull string) (*Container, error) {
+	return run(ctx, spec{
+		product:  "CockroachDB",
+		driver:   "postgres",
+		image:    crdb.Image,
+		prefix:   crdb.ContainerPrefix,
+		cmd:      []string{"start-single-node", "--insecure"},
+		env:      []string{"COCKROACH_DATABASE=" + crdb.Database},
+		port:     cockroachPort,
+		hostPort: crdb.Port,
+		pull:     pull,
+		dsn:      crdb.ContainerDSN,
+	})
+}
+
+func run(ctx context.Context, s spec) (*Container, error) {
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+
+	var name string
+	if s.reuse {
+		name = reusableName(s)
+		c, err := restart(ctx, dockerClient, s, name)
+		if c != nil || err != nil {
+			return c, err
+		}
+	} else {
+		name, err = containerName(s.prefix)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ensureImage(ctx, dockerClient, s.image, s.pull); err != nil {
+		return nil, fmt.Errorf("failed to pull %s docker image: %w", s.product, err)
+	}
+
+	hostConfig := &container.HostConfig{Tmpfs: s.tmpfs, Binds: s.binds, Resources: s.resources}
+	if s.network != "" {
+		hostConfig.NetworkMode = container.NetworkMode(s.network)
+	}
+	var socketDir string
+	switch s.connection {
+	case config.ConnectNetwork, config.ConnectExec:
+		// Nothing is published, the container is reached on its network or
+		// statements run in it
+	case config.ConnectSocket:
+		socketDir, err = os.MkdirTemp("", socketDirPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create socket directory: %w", err)
+		}
+		// The database creates its socket as the user it runs as in the
+		// container
+		if err := os.Chmod(socketDir, 0777); err != nil {
+			os.RemoveAll(socketDir)
+			return nil, fmt.Errorf("failed to create socket directory: %w", err)
+		}
+		hostConfig.Binds = append(hostConfig.Binds, socketDir+":"+s.socketDir)
+	default:
+		// Without a configured port Docker picks a free one
+		hostPort := ""
+		if s.hostPort != 0 {
+			hostPort = strconv.Itoa(s.hostPort)
+		}
+		hostConfig.PortBindings = nat.PortMap{
+			s.port: []nat.PortBinding{
+				{
+					HostIP:   "127.0.0.1",
+					HostPort: hostPort,
+				},
+			},
+		}
+	}
+
+	log.Trace().Msgf("Starting %s docker container %s", s.product, name)
+	resp, err := dockerClient.ContainerCreate(
+		ctx,
+		&container.Config{
+			Image: s.image,
+			Cmd:   s.cmd,
+			Env:   s.env,
+			ExposedPorts: nat.PortSet{
+				s.port: struct{}{},
+			},
+			Labels: map[string]string{Label: "true"},
+		},
+		hostConfig,
+		nil,
+		nil,
+		name,
+	)
+	if err != nil {
+		if socketDir != "" {
+			os.RemoveAll(socketDir)
+		}
+		return nil, fmt.Errorf("failed to create %s container: %w", s.product, err)
+	}
+
+	c := &Container{ID: resp.ID, Name: name, Reusable: s.reuse, driver: s.driver, product: s.product, client: dockerClient, socketDir: socketDir}
+	if err := dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		c.Remove(ctx)
+		return nil, fmt.Errorf("failed to start %s container: %w", s.product, err)
+	}
+	if err := c.connect(ctx, s); err != nil {
+		c.Remove(ctx)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Returns the container named name left by an earlier run, started again if
+// it was stopped, or nil if there's none
+func restart(ctx context.Context, dockerClient *client.Client, s spec, name string) (*Container, error) {
+	inspect, err := dockerClient.ContainerInspect(ctx, name)
+	if client.IsErrNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s container: %w", s.product, err)
+	}
+
+	c := &Container{ID: inspect.ID, Name: name, Reusable: true, Reused: true, driver: s.driver, product: s.product, client: dockerClient}
+	if inspect.State.Running {
+		log.Info().Msgf("Reusing %s container %s", s.product, name)
+	} else {
+		log.Info().Msgf("Restarting %s container %s...", s.product, name)
+		if err := dockerClient.ContainerStart(ctx, inspect.ID, container.StartOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to start %s container %s, run `styx clean` to remove it: %w", s.product, name, err)
+		}
+	}
+	if err := c.connect(ctx, s); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Sets the DSN from where the container is reached: the host port its port
+// is published on, its address on its network, or its socket directory
+func (c *Container) connect(ctx context.Context, s spec) error {
+	inspect, err := c.client.ContainerInspect(ctx, c.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s container: %w", s.product, err)
+	}
+
+	switch s.connection {
+	case config.ConnectNetwork:
+		ip := inspect.NetworkSettings.IPAddress
+		if network, ok := inspect.NetworkSettings.Networks[s.network]; ok {
+			ip = network.IPAddress
+		}
+		if ip == "" {
+			return fmt.Errorf("%s container has no address on its network", s.product)
+		}
+		c.DSN = s.dsn(ip, s.port.Port())
+	case config.ConnectSocket:
+		for _, mount := range inspect.Mounts {
+			if mount.Destination == s.socketDir {
+				c.socketDir = mount.Source
+			}
+		}
+		if c.socketDir == "" {
+			return fmt.Errorf("%s container has no socket directory mounted", s.product)
+		}
+		c.DSN = s.dsn(c.socketDir, s.port.Port())
+	case config.ConnectExec:
+		u, err := url.Parse(s.dsn("localhost", s.port.Port()))
+		if err != nil {
+			return err
+		}
+		u.Scheme = psqlexec.Scheme
+		u.Host = c.Name
+		c.DSN = u.String()
+		c.driver = psqlexec.DriverName
+	default:
+		bindings := inspect.NetworkSettings.Ports[s.port]
+		if len(bindings) == 0 {
+			return fmt.Errorf("%s container has no published port", s.product)
+		}
+		c.DSN = s.dsn(bindings[0].HostIP, bindings[0].HostPort)
+	}
+	return nil
+}
+
+// Wait polls the database until it accepts connections, backing off
+// exponentially between attempts
+func (c *Container) Wait(ctx context.Context, timeout time.Duration) error {
+	db, err := sql.Open(c.driver, c.DSN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delay := 50 * time.Millisecond
+	for {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		log.Trace().Err(err).Msgf("%s is not ready yet", c.product)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s did not become ready within %s: %w", c.product, timeout, err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, 2*time.Second)
+	}
+}
+
+// Remove stops and removes the container along with its volumes. It runs
+// even when ctx is cancelled, since that's when runs that were interrupted
+// clean up
+func (c *Container) Remove(ctx context.Context) error {
+	log.Info().Msgf("Removing %s container %s...", c.product, c.Name)
+	ctx = context.WithoutCancel(ctx)
+
+	timeout := 10
+	if err := c.client.ContainerStop(ctx, c.ID, container.StopOptions{Timeout: &timeout}); err != nil {
+		log.Warn().Err(err).Msg("Failed to stop container, removing it anyway")
+	}
+
+	if err := c.client.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true, RemoveVolumes: true}); err != nil {
+		return fmt.Errorf("failed to remove container %s: %w", c.Name, err)
+	}
+	if c.socketDir != "" {
+		os.RemoveAll(c.socketDir)
+	}
+
+	return nil
+}
+
+// Clean removes the containers and volumes left behind by runs that didn't
+// clean up after themselves, and returns their names
+func Clean(ctx context.Context) ([]string, error) {
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+
+	labelFilter := filters.NewArgs(filters.Arg("label", Label))
+
+	containers, err := dockerClient.ContainerList(ctx, container.ListOptions{All: true, Filters: labelFilter})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	var removed []string
+	for _, c := range containers {
+		name := c.ID[:12]
+		if len(c.Names) > 0 {
+			name = c.Names[0][1:]
+		}
+		if err := dockerClient.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true, RemoveVolumes: true}); err != nil {
+			return removed, fmt.Errorf("failed to remove container %s: %w", name, err)
+		}
+		removed = append(removed, name)
+		for _, mount := range c.Mounts {
+			if strings.HasPrefix(filepath.Base(mount.Source), socketDirPrefix) {
+				os.RemoveAll(mount.Source)
+			}
+		}
+	}
+
+	volumes, err := dockerClient.VolumeList(ctx, volume.ListOptions{Filters: labelFilter})
+	if err != nil {
+		return removed, fmt.Errorf("failed to list volumes: %w", err)
+	}
+	for _, v := range volumes.Volumes {
+		if err := dockerClient.VolumeRemove(ctx, v.Name, true); err != nil {
+			return removed, fmt.Errorf("failed to remove volume %s: %w", v.Name, err)
+		}
+		removed = append(removed, v.Name)
+	}
+
+	return removed, nil
+}
+
+// Returns the name of the container kept for reuse, which is the same for
+// runs in the same directory with the same image and settings, so changing
+// them doesn't reuse a container that doesn't match
+func reusableName(s spec) string {
+	dir, _ := os.Getwd()
+	sum := sha256.Sum256([]byte(strings.Join(slices.Concat([]string{dir, s.image, strconv.Itoa(s.hostPort), fmt.Sprint(s.tmpfs), s.connection, s.network, fmt.Sprint(s.resources.Memory, s.resources.NanoCPUs)}, s.env, s.cmd, s.binds), "\x00")))
+	return s.prefix + "-reuse-" + hex.EncodeToString(sum[:6])
+}
+
+// Appends a random suffix to the prefix, so concurrent runs don't collide
+func containerName(prefix string) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate container name: %w", err)
+	}
+	return prefix + "-" + hex.EncodeToString(suffix), nil
+}
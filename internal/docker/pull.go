@@ -0,0 +1,143 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"styx/internal/config"
+)
+
+// pullMessage is a line of the JSON stream Docker reports a pull's progress in
+type pullMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error string `json:"error"`
+}
+
+// layer is the download progress of one of the image's layers
+type layer struct {
+	current int64
+	total   int64
+	done    bool
+}
+
+// Statuses of the layers, the other messages are about the whole image
+var layerStatuses = map[string]bool{
+	"Pulling fs layer":   true,
+	"Waiting":            true,
+	"Downloading":        true,
+	"Verifying Checksum": true,
+	"Download complete":  true,
+	"Extracting":         true,
+	"Pull complete":      true,
+	"Already exists":     true,
+}
+
+// Makes sure the image is available locally, pulling it as the pull policy
+// says
+func ensureImage(ctx context.Context, dockerClient *client.Client, ref, policy string) error {
+	if policy != config.PullAlways {
+		_, err := dockerClient.ImageInspect(ctx, ref)
+		if err == nil {
+			log.Debug().Msgf("Using the local %s docker image", ref)
+			return nil
+		}
+		if !client.IsErrNotFound(err) {
+			return err
+		}
+		if policy == config.PullNever {
+			return fmt.Errorf("%s isn't available locally and the pull policy is never, load it with docker pull or docker load first", ref)
+		}
+	}
+	return pull(ctx, dockerClient, ref)
+}
+
+// Pulls the image, drawing the layers pulled and the share of their bytes
+// downloaded on a terminal. Elsewhere, and at other log levels than info, the
+// layers' statuses are logged at debug level instead. Docker only pulls as
+// long as the progress is read
+func pull(ctx context.Context, dockerClient *client.Client, ref string) error {
+	log.Info().Msgf("Pulling %s docker image...", ref)
+	reader, err := dockerClient.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	draw := isatty.IsTerminal(os.Stderr.Fd()) && zerolog.GlobalLevel() == zerolog.InfoLevel
+	var ids []string
+	layers := map[string]*layer{}
+	decoder := json.NewDecoder(reader)
+	for {
+		var message pullMessage
+		if err := decoder.Decode(&message); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+		if !layerStatuses[message.Status] {
+			log.Debug().Msg(message.Status)
+			continue
+		}
+
+		l, ok := layers[message.ID]
+		if !ok {
+			l = &layer{}
+			layers[message.ID] = l
+			ids = append(ids, message.ID)
+		}
+		switch message.Status {
+		case "Downloading":
+			l.current, l.total = message.ProgressDetail.Current, message.ProgressDetail.Total
+		case "Download complete":
+			l.current = l.total
+		case "Pull complete", "Already exists":
+			l.current, l.done = l.total, true
+		}
+		if draw {
+			fmt.Fprintf(os.Stderr, "\r\033[K%s", pullProgress(ids, layers))
+		} else if message.Status != "Downloading" && message.Status != "Extracting" {
+			log.Debug().Str("layer", message.ID).Msg(message.Status)
+		}
+	}
+	if draw && len(ids) > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+	return nil
+}
+
+// Returns the progress of a pull, like "3/7 layers, 45.2/150.3 MB (30%)"
+func pullProgress(ids []string, layers map[string]*layer) string {
+	var done int
+	var current, total int64
+	for _, id := range ids {
+		l := layers[id]
+		if l.done {
+			done++
+		}
+		current += l.current
+		total += l.total
+	}
+	progress := fmt.Sprintf("%d/%d layers", done, len(ids))
+	if total > 0 {
+		progress += fmt.Sprintf(", %.1f/%.1f MB (%d%%)", float64(current)/1e6, float64(total)/1e6, current*100/total)
+	}
+	return progress
+}
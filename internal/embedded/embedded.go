@@ -0,0 +1,114 @@
+// Package embedded runs a throwaway Postgres server from downloaded binaries,
+// for environments without access to a Docker daemon.
+package embedded
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/rs/zerolog/log"
+
+	"styx/internal/config"
+)
+
+// Full versions of the binaries to use for each major version
+var versions = map[string]embeddedpostgres.PostgresVersion{
+	"16": embeddedpostgres.V16,
+	"15": embeddedpostgres.V15,
+	"14": embeddedpostgres.V14,
+	"13": embeddedpostgres.V13,
+	"12": embeddedpostgres.V12,
+}
+
+// Postgres is a running embedded Postgres server
+type Postgres struct {
+	DSN string
+
+	server  *embeddedpostgres.EmbeddedPostgres
+	runtime string
+}
+
+// Start downloads the Postgres binaries if they aren't cached yet, and
+// starts a server on a free port. The server is ready once Start returns
+func Start(ctx context.Context, pg config.Postgres) (*Postgres, error) {
+	version, ok := versions[pg.Version]
+	if !ok {
+		// Let full versions like 16.4.0 through as-is
+		if strings.Count(pg.Version, ".") != 2 {
+			return nil, fmt.Errorf("unsupported embedded Postgres version %s", pg.Version)
+		}
+		version = embeddedpostgres.PostgresVersion(pg.Version)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	runtime, err := os.MkdirTemp("", "styx-postgres-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create runtime directory: %w", err)
+	}
+
+	settings := map[string]string{}
+	for _, setting := range pg.ServerSettings() {
+		name, value, _ := strings.Cut(setting, "=")
+		settings[name] = value
+	}
+
+	timeout := pg.StartupTimeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	cfg := embeddedpostgres.DefaultConfig().
+		Version(version).
+		Port(port).
+		Username(pg.User).
+		Password(pg.Password).
+		Database(pg.Database).
+		RuntimePath(runtime).
+		StartTimeout(timeout).
+		StartParameters(settings).
+		Logger(io.Discard)
+
+	log.Trace().Msgf("Starting embedded PostgreSQL %s on port %d", version, port)
+	server := embeddedpostgres.NewDatabase(cfg)
+	if err := server.Start(); err != nil {
+		os.RemoveAll(runtime)
+		return nil, fmt.Errorf("failed to start embedded PostgreSQL: %w", err)
+	}
+
+	return &Postgres{
+		DSN:     pg.ContainerDSN("localhost", fmt.Sprint(port)),
+		server:  server,
+		runtime: runtime,
+	}, nil
+}
+
+// Remove stops the server and deletes its data
+func (p *Postgres) Remove(ctx context.Context) error {
+	log.Info().Msg("Stopping embedded PostgreSQL...")
+	defer os.RemoveAll(p.runtime)
+
+	if err := p.server.Stop(); err != nil {
+		return fmt.Errorf("failed to stop embedded PostgreSQL: %w", err)
+	}
+	return nil
+}
+
+// Asks the kernel for a port nothing is listening on
+func freePort() (uint32, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer listener.Close()
+
+	return uint32(listener.Addr().(*net.TCPAddr).Port), nil
+}
@@ -0,0 +1,56 @@
+// Package naming derives table and column names from the names of types and
+// fields, the way ORMs do.
+package naming
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SnakeCase converts a Go or protobuf name to snake case the way ORMs do,
+// keeping initialisms together, e.g. UserID to user_id and HTTPCode to
+// http_code
+func SnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// Nouns not pluralized with a suffix
+var irregularPlurals = map[string]string{
+	"person": "people",
+	"child":  "children",
+	"man":    "men",
+	"woman":  "women",
+	"mouse":  "mice",
+}
+
+// Plural pluralizes the last word of a snake case name, e.g. user_address to
+// user_addresses
+func Plural(name string) string {
+	i := strings.LastIndex(name, "_") + 1
+	prefix, word := name[:i], name[i:]
+	if irregular, ok := irregularPlurals[word]; ok {
+		return prefix + irregular
+	}
+	switch {
+	case strings.HasSuffix(word, "y") && len(word) > 1 && !strings.ContainsRune("aeiou", rune(word[len(word)-2])):
+		word = word[:len(word)-1] + "ies"
+	case strings.HasSuffix(word, "s") || strings.HasSuffix(word, "x") || strings.HasSuffix(word, "z") ||
+		strings.HasSuffix(word, "ch") || strings.HasSuffix(word, "sh"):
+		word += "es"
+	default:
+		word += "s"
+	}
+	return prefix + word
+}
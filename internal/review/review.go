@@ -0,0 +1,243 @@
+// Package review is the terminal UI changesets are curated in before a
+// migration is written: each change can be accepted or skipped, and dropped
+// tables and columns can be marked as renames of created ones.
+package review
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"styx/diff"
+)
+
+// ErrAborted is returned when the user quits without writing the migration
+var ErrAborted = errors.New("review aborted, no migration was written")
+
+// Result is what the user decided
+type Result struct {
+	// Skipped are the changes left out of the migration
+	Skipped []diff.Change
+	// Renames are the drops the user marked as renames. When there are any,
+	// the changeset has to be computed again with them
+	Renames diff.Renames
+}
+
+// Run shows the changes and blocks until the user writes or quits
+func Run(changes []diff.Change) (Result, error) {
+	final, err := tea.NewProgram(newModel(changes)).Run()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to run review: %w", err)
+	}
+
+	m := final.(model)
+	if !m.done {
+		return Result{}, ErrAborted
+	}
+
+	result := Result{Renames: diff.Renames{Tables: map[string]string{}, Columns: map[string]string{}}}
+	for _, item := range m.items {
+		switch {
+		case item.renameTo >= 0:
+			target := m.items[item.renameTo].change
+			if item.change.Kind == diff.KindTable {
+				result.Renames.Tables[item.change.Name] = target.Name
+			} else {
+				result.Renames.Columns[item.change.Table+"."+item.change.Name] = target.Name
+			}
+		case item.skipped:
+			result.Skipped = append(result.Skipped, item.change)
+		}
+	}
+	return result, nil
+}
+
+type item struct {
+	change  diff.Change
+	skipped bool
+	// renameTo is the index of the created table or column this one is
+	// renamed to, or -1
+	renameTo int
+}
+
+type model struct {
+	items  []item
+	cursor int
+	// picking lists the rename targets of the item under the cursor, while
+	// one is being chosen
+	picking    []int
+	pickCursor int
+	height     int
+	done       bool
+}
+
+func newModel(changes []diff.Change) model {
+	m := model{height: 24}
+	for _, change := range changes {
+		m.items = append(m.items, item{change: change, renameTo: -1})
+	}
+	return m
+}
+
+func (m model) Init() tea.Cmd {
+	return nil
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case tea.KeyMsg:
+		if m.picking != nil {
+			return m.updatePicker(msg)
+		}
+		return m.updateList(msg)
+	}
+	return m, nil
+}
+
+func (m model) updateList(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	current := &m.items[m.cursor]
+	switch msg.String() {
+	case "ctrl+c", "q", "esc":
+		return m, tea.Quit
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, len(m.items)-1)
+	case "a", " ":
+		current.skipped = false
+		current.renameTo = -1
+	case "s":
+		current.skipped = true
+		current.renameTo = -1
+	case "r":
+		if targets := m.renameTargets(m.cursor); len(targets) > 0 {
+			m.picking = targets
+			m.pickCursor = 0
+		}
+	case "w", "enter":
+		m.done = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m model) updatePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q":
+		m.picking = nil
+	case "up", "k":
+		m.pickCursor = max(m.pickCursor-1, 0)
+	case "down", "j":
+		m.pickCursor = min(m.pickCursor+1, len(m.picking)-1)
+	case "enter":
+		m.items[m.cursor].renameTo = m.picking[m.pickCursor]
+		m.items[m.cursor].skipped = false
+		m.picking = nil
+	}
+	return m, nil
+}
+
+// Returns the created tables or columns the dropped one under i could have
+// been renamed to. Columns can only be renamed within their table
+func (m model) renameTargets(i int) []int {
+	dropped := m.items[i].change
+	if dropped.Op != diff.OpDrop || dropped.Kind != diff.KindTable && dropped.Kind != diff.KindColumn {
+		return nil
+	}
+
+	var targets []int
+	for j, other := range m.items {
+		created := other.change
+		if created.Op != diff.OpCreate || created.Kind != dropped.Kind {
+			continue
+		}
+		if dropped.Kind == diff.KindColumn && created.Table != dropped.Table {
+			continue
+		}
+		if m.renamedFrom(j) >= 0 && m.renamedFrom(j) != i {
+			continue
+		}
+		targets = append(targets, j)
+	}
+	return targets
+}
+
+func (m model) View() string {
+	if m.done {
+		return ""
+	}
+
+	var b strings.Builder
+	if m.picking != nil {
+		fmt.Fprintf(&b, "Rename %s to:\n\n", m.items[m.cursor].change.Name)
+		for i, target := range m.picking {
+			fmt.Fprintf(&b, "%s %s\n", pointer(i == m.pickCursor), m.items[target].change.Name)
+		}
+		b.WriteString("\n↑/↓ move · enter pick · esc cancel\n")
+		return b.String()
+	}
+
+	b.WriteString("Review the changes, then write the migration\n\n")
+
+	// The SQL pane and help take up the rest of the screen
+	current := m.items[m.cursor]
+	rows := max(m.height-strings.Count(current.change.SQL, "\n")-8, 3)
+	first := max(min(m.cursor-rows/2, len(m.items)-rows), 0)
+	width := 0
+	for _, item := range m.items {
+		width = max(width, len(item.change.String()))
+	}
+	for i := first; i < min(first+rows, len(m.items)); i++ {
+		fmt.Fprintf(&b, "%s %s %-*s  %s%s\n", pointer(i == m.cursor), m.mark(i), width, m.items[i].change, m.items[i].change.Severity(), m.note(i))
+	}
+
+	b.WriteString("\n")
+	for _, line := range strings.Split(current.change.SQL, "\n") {
+		fmt.Fprintf(&b, "    %s\n", line)
+	}
+	b.WriteString("\n↑/↓ move · a accept · s skip · r rename · w write · q quit\n")
+	return b.String()
+}
+
+func pointer(selected bool) string {
+	if selected {
+		return ">"
+	}
+	return " "
+}
+
+func (m model) mark(i int) string {
+	switch {
+	case m.items[i].renameTo >= 0:
+		return "[r]"
+	case m.items[i].skipped:
+		return "[ ]"
+	}
+	return "[x]"
+}
+
+func (m model) note(i int) string {
+	if target := m.items[i].renameTo; target >= 0 {
+		return ", renamed to " + m.items[target].change.Name
+	}
+	if from := m.renamedFrom(i); from >= 0 {
+		return ", renamed from " + m.items[from].change.Name
+	}
+	return ""
+}
+
+// Returns the index of the item renamed to the one at i, or -1
+func (m model) renamedFrom(i int) int {
+	for j, other := range m.items {
+		if other.renameTo == i {
+			return j
+		}
+	}
+	return -1
+}
@@ -0,0 +1,205 @@
+// Package sqltrace opens databases whose statements are logged at trace
+// level, to see what styx runs against the scratch database when debugging a
+// bad migration.
+package sqltrace
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Open opens a database like sql.Open, logging every statement executed on it
+// when the log level is trace
+func Open(driverName, dsn string) (*sql.DB, error) {
+	if zerolog.GlobalLevel() > zerolog.TraceLevel {
+		return sql.Open(driverName, dsn)
+	}
+	// sql.Open doesn't connect, it only looks the driver up
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	db.Close()
+	return sql.OpenDB(connector{driver: d, dsn: dsn}), nil
+}
+
+// Logs a statement once it ran
+func trace(query string, args []driver.NamedValue, start time.Time, err error) {
+	event := log.Trace().Dur("took", time.Since(start))
+	if len(args) > 0 {
+		event = event.Interface("args", values(args))
+	}
+	if err != nil {
+		event = event.Err(err)
+	}
+	event.Msg(strings.TrimSpace(query))
+}
+
+type connector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if dc, ok := c.driver.(driver.DriverContext); ok {
+		inner, err := dc.OpenConnector(c.dsn)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := inner.Connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &tracedConn{conn}, nil
+	}
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{conn}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// tracedConn logs the statements run on a connection. The optional
+// interfaces of database/sql are passed through, returning driver.ErrSkip
+// when the wrapped connection doesn't implement them so database/sql falls
+// back to the statements, which are traced too
+type tracedConn struct {
+	driver.Conn
+}
+
+func (c *tracedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = pc.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{Stmt: stmt, query: query}, nil
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		trace(query, args, start, err)
+	}
+	return result, err
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		trace(query, args, start, err)
+	}
+	return rows, err
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bt, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bt.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+// tracedStmt logs each execution of a prepared statement
+type tracedStmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var result driver.Result
+	var err error
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		result, err = s.Stmt.Exec(values(args))
+	}
+	trace(s.query, args, start, err)
+	return result, err
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(values(args))
+	}
+	trace(s.query, args, start, err)
+	return rows, err
+}
+
+func (s *tracedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
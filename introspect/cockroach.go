@@ -0,0 +1,75 @@
+package introspect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"styx/schema"
+)
+
+// Cockroach reads the user schemas of a CockroachDB database through its
+// Postgres-compatible catalogs. User-defined functions and triggers are left
+// out, as older versions don't implement the catalog functions rendering them
+func Cockroach(ctx context.Context, db *sql.DB) (*schema.Schema, error) {
+	s := &schema.Schema{}
+
+	steps := []struct {
+		name string
+		load func(context.Context, *sql.DB, *schema.Schema) error
+	}{
+		{"schemas", loadSchemas},
+		{"enums", loadEnums},
+		{"tables", loadTables},
+		{"columns", loadColumns},
+		{"constraints", loadConstraints},
+		{"indexes", loadIndexes},
+		{"views", loadViews},
+		{"sequences", loadSequences},
+		{"hidden columns", removeHiddenColumns},
+	}
+	for _, step := range steps {
+		if err := step.load(ctx, db, s); err != nil {
+			return nil, fmt.Errorf("failed to introspect %s: %w", step.name, err)
+		}
+	}
+
+	s.Sort()
+	return s, nil
+}
+
+// CockroachDB adds hidden columns, like the rowid primary key of tables
+// declared without one. They show up in the catalogs but aren't part of
+// schema.sql, so they're removed along with the constraints using them
+func removeHiddenColumns(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT table_schema, table_name, column_name
+FROM information_schema.columns
+WHERE table_schema NOT IN ('information_schema', 'crdb_internal', 'pg_catalog', 'pg_extension')
+  AND is_hidden = 'YES';`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, tableName, columnName string
+		if err := rows.Scan(&schemaName, &tableName, &columnName); err != nil {
+			return err
+		}
+
+		table := s.Table(schema.QualifiedName(schemaName, tableName))
+		if table == nil {
+			continue
+		}
+		table.Columns = slices.DeleteFunc(table.Columns, func(c *schema.Column) bool {
+			return c.Name == columnName
+		})
+		table.Constraints = slices.DeleteFunc(table.Constraints, func(c *schema.Constraint) bool {
+			return slices.Contains(c.Columns, columnName)
+		})
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,893 @@
+// Package introspect reads the schema of a live Postgres database from the
+// system catalogs and builds a schema model out of it.
+package introspect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"styx/schema"
+)
+
+// Matches the namespaces holding user objects, leaving out the system
+// catalogs. Objects outside public are named "schema.name" in the model
+const userSchemas = `n.nspname NOT IN ('information_schema', 'crdb_internal', 'pg_extension') AND n.nspname NOT LIKE 'pg\_%'`
+
+// Introspect reads every object styx manages from the user schemas
+func Introspect(ctx context.Context, db *sql.DB) (*schema.Schema, error) {
+	s := &schema.Schema{}
+
+	steps := []struct {
+		name string
+		load func(context.Context, *sql.DB, *schema.Schema) error
+	}{
+		{"schemas", loadSchemas},
+		{"extensions", loadExtensions},
+		{"enums", loadEnums},
+		{"domains", loadDomains},
+		{"domain constraints", loadDomainChecks},
+		{"composite types", loadCompositeTypes},
+		{"tables", loadTables},
+		{"columns", loadColumns},
+		{"constraints", loadConstraints},
+		{"indexes", loadIndexes},
+		{"views", loadViews},
+		{"sequences", loadSequences},
+		{"functions", loadFunctions},
+		{"triggers", loadTriggers},
+		{"row security", loadRowSecurity},
+		{"policies", loadPolicies},
+		{"grants", loadGrants},
+		{"foreign servers", loadServers},
+		{"user mappings", loadUserMappings},
+		{"foreign tables", loadForeignTables},
+		{"foreign columns", loadForeignColumns},
+		{"publications", loadPublications},
+	}
+	for _, step := range steps {
+		if err := step.load(ctx, db, s); err != nil {
+			return nil, fmt.Errorf("failed to introspect %s: %w", step.name, err)
+		}
+	}
+
+	s.Sort()
+	return s, nil
+}
+
+func loadSchemas(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname
+FROM pg_namespace n
+WHERE `+userSchemas+` AND n.nspname <> 'public'
+ORDER BY n.nspname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		s.Schemas = append(s.Schemas, name)
+	}
+
+	return rows.Err()
+}
+
+func loadExtensions(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	// plpgsql comes preinstalled
+	rows, err := db.QueryContext(ctx, `
+SELECT e.extname, n.nspname
+FROM pg_extension e
+JOIN pg_namespace n ON n.oid = e.extnamespace
+WHERE e.extname <> 'plpgsql'
+ORDER BY e.extname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName string
+		extension := &schema.Extension{}
+		if err := rows.Scan(&extension.Name, &schemaName); err != nil {
+			return err
+		}
+		if schemaName != "public" {
+			extension.Schema = schemaName
+		}
+		s.Extensions = append(s.Extensions, extension)
+	}
+
+	return rows.Err()
+}
+
+func loadEnums(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, t.typname,
+       ARRAY(SELECT e.enumlabel FROM pg_enum e WHERE e.enumtypid = t.oid ORDER BY e.enumsortorder)
+FROM pg_type t
+JOIN pg_namespace n ON n.oid = t.typnamespace
+WHERE `+userSchemas+` AND t.typtype = 'e' AND NOT `+extensionMember("pg_type", "t.oid")+`
+ORDER BY n.nspname, t.typname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName string
+		enum := &schema.Enum{}
+		if err := rows.Scan(&schemaName, &enum.Name, pq.Array(&enum.Values)); err != nil {
+			return err
+		}
+		enum.Name = schema.QualifiedName(schemaName, enum.Name)
+		s.Enums = append(s.Enums, enum)
+	}
+
+	return rows.Err()
+}
+
+func loadDomains(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, t.typname, format_type(t.typbasetype, t.typtypmod), t.typnotnull,
+       COALESCE(pg_get_expr(t.typdefaultbin, 0), '')
+FROM pg_type t
+JOIN pg_namespace n ON n.oid = t.typnamespace
+WHERE `+userSchemas+` AND t.typtype = 'd' AND NOT `+extensionMember("pg_type", "t.oid")+`
+ORDER BY n.nspname, t.typname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName string
+		domain := &schema.Domain{}
+		if err := rows.Scan(&schemaName, &domain.Name, &domain.Type, &domain.NotNull, &domain.Default); err != nil {
+			return err
+		}
+		domain.Name = schema.QualifiedName(schemaName, domain.Name)
+		s.Domains = append(s.Domains, domain)
+	}
+
+	return rows.Err()
+}
+
+func loadDomainChecks(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, t.typname, con.conname, pg_get_expr(con.conbin, 0)
+FROM pg_constraint con
+JOIN pg_type t ON t.oid = con.contypid
+JOIN pg_namespace n ON n.oid = t.typnamespace
+WHERE `+userSchemas+` AND con.contype = 'c'
+ORDER BY n.nspname, t.typname, con.conname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, domainName string
+		check := &schema.Constraint{Type: schema.Check}
+		if err := rows.Scan(&schemaName, &domainName, &check.Name, &check.Expression); err != nil {
+			return err
+		}
+		if domain := s.Domain(schema.QualifiedName(schemaName, domainName)); domain != nil {
+			domain.Checks = append(domain.Checks, check)
+		}
+	}
+
+	return rows.Err()
+}
+
+func loadCompositeTypes(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, t.typname, a.attname, format_type(a.atttypid, a.atttypmod)
+FROM pg_type t
+JOIN pg_namespace n ON n.oid = t.typnamespace
+JOIN pg_class c ON c.oid = t.typrelid
+LEFT JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+WHERE `+userSchemas+` AND t.typtype = 'c' AND c.relkind = 'c' AND NOT `+extensionMember("pg_type", "t.oid")+`
+ORDER BY n.nspname, t.typname, a.attnum;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, typeName string
+		var attribute, attributeType sql.NullString
+		if err := rows.Scan(&schemaName, &typeName, &attribute, &attributeType); err != nil {
+			return err
+		}
+
+		name := schema.QualifiedName(schemaName, typeName)
+		typ := s.CompositeType(name)
+		if typ == nil {
+			typ = &schema.CompositeType{Name: name}
+			s.CompositeTypes = append(s.CompositeTypes, typ)
+		}
+		// Types without attributes come back as a single row of NULLs
+		if attribute.Valid {
+			typ.Attributes = append(typ.Attributes, &schema.Attribute{Name: attribute.String, Type: attributeType.String})
+		}
+	}
+
+	return rows.Err()
+}
+
+func loadTables(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	// Temporary tables belong to the session that created them, not the
+	// schema, whichever schema they're listed in
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, c.relname, COALESCE(obj_description(c.oid, 'pg_class'), ''), c.relpersistence = 'u',
+       ARRAY(
+           SELECT option FROM unnest(c.reloptions) option
+           UNION ALL
+           SELECT 'toast.' || option FROM unnest(tc.reloptions) option
+           ORDER BY 1),
+       COALESCE(ts.spcname, '')
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_class tc ON tc.oid = c.reltoastrelid
+LEFT JOIN pg_tablespace ts ON ts.oid = c.reltablespace
+WHERE `+userSchemas+` AND c.relkind IN ('r', 'p') AND NOT `+extensionMember("pg_class", "c.oid")+`
+  AND c.relpersistence <> 't'
+ORDER BY n.nspname, c.relname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, name, comment string
+		table := &schema.Table{}
+		if err := rows.Scan(&schemaName, &name, &comment, &table.Unlogged, pq.Array(&table.Options), &table.Tablespace); err != nil {
+			return err
+		}
+		table.Name = schema.QualifiedName(schemaName, name)
+		table.Comment = comment
+		s.Tables = append(s.Tables, table)
+	}
+
+	return rows.Err()
+}
+
+func loadColumns(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, c.relname, a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+       COALESCE(pg_get_expr(d.adbin, d.adrelid), ''), a.attidentity, a.attgenerated,
+       COALESCE(col_description(c.oid, a.attnum), '')
+FROM pg_attribute a
+JOIN pg_class c ON c.oid = a.attrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+WHERE `+userSchemas+` AND c.relkind IN ('r', 'p')
+  AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY n.nspname, c.relname, a.attnum;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, tableName, identity, generated string
+		column := &schema.Column{}
+		if err := rows.Scan(&schemaName, &tableName, &column.Name, &column.Type, &column.NotNull, &column.Default, &identity, &generated, &column.Comment); err != nil {
+			return err
+		}
+
+		// The expression of a generated column is stored as its default
+		if generated == "s" {
+			column.Generated, column.Default = column.Default, ""
+		}
+
+		switch identity {
+		case "a":
+			column.Identity = "ALWAYS"
+		case "d":
+			column.Identity = "BY DEFAULT"
+		}
+
+		if table := s.Table(schema.QualifiedName(schemaName, tableName)); table != nil {
+			table.Columns = append(table.Columns, column)
+		}
+	}
+
+	return rows.Err()
+}
+
+func loadConstraints(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, cl.relname, con.conname, con.contype,
+       ARRAY(SELECT a.attname
+             FROM unnest(con.conkey) WITH ORDINALITY AS k(attnum, ord)
+             JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+             ORDER BY k.ord),
+       COALESCE(refn.nspname, ''), COALESCE(ref.relname, ''),
+       ARRAY(SELECT a.attname
+             FROM unnest(con.confkey) WITH ORDINALITY AS k(attnum, ord)
+             JOIN pg_attribute a ON a.attrelid = con.confrelid AND a.attnum = k.attnum
+             ORDER BY k.ord),
+       con.confupdtype, con.confdeltype, con.confmatchtype,
+       con.condeferrable, con.condeferred,
+       COALESCE(pg_get_expr(con.conbin, con.conrelid), ''),
+       pg_get_constraintdef(con.oid)
+FROM pg_constraint con
+JOIN pg_class cl ON cl.oid = con.conrelid
+JOIN pg_namespace n ON n.oid = cl.relnamespace
+LEFT JOIN pg_class ref ON ref.oid = con.confrelid
+LEFT JOIN pg_namespace refn ON refn.oid = ref.relnamespace
+WHERE `+userSchemas+` AND con.contype IN ('p', 'u', 'f', 'c', 'x')
+ORDER BY n.nspname, cl.relname, con.conname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, tableName, refSchema, contype, onUpdate, onDelete, match string
+		c := &schema.Constraint{}
+		err := rows.Scan(&schemaName, &tableName, &c.Name, &contype,
+			pq.Array(&c.Columns), &refSchema, &c.RefTable, pq.Array(&c.RefColumns),
+			&onUpdate, &onDelete, &match,
+			&c.Deferrable, &c.InitiallyDeferred,
+			&c.Expression, &c.Definition)
+		if err != nil {
+			return err
+		}
+
+		switch contype {
+		case "p":
+			c.Type = schema.PrimaryKey
+		case "u":
+			c.Type = schema.Unique
+		case "f":
+			c.Type = schema.ForeignKey
+			c.RefTable = schema.QualifiedName(refSchema, c.RefTable)
+			c.OnUpdate = foreignKeyAction(onUpdate)
+			c.OnDelete = foreignKeyAction(onDelete)
+			switch match {
+			case "f":
+				c.Match = "FULL"
+			case "p":
+				c.Match = "PARTIAL"
+			}
+		case "c":
+			c.Type = schema.Check
+			// Check constraints don't have a fixed column list
+			c.Columns = nil
+		case "x":
+			c.Type = schema.Exclusion
+		}
+
+		if table := s.Table(schema.QualifiedName(schemaName, tableName)); table != nil {
+			table.Constraints = append(table.Constraints, c)
+		}
+	}
+
+	return rows.Err()
+}
+
+func loadIndexes(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	// Indexes backing a primary key, unique or exclusion constraint are
+	// managed through the constraint
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, t.relname, pg_get_indexdef(ix.indexrelid), COALESCE(ts.spcname, '')
+FROM pg_index ix
+JOIN pg_class i ON i.oid = ix.indexrelid
+JOIN pg_class t ON t.oid = ix.indrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+LEFT JOIN pg_tablespace ts ON ts.oid = i.reltablespace
+WHERE `+userSchemas+` AND t.relkind IN ('r', 'p')
+  AND NOT EXISTS (
+      SELECT 1 FROM pg_constraint con
+      WHERE con.conindid = ix.indexrelid AND con.contype IN ('p', 'u', 'x'))
+ORDER BY n.nspname, t.relname, i.relname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, tableName, definition, tablespace string
+		if err := rows.Scan(&schemaName, &tableName, &definition, &tablespace); err != nil {
+			return err
+		}
+
+		// pg_get_indexdef leaves the tablespace out
+		index, err := schema.ParseIndex(definition)
+		if err != nil {
+			return err
+		}
+		index.Tablespace = tablespace
+
+		if table := s.Table(schema.QualifiedName(schemaName, tableName)); table != nil {
+			table.Indexes = append(table.Indexes, index)
+		}
+	}
+
+	return rows.Err()
+}
+
+func loadViews(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, c.relname, c.relkind = 'm', pg_get_viewdef(c.oid)
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE `+userSchemas+` AND c.relkind IN ('v', 'm') AND NOT `+extensionMember("pg_class", "c.oid")+`
+ORDER BY n.nspname, c.relname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, name, definition string
+		var materialized bool
+		if err := rows.Scan(&schemaName, &name, &materialized, &definition); err != nil {
+			return err
+		}
+
+		view, err := schema.ParseView(schema.QualifiedName(schemaName, name), materialized, definition)
+		if err != nil {
+			return err
+		}
+		s.Views = append(s.Views, view)
+	}
+
+	return rows.Err()
+}
+
+func loadSequences(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	// Identity sequences are part of the column definition, so they're skipped
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, c.relname, format_type(seq.seqtypid, NULL),
+       seq.seqstart, seq.seqincrement, seq.seqmin, seq.seqmax, seq.seqcache, seq.seqcycle,
+       COALESCE(tn.nspname, ''), COALESCE(t.relname || '.' || a.attname, '')
+FROM pg_sequence seq
+JOIN pg_class c ON c.oid = seq.seqrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_depend d ON d.classid = 'pg_class'::regclass AND d.objid = c.oid
+     AND d.refclassid = 'pg_class'::regclass AND d.deptype = 'a'
+LEFT JOIN pg_class t ON t.oid = d.refobjid
+LEFT JOIN pg_namespace tn ON tn.oid = t.relnamespace
+LEFT JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+WHERE `+userSchemas+` AND NOT `+extensionMember("pg_class", "c.oid")+`
+  AND NOT EXISTS (
+      SELECT 1 FROM pg_depend i
+      WHERE i.classid = 'pg_class'::regclass AND i.objid = c.oid AND i.deptype = 'i')
+ORDER BY n.nspname, c.relname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, ownerSchema string
+		seq := &schema.Sequence{}
+		err := rows.Scan(&schemaName, &seq.Name, &seq.Type,
+			&seq.Start, &seq.Increment, &seq.MinValue, &seq.MaxValue, &seq.Cache, &seq.Cycle,
+			&ownerSchema, &seq.OwnedBy)
+		if err != nil {
+			return err
+		}
+		seq.Name = schema.QualifiedName(schemaName, seq.Name)
+		if seq.OwnedBy != "" {
+			seq.OwnedBy = schema.QualifiedName(ownerSchema, seq.OwnedBy)
+		}
+		s.Sequences = append(s.Sequences, seq)
+	}
+
+	return rows.Err()
+}
+
+func loadFunctions(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	// Functions that belong to an extension are managed by the extension
+	rows, err := db.QueryContext(ctx, `
+SELECT pg_get_functiondef(p.oid)
+FROM pg_proc p
+JOIN pg_namespace n ON n.oid = p.pronamespace
+WHERE `+userSchemas+` AND p.prokind IN ('f', 'p') AND NOT `+extensionMember("pg_proc", "p.oid")+`
+ORDER BY n.nspname, p.proname, p.oid;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var definition string
+		if err := rows.Scan(&definition); err != nil {
+			return err
+		}
+
+		function, err := schema.ParseFunction(definition)
+		if err != nil {
+			return err
+		}
+		s.Functions = append(s.Functions, function)
+	}
+
+	return rows.Err()
+}
+
+func loadTriggers(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	// Internal triggers implement foreign keys and are managed through them
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, c.relname, pg_get_triggerdef(t.oid)
+FROM pg_trigger t
+JOIN pg_class c ON c.oid = t.tgrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE `+userSchemas+` AND c.relkind IN ('r', 'p') AND NOT t.tgisinternal
+ORDER BY n.nspname, c.relname, t.tgname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, tableName, definition string
+		if err := rows.Scan(&schemaName, &tableName, &definition); err != nil {
+			return err
+		}
+
+		trigger, err := schema.ParseTrigger(definition)
+		if err != nil {
+			return err
+		}
+
+		if table := s.Table(schema.QualifiedName(schemaName, tableName)); table != nil {
+			table.Triggers = append(table.Triggers, trigger)
+		}
+	}
+
+	return rows.Err()
+}
+
+func loadRowSecurity(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, c.relname, c.relrowsecurity, c.relforcerowsecurity
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE `+userSchemas+` AND c.relkind IN ('r', 'p')
+  AND (c.relrowsecurity OR c.relforcerowsecurity);`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, tableName string
+		var enabled, forced bool
+		if err := rows.Scan(&schemaName, &tableName, &enabled, &forced); err != nil {
+			return err
+		}
+
+		if table := s.Table(schema.QualifiedName(schemaName, tableName)); table != nil {
+			table.RowSecurity = enabled
+			table.ForceRowSecurity = forced
+		}
+	}
+
+	return rows.Err()
+}
+
+func loadPolicies(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, p.tablename, p.policyname, p.permissive, p.cmd, p.roles,
+       COALESCE(p.qual, ''), COALESCE(p.with_check, '')
+FROM pg_policies p
+JOIN pg_namespace n ON n.nspname = p.schemaname
+WHERE `+userSchemas+`
+ORDER BY n.nspname, p.tablename, p.policyname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, tableName, name, permissive, command, using, check string
+		var roles []string
+		if err := rows.Scan(&schemaName, &tableName, &name, &permissive, &command, pq.Array(&roles), &using, &check); err != nil {
+			return err
+		}
+
+		// The catalogs hold the policy in pieces, which are put back
+		// together so it's normalized like a parsed one
+		quoted := make([]string, len(roles))
+		for i, role := range roles {
+			quoted[i] = schema.QuoteRole(role)
+		}
+		tableName = schema.QualifiedName(schemaName, tableName)
+		definition := fmt.Sprintf("CREATE POLICY %s ON %s AS %s FOR %s TO %s",
+			schema.QuoteIdent(name), schema.QuoteName(tableName), permissive, command, strings.Join(quoted, ", "))
+		if using != "" {
+			definition += " USING (" + using + ")"
+		}
+		if check != "" {
+			definition += " WITH CHECK (" + check + ")"
+		}
+
+		policy, err := schema.ParsePolicy(definition)
+		if err != nil {
+			return err
+		}
+
+		if table := s.Table(tableName); table != nil {
+			table.Policies = append(table.Policies, policy)
+		}
+	}
+
+	return rows.Err()
+}
+
+func loadGrants(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	// The owner's privileges are implied. Privileges newer than the ones
+	// styx knows, like MAINTAIN, are left out
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, c.relname,
+       CASE WHEN a.grantee = 0 THEN 'public' ELSE pg_get_userbyid(a.grantee) END,
+       a.privilege_type
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+CROSS JOIN LATERAL aclexplode(c.relacl) a
+WHERE `+userSchemas+` AND c.relkind IN ('r', 'p', 'v', 'm') AND a.grantee <> c.relowner
+  AND a.privilege_type IN ('DELETE', 'INSERT', 'REFERENCES', 'SELECT', 'TRIGGER', 'TRUNCATE', 'UPDATE')
+ORDER BY n.nspname, c.relname, 3, a.privilege_type;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, objectName, role, privilege string
+		if err := rows.Scan(&schemaName, &objectName, &role, &privilege); err != nil {
+			return err
+		}
+
+		object := schema.QualifiedName(schemaName, objectName)
+		if s.Table(object) == nil && s.View(object) == nil {
+			continue
+		}
+		grant := s.Grant(object, role)
+		if grant == nil {
+			grant = &schema.Grant{Object: object, Role: role}
+			s.Grants = append(s.Grants, grant)
+		}
+		// Grants from different grantors show up separately
+		if !slices.Contains(grant.Privileges, privilege) {
+			grant.Privileges = append(grant.Privileges, privilege)
+		}
+	}
+
+	return rows.Err()
+}
+
+func loadServers(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT srv.srvname, w.fdwname, COALESCE(srv.srvtype, ''), COALESCE(srv.srvversion, ''),
+       ARRAY(SELECT option FROM unnest(srv.srvoptions) option ORDER BY 1)
+FROM pg_foreign_server srv
+JOIN pg_foreign_data_wrapper w ON w.oid = srv.srvfdw
+WHERE NOT `+extensionMember("pg_foreign_server", "srv.oid")+`
+ORDER BY srv.srvname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		server := &schema.Server{}
+		if err := rows.Scan(&server.Name, &server.Wrapper, &server.Type, &server.Version, pq.Array(&server.Options)); err != nil {
+			return err
+		}
+		s.Servers = append(s.Servers, server)
+	}
+
+	return rows.Err()
+}
+
+func loadUserMappings(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	// The options are only visible to the owner of the server and superusers
+	rows, err := db.QueryContext(ctx, `
+SELECT um.srvname, CASE WHEN um.umuser = 0 THEN 'public' ELSE um.usename END,
+       ARRAY(SELECT option FROM unnest(um.umoptions) option ORDER BY 1)
+FROM pg_user_mappings um
+ORDER BY um.srvname, 2;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		mapping := &schema.UserMapping{}
+		if err := rows.Scan(&mapping.Server, &mapping.User, pq.Array(&mapping.Options)); err != nil {
+			return err
+		}
+		if s.Server(mapping.Server) != nil {
+			s.UserMappings = append(s.UserMappings, mapping)
+		}
+	}
+
+	return rows.Err()
+}
+
+func loadForeignTables(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, c.relname, srv.srvname,
+       ARRAY(SELECT option FROM unnest(ft.ftoptions) option ORDER BY 1)
+FROM pg_foreign_table ft
+JOIN pg_class c ON c.oid = ft.ftrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_foreign_server srv ON srv.oid = ft.ftserver
+WHERE `+userSchemas+` AND NOT `+extensionMember("pg_class", "c.oid")+`
+ORDER BY n.nspname, c.relname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, name string
+		table := &schema.ForeignTable{}
+		if err := rows.Scan(&schemaName, &name, &table.Server, pq.Array(&table.Options)); err != nil {
+			return err
+		}
+		table.Name = schema.QualifiedName(schemaName, name)
+		s.ForeignTables = append(s.ForeignTables, table)
+	}
+
+	return rows.Err()
+}
+
+func loadForeignColumns(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT n.nspname, c.relname, a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+       ARRAY(SELECT option FROM unnest(a.attfdwoptions) option ORDER BY 1)
+FROM pg_attribute a
+JOIN pg_class c ON c.oid = a.attrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE `+userSchemas+` AND c.relkind = 'f'
+  AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY n.nspname, c.relname, a.attnum;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schemaName, tableName string
+		column := &schema.ForeignColumn{}
+		if err := rows.Scan(&schemaName, &tableName, &column.Name, &column.Type, &column.NotNull, pq.Array(&column.Options)); err != nil {
+			return err
+		}
+		if table := s.ForeignTable(schema.QualifiedName(schemaName, tableName)); table != nil {
+			table.Columns = append(table.Columns, column)
+		}
+	}
+
+	return rows.Err()
+}
+
+func loadPublications(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT p.pubname, p.puballtables, p.pubinsert, p.pubupdate, p.pubdelete, p.pubtruncate, p.pubviaroot
+FROM pg_publication p
+ORDER BY p.pubname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		pub := &schema.Publication{}
+		var published [4]bool
+		if err := rows.Scan(&pub.Name, &pub.AllTables, &published[0], &published[1], &published[2], &published[3], &pub.ViaPartitionRoot); err != nil {
+			return err
+		}
+		for i, operation := range schema.PublishOperations {
+			if published[i] {
+				pub.Publish = append(pub.Publish, operation)
+			}
+		}
+		s.Publications = append(s.Publications, pub)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(s.Publications) == 0 {
+		return nil
+	}
+
+	// Row filters, column lists and schemas came with Postgres 15
+	var version int
+	if err := db.QueryRowContext(ctx, "SELECT current_setting('server_version_num')::int;").Scan(&version); err != nil {
+		return err
+	}
+	filters := "'', '{}'::text[]"
+	if version >= 150000 {
+		filters = `COALESCE(pg_get_expr(pr.prqual, pr.prrelid), ''),
+       ARRAY(SELECT a.attname FROM pg_attribute a WHERE a.attrelid = pr.prrelid AND a.attnum = ANY (pr.prattrs) ORDER BY 1)`
+	}
+	rows, err = db.QueryContext(ctx, `
+SELECT p.pubname, n.nspname, c.relname, `+filters+`
+FROM pg_publication_rel pr
+JOIN pg_publication p ON p.oid = pr.prpubid
+JOIN pg_class c ON c.oid = pr.prrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+ORDER BY p.pubname, n.nspname, c.relname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pubName, schemaName, tableName string
+		table := &schema.PublicationTable{}
+		if err := rows.Scan(&pubName, &schemaName, &tableName, &table.Where, pq.Array(&table.Columns)); err != nil {
+			return err
+		}
+		table.Table = schema.QualifiedName(schemaName, tableName)
+		if pub := s.Publication(pubName); pub != nil {
+			pub.Tables = append(pub.Tables, table)
+		}
+	}
+	if err := rows.Err(); err != nil || version < 150000 {
+		return err
+	}
+
+	rows, err = db.QueryContext(ctx, `
+SELECT p.pubname, n.nspname
+FROM pg_publication_namespace pn
+JOIN pg_publication p ON p.oid = pn.pnpubid
+JOIN pg_namespace n ON n.oid = pn.pnnspid
+ORDER BY p.pubname, n.nspname;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pubName, schemaName string
+		if err := rows.Scan(&pubName, &schemaName); err != nil {
+			return err
+		}
+		if pub := s.Publication(pubName); pub != nil {
+			pub.Schemas = append(pub.Schemas, schemaName)
+		}
+	}
+
+	return rows.Err()
+}
+
+// Returns a condition matching the objects of the catalog that belong to an
+// extension. They're managed by the extension, not by styx
+func extensionMember(catalog, oid string) string {
+	return fmt.Sprintf(`EXISTS (
+      SELECT 1 FROM pg_depend ext
+      WHERE ext.classid = '%s'::regclass AND ext.objid = %s AND ext.deptype = 'e')`, catalog, oid)
+}
+
+// Converts a pg_constraint action code to its SQL keyword. NO ACTION is the
+// default and is left empty
+func foreignKeyAction(code string) string {
+	switch strings.TrimSpace(code) {
+	case "r":
+		return "RESTRICT"
+	case "c":
+		return "CASCADE"
+	case "n":
+		return "SET NULL"
+	case "d":
+		return "SET DEFAULT"
+	}
+	return ""
+}
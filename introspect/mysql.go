@@ -0,0 +1,259 @@
+package introspect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"styx/schema"
+)
+
+// MySQL reads the tables and views of the connection's current database from
+// information_schema. It works with MySQL 8 and MariaDB
+func MySQL(ctx context.Context, db *sql.DB) (*schema.Schema, error) {
+	s := &schema.Schema{}
+
+	steps := []struct {
+		name string
+		load func(context.Context, *sql.DB, *schema.Schema) error
+	}{
+		{"tables", loadMySQLTables},
+		{"columns", loadMySQLColumns},
+		{"constraints", loadMySQLConstraints},
+		{"indexes", loadMySQLIndexes},
+		{"views", loadMySQLViews},
+	}
+	for _, step := range steps {
+		if err := step.load(ctx, db, s); err != nil {
+			return nil, fmt.Errorf("failed to introspect %s: %w", step.name, err)
+		}
+	}
+
+	s.Sort()
+	return s, nil
+}
+
+func loadMySQLTables(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT TABLE_NAME
+FROM information_schema.TABLES
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE'
+ORDER BY TABLE_NAME;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		table := &schema.Table{}
+		if err := rows.Scan(&table.Name); err != nil {
+			return err
+		}
+		s.Tables = append(s.Tables, table)
+	}
+
+	return rows.Err()
+}
+
+func loadMySQLColumns(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT TABLE_NAME, COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE = 'NO', COLUMN_DEFAULT, DATA_TYPE, EXTRA,
+       COALESCE(GENERATION_EXPRESSION, '')
+FROM information_schema.COLUMNS
+WHERE TABLE_SCHEMA = DATABASE()
+ORDER BY TABLE_NAME, ORDINAL_POSITION;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, dataType, extra, generated string
+		var def sql.NullString
+		column := &schema.Column{}
+		if err := rows.Scan(&tableName, &column.Name, &column.Type, &column.NotNull, &def, &dataType, &extra, &generated); err != nil {
+			return err
+		}
+
+		extra = strings.ToLower(extra)
+		column.AutoIncrement = strings.Contains(extra, "auto_increment")
+		// MariaDB calls stored generated columns persistent
+		if strings.Contains(extra, "stored generated") || strings.Contains(extra, "persistent generated") {
+			column.Generated = generated
+		}
+		if def.Valid {
+			column.Default = mysqlDefault(def.String, dataType, extra)
+		}
+
+		if table := s.Table(tableName); table != nil {
+			table.Columns = append(table.Columns, column)
+		}
+	}
+
+	return rows.Err()
+}
+
+// Turns COLUMN_DEFAULT back into an expression. MySQL reports literals
+// unquoted and flags expressions in EXTRA, while MariaDB quotes literals and
+// reports a NULL default as the string NULL
+func mysqlDefault(value, dataType, extra string) string {
+	switch {
+	case value == "NULL":
+		return ""
+	case strings.HasPrefix(value, "'"):
+		return value
+	case strings.HasPrefix(strings.ToUpper(value), "CURRENT_TIMESTAMP"):
+		return value
+	case strings.Contains(extra, "default_generated"):
+		return "(" + value + ")"
+	}
+
+	switch dataType {
+	case "tinyint", "smallint", "mediumint", "int", "bigint", "decimal", "float", "double", "bit":
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func loadMySQLConstraints(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	// One row per constraint column, in order. Check constraints have no
+	// key columns and come back as a single row
+	rows, err := db.QueryContext(ctx, `
+SELECT tc.TABLE_NAME, tc.CONSTRAINT_NAME, tc.CONSTRAINT_TYPE,
+       COALESCE(k.COLUMN_NAME, ''), COALESCE(k.REFERENCED_TABLE_NAME, ''), COALESCE(k.REFERENCED_COLUMN_NAME, ''),
+       COALESCE(rc.UPDATE_RULE, ''), COALESCE(rc.DELETE_RULE, ''), COALESCE(cc.CHECK_CLAUSE, '')
+FROM information_schema.TABLE_CONSTRAINTS tc
+LEFT JOIN information_schema.KEY_COLUMN_USAGE k
+     ON k.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA AND k.TABLE_NAME = tc.TABLE_NAME
+     AND k.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+LEFT JOIN information_schema.REFERENTIAL_CONSTRAINTS rc
+     ON rc.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA AND rc.TABLE_NAME = tc.TABLE_NAME
+     AND rc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+LEFT JOIN information_schema.CHECK_CONSTRAINTS cc
+     ON cc.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA AND cc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+     AND tc.CONSTRAINT_TYPE = 'CHECK'
+WHERE tc.TABLE_SCHEMA = DATABASE()
+ORDER BY tc.TABLE_NAME, tc.CONSTRAINT_NAME, k.ORDINAL_POSITION;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var last *schema.Constraint
+	var lastTable string
+	for rows.Next() {
+		var tableName, name, contype, column, refTable, refColumn, onUpdate, onDelete, check string
+		err := rows.Scan(&tableName, &name, &contype, &column, &refTable, &refColumn, &onUpdate, &onDelete, &check)
+		if err != nil {
+			return err
+		}
+
+		if last == nil || last.Name != name || lastTable != tableName {
+			last = &schema.Constraint{Name: name, Type: schema.ConstraintType(contype)}
+			lastTable = tableName
+			switch last.Type {
+			case schema.ForeignKey:
+				last.RefTable = refTable
+				last.OnUpdate = foreignKeyRule(onUpdate)
+				last.OnDelete = foreignKeyRule(onDelete)
+			case schema.Check:
+				last.Expression = check
+			}
+
+			if table := s.Table(tableName); table != nil {
+				table.Constraints = append(table.Constraints, last)
+			}
+		}
+
+		if column != "" {
+			last.Columns = append(last.Columns, column)
+		}
+		if refColumn != "" {
+			last.RefColumns = append(last.RefColumns, refColumn)
+		}
+	}
+
+	return rows.Err()
+}
+
+// Converts an information_schema or pragma rule to its SQL keyword. NO ACTION
+// is the default and is left empty, like for Postgres
+func foreignKeyRule(rule string) string {
+	if rule == "NO ACTION" {
+		return ""
+	}
+	return rule
+}
+
+func loadMySQLIndexes(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	// Primary keys and unique constraints are indexes in MySQL, and foreign
+	// keys get an index of the same name created for them. Those are all
+	// managed through the constraint
+	rows, err := db.QueryContext(ctx, `
+SELECT st.TABLE_NAME, st.INDEX_NAME, st.NON_UNIQUE = 0, st.INDEX_TYPE,
+       COALESCE(st.COLUMN_NAME, ''), COALESCE(st.SUB_PART, 0), COALESCE(st.COLLATION, '') = 'D'
+FROM information_schema.STATISTICS st
+WHERE st.TABLE_SCHEMA = DATABASE()
+  AND NOT EXISTS (
+      SELECT 1 FROM information_schema.TABLE_CONSTRAINTS tc
+      WHERE tc.TABLE_SCHEMA = st.TABLE_SCHEMA AND tc.TABLE_NAME = st.TABLE_NAME
+        AND tc.CONSTRAINT_NAME = st.INDEX_NAME)
+ORDER BY st.TABLE_NAME, st.INDEX_NAME, st.SEQ_IN_INDEX;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var last *schema.Index
+	var lastTable string
+	for rows.Next() {
+		var tableName, name, method, column string
+		var unique, descending bool
+		var prefix int
+		if err := rows.Scan(&tableName, &name, &unique, &method, &column, &prefix, &descending); err != nil {
+			return err
+		}
+
+		if last == nil || last.Name != name || lastTable != tableName {
+			last = &schema.Index{Name: name, Unique: unique, Method: method}
+			lastTable = tableName
+			if table := s.Table(tableName); table != nil {
+				table.Indexes = append(table.Indexes, last)
+			}
+		}
+
+		key := "`" + strings.ReplaceAll(column, "`", "``") + "`"
+		if prefix > 0 {
+			key += fmt.Sprintf("(%d)", prefix)
+		}
+		if descending {
+			key += " DESC"
+		}
+		last.Keys = append(last.Keys, key)
+	}
+
+	return rows.Err()
+}
+
+func loadMySQLViews(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT TABLE_NAME, VIEW_DEFINITION
+FROM information_schema.VIEWS
+WHERE TABLE_SCHEMA = DATABASE()
+ORDER BY TABLE_NAME;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		view := &schema.View{}
+		if err := rows.Scan(&view.Name, &view.Query); err != nil {
+			return err
+		}
+		s.Views = append(s.Views, view)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,416 @@
+package introspect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"styx/schema"
+)
+
+// SQLite reads the tables and views of a SQLite database from sqlite_master
+// and the pragma functions.
+//
+// SQLite doesn't name primary keys, unique and foreign key constraints, so
+// they're named the way Postgres would, e.g. users_pkey
+func SQLite(ctx context.Context, db *sql.DB) (*schema.Schema, error) {
+	s := &schema.Schema{}
+
+	steps := []struct {
+		name string
+		load func(context.Context, *sql.DB, *schema.Schema) error
+	}{
+		{"tables", loadSQLiteTables},
+		{"columns", loadSQLiteColumns},
+		{"constraints", loadSQLiteConstraints},
+		{"indexes", loadSQLiteIndexes},
+		{"views", loadSQLiteViews},
+	}
+	for _, step := range steps {
+		if err := step.load(ctx, db, s); err != nil {
+			return nil, fmt.Errorf("failed to introspect %s: %w", step.name, err)
+		}
+	}
+
+	s.Sort()
+	return s, nil
+}
+
+// The CREATE TABLE statements are kept around for what the pragmas don't
+// report: AUTOINCREMENT and check constraints
+type sqliteTable struct {
+	*schema.Table
+	sql string
+}
+
+func sqliteTables(ctx context.Context, db *sql.DB) ([]sqliteTable, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT name, sql
+FROM sqlite_master
+WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+ORDER BY name;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []sqliteTable
+	for rows.Next() {
+		table := sqliteTable{Table: &schema.Table{}}
+		if err := rows.Scan(&table.Name, &table.sql); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+
+	return tables, rows.Err()
+}
+
+func loadSQLiteTables(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	tables, err := sqliteTables(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		s.Tables = append(s.Tables, table.Table)
+	}
+	return nil
+}
+
+var autoIncrementPattern = regexp.MustCompile(`(?i)\bAUTOINCREMENT\b`)
+
+func loadSQLiteColumns(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	tables, err := sqliteTables(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tables {
+		table := s.Table(t.Name)
+		rows, err := db.QueryContext(ctx, `
+SELECT name, type, "notnull", COALESCE(dflt_value, ''), pk
+FROM pragma_table_info(?)
+ORDER BY cid;`, t.Name)
+		if err != nil {
+			return err
+		}
+
+		var primaryKey []*schema.Column
+		for rows.Next() {
+			var pk int
+			column := &schema.Column{}
+			if err := rows.Scan(&column.Name, &column.Type, &column.NotNull, &column.Default, &pk); err != nil {
+				rows.Close()
+				return err
+			}
+			if pk > 0 {
+				primaryKey = append(primaryKey, column)
+			}
+			table.Columns = append(table.Columns, column)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// Only a single INTEGER PRIMARY KEY column can be AUTOINCREMENT
+		if len(primaryKey) == 1 && autoIncrementPattern.MatchString(t.sql) {
+			primaryKey[0].AutoIncrement = true
+		}
+	}
+
+	return nil
+}
+
+func loadSQLiteConstraints(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	tables, err := sqliteTables(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tables {
+		table := s.Table(t.Name)
+
+		primaryKey, err := sqliteStrings(ctx, db, `
+SELECT name FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk;`, t.Name)
+		if err != nil {
+			return err
+		}
+		if len(primaryKey) > 0 {
+			table.Constraints = append(table.Constraints, &schema.Constraint{
+				Name:    t.Name + "_pkey",
+				Type:    schema.PrimaryKey,
+				Columns: primaryKey,
+			})
+		}
+
+		uniques, err := sqliteStrings(ctx, db, `
+SELECT name FROM pragma_index_list(?) WHERE origin = 'u' ORDER BY seq DESC;`, t.Name)
+		if err != nil {
+			return err
+		}
+		for _, index := range uniques {
+			columns, err := sqliteStrings(ctx, db, `
+SELECT name FROM pragma_index_info(?) ORDER BY seqno;`, index)
+			if err != nil {
+				return err
+			}
+			table.Constraints = append(table.Constraints, &schema.Constraint{
+				Name:    t.Name + "_" + strings.Join(columns, "_") + "_key",
+				Type:    schema.Unique,
+				Columns: columns,
+			})
+		}
+
+		if err := loadSQLiteForeignKeys(ctx, db, s, table); err != nil {
+			return err
+		}
+
+		for i, check := range sqliteChecks(t.sql) {
+			if check.Name == "" {
+				check.Name = fmt.Sprintf("%s_check%d", t.Name, i+1)
+			}
+			table.Constraints = append(table.Constraints, check)
+		}
+	}
+
+	return nil
+}
+
+func loadSQLiteForeignKeys(ctx context.Context, db *sql.DB, s *schema.Schema, table *schema.Table) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT id, "table", "from", COALESCE("to", ''), on_update, on_delete
+FROM pragma_foreign_key_list(?)
+ORDER BY id, seq;`, table.Name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var keys []*schema.Constraint
+	lastID := -1
+	for rows.Next() {
+		var id int
+		var refTable, column, refColumn, onUpdate, onDelete string
+		if err := rows.Scan(&id, &refTable, &column, &refColumn, &onUpdate, &onDelete); err != nil {
+			return err
+		}
+
+		if id != lastID {
+			lastID = id
+			keys = append(keys, &schema.Constraint{
+				Type:     schema.ForeignKey,
+				RefTable: refTable,
+				OnUpdate: foreignKeyRule(onUpdate),
+				OnDelete: foreignKeyRule(onDelete),
+			})
+		}
+		key := keys[len(keys)-1]
+		key.Columns = append(key.Columns, column)
+		if refColumn != "" {
+			key.RefColumns = append(key.RefColumns, refColumn)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// The pragma lists foreign keys last declared first
+	for i := len(keys) - 1; i >= 0; i-- {
+		key := keys[i]
+		key.Name = table.Name + "_" + strings.Join(key.Columns, "_") + "_fkey"
+		// Leaving out the referenced columns references the primary key
+		if len(key.RefColumns) == 0 {
+			if ref := s.Table(key.RefTable); ref != nil && ref.PrimaryKey() != nil {
+				key.RefColumns = ref.PrimaryKey().Columns
+			}
+		}
+		table.Constraints = append(table.Constraints, key)
+	}
+
+	return nil
+}
+
+var (
+	checkPattern      = regexp.MustCompile(`(?i)\bCHECK\s*\(`)
+	checkNamePattern  = regexp.MustCompile(`(?i)\bCONSTRAINT\s+("[^"]+"|\S+)\s*$`)
+	viewPrefixPattern = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:TEMP\s+|TEMPORARY\s+)?VIEW\s+(?:IF\s+NOT\s+EXISTS\s+)?(?:"[^"]+"|\S+)\s+AS\s+`)
+)
+
+// Pulls the check constraints out of a CREATE TABLE statement, as SQLite has
+// no pragma listing them. Unnamed checks are left without a name
+func sqliteChecks(createTable string) []*schema.Constraint {
+	var checks []*schema.Constraint
+	for _, loc := range checkPattern.FindAllStringIndex(createTable, -1) {
+		if inString(createTable[:loc[0]]) {
+			continue
+		}
+
+		start := loc[1]
+		depth := 1
+		end := start
+		for ; end < len(createTable) && depth > 0; end++ {
+			switch createTable[end] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+		}
+		if depth > 0 {
+			continue
+		}
+
+		check := &schema.Constraint{Type: schema.Check, Expression: strings.TrimSpace(createTable[start : end-1])}
+		if match := checkNamePattern.FindStringSubmatch(createTable[:loc[0]]); match != nil {
+			check.Name = strings.Trim(match[1], `"`)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// Reports whether a string literal is left open at the end of sql
+func inString(sql string) bool {
+	return strings.Count(sql, "'")%2 == 1
+}
+
+func loadSQLiteIndexes(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	// Indexes created for primary keys and unique constraints are managed
+	// through the constraint
+	rows, err := db.QueryContext(ctx, `
+SELECT m.tbl_name, m.name, il."unique", m.sql
+FROM sqlite_master m
+JOIN pragma_index_list(m.tbl_name) il ON il.name = m.name
+WHERE m.type = 'index' AND il.origin = 'c' AND m.tbl_name NOT LIKE 'sqlite_%'
+ORDER BY m.tbl_name, m.name;`)
+	if err != nil {
+		return err
+	}
+
+	type row struct {
+		table string
+		index *schema.Index
+		sql   string
+	}
+	var indexes []row
+	for rows.Next() {
+		r := row{index: &schema.Index{}}
+		if err := rows.Scan(&r.table, &r.index.Name, &r.index.Unique, &r.sql); err != nil {
+			rows.Close()
+			return err
+		}
+		indexes = append(indexes, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range indexes {
+		keys, err := sqliteIndexKeys(ctx, db, r.index.Name)
+		if err != nil {
+			return err
+		}
+		r.index.Keys = keys
+		r.index.Where = sqliteIndexWhere(r.sql)
+
+		if table := s.Table(r.table); table != nil {
+			table.Indexes = append(table.Indexes, r.index)
+		}
+	}
+
+	return nil
+}
+
+func sqliteIndexKeys(ctx context.Context, db *sql.DB, index string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT cid, COALESCE(name, ''), coll, "desc"
+FROM pragma_index_xinfo(?)
+WHERE key
+ORDER BY seqno;`, index)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var cid int
+		var name, collation string
+		var descending bool
+		if err := rows.Scan(&cid, &name, &collation, &descending); err != nil {
+			return nil, err
+		}
+		// The pragma doesn't report the expression itself
+		if cid == -2 {
+			return nil, fmt.Errorf("index %s: expression indexes aren't supported on SQLite", index)
+		}
+
+		key := schema.QuoteIdent(name)
+		if collation != "BINARY" {
+			key += " COLLATE " + collation
+		}
+		if descending {
+			key += " DESC"
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+var wherePattern = regexp.MustCompile(`(?i)\)\s*WHERE\s+`)
+
+// Returns the predicate of a partial index, or an empty string
+func sqliteIndexWhere(createIndex string) string {
+	locs := wherePattern.FindAllStringIndex(createIndex, -1)
+	if len(locs) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(createIndex[locs[len(locs)-1][1]:])
+}
+
+func loadSQLiteViews(ctx context.Context, db *sql.DB, s *schema.Schema) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT name, sql
+FROM sqlite_master
+WHERE type = 'view'
+ORDER BY name;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var definition string
+		view := &schema.View{}
+		if err := rows.Scan(&view.Name, &definition); err != nil {
+			return err
+		}
+		view.Query = strings.TrimSuffix(strings.TrimSpace(viewPrefixPattern.ReplaceAllString(definition, "")), ";")
+		s.Views = append(s.Views, view)
+	}
+
+	return rows.Err()
+}
+
+// Runs a query returning a single text column
+func sqliteStrings(ctx context.Context, db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
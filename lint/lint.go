@@ -0,0 +1,146 @@
+// Package lint checks migrations for statements that are unsafe to run
+// against a live Postgres database, like adding a NOT NULL column without a
+// default or building an index while blocking writes.
+package lint
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Off     Severity = "off"
+)
+
+// Rule is a single check run against every statement of a migration
+type Rule struct {
+	Name        string
+	Description string
+	// Severity is used unless the config overrides it
+	Severity Severity
+
+	check func(m *migration) []finding
+}
+
+// Finding is a rule violation
+type Finding struct {
+	Rule     string
+	Severity Severity
+	File     string
+	// Line is where the offending statement starts
+	Line    int
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s [%s] %s", f.File, f.Line, f.Severity, f.Rule, f.Message)
+}
+
+// A parsed migration file
+type migration struct {
+	sql   string
+	stmts []*pg_query.RawStmt
+}
+
+// A finding before its rule and file are known
+type finding struct {
+	stmt    *pg_query.RawStmt
+	message string
+}
+
+// Severities resolves the severity of every rule, applying the overrides
+// from the config on top of the defaults
+func Severities(overrides map[string]string) (map[string]Severity, error) {
+	severities := map[string]Severity{}
+	for _, rule := range Rules {
+		severities[rule.Name] = rule.Severity
+	}
+
+	for name, value := range overrides {
+		if _, ok := severities[name]; !ok {
+			return nil, fmt.Errorf("unknown lint rule %s", name)
+		}
+		severity := Severity(strings.ToLower(value))
+		if !slices.Contains([]Severity{Error, Warning, Off}, severity) {
+			return nil, fmt.Errorf("invalid severity %s for lint rule %s, expected error, warning or off", value, name)
+		}
+		severities[name] = severity
+	}
+
+	return severities, nil
+}
+
+// File lints the migration at path
+func File(path string, severities map[string]Severity) ([]Finding, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return Lint(path, string(contents), severities)
+}
+
+// Lint checks the statements of a migration, named name in the findings
+func Lint(name, sql string, severities map[string]Severity) ([]Finding, error) {
+	tree, err := pg_query.Parse(sql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	m := &migration{sql: sql, stmts: tree.Stmts}
+
+	var findings []Finding
+	for _, rule := range Rules {
+		severity := severities[rule.Name]
+		if severity == "" {
+			severity = rule.Severity
+		}
+		if severity == Off {
+			continue
+		}
+
+		for _, f := range rule.check(m) {
+			findings = append(findings, Finding{
+				Rule:     rule.Name,
+				Severity: severity,
+				File:     name,
+				Line:     m.line(f.stmt),
+				Message:  f.message,
+			})
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Line < findings[j].Line
+	})
+	return findings, nil
+}
+
+// Returns the line the statement starts on, skipping the whitespace and
+// comments pg_query counts as part of it
+func (m *migration) line(stmt *pg_query.RawStmt) int {
+	start := int(stmt.StmtLocation)
+	for start < len(m.sql) {
+		rest := m.sql[start:]
+		trimmed := strings.TrimLeft(rest, " \t\r\n")
+		if strings.HasPrefix(trimmed, "--") {
+			end := strings.IndexByte(trimmed, '\n')
+			if end < 0 {
+				break
+			}
+			trimmed = trimmed[end+1:]
+		}
+		if len(trimmed) == len(rest) {
+			break
+		}
+		start += len(rest) - len(trimmed)
+	}
+	return strings.Count(m.sql[:start], "\n") + 1
+}
@@ -0,0 +1,278 @@
+package lint
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Rules lists every rule, in the order they're run
+var Rules = []Rule{
+	{
+		Name:        "require-primary-key",
+		Description: "Tables should have a primary key",
+		Severity:    Warning,
+		check:       requirePrimaryKey,
+	},
+	{
+		Name:        "adding-not-null-column",
+		Description: "Columns added to existing tables as NOT NULL need a default",
+		Severity:    Error,
+		check:       addingNotNullColumn,
+	},
+	{
+		Name:        "require-concurrent-index",
+		Description: "Indexes on existing tables should be built CONCURRENTLY",
+		Severity:    Warning,
+		check:       requireConcurrentIndex,
+	},
+	{
+		Name:        "rename-via-drop-add",
+		Description: "Columns shouldn't be renamed by dropping and adding them",
+		Severity:    Error,
+		check:       renameViaDropAdd,
+	},
+	{
+		Name:        "volatile-default",
+		Description: "Columns added to existing tables shouldn't have a volatile default",
+		Severity:    Warning,
+		check:       volatileDefault,
+	},
+}
+
+// Functions whose result changes on every call. A column added with one of
+// them as default makes Postgres rewrite the whole table
+var volatileFunctions = map[string]bool{
+	"random":             true,
+	"clock_timestamp":    true,
+	"timeofday":          true,
+	"nextval":            true,
+	"gen_random_uuid":    true,
+	"uuid_generate_v1":   true,
+	"uuid_generate_v1mc": true,
+	"uuid_generate_v4":   true,
+	"txid_current":       true,
+}
+
+var serialTypes = map[string]bool{
+	"smallserial": true,
+	"serial2":     true,
+	"serial":      true,
+	"serial4":     true,
+	"bigserial":   true,
+	"serial8":     true,
+}
+
+// A column added to an existing table
+type addedColumn struct {
+	stmt   *pg_query.RawStmt
+	table  string
+	column *pg_query.ColumnDef
+}
+
+// Calls fn for every ALTER TABLE subcommand of the migration
+func (m *migration) alterCommands(fn func(stmt *pg_query.RawStmt, table string, cmd *pg_query.AlterTableCmd)) {
+	for _, stmt := range m.stmts {
+		alter := stmt.Stmt.GetAlterTableStmt()
+		if alter == nil || alter.Objtype != pg_query.ObjectType_OBJECT_TABLE {
+			continue
+		}
+		for _, cmd := range alter.Cmds {
+			fn(stmt, alter.Relation.Relname, cmd.GetAlterTableCmd())
+		}
+	}
+}
+
+// Tables created by the migration itself are empty and unused, so locking
+// or rewriting them is harmless
+func (m *migration) createdTables() map[string]bool {
+	created := map[string]bool{}
+	for _, stmt := range m.stmts {
+		if create := stmt.Stmt.GetCreateStmt(); create != nil {
+			created[create.Relation.Relname] = true
+		}
+	}
+	return created
+}
+
+func (m *migration) addedColumns() []addedColumn {
+	created := m.createdTables()
+	var columns []addedColumn
+	m.alterCommands(func(stmt *pg_query.RawStmt, table string, cmd *pg_query.AlterTableCmd) {
+		if cmd.Subtype == pg_query.AlterTableType_AT_AddColumn && !created[table] {
+			columns = append(columns, addedColumn{stmt, table, cmd.Def.GetColumnDef()})
+		}
+	})
+	return columns
+}
+
+func columnConstraint(column *pg_query.ColumnDef, types ...pg_query.ConstrType) *pg_query.Constraint {
+	for _, node := range column.Constraints {
+		if c := node.GetConstraint(); c != nil {
+			for _, typ := range types {
+				if c.Contype == typ {
+					return c
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func typeName(column *pg_query.ColumnDef) string {
+	names := column.TypeName.GetNames()
+	if len(names) == 0 {
+		return ""
+	}
+	return strings.ToLower(names[len(names)-1].GetString_().Sval)
+}
+
+func requirePrimaryKey(m *migration) []finding {
+	withKey := map[string]bool{}
+	m.alterCommands(func(stmt *pg_query.RawStmt, table string, cmd *pg_query.AlterTableCmd) {
+		if cmd.Subtype == pg_query.AlterTableType_AT_AddConstraint &&
+			cmd.Def.GetConstraint().GetContype() == pg_query.ConstrType_CONSTR_PRIMARY {
+			withKey[table] = true
+		}
+	})
+
+	var findings []finding
+	for _, stmt := range m.stmts {
+		create := stmt.Stmt.GetCreateStmt()
+		// Partitions inherit the key of their parent
+		if create == nil || create.Partbound != nil || withKey[create.Relation.Relname] {
+			continue
+		}
+
+		hasKey := false
+		for _, elt := range create.TableElts {
+			if column := elt.GetColumnDef(); column != nil {
+				hasKey = hasKey || columnConstraint(column, pg_query.ConstrType_CONSTR_PRIMARY) != nil
+			} else if elt.GetConstraint().GetContype() == pg_query.ConstrType_CONSTR_PRIMARY {
+				hasKey = true
+			}
+		}
+		if !hasKey {
+			findings = append(findings, finding{stmt, fmt.Sprintf("table %s is created without a primary key", create.Relation.Relname)})
+		}
+	}
+	return findings
+}
+
+func addingNotNullColumn(m *migration) []finding {
+	var findings []finding
+	for _, added := range m.addedColumns() {
+		column := added.column
+		if columnConstraint(column, pg_query.ConstrType_CONSTR_NOTNULL, pg_query.ConstrType_CONSTR_PRIMARY) == nil {
+			continue
+		}
+		// Identity, generated and serial columns fill in existing rows
+		if columnConstraint(column, pg_query.ConstrType_CONSTR_DEFAULT, pg_query.ConstrType_CONSTR_IDENTITY, pg_query.ConstrType_CONSTR_GENERATED) != nil ||
+			serialTypes[typeName(column)] {
+			continue
+		}
+		findings = append(findings, finding{added.stmt, fmt.Sprintf(
+			"column %s.%s is added as NOT NULL without a default, which fails if %s has rows",
+			added.table, column.Colname, added.table)})
+	}
+	return findings
+}
+
+func requireConcurrentIndex(m *migration) []finding {
+	created := m.createdTables()
+	var findings []finding
+	for _, stmt := range m.stmts {
+		index := stmt.Stmt.GetIndexStmt()
+		if index == nil || index.Concurrent || created[index.Relation.Relname] {
+			continue
+		}
+		name := index.Idxname
+		if name == "" {
+			name = "on " + index.Relation.Relname
+		}
+		findings = append(findings, finding{stmt, fmt.Sprintf(
+			"index %s is built without CONCURRENTLY, which blocks writes to %s until it's done",
+			name, index.Relation.Relname)})
+	}
+	return findings
+}
+
+func renameViaDropAdd(m *migration) []finding {
+	type drop struct {
+		stmt   *pg_query.RawStmt
+		column string
+	}
+	dropped := map[string][]drop{}
+	m.alterCommands(func(stmt *pg_query.RawStmt, table string, cmd *pg_query.AlterTableCmd) {
+		if cmd.Subtype == pg_query.AlterTableType_AT_DropColumn {
+			dropped[table] = append(dropped[table], drop{stmt, cmd.Name})
+		}
+	})
+
+	// A column dropped and added under the same name is recreated, not renamed
+	var renamed []addedColumn
+	for _, added := range m.addedColumns() {
+		drops := dropped[added.table]
+		if i := slices.IndexFunc(drops, func(d drop) bool { return d.column == added.column.Colname }); i >= 0 {
+			dropped[added.table] = slices.Delete(drops, i, i+1)
+			continue
+		}
+		renamed = append(renamed, added)
+	}
+
+	var findings []finding
+	for _, added := range renamed {
+		drops := dropped[added.table]
+		if len(drops) == 0 {
+			continue
+		}
+		d := drops[0]
+		dropped[added.table] = drops[1:]
+		findings = append(findings, finding{d.stmt, fmt.Sprintf(
+			"column %s.%s is dropped and %s added, which loses its data if it's a rename; use ALTER TABLE ... RENAME COLUMN instead",
+			added.table, d.column, added.column.Colname)})
+	}
+	return findings
+}
+
+func volatileDefault(m *migration) []finding {
+	var findings []finding
+	for _, added := range m.addedColumns() {
+		column := added.column
+		volatile := serialTypes[typeName(column)]
+		if def := columnConstraint(column, pg_query.ConstrType_CONSTR_DEFAULT); def != nil && def.RawExpr != nil {
+			walk(def.RawExpr.ProtoReflect(), func(msg protoreflect.Message) {
+				if call, ok := msg.Interface().(*pg_query.FuncCall); ok && len(call.Funcname) > 0 {
+					name := call.Funcname[len(call.Funcname)-1].GetString_().Sval
+					volatile = volatile || volatileFunctions[strings.ToLower(name)]
+				}
+			})
+		}
+		if volatile {
+			findings = append(findings, finding{added.stmt, fmt.Sprintf(
+				"column %s.%s has a volatile default, which rewrites %s while locking it",
+				added.table, column.Colname, added.table)})
+		}
+	}
+	return findings
+}
+
+// Calls fn for every message in the tree rooted at m
+func walk(m protoreflect.Message, fn func(protoreflect.Message)) {
+	fn(m)
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				walk(list.Get(i).Message(), fn)
+			}
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			walk(v.Message(), fn)
+		}
+		return true
+	})
+}
@@ -0,0 +1,187 @@
+package lint
+
+import (
+	"slices"
+	"testing"
+)
+
+type ruleTest struct {
+	name string
+	sql  string
+	// want are the lines the rule reports findings on
+	want []int
+}
+
+func testRule(t *testing.T, rule string, tests []ruleTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := Lint("migration.sql", tt.sql, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, f := range findings {
+				if f.Rule == rule {
+					got = append(got, f.Line)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got findings on lines %v, want %v: %v", got, tt.want, findings)
+			}
+		})
+	}
+}
+
+func TestRenameViaDropAdd(t *testing.T) {
+	testRule(t, "rename-via-drop-add", []ruleTest{
+		{
+			name: "drop and add",
+			sql:  "ALTER TABLE t DROP COLUMN a;\nALTER TABLE t ADD COLUMN b int;",
+			want: []int{1},
+		},
+		{
+			name: "in one statement",
+			sql:  "ALTER TABLE t DROP COLUMN a, ADD COLUMN b int;",
+			want: []int{1},
+		},
+		{
+			name: "recreated under the same name",
+			sql:  "ALTER TABLE t DROP COLUMN a;\nALTER TABLE t ADD COLUMN a bigint;",
+		},
+		{
+			name: "recreated next to a rename",
+			sql:  "ALTER TABLE t DROP COLUMN a;\nALTER TABLE t DROP COLUMN b;\nALTER TABLE t ADD COLUMN c int;\nALTER TABLE t ADD COLUMN a bigint;",
+			want: []int{2},
+		},
+		{
+			name: "on different tables",
+			sql:  "ALTER TABLE t DROP COLUMN a;\nALTER TABLE u ADD COLUMN b int;",
+		},
+		{
+			name: "drop only",
+			sql:  "ALTER TABLE t DROP COLUMN a;",
+		},
+	})
+}
+
+func TestRequirePrimaryKey(t *testing.T) {
+	testRule(t, "require-primary-key", []ruleTest{
+		{
+			name: "without a key",
+			sql:  "CREATE TABLE t (id int);",
+			want: []int{1},
+		},
+		{
+			name: "column key",
+			sql:  "CREATE TABLE t (id int PRIMARY KEY);",
+		},
+		{
+			name: "table key",
+			sql:  "CREATE TABLE t (a int, b int, PRIMARY KEY (a, b));",
+		},
+		{
+			name: "key added later",
+			sql:  "CREATE TABLE t (id int);\nALTER TABLE t ADD PRIMARY KEY (id);",
+		},
+		{
+			name: "partition",
+			sql:  "CREATE TABLE t (id int PRIMARY KEY) PARTITION BY RANGE (id);\nCREATE TABLE t_1 PARTITION OF t FOR VALUES FROM (0) TO (10);",
+		},
+	})
+}
+
+func TestAddingNotNullColumn(t *testing.T) {
+	testRule(t, "adding-not-null-column", []ruleTest{
+		{
+			name: "without a default",
+			sql:  "ALTER TABLE t ADD COLUMN a int NOT NULL;",
+			want: []int{1},
+		},
+		{
+			name: "primary key",
+			sql:  "ALTER TABLE t ADD COLUMN a int PRIMARY KEY;",
+			want: []int{1},
+		},
+		{
+			name: "with a default",
+			sql:  "ALTER TABLE t ADD COLUMN a int NOT NULL DEFAULT 0;",
+		},
+		{
+			name: "identity",
+			sql:  "ALTER TABLE t ADD COLUMN a int NOT NULL GENERATED ALWAYS AS IDENTITY;",
+		},
+		{
+			name: "serial",
+			sql:  "ALTER TABLE t ADD COLUMN a bigserial NOT NULL;",
+		},
+		{
+			name: "nullable",
+			sql:  "ALTER TABLE t ADD COLUMN a int;",
+		},
+		{
+			name: "table created in the migration",
+			sql:  "CREATE TABLE t (id int PRIMARY KEY);\nALTER TABLE t ADD COLUMN a int NOT NULL;",
+		},
+	})
+}
+
+func TestRequireConcurrentIndex(t *testing.T) {
+	testRule(t, "require-concurrent-index", []ruleTest{
+		{
+			name: "without concurrently",
+			sql:  "CREATE INDEX t_a_idx ON t (a);",
+			want: []int{1},
+		},
+		{
+			name: "unnamed",
+			sql:  "\nCREATE UNIQUE INDEX ON t (a);",
+			want: []int{2},
+		},
+		{
+			name: "concurrently",
+			sql:  "CREATE INDEX CONCURRENTLY t_a_idx ON t (a);",
+		},
+		{
+			name: "table created in the migration",
+			sql:  "CREATE TABLE t (id int PRIMARY KEY, a int);\nCREATE INDEX t_a_idx ON t (a);",
+		},
+	})
+}
+
+func TestVolatileDefault(t *testing.T) {
+	testRule(t, "volatile-default", []ruleTest{
+		{
+			name: "volatile function",
+			sql:  "ALTER TABLE t ADD COLUMN id uuid DEFAULT gen_random_uuid();",
+			want: []int{1},
+		},
+		{
+			name: "nested in an expression",
+			sql:  "ALTER TABLE t ADD COLUMN n int DEFAULT floor(random() * 10)::int;",
+			want: []int{1},
+		},
+		{
+			name: "qualified function",
+			sql:  "ALTER TABLE t ADD COLUMN at timestamptz DEFAULT pg_catalog.clock_timestamp();",
+			want: []int{1},
+		},
+		{
+			name: "serial",
+			sql:  "ALTER TABLE t ADD COLUMN n serial;",
+			want: []int{1},
+		},
+		{
+			name: "stable function",
+			sql:  "ALTER TABLE t ADD COLUMN at timestamptz DEFAULT now();",
+		},
+		{
+			name: "constant",
+			sql:  "ALTER TABLE t ADD COLUMN n int DEFAULT 0;",
+		},
+		{
+			name: "table created in the migration",
+			sql:  "CREATE TABLE t (id int PRIMARY KEY);\nALTER TABLE t ADD COLUMN u uuid DEFAULT gen_random_uuid();",
+		},
+	})
+}
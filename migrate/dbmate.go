@@ -0,0 +1,69 @@
+package migrate
+
+import (
+	"strings"
+)
+
+// Annotations dbmate splits a migration at
+const (
+	dbmateUp   = "-- migrate:up"
+	dbmateDown = "-- migrate:down"
+	// Follows the annotation of a section to run it outside of a transaction
+	dbmateNoTransaction = " transaction:false"
+)
+
+type dbmate struct{}
+
+func (dbmate) parse(name string) (string, string, []string) {
+	return goose{}.parse(name)
+}
+
+func (dbmate) directions() []string {
+	return []string{""}
+}
+
+func (dbmate) filename(version, description, direction string) string {
+	return goose{}.filename(version, description, direction)
+}
+
+// dbmate runs each section in a transaction of its own, unless its annotation
+// says otherwise
+func (dbmate) render(m *Migration, _, header string) string {
+	var b strings.Builder
+	b.WriteString(header)
+	for i, section := range []struct{ annotation, content string }{{dbmateUp, m.Up}, {dbmateDown, m.Down}} {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(section.annotation)
+		if strings.HasPrefix(section.content, NoTransactionMarker) {
+			b.WriteString(dbmateNoTransaction)
+		}
+		b.WriteString("\n" + m.settings(section.content) + section.content)
+	}
+	return b.String()
+}
+
+func (dbmate) section(content, direction string) (string, bool) {
+	if !strings.Contains(content, dbmateUp) {
+		return "", false
+	}
+	// The down annotation may carry options too
+	lines := strings.SplitAfter(content, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), dbmateDown) {
+			if direction == "down" {
+				return strings.Join(lines[i+1:], ""), true
+			}
+			return strings.Join(lines[:i], ""), true
+		}
+	}
+	if direction == "down" {
+		return "", true
+	}
+	return content, true
+}
+
+func (dbmate) versionWidth() int {
+	return 6
+}
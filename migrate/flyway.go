@@ -0,0 +1,50 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Versioned migrations start with V and undo ones with U. Flyway's dotted
+// versions, like V1.1, aren't supported
+var flywayPattern = regexp.MustCompile(`^([VU])([0-9]+)__(.*)\.sql$`)
+
+type flyway struct{}
+
+func (flyway) parse(name string) (string, string, []string) {
+	match := flywayPattern.FindStringSubmatch(name)
+	if match == nil {
+		return "", "", nil
+	}
+	if match[1] == "U" {
+		return match[2], match[3], []string{"down"}
+	}
+	return match[2], match[3], []string{"up"}
+}
+
+func (flyway) directions() []string {
+	return []string{"up", "down"}
+}
+
+func (flyway) filename(version, description, direction string) string {
+	prefix := "V"
+	if direction == "down" {
+		prefix = "U"
+	}
+	return fmt.Sprintf("%s%s__%s.sql", prefix, version, description)
+}
+
+// Flyway runs a migration made only of statements that can't run in a
+// transaction, like CREATE INDEX CONCURRENTLY, outside of one by itself
+func (flyway) render(m *Migration, direction, header string) string {
+	return m.separateFile(direction, header)
+}
+
+func (flyway) section(content, _ string) (string, bool) {
+	return content, true
+}
+
+// Flyway versions aren't padded
+func (flyway) versionWidth() int {
+	return 1
+}
@@ -0,0 +1,178 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Format is the layout of the migration files, after the tool applying them
+type Format string
+
+const (
+	// GolangMigrate writes an up and a down file per migration, e.g.
+	// 000001_create_users.up.sql
+	GolangMigrate Format = "golang-migrate"
+	// Goose writes a single file per migration, e.g. 00001_create_users.sql,
+	// holding the up and down sections goose splits it into
+	Goose Format = "goose"
+	// Dbmate writes a single file per migration, e.g.
+	// 000001_create_users.sql, with -- migrate:up and -- migrate:down sections
+	Dbmate Format = "dbmate"
+	// Flyway writes a versioned migration and an undo one, e.g.
+	// V1__create_users.sql and U1__create_users.sql
+	Flyway Format = "flyway"
+)
+
+// A layout names the files of a format and renders their contents. Adding a
+// format takes a layout in formats
+type layout interface {
+	// parse returns the version, description and directions of the
+	// migration file called name, or nil directions if it isn't one
+	parse(name string) (version, description string, directions []string)
+	// directions lists the directions of the files of a migration, a single
+	// empty one for formats holding both in one file
+	directions() []string
+	// filename is the name of the file of direction, when there's no
+	// filename template
+	filename(version, description, direction string) string
+	// render returns the contents of the file of direction
+	render(m *Migration, direction, header string) string
+	// section returns the SQL of direction in a file of the format, or false
+	// if content isn't one
+	section(content, direction string) (string, bool)
+	// versionWidth is the width of the version of the first migration
+	versionWidth() int
+}
+
+var formats = map[Format]layout{
+	GolangMigrate: golangMigrate{},
+	Goose:         goose{},
+	Dbmate:        dbmate{},
+	Flyway:        flyway{},
+}
+
+// Order the files are matched in. goose and dbmate files have the same names
+// and are told apart by their annotations
+var layouts = []layout{golangMigrate{}, flyway{}, goose{}, dbmate{}}
+
+func (m *Migration) layout() (layout, error) {
+	if m.Format == "" {
+		return golangMigrate{}, nil
+	}
+	l, ok := formats[m.Format]
+	if !ok {
+		return nil, fmt.Errorf("unknown migration format %q, expected %s, %s, %s or %s", m.Format, GolangMigrate, Goose, Dbmate, Flyway)
+	}
+	return l, nil
+}
+
+// Returns the version, description and directions held by the migration file
+// called name, or nil directions if it isn't one
+func parseFilename(name string) (version, description string, directions []string) {
+	for _, l := range layouts {
+		if version, description, directions = l.parse(name); directions != nil {
+			return version, description, directions
+		}
+	}
+	return "", "", nil
+}
+
+// Read returns the SQL of the file's direction. For a file holding both
+// directions that's its section of the file, the lines before the up section
+// included so line numbers still match the file
+func (f File) Read() (string, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", f.Path, err)
+	}
+
+	name := filepath.Base(f.Path)
+	for _, l := range layouts {
+		if _, _, directions := l.parse(name); directions == nil {
+			continue
+		}
+		if sql, ok := l.section(string(data), f.Direction); ok {
+			return sql, nil
+		}
+	}
+	return string(data), nil
+}
+
+// ReadUp returns the SQL of the up migration in the file at path
+func ReadUp(path string) (string, error) {
+	return File{Direction: "up", Path: path}.Read()
+}
+
+// Splits content at the line holding the annotation starting the down
+// section, compared case-insensitively
+func splitSections(content, down string) (string, string) {
+	lines := strings.SplitAfter(content, "\n")
+	for i, line := range lines {
+		if strings.EqualFold(strings.TrimSpace(line), down) {
+			return strings.Join(lines[:i], ""), strings.Join(lines[i+1:], "")
+		}
+	}
+	return content, ""
+}
+
+// Returns the section of direction in a file holding both, split at the
+// annotation starting the down section
+func sectionOf(content, direction, down string) string {
+	up, rest := splitSections(content, down)
+	if direction == "down" {
+		return rest
+	}
+	return up
+}
+
+// Renders the file of a format writing one file per direction: the header,
+// then the settings and the changes
+func (m *Migration) separateFile(direction, header string) string {
+	content := m.Up
+	if direction == "down" {
+		content = m.Down
+	}
+	return header + m.settings(content) + content
+}
+
+// Reports whether either direction holds a statement that can't run in a
+// transaction, which makes tools running a file as a whole run it outside of
+// one
+func (m *Migration) nonTransactional() bool {
+	return strings.HasPrefix(m.Up, NoTransactionMarker) || strings.HasPrefix(m.Down, NoTransactionMarker)
+}
+
+var golangMigratePattern = regexp.MustCompile(`^([0-9]+)_(.*)\.(down|up)\.sql$`)
+
+type golangMigrate struct{}
+
+func (golangMigrate) parse(name string) (string, string, []string) {
+	if match := golangMigratePattern.FindStringSubmatch(name); match != nil {
+		return match[1], match[2], []string{match[3]}
+	}
+	return "", "", nil
+}
+
+func (golangMigrate) directions() []string {
+	return []string{"up", "down"}
+}
+
+func (golangMigrate) filename(version, description, direction string) string {
+	return fmt.Sprintf("%s_%s.%s.sql", version, description, direction)
+}
+
+func (golangMigrate) render(m *Migration, direction, header string) string {
+	return m.separateFile(direction, header)
+}
+
+func (golangMigrate) section(content, direction string) (string, bool) {
+	return content, true
+}
+
+// Matches the default of `migrate create -seq`
+func (golangMigrate) versionWidth() int {
+	return 6
+}
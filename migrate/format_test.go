@@ -0,0 +1,238 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseFilename(t *testing.T) {
+	tests := []struct {
+		name                 string
+		version, description string
+		directions           []string
+	}{
+		{"000001_create_users.up.sql", "000001", "create_users", []string{"up"}},
+		{"000001_create_users.down.sql", "000001", "create_users", []string{"down"}},
+		{"20240102150405_add_email.up.sql", "20240102150405", "add_email", []string{"up"}},
+		{"V3__create_users.sql", "3", "create_users", []string{"up"}},
+		{"U3__create_users.sql", "3", "create_users", []string{"down"}},
+		{"00001_create_users.sql", "00001", "create_users", []string{"up", "down"}},
+		{"V1.1__create_users.sql", "", "", nil},
+		{"create_users.sql", "", "", nil},
+		{"000001_create_users.up.txt", "", "", nil},
+		{"README.md", "", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, description, directions := parseFilename(tt.name)
+			if version != tt.version || description != tt.description || !slices.Equal(directions, tt.directions) {
+				t.Errorf("got %q, %q, %v, want %q, %q, %v", version, description, directions, tt.version, tt.description, tt.directions)
+			}
+		})
+	}
+}
+
+func TestWriteFormats(t *testing.T) {
+	up := "CREATE TABLE users (id int);\n"
+	down := "DROP TABLE users;\n"
+	concurrent := NoTransactionMarker + "\nCREATE INDEX CONCURRENTLY users_id_idx ON users (id);\n"
+	tests := []struct {
+		format   Format
+		up, down string
+		// files maps the names of the files written to what they hold
+		files map[string][]string
+	}{
+		{
+			format: GolangMigrate,
+			up:     up,
+			down:   down,
+			files: map[string][]string{
+				"000001_create_users.up.sql":   {"SET lock_timeout = '5s';", up},
+				"000001_create_users.down.sql": {"SET lock_timeout = '5s';", down},
+			},
+		},
+		{
+			format: Goose,
+			up:     up,
+			down:   down,
+			files: map[string][]string{
+				"00001_create_users.sql": {gooseUp, gooseStatementBegin, "SET lock_timeout = '5s';", up, gooseDown, down},
+			},
+		},
+		{
+			format: Goose,
+			up:     concurrent,
+			files: map[string][]string{
+				"00001_create_users.sql": {gooseNoTransaction, gooseUp, concurrent, gooseDown},
+			},
+		},
+		{
+			format: Dbmate,
+			up:     up,
+			down:   down,
+			files: map[string][]string{
+				"000001_create_users.sql": {dbmateUp + "\n", "SET lock_timeout = '5s';", up, dbmateDown + "\n", down},
+			},
+		},
+		{
+			format: Dbmate,
+			up:     concurrent,
+			down:   down,
+			files: map[string][]string{
+				"000001_create_users.sql": {dbmateUp + dbmateNoTransaction, concurrent, dbmateDown + "\n", down},
+			},
+		},
+		{
+			format: Flyway,
+			up:     up,
+			down:   down,
+			files: map[string][]string{
+				"V1__create_users.sql": {"SET lock_timeout = '5s';", up},
+				"U1__create_users.sql": {"SET lock_timeout = '5s';", down},
+			},
+		},
+	}
+	for _, tt := range tests {
+		name := string(tt.format)
+		if strings.HasPrefix(tt.up, NoTransactionMarker) {
+			name += " without a transaction"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			m := &Migration{Version: 1, Description: "create_users", Up: tt.up, Down: tt.down, Format: tt.format, Settings: []string{"SET lock_timeout = '5s';"}}
+			paths, err := m.Write(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(paths) != len(tt.files) {
+				t.Errorf("got files %v, want %d", paths, len(tt.files))
+			}
+			for _, path := range paths {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// What the file holds comes in order
+				content := string(data)
+				want, ok := tt.files[filepath.Base(path)]
+				if !ok {
+					t.Errorf("unexpected file %s", filepath.Base(path))
+				}
+				for _, part := range want {
+					i := strings.Index(content, part)
+					if i < 0 {
+						t.Errorf("%s doesn't hold %q next, holding:\n%s", filepath.Base(path), part, data)
+						break
+					}
+					content = content[i+len(part):]
+				}
+			}
+
+			// Reading the files back splits them into the same directions
+			files, err := ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range files {
+				sql, err := f.Read()
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := tt.up
+				if f.Direction == "down" {
+					want = tt.down
+				}
+				if f.Version != 1 || f.Description != "create_users" || !strings.Contains(sql, want) {
+					t.Errorf("read %d %s %s as:\n%s\nwant it to hold:\n%s", f.Version, f.Description, f.Direction, sql, want)
+				}
+				if f.Direction == "up" && tt.down != "" && strings.Contains(sql, tt.down) {
+					t.Errorf("the up section holds the down migration:\n%s", sql)
+				}
+			}
+		})
+	}
+}
+
+func TestSections(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   Format
+		content  string
+		up, down string
+		ok       bool
+	}{
+		{
+			name:    "goose",
+			format:  Goose,
+			content: "-- +goose Up\nCREATE TABLE t (a int);\n\n-- +goose Down\nDROP TABLE t;\n",
+			up:      "-- +goose Up\nCREATE TABLE t (a int);\n\n",
+			down:    "DROP TABLE t;\n",
+			ok:      true,
+		},
+		{
+			name:    "goose annotations in another case",
+			format:  Goose,
+			content: "-- +goose up\nCREATE TABLE t (a int);\n-- +GOOSE DOWN\nDROP TABLE t;\n",
+			up:      "-- +goose up\nCREATE TABLE t (a int);\n",
+			down:    "DROP TABLE t;\n",
+			ok:      true,
+		},
+		{
+			name:    "goose without a down section",
+			format:  Goose,
+			content: "-- +goose Up\nCREATE TABLE t (a int);\n",
+			up:      "-- +goose Up\nCREATE TABLE t (a int);\n",
+			ok:      true,
+		},
+		{
+			name:    "goose without annotations",
+			format:  Goose,
+			content: "CREATE TABLE t (a int);\n",
+		},
+		{
+			name:    "dbmate",
+			format:  Dbmate,
+			content: "-- migrate:up\nCREATE TABLE t (a int);\n\n-- migrate:down\nDROP TABLE t;\n",
+			up:      "-- migrate:up\nCREATE TABLE t (a int);\n\n",
+			down:    "DROP TABLE t;\n",
+			ok:      true,
+		},
+		{
+			name:    "dbmate down section with options",
+			format:  Dbmate,
+			content: "-- migrate:up\nCREATE TABLE t (a int);\n-- migrate:down transaction:false\nDROP TABLE t;\n",
+			up:      "-- migrate:up\nCREATE TABLE t (a int);\n",
+			down:    "DROP TABLE t;\n",
+			ok:      true,
+		},
+		{
+			name:    "dbmate without annotations",
+			format:  Dbmate,
+			content: "CREATE TABLE t (a int);\n",
+		},
+		{
+			name:    "flyway",
+			format:  Flyway,
+			content: "CREATE TABLE t (a int);\n",
+			up:      "CREATE TABLE t (a int);\n",
+			down:    "CREATE TABLE t (a int);\n",
+			ok:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := formats[tt.format]
+			up, ok := l.section(tt.content, "up")
+			if ok != tt.ok {
+				t.Fatalf("got ok %v, want %v", ok, tt.ok)
+			}
+			down, _ := l.section(tt.content, "down")
+			if ok && (up != tt.up || down != tt.down) {
+				t.Errorf("got up %q and down %q, want %q and %q", up, down, tt.up, tt.down)
+			}
+		})
+	}
+}
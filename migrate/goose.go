@@ -0,0 +1,76 @@
+package migrate
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Annotations goose reads from the comments of a migration
+const (
+	gooseUp             = "-- +goose Up"
+	gooseDown           = "-- +goose Down"
+	gooseNoTransaction  = "-- +goose NO TRANSACTION"
+	gooseStatementBegin = "-- +goose StatementBegin"
+	gooseStatementEnd   = "-- +goose StatementEnd"
+)
+
+// Names of the migrations of the formats holding both directions in a file
+var singleFilePattern = regexp.MustCompile(`^([0-9]+)_(.*)\.sql$`)
+
+type goose struct{}
+
+func (goose) parse(name string) (string, string, []string) {
+	if match := singleFilePattern.FindStringSubmatch(name); match != nil {
+		return match[1], match[2], []string{"up", "down"}
+	}
+	return "", "", nil
+}
+
+func (goose) directions() []string {
+	return []string{""}
+}
+
+func (goose) filename(version, description, _ string) string {
+	return version + "_" + description + ".sql"
+}
+
+// Each section is a single statement block, so goose sends it whole like
+// golang-migrate does with a file. goose runs the migration outside of a
+// transaction, without the settings, if either direction can't run in one
+func (goose) render(m *Migration, _, header string) string {
+	var b strings.Builder
+	b.WriteString(header)
+	noTransaction := m.nonTransactional()
+	if noTransaction {
+		b.WriteString(gooseNoTransaction + "\n\n")
+	}
+	for i, section := range []struct{ annotation, content string }{{gooseUp, m.Up}, {gooseDown, m.Down}} {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(section.annotation + "\n")
+		if section.content == "" {
+			continue
+		}
+		b.WriteString(gooseStatementBegin + "\n")
+		// Settings are local to the transaction
+		if !noTransaction {
+			b.WriteString(m.settings(section.content))
+		}
+		b.WriteString(section.content)
+		b.WriteString(gooseStatementEnd + "\n")
+	}
+	return b.String()
+}
+
+func (goose) section(content, direction string) (string, bool) {
+	if !strings.Contains(strings.ToLower(content), strings.ToLower(gooseUp)) {
+		return "", false
+	}
+	return sectionOf(content, direction, gooseDown), true
+}
+
+// Matches `goose create -s`
+func (goose) versionWidth() int {
+	return 5
+}
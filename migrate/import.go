@@ -0,0 +1,179 @@
+package migrate
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PlainSQL is a directory of SQL files applied in name order, without down
+// migrations. It's only read by Import
+const PlainSQL Format = "sql"
+
+// Comments the tools read annotations from, removed from imported migrations
+var annotationPrefixes = map[Format]string{
+	Goose:  "-- +goose",
+	Dbmate: "-- migrate:",
+}
+
+var leadingNumber = regexp.MustCompile(`^([0-9]+)[_.-]*`)
+
+// Import reads the migrations in dir, written for another tool, as
+// golang-migrate migrations in the same order. Versioned migrations keep
+// their version, and the files of PlainSQL are numbered from 1 in name order,
+// the leading numbers of names compared as numbers
+func Import(dir string, from Format) ([]*Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory %s: %w", dir, err)
+	}
+	if from == PlainSQL {
+		return importPlainSQL(dir, entries)
+	}
+	l, ok := formats[from]
+	if !ok || from == GolangMigrate {
+		return nil, fmt.Errorf("can't import %q migrations, expected %s, %s, %s or %s", from, Goose, Dbmate, Flyway, PlainSQL)
+	}
+
+	migrations := map[uint64]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if from == Goose && strings.HasSuffix(name, ".go") {
+			return nil, fmt.Errorf("%s is a Go migration, only SQL ones can be imported", name)
+		}
+		if filepath.Ext(name) != ".sql" {
+			continue
+		}
+		prefix, description, directions := l.parse(name)
+		if directions == nil {
+			return nil, fmt.Errorf("%s isn't a %s migration", name, from)
+		}
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", name, err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		m, ok := migrations[version]
+		if !ok {
+			m = &Migration{Version: version, Description: slug(description), width: max(len(prefix), golangMigrate{}.versionWidth())}
+			migrations[version] = m
+		}
+		for _, direction := range directions {
+			section, ok := l.section(string(data), direction)
+			if !ok {
+				return nil, fmt.Errorf("%s has no %s annotations", name, from)
+			}
+			section = importedSQL(section, from, nonTransactionalSection(string(data), direction, from))
+			if direction == "up" {
+				m.Up = section
+			} else {
+				m.Down = section
+			}
+		}
+	}
+
+	imported := make([]*Migration, 0, len(migrations))
+	for _, m := range migrations {
+		imported = append(imported, m)
+	}
+	slices.SortFunc(imported, func(a, b *Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return imported, nil
+}
+
+func importPlainSQL(dir string, entries []os.DirEntry) ([]*Migration, error) {
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".sql" {
+			names = append(names, entry.Name())
+		}
+	}
+	// Numbered files come first, so 2_users.sql runs before 10_posts.sql
+	slices.SortFunc(names, func(a, b string) int {
+		na, numberedA := nameNumber(a)
+		nb, numberedB := nameNumber(b)
+		if numberedA != numberedB {
+			if numberedA {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(cmp.Compare(na, nb), strings.Compare(a, b))
+	})
+
+	var imported []*Migration
+	for i, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		description := slug(leadingNumber.ReplaceAllString(strings.TrimSuffix(name, ".sql"), ""))
+		if description == "" {
+			description = "migration"
+		}
+		imported = append(imported, &Migration{
+			Version:     uint64(i + 1),
+			Description: description,
+			Up:          importedSQL(string(data), PlainSQL, false),
+			width:       golangMigrate{}.versionWidth(),
+		})
+	}
+	return imported, nil
+}
+
+// Returns the number name starts with, if any
+func nameNumber(name string) (uint64, bool) {
+	match := leadingNumber.FindStringSubmatch(name)
+	if match == nil {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(match[1], 10, 64)
+	return n, err == nil
+}
+
+// Removes the annotations of the tool from a section, starting it with
+// NoTransactionMarker if it can't run in a transaction
+func importedSQL(section string, from Format, nonTransactional bool) string {
+	var lines []string
+	for _, line := range strings.Split(section, "\n") {
+		if prefix, ok := annotationPrefixes[from]; ok && strings.HasPrefix(strings.ToLower(strings.TrimSpace(line)), prefix) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	sql := strings.TrimSpace(strings.Join(lines, "\n"))
+	if sql == "" {
+		return ""
+	}
+	if nonTransactional {
+		sql = NoTransactionMarker + "\n" + sql
+	}
+	return sql + "\n"
+}
+
+// Reports whether the section of direction runs outside of a transaction:
+// the whole migration for goose, each section for dbmate
+func nonTransactionalSection(content, direction string, from Format) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.ToLower(strings.TrimSpace(line))
+		switch {
+		case from == Goose && line == strings.ToLower(gooseNoTransaction):
+			return true
+		case from == Dbmate && strings.HasPrefix(line, "-- migrate:"+direction):
+			return strings.Contains(line, strings.TrimSpace(dbmateNoTransaction))
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    Format
+		files   map[string]string
+		want    []Migration
+		wantErr string
+	}{
+		{
+			name: "goose",
+			from: Goose,
+			files: map[string]string{
+				"00002_add_email.sql":    "-- +goose Up\nALTER TABLE users ADD COLUMN email text;\n\n-- +goose Down\nALTER TABLE users DROP COLUMN email;\n",
+				"00001_create_users.sql": "-- +goose Up\n-- +goose StatementBegin\nCREATE TABLE users (id int);\n-- +goose StatementEnd\n\n-- +goose Down\nDROP TABLE users;\n",
+			},
+			want: []Migration{
+				{Version: 1, Description: "create_users", Up: "CREATE TABLE users (id int);\n", Down: "DROP TABLE users;\n"},
+				{Version: 2, Description: "add_email", Up: "ALTER TABLE users ADD COLUMN email text;\n", Down: "ALTER TABLE users DROP COLUMN email;\n"},
+			},
+		},
+		{
+			name: "goose without a transaction",
+			from: Goose,
+			files: map[string]string{
+				"00001_index_users.sql": "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY users_id_idx ON users (id);\n\n-- +goose Down\nDROP INDEX CONCURRENTLY users_id_idx;\n",
+			},
+			want: []Migration{{
+				Version:     1,
+				Description: "index_users",
+				Up:          NoTransactionMarker + "\nCREATE INDEX CONCURRENTLY users_id_idx ON users (id);\n",
+				Down:        NoTransactionMarker + "\nDROP INDEX CONCURRENTLY users_id_idx;\n",
+			}},
+		},
+		{
+			name: "goose Go migration",
+			from: Goose,
+			files: map[string]string{
+				"00001_create_users.sql": "-- +goose Up\nCREATE TABLE users (id int);\n",
+				"00002_backfill.go":      "package migrations\n",
+			},
+			wantErr: "00002_backfill.go is a Go migration, only SQL ones can be imported",
+		},
+		{
+			name:    "goose without annotations",
+			from:    Goose,
+			files:   map[string]string{"00001_create_users.sql": "CREATE TABLE users (id int);\n"},
+			wantErr: "00001_create_users.sql has no goose annotations",
+		},
+		{
+			name: "dbmate",
+			from: Dbmate,
+			files: map[string]string{
+				"20240101120000_create_users.sql": "-- migrate:up\nCREATE TABLE users (id int);\n\n-- migrate:down\nDROP TABLE users;\n",
+				"20240102120000_index_users.sql":  "-- migrate:up transaction:false\nCREATE INDEX CONCURRENTLY users_id_idx ON users (id);\n\n-- migrate:down\nDROP INDEX users_id_idx;\n",
+			},
+			want: []Migration{
+				{Version: 20240101120000, Description: "create_users", Up: "CREATE TABLE users (id int);\n", Down: "DROP TABLE users;\n"},
+				{Version: 20240102120000, Description: "index_users", Up: NoTransactionMarker + "\nCREATE INDEX CONCURRENTLY users_id_idx ON users (id);\n", Down: "DROP INDEX users_id_idx;\n"},
+			},
+		},
+		{
+			name: "flyway",
+			from: Flyway,
+			files: map[string]string{
+				"V1__Create_users.sql": "CREATE TABLE users (id int);\n",
+				"U1__Create_users.sql": "DROP TABLE users;\n",
+				"V2__Add_email.sql":    "ALTER TABLE users ADD COLUMN email text;\n",
+				"README.md":            "Migrations of the users service\n",
+			},
+			want: []Migration{
+				{Version: 1, Description: "create_users", Up: "CREATE TABLE users (id int);\n", Down: "DROP TABLE users;\n"},
+				{Version: 2, Description: "add_email", Up: "ALTER TABLE users ADD COLUMN email text;\n"},
+			},
+		},
+		{
+			name:    "flyway repeatable migration",
+			from:    Flyway,
+			files:   map[string]string{"R__views.sql": "CREATE VIEW v AS SELECT 1;\n"},
+			wantErr: "R__views.sql isn't a flyway migration",
+		},
+		{
+			name: "plain SQL",
+			from: PlainSQL,
+			files: map[string]string{
+				"10_posts.sql": "CREATE TABLE posts (id int);\n",
+				"2_users.sql":  "CREATE TABLE users (id int);\n",
+				"seed.sql":     "INSERT INTO users VALUES (1);\n",
+			},
+			want: []Migration{
+				{Version: 1, Description: "users", Up: "CREATE TABLE users (id int);\n"},
+				{Version: 2, Description: "posts", Up: "CREATE TABLE posts (id int);\n"},
+				{Version: 3, Description: "seed", Up: "INSERT INTO users VALUES (1);\n"},
+			},
+		},
+		{
+			name:    "golang-migrate",
+			from:    GolangMigrate,
+			wantErr: `can't import "golang-migrate" migrations`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := Import(dir, tt.from)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d migrations, want %d", len(got), len(tt.want))
+			}
+			for i, m := range got {
+				want := tt.want[i]
+				if m.Version != want.Version || m.Description != want.Description || m.Up != want.Up || m.Down != want.Down {
+					t.Errorf("got migration %d %s:\nup: %q\ndown: %q\nwant %d %s:\nup: %q\ndown: %q", m.Version, m.Description, m.Up, m.Down, want.Version, want.Description, want.Up, want.Down)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"styx/diff"
+	"styx/schema"
+)
+
+const lockHeader = "# Generated by styx. Migrations listed here must not be edited, see `styx verify`\n"
+
+// Lock records the hash of every migration file when it was generated, so
+// migrations edited by hand afterwards can be caught, along with the
+// fingerprint of the schema the migrations add up to
+type Lock struct {
+	Fingerprint string `yaml:"fingerprint"`
+	// Migrations maps file names to the SHA-256 of their contents
+	Migrations map[string]string `yaml:"migrations"`
+}
+
+// LockProblem is a migration that doesn't match the lock file
+type LockProblem struct {
+	File string
+	// Reason is "edited", "missing" or "unlocked"
+	Reason string
+}
+
+func (p LockProblem) String() string {
+	switch p.Reason {
+	case "edited":
+		return p.File + " was edited after it was generated"
+	case "missing":
+		return p.File + " is listed in the lock file but doesn't exist"
+	}
+	return p.File + " isn't listed in the lock file"
+}
+
+// ReadLock reads the lock file at path. A missing file is an empty lock
+func ReadLock(path string) (*Lock, error) {
+	lock := &Lock{Migrations: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if lock.Migrations == nil {
+		lock.Migrations = map[string]string{}
+	}
+	return lock, nil
+}
+
+// Write saves the lock file at path. Migrations are listed in file name
+// order, so the file only changes where migrations do
+func (l *Lock) Write(path string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to encode lock file: %w", err)
+	}
+	if err := os.WriteFile(path, append([]byte(lockHeader), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// Add records the hashes of the files that aren't locked yet. Locked files
+// keep their hash, even if they changed since
+func (l *Lock) Add(files []File) error {
+	for _, f := range files {
+		name := filepath.Base(f.Path)
+		if _, ok := l.Migrations[name]; ok {
+			continue
+		}
+		hash, err := hashFile(f.Path)
+		if err != nil {
+			return err
+		}
+		l.Migrations[name] = hash
+	}
+	return nil
+}
+
+// Remove forgets the files, e.g. once they were squashed
+func (l *Lock) Remove(files []File) {
+	for _, f := range files {
+		delete(l.Migrations, filepath.Base(f.Path))
+	}
+}
+
+// Verify compares the files against the lock, returning the ones edited
+// since they were locked, the locked ones that are gone, and the ones that
+// were never locked
+func (l *Lock) Verify(files []File) ([]LockProblem, error) {
+	var problems []LockProblem
+	seen := map[string]bool{}
+	for _, f := range files {
+		name := filepath.Base(f.Path)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		locked, ok := l.Migrations[name]
+		if !ok {
+			problems = append(problems, LockProblem{File: name, Reason: "unlocked"})
+			continue
+		}
+		hash, err := hashFile(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if hash != locked {
+			problems = append(problems, LockProblem{File: name, Reason: "edited"})
+		}
+	}
+	for name := range l.Migrations {
+		if !seen[name] {
+			problems = append(problems, LockProblem{File: name, Reason: "missing"})
+		}
+	}
+
+	slices.SortFunc(problems, func(a, b LockProblem) int { return strings.Compare(a.File, b.File) })
+	return problems, nil
+}
+
+// Fingerprint returns the SHA-256 of the statements creating the schema
+// from scratch. The schema is sorted first, so the same objects always
+// fingerprint the same
+func Fingerprint(s *schema.Schema, d diff.Dialect) string {
+	s.Sort()
+	up := diff.Diff(&schema.Schema{}, s, diff.Options{Dialect: d})
+	sum := sha256.Sum256([]byte(render(up)))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
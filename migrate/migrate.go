@@ -0,0 +1,455 @@
+// Package migrate reads and writes migration files for golang-migrate, goose,
+// dbmate and Flyway.
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"styx/diff"
+	"styx/schema"
+)
+
+// Versioning is the scheme migration versions follow
+type Versioning string
+
+const (
+	// Sequential versions count up from 1, e.g. 000001
+	Sequential Versioning = "sequential"
+	// Timestamp versions are the UTC time the migration was generated at,
+	// e.g. 20240614120000, so migrations generated on different branches
+	// don't collide
+	Timestamp Versioning = "timestamp"
+)
+
+// Layout of timestamp versions, as produced by `migrate create -format`
+const timestampLayout = "20060102150405"
+
+// Smallest timestamp version, anything below is a sequential one
+const minTimestamp = 10000000000000
+
+// Migration is a pair of up/down migration files, or a single file holding
+// both in formats like goose
+type Migration struct {
+	Version     uint64
+	Description string
+	Up          string
+	Down        string
+	// Changes are the changes made by the up migration
+	Changes []diff.Change
+	// Template customizes the file names and headers, if set
+	Template *Template
+	// Settings are statements run before the changes of both files, like
+	// SET lock_timeout, except in files that can't run in a transaction
+	Settings []string
+	// Format is the layout of the files, golang-migrate if empty
+	Format Format
+
+	downChanges []diff.Change
+	created     time.Time
+	width       int
+}
+
+// File is a migration file found on disk. A file holding both directions,
+// like a goose or dbmate migration, is listed once per direction
+type File struct {
+	Version     uint64
+	Description string
+	// Direction is either "up" or "down"
+	Direction string
+	Path      string
+}
+
+// ReadDir lists the migration files in dir. A missing directory is treated
+// as empty.
+func ReadDir(dir string) ([]File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read migrations directory %s: %w", dir, err)
+	}
+
+	var files []File
+	for _, entry := range entries {
+		prefix, description, directions := parseFilename(entry.Name())
+		if entry.IsDir() || directions == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		for _, direction := range directions {
+			files = append(files, File{
+				Version:     version,
+				Description: description,
+				Direction:   direction,
+				Path:        filepath.Join(dir, entry.Name()),
+			})
+		}
+	}
+
+	return files, nil
+}
+
+// Generate builds the migration turning current into desired, numbered
+// after the migrations in dir following the versioning scheme, or the one
+// already used in dir if versioning is empty. The down migration is the diff in the opposite
+// direction, undoing the renames. It returns nil if the schemas are already
+// in sync, and a *diff.CycleError if objects of desired depend on each other
+// in a cycle
+func Generate(dir string, current, desired *schema.Schema, opts diff.Options, versioning Versioning) (*Migration, error) {
+	if err := diff.CheckDependencies(desired); err != nil {
+		return nil, err
+	}
+	up, down := Changes(current, desired, opts)
+	if len(up) == 0 {
+		return nil, nil
+	}
+
+	return New(dir, up, down, versioning)
+}
+
+// Changes returns the up and down changesets of the migration turning
+// current into desired
+func Changes(current, desired *schema.Schema, opts diff.Options) (up, down []diff.Change) {
+	downOpts := opts
+	downOpts.Renames = opts.Renames.Invert()
+	return diff.Diff(current, desired, opts), diff.Diff(desired, current, downOpts)
+}
+
+// Without removes the skipped up changes, along with the down changes undoing
+// them: those of the same object with the opposite operation
+func Without(up, down, skipped []diff.Change) ([]diff.Change, []diff.Change) {
+	var keptUp, keptDown []diff.Change
+	for _, change := range up {
+		if !slices.Contains(skipped, change) {
+			keptUp = append(keptUp, change)
+		}
+	}
+	for _, change := range down {
+		if !slices.ContainsFunc(skipped, func(skip diff.Change) bool { return undoes(change, skip) }) {
+			keptDown = append(keptDown, change)
+		}
+	}
+	return keptUp, keptDown
+}
+
+// Reports whether the down change undoes the up one: it's about the same
+// object, with the opposite operation
+func undoes(down, up diff.Change) bool {
+	inverse := map[diff.Op]diff.Op{diff.OpCreate: diff.OpDrop, diff.OpDrop: diff.OpCreate, diff.OpAlter: diff.OpAlter}
+	return down.Kind == up.Kind && down.Table == up.Table && down.Name == up.Name && down.Op == inverse[up.Op]
+}
+
+// New builds the next migration in dir from the up and down changesets. An
+// empty versioning follows the scheme already used in dir, or is sequential
+// if dir has no migrations yet. Asking for a scheme other than the one in use
+// is an error, since golang-migrate applies versions in numeric order and
+// mixing them would run the sequential ones first
+func New(dir string, up, down []diff.Change, versioning Versioning) (*Migration, error) {
+	files, err := ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := DetectVersioning(files)
+	switch {
+	case versioning == "" && existing == "":
+		versioning = Sequential
+	case versioning == "":
+		versioning = existing
+	case versioning != Sequential && versioning != Timestamp:
+		return nil, fmt.Errorf("unknown versioning %q, expected %s or %s", versioning, Sequential, Timestamp)
+	case existing != "" && existing != versioning:
+		return nil, fmt.Errorf("migrations in %s use %s versions, not %s", dir, existing, versioning)
+	}
+
+	m := &Migration{
+		Version:     1,
+		Description: describe(up),
+		Up:          render(up),
+		Down:        render(down),
+		Changes:     up,
+		downChanges: down,
+		created:     time.Now().UTC(),
+	}
+	var latest uint64
+	for _, f := range files {
+		if f.Version > latest {
+			latest = f.Version
+			m.width = versionWidth(f)
+		}
+	}
+
+	m.Version = latest + 1
+	if versioning == Timestamp {
+		m.width = len(timestampLayout)
+		// The clock may be behind the latest migration, e.g. one generated
+		// in another timezone by an older version
+		now, _ := strconv.ParseUint(m.created.Format(timestampLayout), 10, 64)
+		m.Version = max(now, latest+1)
+	}
+
+	return m, nil
+}
+
+// Baseline builds the migration replacing the ones in dir up to version,
+// from the changes creating the schema they add up to. It takes over the
+// version of the last one it replaces, so databases already migrated past it
+// don't run it again
+func Baseline(dir string, version uint64, up, down []diff.Change) (*Migration, error) {
+	files, err := ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Migration{
+		Version:     version,
+		Description: "baseline",
+		Up:          render(up),
+		Down:        render(down),
+		Changes:     up,
+		downChanges: down,
+		created:     time.Now().UTC(),
+	}
+	for _, f := range files {
+		if f.Version == version {
+			m.width = versionWidth(f)
+		}
+	}
+
+	return m, nil
+}
+
+// DetectVersioning returns the scheme of the latest migration, or an empty
+// string if there are none
+func DetectVersioning(files []File) Versioning {
+	var latest uint64
+	for _, f := range files {
+		latest = max(latest, f.Version)
+	}
+
+	switch {
+	case len(files) == 0:
+		return ""
+	case latest >= minTimestamp:
+		return Timestamp
+	}
+	return Sequential
+}
+
+func (m *Migration) versionWidth() int {
+	if m.width > 0 {
+		return m.width
+	}
+	if l, err := m.layout(); err == nil {
+		return l.versionWidth()
+	}
+	return golangMigrate{}.versionWidth()
+}
+
+// Returns the width of the version in the name of f, padding included
+func versionWidth(f File) int {
+	prefix, _, _ := parseFilename(filepath.Base(f.Path))
+	return len(prefix)
+}
+
+// Filename returns the name of the file for the given direction ("up" or
+// "down"), which is empty in formats holding both in one file. Names
+// rendered from a template must still be ones the migration tool
+// recognizes, with the version up front
+func (m *Migration) Filename(direction string) (string, error) {
+	l, err := m.layout()
+	if err != nil {
+		return "", err
+	}
+	version := fmt.Sprintf("%0*d", m.versionWidth(), m.Version)
+	expected := l.filename("<version>", "<name>", direction)
+	if m.Template == nil || m.Template.filename == nil {
+		return l.filename(version, m.Description, direction), nil
+	}
+
+	name, err := m.Template.render(m.Template.filename, m.templateData(direction))
+	if err != nil {
+		return "", err
+	}
+	prefix, _, found := l.parse(name)
+	directions := []string{direction}
+	if direction == "" {
+		directions = []string{"up", "down"}
+	}
+	if !slices.Equal(found, directions) || strings.Contains(name, "/") {
+		return "", fmt.Errorf("filename template rendered %q, expected %s", name, expected)
+	}
+	if version, _ := strconv.ParseUint(prefix, 10, 64); version != m.Version {
+		return "", fmt.Errorf("filename template rendered %q, which doesn't start with version %d", name, m.Version)
+	}
+	return name, nil
+}
+
+// Write creates the migration files in dir and returns their paths: the up
+// and down files, or the single file of formats holding both
+func (m *Migration) Write(dir string) ([]string, error) {
+	l, err := m.layout()
+	if err != nil {
+		return nil, err
+	}
+	var files []struct{ path, content string }
+	for _, direction := range l.directions() {
+		name, err := m.Filename(direction)
+		if err != nil {
+			return nil, err
+		}
+		header, err := m.header(direction)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, struct{ path, content string }{filepath.Join(dir, name), l.render(m, direction, header)})
+	}
+
+	for _, f := range files {
+		if _, err := os.Stat(f.path); err == nil {
+			return nil, fmt.Errorf("migration %s already exists", f.path)
+		}
+	}
+
+	var paths []string
+	for _, f := range files {
+		if err := os.WriteFile(f.path, []byte(f.content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", f.path, err)
+		}
+		paths = append(paths, f.path)
+	}
+
+	return paths, nil
+}
+
+// Renders the settings to put before content. A file holding a statement
+// that can't run in a transaction must hold nothing else
+func (m *Migration) settings(content string) string {
+	if len(m.Settings) == 0 || strings.HasPrefix(content, NoTransactionMarker) {
+		return ""
+	}
+	return strings.Join(m.Settings, "\n") + "\n\n"
+}
+
+func render(changes []diff.Change) string {
+	var b strings.Builder
+	b.WriteString(warning(changes))
+	backfilled := map[string]bool{}
+	for i, change := range changes {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if column := change.Table + "." + change.Name; change.Backfill && !backfilled[column] {
+			b.WriteString(backfill(change))
+			backfilled[column] = true
+		}
+		b.WriteString(change.SQL + "\n")
+	}
+
+	return b.String()
+}
+
+// BackfillMarker starts the skeleton of a backfill, and is removed once the
+// backfill is written
+const BackfillMarker = "-- styx:backfill"
+
+// Returns the skeleton of the UPDATE filling in the rows of a column about to
+// become NOT NULL
+func backfill(change diff.Change) string {
+	column := schema.QuoteIdent(change.Name)
+	return fmt.Sprintf(`%s %s.%s
+-- The column becomes NOT NULL below, which fails while rows hold NULL. Fill
+-- them in, then remove the line above, e.g.:
+-- UPDATE %s SET %s = ... WHERE %s IS NULL;
+`, BackfillMarker, change.Table, change.Name, schema.QuoteName(change.Table), column, column)
+}
+
+// Backfills returns the columns whose backfill is still a skeleton in the
+// migration file at path
+func Backfills(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var columns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, BackfillMarker+" "); ok {
+			columns = append(columns, strings.TrimSpace(rest))
+		}
+	}
+	return columns, nil
+}
+
+// Returns a comment block listing the changes that can lose data, so they
+// stand out in review, or an empty string if there are none
+func warning(changes []diff.Change) string {
+	var destructive []string
+	for _, change := range changes {
+		line := "--   " + change.String()
+		if change.Destructive && !slices.Contains(destructive, line) {
+			destructive = append(destructive, line)
+		}
+	}
+	if len(destructive) == 0 {
+		return ""
+	}
+
+	return "-- WARNING: this migration contains changes that can lose data:\n" +
+		strings.Join(destructive, "\n") +
+		"\n-- Make sure the data is backed up or no longer needed before applying it.\n\n"
+}
+
+// Builds a short description for the filename from the changed objects
+func describe(changes []diff.Change) string {
+	if len(changes) == 0 {
+		return "empty"
+	}
+
+	first := changes[0]
+	description := strings.ToLower(string(first.Op)) + "_" + slug(subject(first))
+	for _, change := range changes[1:] {
+		if subject(change) != subject(first) {
+			return description + "_and_more"
+		}
+	}
+	if first.Table == "" || first.Kind == diff.KindTable && len(changes) == 1 {
+		return description
+	}
+
+	return "alter_" + slug(first.Table)
+}
+
+// Returns the object a change is about: its table, or the object itself for
+// changes that don't belong to a table, like views and enums
+func subject(change diff.Change) string {
+	if change.Table != "" {
+		return change.Table
+	}
+	return change.Name
+}
+
+func slug(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+
+	return strings.Trim(b.String(), "_")
+}
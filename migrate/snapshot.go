@@ -0,0 +1,66 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"styx/diff"
+	"styx/schema"
+)
+
+// Snapshot is the schema the migrations add up to at a version, as written
+// next to the SQL creating it
+type Snapshot struct {
+	Version     uint64         `json:"version"`
+	Fingerprint string         `json:"fingerprint"`
+	Schema      *schema.Schema `json:"schema"`
+}
+
+// WriteSnapshot saves the schema at version to dir, as <version>.json holding
+// the model and <version>.sql holding the statements creating it
+func WriteSnapshot(dir string, version uint64, s *schema.Schema, d diff.Dialect) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	snapshot := Snapshot{Version: version, Fingerprint: Fingerprint(s, d), Schema: s}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	base := filepath.Join(dir, strconv.FormatUint(version, 10))
+	if err := os.WriteFile(base+".json", append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s.json: %w", base, err)
+	}
+
+	up := diff.Diff(&schema.Schema{}, s, diff.Options{Dialect: d})
+	if err := os.WriteFile(base+".sql", []byte(render(up)), 0644); err != nil {
+		return fmt.Errorf("failed to write %s.sql: %w", base, err)
+	}
+	return nil
+}
+
+// ReadSnapshot loads the schema saved at version from dir. It returns nil
+// without an error if there's no snapshot of that version
+func ReadSnapshot(dir string, version uint64) (*Snapshot, error) {
+	path := filepath.Join(dir, strconv.FormatUint(version, 10)+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if snapshot.Schema == nil {
+		snapshot.Schema = &schema.Schema{}
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+	nurl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang-migrate/migrate/source"
+)
+
+func init() {
+	source.Register("styx", &dirSource{})
+}
+
+// SourceURL is the golang-migrate source of the migrations in dir. Unlike
+// file://, it reads goose migrations as well, applying their sections
+func SourceURL(dir string) string {
+	return "styx://" + dir
+}
+
+// A golang-migrate source driver reading the migrations ReadDir finds
+type dirSource struct {
+	dir        string
+	files      map[string]File
+	migrations *source.Migrations
+}
+
+func (d *dirSource) Open(url string) (source.Driver, error) {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+	// The host holds the first element of relative paths
+	dir := u.Host + u.Path
+	if dir == "" {
+		dir = "."
+	}
+
+	files, err := ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	opened := &dirSource{dir: dir, files: map[string]File{}, migrations: source.NewMigrations()}
+	for _, f := range files {
+		raw := f.Direction + "/" + filepath.Base(f.Path)
+		opened.files[raw] = f
+		migration := &source.Migration{
+			Version:    uint(f.Version),
+			Identifier: f.Description,
+			Direction:  source.Direction(f.Direction),
+			Raw:        raw,
+		}
+		if !opened.migrations.Append(migration) {
+			return nil, fmt.Errorf("duplicate %s migration with version %d in %s", f.Direction, f.Version, dir)
+		}
+	}
+	return opened, nil
+}
+
+func (d *dirSource) Close() error {
+	return nil
+}
+
+func (d *dirSource) First() (uint, error) {
+	if version, ok := d.migrations.First(); ok {
+		return version, nil
+	}
+	return 0, &os.PathError{Op: "first", Path: d.dir, Err: os.ErrNotExist}
+}
+
+func (d *dirSource) Prev(version uint) (uint, error) {
+	if prev, ok := d.migrations.Prev(version); ok {
+		return prev, nil
+	}
+	return 0, &os.PathError{Op: fmt.Sprintf("prev for version %d", version), Path: d.dir, Err: os.ErrNotExist}
+}
+
+func (d *dirSource) Next(version uint) (uint, error) {
+	if next, ok := d.migrations.Next(version); ok {
+		return next, nil
+	}
+	return 0, &os.PathError{Op: fmt.Sprintf("next for version %d", version), Path: d.dir, Err: os.ErrNotExist}
+}
+
+func (d *dirSource) ReadUp(version uint) (io.ReadCloser, string, error) {
+	m, ok := d.migrations.Up(version)
+	return d.read(m, ok, version)
+}
+
+func (d *dirSource) ReadDown(version uint) (io.ReadCloser, string, error) {
+	m, ok := d.migrations.Down(version)
+	return d.read(m, ok, version)
+}
+
+func (d *dirSource) read(m *source.Migration, ok bool, version uint) (io.ReadCloser, string, error) {
+	if !ok {
+		return nil, "", &os.PathError{Op: fmt.Sprintf("read version %d", version), Path: d.dir, Err: os.ErrNotExist}
+	}
+	content, err := d.files[m.Raw].Read()
+	if err != nil {
+		return nil, "", err
+	}
+	return io.NopCloser(strings.NewReader(content)), m.Identifier, nil
+}
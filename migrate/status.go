@@ -0,0 +1,103 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"styx/schema"
+)
+
+// DefaultTable is the table golang-migrate records the database version in
+const DefaultTable = "schema_migrations"
+
+type Status string
+
+const (
+	StatusApplied Status = "applied"
+	StatusPending Status = "pending"
+	// StatusDirty marks a migration that failed halfway through
+	StatusDirty Status = "dirty"
+	// StatusMissing marks the applied version when it has no file on disk
+	StatusMissing Status = "missing"
+)
+
+// State is the version recorded in the migrations table
+type State struct {
+	// Version is 0 if no migration has been applied
+	Version uint64
+	Dirty   bool
+}
+
+// MigrationStatus is the state of a single migration
+type MigrationStatus struct {
+	Version     uint64
+	Description string
+	Status      Status
+}
+
+// ReadState reads the current version from the migrations table. A missing
+// table means nothing has been applied yet
+func ReadState(ctx context.Context, db *sql.DB, table string) (*State, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", schema.QuoteIdent(table)).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", table, err)
+	}
+	if !exists {
+		return &State{}, nil
+	}
+
+	state := &State{}
+	query := fmt.Sprintf("SELECT version, dirty FROM %s LIMIT 1", schema.QuoteIdent(table))
+	if err := db.QueryRowContext(ctx, query).Scan(&state.Version, &state.Dirty); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+
+	return state, nil
+}
+
+// Statuses lists the migrations in files along with their state. golang-migrate
+// only records the latest version, so every migration up to it counts as applied
+func Statuses(files []File, state *State) []MigrationStatus {
+	var statuses []MigrationStatus
+	for _, f := range files {
+		if f.Direction != "up" {
+			continue
+		}
+
+		status := StatusPending
+		switch {
+		case f.Version == state.Version && state.Dirty:
+			status = StatusDirty
+		case f.Version <= state.Version:
+			status = StatusApplied
+		}
+		statuses = append(statuses, MigrationStatus{
+			Version:     f.Version,
+			Description: f.Description,
+			Status:      status,
+		})
+	}
+
+	if state.Version > 0 && !slices.ContainsFunc(statuses, func(s MigrationStatus) bool { return s.Version == state.Version }) {
+		status := StatusMissing
+		if state.Dirty {
+			status = StatusDirty
+		}
+		statuses = append(statuses, MigrationStatus{Version: state.Version, Status: status})
+	}
+
+	slices.SortFunc(statuses, func(a, b MigrationStatus) int {
+		switch {
+		case a.Version < b.Version:
+			return -1
+		case a.Version > b.Version:
+			return 1
+		}
+		return 0
+	})
+
+	return statuses
+}
@@ -0,0 +1,111 @@
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"styx/diff"
+)
+
+// Template customizes the names and headers of the generated files. Both are
+// text/template templates rendered with TemplateData
+type Template struct {
+	filename *template.Template
+	header   *template.Template
+
+	// StyxVersion and SchemaHash are passed on to the templates
+	StyxVersion string
+	SchemaHash  string
+}
+
+// TemplateData is what the templates are rendered with, once per file
+type TemplateData struct {
+	// Version is zero-padded like the existing migrations
+	Version string
+	Slug    string
+	// Direction is "up" or "down", or empty for the single file of formats
+	// holding both, like goose
+	Direction string
+	// Time is when the migration was generated, in UTC
+	Time        time.Time
+	StyxVersion string
+	// SchemaHash is the SHA-256 of the schema.sql the migration was
+	// generated from
+	SchemaHash string
+	// Summary describes each change of the file, e.g. "create column users.email"
+	Summary []string
+}
+
+// ParseTemplate parses the filename and header templates. Either can be
+// empty to keep the default, e.g. "{{.Version}}_{{.Slug}}.{{.Direction}}.sql"
+// for golang-migrate, and no header
+func ParseTemplate(filename, header string) (*Template, error) {
+	t := &Template{}
+	var err error
+	if filename != "" {
+		if t.filename, err = template.New("filename").Parse(filename); err != nil {
+			return nil, fmt.Errorf("failed to parse filename template: %w", err)
+		}
+	}
+	if header != "" {
+		if t.header, err = template.New("header").Parse(header); err != nil {
+			return nil, fmt.Errorf("failed to parse header template: %w", err)
+		}
+	}
+	return t, nil
+}
+
+func (t *Template) render(tmpl *template.Template, data TemplateData) (string, error) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", tmpl.Name(), err)
+	}
+	return b.String(), nil
+}
+
+func (m *Migration) templateData(direction string) TemplateData {
+	changes := m.Changes
+	if direction == "down" {
+		changes = m.downChanges
+	}
+
+	data := TemplateData{
+		Version:   fmt.Sprintf("%0*d", m.versionWidth(), m.Version),
+		Slug:      m.Description,
+		Direction: direction,
+		Time:      m.created,
+		Summary:   summarize(changes),
+	}
+	if m.Template != nil {
+		data.StyxVersion = m.Template.StyxVersion
+		data.SchemaHash = m.Template.SchemaHash
+	}
+	return data
+}
+
+// Describes each change once, in order
+func summarize(changes []diff.Change) []string {
+	var summary []string
+	for _, change := range changes {
+		if line := change.String(); len(summary) == 0 || summary[len(summary)-1] != line {
+			summary = append(summary, line)
+		}
+	}
+	return summary
+}
+
+// Renders the header of the file, making sure it ends with a blank line
+func (m *Migration) header(direction string) (string, error) {
+	if m.Template == nil || m.Template.header == nil {
+		return "", nil
+	}
+
+	header, err := m.Template.render(m.Template.header, m.templateData(direction))
+	if err != nil || header == "" {
+		return header, err
+	}
+	return strings.TrimRight(header, "\n") + "\n\n", nil
+}
@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"slices"
+
+	"styx/diff"
+)
+
+// NoTransactionMarker starts the migrations holding a statement that can't
+// run inside a transaction. golang-migrate sends a file as a single query,
+// which Postgres runs in an implicit transaction unless it holds a single
+// statement
+const NoTransactionMarker = "-- styx:no-transaction"
+
+// Split breaks the migration into consecutive ones, so every change that
+// can't run inside a transaction, like CREATE INDEX CONCURRENTLY, is alone in
+// its migration. The others are kept together in between, in their order,
+// except for the validations of constraints added NOT VALID, which go last in
+// a migration of their own. The down changes go with the migration holding
+// the up change they undo, the remaining ones with the first migration that
+// runs in a transaction. A migration without such changes is returned as is
+func (m *Migration) Split() []*Migration {
+	if !slices.ContainsFunc(m.Changes, diff.Change.NonTransactional) && !slices.ContainsFunc(m.Changes, diff.Change.Validation) {
+		return []*Migration{m}
+	}
+
+	var changes, validations []diff.Change
+	for _, change := range m.Changes {
+		if change.Validation() {
+			validations = append(validations, change)
+		} else {
+			changes = append(changes, change)
+		}
+	}
+	changes = append(changes, validations...)
+
+	var parts [][]diff.Change
+	for i, change := range changes {
+		if i == 0 || change.NonTransactional() || changes[i-1].NonTransactional() || change.Validation() != changes[i-1].Validation() {
+			parts = append(parts, nil)
+		}
+		parts[len(parts)-1] = append(parts[len(parts)-1], change)
+	}
+
+	downs := make([][]diff.Change, len(parts))
+	first := slices.IndexFunc(parts, func(part []diff.Change) bool { return !part[0].NonTransactional() && !part[0].Validation() })
+	for _, change := range m.downChanges {
+		part := slices.IndexFunc(parts, func(part []diff.Change) bool {
+			return slices.ContainsFunc(part, func(up diff.Change) bool { return undoes(change, up) })
+		})
+		if part == -1 {
+			part = max(first, 0)
+		}
+		downs[part] = append(downs[part], change)
+	}
+
+	migrations := make([]*Migration, len(parts))
+	for i, up := range parts {
+		migrations[i] = &Migration{
+			Version:     m.Version + uint64(i),
+			Description: describe(up),
+			Up:          render(up),
+			Down:        render(downs[i]),
+			Changes:     up,
+			Template:    m.Template,
+			Settings:    m.Settings,
+			Format:      m.Format,
+			downChanges: downs[i],
+			created:     m.created,
+			width:       m.width,
+		}
+		if up[0].NonTransactional() {
+			migrations[i].Up = NoTransactionMarker + "\n" + migrations[i].Up
+		}
+		if slices.ContainsFunc(downs[i], diff.Change.NonTransactional) {
+			migrations[i].Down = NoTransactionMarker + "\n" + migrations[i].Down
+		}
+	}
+	return migrations
+}
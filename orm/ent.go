@@ -0,0 +1,648 @@
+package orm
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"styx/internal/naming"
+	"styx/schema"
+)
+
+// Kinds of the field builders of ent
+var entKinds = map[string]string{
+	"String": kindString, "Text": kindText, "Bool": kindBool,
+	"Int": kindInt64, "Int64": kindInt64, "Int32": kindInt32, "Int16": kindInt16, "Int8": kindInt8,
+	"Uint": kindUint64, "Uint64": kindUint64, "Uint32": kindUint32, "Uint16": kindUint16, "Uint8": kindUint8,
+	"Float": kindFloat64, "Float32": kindFloat32, "Time": kindTime, "Bytes": kindBytes, "UUID": kindUUID,
+	"JSON": kindJSON, "Strings": kindJSON, "Ints": kindJSON, "Floats": kindJSON, "Any": kindJSON,
+	"Enum": kindString, "Other": "",
+}
+
+// Unbounded strings in each dialect. Other kinds take the common types
+var entStrings = map[string]string{
+	"postgres": "character varying",
+	"mysql":    "varchar(255)",
+	"sqlite":   "text",
+}
+
+// Fields the mixins of ent add
+var entMixins = map[string][]string{
+	"mixin.Time":       {"create_time", "update_time"},
+	"mixin.CreateTime": {"create_time"},
+	"mixin.UpdateTime": {"update_time"},
+}
+
+// Dialect names of ent's SchemaType keys
+var entDialects = map[string]string{
+	"dialect.Postgres": "postgres",
+	"dialect.MySQL":    "mysql",
+	"dialect.SQLite":   "sqlite",
+	"sqlite3":          "sqlite",
+}
+
+type entReader struct {
+	types   map[string]string
+	dialect string
+	methods map[string]map[string]*ast.FuncDecl
+	nodes   map[string]*entNode
+	schema  *schema.Schema
+}
+
+// entNode is a type of the ent schema, stored in a table
+type entNode struct {
+	name  string
+	table *schema.Table
+	// columns maps fields, and the edges stored on the table, to columns
+	columns map[string]*schema.Column
+	idKind  string
+	edges   []*entEdge
+	indexes []*chain
+}
+
+// entEdge is an edge declared with edge.To, or its inverse with edge.From
+type entEdge struct {
+	from     bool
+	name     string
+	target   string
+	ref      string
+	unique   bool
+	required bool
+	field    string
+	onDelete string
+	// inverse is the edge.From declared inline, like
+	// edge.To("children", Node.Type).From("parent")
+	inverse *entEdge
+}
+
+// chain is a chain of builder calls like field.String("name").Optional(),
+// starting with the package function
+type chain struct {
+	pkg  string
+	fn   string
+	args []ast.Expr
+	// calls are the methods called on the result, in order
+	calls []call
+}
+
+type call struct {
+	name string
+	args []ast.Expr
+}
+
+// Ent reads the tables ent would create for the schemas declared in dir,
+// usually ent/schema: the types embedding ent.Schema, with their fields,
+// edges, indexes and mixins
+func Ent(dir, dialect string) (*schema.Schema, error) {
+	types, err := dialectTypes(dialect)
+	if err != nil {
+		return nil, err
+	}
+	if dialect == "cockroachdb" || dialect == "cockroach" {
+		dialect = "postgres"
+	}
+	_, files, err := parseDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names, structs := structs(files)
+	r := &entReader{types: types, dialect: dialect, methods: methods(files), nodes: map[string]*entNode{}, schema: &schema.Schema{}}
+	var nodes []*entNode
+	for _, name := range names {
+		if !embeds(structs[name], "ent.Schema") {
+			continue
+		}
+		node := &entNode{name: name, table: &schema.Table{Name: r.tableName(name)}, columns: map[string]*schema.Column{}}
+		r.nodes[name] = node
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no ent schemas found in %s", dir)
+	}
+
+	for _, node := range nodes {
+		if err := r.addFields(node); err != nil {
+			return nil, fmt.Errorf("%s: %w", node.name, err)
+		}
+		r.schema.Tables = append(r.schema.Tables, node.table)
+	}
+	for _, node := range nodes {
+		for _, edge := range node.edges {
+			if edge.from {
+				continue
+			}
+			if err := r.addEdge(node, edge); err != nil {
+				return nil, fmt.Errorf("%s edge %s: %w", node.name, edge.name, err)
+			}
+		}
+	}
+	for _, node := range nodes {
+		for _, index := range node.indexes {
+			if err := r.addIndex(node, index); err != nil {
+				return nil, fmt.Errorf("%s: %w", node.name, err)
+			}
+		}
+	}
+	return r.schema, nil
+}
+
+func embeds(st *ast.StructType, typeName string) bool {
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 && exprName(field.Type) == typeName {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the table set with an entsql annotation, or the name in snake
+// case and plural
+func (r *entReader) tableName(name string) string {
+	list, _ := returned(r.methods[name]["Annotations"]).(*ast.CompositeLit)
+	if list != nil {
+		for _, elt := range list.Elts {
+			if lit, ok := elt.(*ast.CompositeLit); ok && exprName(lit.Type) == "entsql.Annotation" {
+				for _, kv := range lit.Elts {
+					if kv, ok := kv.(*ast.KeyValueExpr); ok && exprName(kv.Key) == "Table" {
+						if table, ok := literal(kv.Value); ok {
+							return table
+						}
+					}
+				}
+			}
+			if c, ok := parseChain(elt); ok && c.pkg == "entsql" && c.fn == "Table" && len(c.args) == 1 {
+				if table, ok := literal(c.args[0]); ok {
+					return table
+				}
+			}
+		}
+	}
+	return naming.Plural(naming.SnakeCase(name))
+}
+
+// Returns the elements of the list a method of the type returns, like the
+// fields of Fields
+func (r *entReader) list(typeName, method string) ([]ast.Expr, error) {
+	fn := r.methods[typeName][method]
+	if fn == nil {
+		return nil, nil
+	}
+	switch e := returned(fn).(type) {
+	case *ast.CompositeLit:
+		return e.Elts, nil
+	case *ast.Ident:
+		if e.Name == "nil" {
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("%s.%s has to return a list literal", typeName, method)
+}
+
+// Adds the id column, the columns of the mixins' and the node's fields, and
+// collects its edges and indexes
+func (r *entReader) addFields(node *entNode) error {
+	var fields, edges, indexes []ast.Expr
+	mixins, err := r.list(node.name, "Mixin")
+	if err != nil {
+		return err
+	}
+	for _, mixin := range append(mixins, &ast.Ident{Name: node.name}) {
+		name := exprName(mixin)
+		if lit, ok := mixin.(*ast.CompositeLit); ok {
+			name = exprName(lit.Type)
+		}
+		if columns, ok := entMixins[name]; ok {
+			for _, column := range columns {
+				node.table.Columns = append(node.table.Columns, &schema.Column{Name: column, Type: r.types[kindTime], NotNull: true})
+			}
+			continue
+		}
+		for _, list := range []struct {
+			method string
+			target *[]ast.Expr
+		}{{"Fields", &fields}, {"Edges", &edges}, {"Indexes", &indexes}} {
+			elts, err := r.list(name, list.method)
+			if err != nil {
+				return err
+			}
+			*list.target = append(*list.target, elts...)
+		}
+	}
+
+	var columns, unique []*schema.Column
+	for _, expr := range fields {
+		c, ok := parseChain(expr)
+		if !ok || c.pkg != "field" {
+			return fmt.Errorf("can't read field %s, fields have to be built with the field package", exprName(expr))
+		}
+		column, kind, isUnique, err := r.column(c)
+		if err != nil {
+			return err
+		}
+		if isUnique {
+			unique = append(unique, column)
+		}
+		if column.Name == "id" {
+			node.idKind = kind
+			node.table.Columns = append([]*schema.Column{column}, node.table.Columns...)
+		} else {
+			columns = append(columns, column)
+		}
+		node.columns[fieldName(c)] = column
+	}
+	if node.idKind == "" {
+		node.idKind = kindInt64
+		id := &schema.Column{Name: "id", Type: columnType(r.types, kindInt64, 0), NotNull: true}
+		node.table.Columns = append([]*schema.Column{id}, node.table.Columns...)
+		node.columns["id"] = id
+	}
+	id := node.table.Columns[0]
+	id.NotNull, id.Default = true, ""
+	if isInteger(node.idKind) {
+		r.autoIncrement(id)
+	}
+	node.table.Columns = append(node.table.Columns, columns...)
+	node.table.Constraints = append(node.table.Constraints, &schema.Constraint{Name: node.table.Name + "_pkey", Type: schema.PrimaryKey, Columns: []string{"id"}})
+	for _, column := range unique {
+		r.addUnique(node.table, column.Name)
+	}
+
+	for _, expr := range edges {
+		edge, err := parseEdge(expr)
+		if err != nil {
+			return err
+		}
+		node.edges = append(node.edges, edge)
+	}
+	for _, expr := range indexes {
+		c, ok := parseChain(expr)
+		if !ok || c.pkg != "index" {
+			return fmt.Errorf("can't read index %s, indexes have to be built with the index package", exprName(expr))
+		}
+		node.indexes = append(node.indexes, c)
+	}
+	return nil
+}
+
+func (r *entReader) autoIncrement(column *schema.Column) {
+	switch r.dialect {
+	case "mysql":
+		column.AutoIncrement = true
+	case "sqlite":
+		column.Type = "integer"
+		column.AutoIncrement = true
+	default:
+		column.Identity = "BY DEFAULT"
+	}
+}
+
+func (r *entReader) addUnique(table *schema.Table, column string) {
+	table.Constraints = append(table.Constraints, &schema.Constraint{
+		Name:    table.Name + "_" + column + "_key",
+		Type:    schema.Unique,
+		Columns: []string{column},
+	})
+}
+
+// The name of a field, the first argument of its builder
+func fieldName(c *chain) string {
+	if len(c.args) == 0 {
+		return ""
+	}
+	name, _ := literal(c.args[0])
+	return name
+}
+
+// Returns the column of a field, its kind and whether it's unique
+func (r *entReader) column(c *chain) (*schema.Column, string, bool, error) {
+	name := fieldName(c)
+	kind, ok := entKinds[c.fn]
+	if name == "" || !ok {
+		return nil, "", false, fmt.Errorf("unsupported field builder field.%s", c.fn)
+	}
+
+	column := &schema.Column{Name: name, NotNull: true}
+	size, unique := 0, false
+	var values []string
+	for _, call := range c.calls {
+		switch call.name {
+		case "Optional":
+			column.NotNull = false
+		case "Unique":
+			unique = true
+		case "StorageKey":
+			if len(call.args) == 1 {
+				column.Name, _ = literal(call.args[0])
+			}
+		case "MaxLen":
+			if len(call.args) == 1 {
+				value, _ := literal(call.args[0])
+				fmt.Sscan(value, &size)
+			}
+		case "Values":
+			for _, arg := range call.args {
+				value, _ := literal(arg)
+				values = append(values, value)
+			}
+		case "NamedValues":
+			for i := 1; i < len(call.args); i += 2 {
+				value, _ := literal(call.args[i])
+				values = append(values, value)
+			}
+		case "Default":
+			if len(call.args) == 1 {
+				if value, ok := literal(call.args[0]); ok {
+					column.Default = entDefault(call.args[0], value)
+				}
+			}
+		case "SchemaType":
+			if t := r.schemaType(call); t != "" {
+				column.Type = t
+			}
+		}
+	}
+
+	if column.Type == "" {
+		switch {
+		case kind == "":
+			return nil, "", false, fmt.Errorf("field %s needs a SchemaType for the %s dialect", name, r.dialect)
+		case c.fn == "Enum" && r.dialect == "mysql" && len(values) > 0:
+			quoted := make([]string, len(values))
+			for i, value := range values {
+				quoted[i] = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+			}
+			column.Type = "enum(" + strings.Join(quoted, ",") + ")"
+		case kind == kindString && size == 0:
+			column.Type = entStrings[r.dialect]
+		default:
+			column.Type = columnType(r.types, kind, size)
+		}
+	}
+	return column, kind, unique, nil
+}
+
+// Returns the type a SchemaType call sets for the dialect, if any
+func (r *entReader) schemaType(c call) string {
+	if len(c.args) != 1 {
+		return ""
+	}
+	lit, ok := c.args[0].(*ast.CompositeLit)
+	if !ok {
+		return ""
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := literal(kv.Key)
+		if !ok {
+			key = exprName(kv.Key)
+		}
+		if mapped, ok := entDialects[key]; ok {
+			key = mapped
+		}
+		if key == r.dialect {
+			value, _ := literal(kv.Value)
+			return value
+		}
+	}
+	return ""
+}
+
+// Strings are quoted, other literals written as is
+func entDefault(expr ast.Expr, value string) string {
+	if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	return value
+}
+
+// Parses an expression like pkg.Fn(args).Method(args)...
+func parseChain(expr ast.Expr) (*chain, bool) {
+	var calls []call
+	for {
+		callExpr, ok := expr.(*ast.CallExpr)
+		if !ok {
+			return nil, false
+		}
+		sel, ok := callExpr.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return nil, false
+		}
+		if pkg, ok := sel.X.(*ast.Ident); ok {
+			// Methods were collected from the last
+			for i, j := 0, len(calls)-1; i < j; i, j = i+1, j-1 {
+				calls[i], calls[j] = calls[j], calls[i]
+			}
+			return &chain{pkg: pkg.Name, fn: sel.Sel.Name, args: callExpr.Args, calls: calls}, true
+		}
+		calls = append(calls, call{name: sel.Sel.Name, args: callExpr.Args})
+		expr = sel.X
+	}
+}
+
+func parseEdge(expr ast.Expr) (*entEdge, error) {
+	c, ok := parseChain(expr)
+	if !ok || c.pkg != "edge" || c.fn != "To" && c.fn != "From" || len(c.args) != 2 {
+		return nil, fmt.Errorf("can't read edge %s, edges have to be built with edge.To or edge.From", exprName(expr))
+	}
+	edge := &entEdge{from: c.fn == "From", target: strings.TrimSuffix(exprName(c.args[1]), ".Type")}
+	edge.name, _ = literal(c.args[0])
+
+	// Calls after an inline From apply to it
+	current := edge
+	for _, call := range c.calls {
+		var arg string
+		if len(call.args) > 0 {
+			arg, _ = literal(call.args[0])
+		}
+		switch call.name {
+		case "From":
+			current = &entEdge{from: true, name: arg, target: edge.target}
+			edge.inverse = current
+		case "Ref":
+			current.ref = arg
+		case "Unique":
+			current.unique = true
+		case "Required":
+			current.required = true
+		case "Field":
+			current.field = arg
+		case "Annotations":
+			for _, annotation := range call.args {
+				if a, ok := parseChain(annotation); ok && a.pkg == "entsql" && a.fn == "OnDelete" && len(a.args) == 1 {
+					current.onDelete = entActions[exprName(a.args[0])]
+				}
+			}
+		}
+	}
+	return edge, nil
+}
+
+var entActions = map[string]string{
+	"entsql.Cascade":    "CASCADE",
+	"entsql.SetNull":    "SET NULL",
+	"entsql.Restrict":   "RESTRICT",
+	"entsql.NoAction":   "",
+	"entsql.SetDefault": "SET DEFAULT",
+}
+
+// Adds the foreign key column, or join table, of an edge.To and its inverse
+func (r *entReader) addEdge(node *entNode, edge *entEdge) error {
+	target, ok := r.nodes[edge.target]
+	if !ok {
+		return fmt.Errorf("%s isn't an ent schema of the package", edge.target)
+	}
+	inverse := edge.inverse
+	if inverse == nil {
+		for _, e := range target.edges {
+			if e.from && e.target == node.name && e.ref == edge.name {
+				inverse = e
+			}
+		}
+	}
+	owner := naming.SnakeCase(node.name)
+
+	switch {
+	case !edge.unique && (inverse == nil && node == target || inverse != nil && !inverse.unique):
+		// Many to many, in a join table
+		otherColumn := naming.SnakeCase(target.name) + "_id"
+		if node == target {
+			name := edge.name
+			if inverse != nil {
+				name = inverse.name
+			}
+			otherColumn = strings.TrimSuffix(naming.SnakeCase(name), "s") + "_id"
+		}
+		r.addJoinTable(owner+"_"+naming.SnakeCase(edge.name), node, owner+"_id", target, otherColumn)
+	case !edge.unique:
+		// One to many, stored on the target
+		return r.addForeignKey(target, node, edge, inverse, owner+"_"+naming.SnakeCase(edge.name), false)
+	case inverse != nil && inverse.unique || inverse == nil && node == target:
+		// One to one, stored on the target
+		return r.addForeignKey(target, node, edge, inverse, owner+"_"+naming.SnakeCase(edge.name), true)
+	default:
+		// Many to one, stored on the node
+		return r.addForeignKey(node, target, edge, edge, owner+"_"+naming.SnakeCase(edge.name), false)
+	}
+	return nil
+}
+
+// Adds a column of table referencing the id of ref, unless the edge is bound
+// to a field, and its foreign key. holder is the edge declared on table, if
+// any, whose field and requiredness apply
+func (r *entReader) addForeignKey(table, ref *entNode, edge, holder *entEdge, defaultColumn string, unique bool) error {
+	var column *schema.Column
+	if holder != nil && holder.field != "" {
+		if column = table.columns[holder.field]; column == nil {
+			return fmt.Errorf("%s has no field %s", table.name, holder.field)
+		}
+	} else {
+		column = &schema.Column{
+			Name:    defaultColumn,
+			Type:    strings.TrimSpace(columnType(r.types, ref.idKind, 0)),
+			NotNull: holder != nil && holder.required,
+		}
+		if ref.idKind == kindString {
+			column.Type = entStrings[r.dialect]
+		}
+		table.table.Columns = append(table.table.Columns, column)
+	}
+	if holder != nil {
+		table.columns[holder.name] = column
+	}
+	if unique {
+		r.addUnique(table.table, column.Name)
+	}
+
+	fk := &schema.Constraint{
+		Name:       table.table.Name + "_" + ref.table.Name + "_" + naming.SnakeCase(edge.name),
+		Type:       schema.ForeignKey,
+		Columns:    []string{column.Name},
+		RefTable:   ref.table.Name,
+		RefColumns: []string{"id"},
+	}
+	if !column.NotNull {
+		fk.OnDelete = "SET NULL"
+	}
+	for _, e := range []*entEdge{edge, holder} {
+		if e != nil && e.onDelete != "" {
+			fk.OnDelete = e.onDelete
+		}
+	}
+	table.table.Constraints = append(table.table.Constraints, fk)
+	return nil
+}
+
+func (r *entReader) addJoinTable(name string, node *entNode, nodeColumn string, target *entNode, targetColumn string) {
+	if r.schema.Table(name) != nil {
+		return
+	}
+	table := &schema.Table{Name: name}
+	table.Constraints = append(table.Constraints, &schema.Constraint{
+		Name:    name + "_pkey",
+		Type:    schema.PrimaryKey,
+		Columns: []string{nodeColumn, targetColumn},
+	})
+	for _, side := range []struct {
+		column string
+		node   *entNode
+	}{{nodeColumn, node}, {targetColumn, target}} {
+		table.Columns = append(table.Columns, &schema.Column{Name: side.column, Type: columnType(r.types, side.node.idKind, 0), NotNull: true})
+		table.Constraints = append(table.Constraints, &schema.Constraint{
+			Name:       name + "_" + side.column,
+			Type:       schema.ForeignKey,
+			Columns:    []string{side.column},
+			RefTable:   side.node.table.Name,
+			RefColumns: []string{"id"},
+			OnDelete:   "CASCADE",
+		})
+	}
+	r.schema.Tables = append(r.schema.Tables, table)
+}
+
+// Adds an index built like index.Fields("name").Edges("owner").Unique()
+func (r *entReader) addIndex(node *entNode, c *chain) error {
+	if c.fn != "Fields" && c.fn != "Edges" {
+		return fmt.Errorf("unsupported index builder index.%s", c.fn)
+	}
+	names := literals(c.args)
+	index := &schema.Index{}
+	for _, call := range c.calls {
+		switch call.name {
+		case "Fields", "Edges":
+			names = append(names, literals(call.args)...)
+		case "Unique":
+			index.Unique = true
+		case "StorageKey":
+			if len(call.args) == 1 {
+				index.Name, _ = literal(call.args[0])
+			}
+		}
+	}
+	for _, name := range names {
+		column := node.columns[name]
+		if column == nil {
+			return fmt.Errorf("index refers to %s, which isn't a field or an edge stored on %s", name, node.table.Name)
+		}
+		index.Keys = append(index.Keys, column.Name)
+	}
+	if index.Name == "" {
+		index.Name = naming.SnakeCase(node.name) + "_" + strings.Join(index.Keys, "_")
+	}
+	node.table.Indexes = append(node.table.Indexes, index)
+	return nil
+}
+
+func literals(exprs []ast.Expr) []string {
+	var values []string
+	for _, expr := range exprs {
+		if value, ok := literal(expr); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,184 @@
+package orm
+
+import "testing"
+
+func TestEnt(t *testing.T) {
+	testFixtures(t, Ent, []fixtureTest{
+		{
+			name: "fields and mixins",
+			source: `package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/mixin"
+)
+
+type User struct {
+	ent.Schema
+}
+
+func (User) Mixin() []ent.Mixin {
+	return []ent.Mixin{mixin.Time{}}
+}
+
+func (User) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("email").Unique(),
+		field.String("nick").MaxLen(32).StorageKey("nickname"),
+		field.Int("age").Optional(),
+		field.Enum("role").Values("admin", "member").Default("member"),
+		field.Float("balance").SchemaType(map[string]string{dialect.Postgres: "numeric(10,2)"}),
+	}
+}
+`,
+			want: `
+CREATE TABLE users (
+  id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  create_time timestamptz NOT NULL,
+  update_time timestamptz NOT NULL,
+  email varchar NOT NULL CONSTRAINT users_email_key UNIQUE,
+  nickname varchar(32) NOT NULL,
+  age bigint,
+  role varchar NOT NULL DEFAULT 'member',
+  balance numeric(10,2) NOT NULL
+);
+`,
+		},
+		{
+			name: "edges",
+			source: `package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type User struct {
+	ent.Schema
+}
+
+func (User) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("pets", Pet.Type),
+		edge.To("groups", Group.Type),
+	}
+}
+
+type Pet struct {
+	ent.Schema
+}
+
+func (Pet) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("name"),
+	}
+}
+
+func (Pet) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("owner", User.Type).Ref("pets").Unique().Required(),
+	}
+}
+
+func (Pet) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("name").Edges("owner").Unique(),
+	}
+}
+
+type Group struct {
+	ent.Schema
+}
+
+func (Group) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("users", User.Type).Ref("groups"),
+	}
+}
+`,
+			want: `
+CREATE TABLE users (id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY);
+CREATE TABLE pets (
+  id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  name varchar NOT NULL,
+  user_pets bigint NOT NULL CONSTRAINT pets_users_pets REFERENCES users (id)
+);
+CREATE UNIQUE INDEX pet_name_user_pets ON pets (name, user_pets);
+CREATE TABLE groups (id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY);
+CREATE TABLE user_groups (
+  user_id bigint CONSTRAINT user_groups_user_id REFERENCES users (id) ON DELETE CASCADE,
+  group_id bigint CONSTRAINT user_groups_group_id REFERENCES groups (id) ON DELETE CASCADE,
+  PRIMARY KEY (user_id, group_id)
+);
+`,
+		},
+		{
+			name: "field without a type for the dialect",
+			source: `package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/schema/field"
+)
+
+type User struct {
+	ent.Schema
+}
+
+func (User) Fields() []ent.Field {
+	return []ent.Field{
+		field.Other("location", Point{}).SchemaType(map[string]string{dialect.MySQL: "point"}),
+	}
+}
+`,
+			wantErr: "User: field location needs a SchemaType for the postgres dialect",
+		},
+		{
+			name: "unknown field builder",
+			source: `package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+)
+
+type User struct {
+	ent.Schema
+}
+
+func (User) Fields() []ent.Field {
+	return []ent.Field{
+		field.Decimal("price"),
+	}
+}
+`,
+			wantErr: "User: unsupported field builder field.Decimal",
+		},
+		{
+			name: "edge to a type outside the package",
+			source: `package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+)
+
+type User struct {
+	ent.Schema
+}
+
+func (User) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("cars", Car.Type),
+	}
+}
+`,
+			wantErr: "User edge cars: Car isn't an ent schema of the package",
+		},
+	})
+}
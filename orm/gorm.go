@@ -0,0 +1,707 @@
+package orm
+
+import (
+	"fmt"
+	"go/ast"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"styx/internal/naming"
+	"styx/schema"
+)
+
+// Kinds of the types models commonly use from other packages. Columns are
+// nullable unless tagged not null, whatever the type
+var gormTypes = map[string]string{
+	"time.Time":           kindTime,
+	"gorm.DeletedAt":      kindTime,
+	"sql.NullString":      kindString,
+	"sql.NullInt64":       kindInt64,
+	"sql.NullInt32":       kindInt32,
+	"sql.NullInt16":       kindInt16,
+	"sql.NullByte":        kindUint8,
+	"sql.NullFloat64":     kindFloat64,
+	"sql.NullBool":        kindBool,
+	"sql.NullTime":        kindTime,
+	"uuid.UUID":           kindUUID,
+	"uuid.NullUUID":       kindUUID,
+	"datatypes.UUID":      kindUUID,
+	"datatypes.JSON":      kindJSON,
+	"datatypes.JSONMap":   kindJSON,
+	"datatypes.Date":      kindDate,
+	"json.RawMessage":     kindJSON,
+	"decimal.Decimal":     kindDecimal,
+	"decimal.NullDecimal": kindDecimal,
+}
+
+// Kinds of Go's basic types
+var basicKinds = map[string]string{
+	"bool": kindBool, "string": kindString,
+	"int": kindInt64, "int64": kindInt64, "int32": kindInt32, "rune": kindInt32, "int16": kindInt16, "int8": kindInt8,
+	"uint": kindUint64, "uint64": kindUint64, "uint32": kindUint32, "uint16": kindUint16, "uint8": kindUint8, "byte": kindUint8,
+	"float64": kindFloat64, "float32": kindFloat32,
+}
+
+// The fields gorm.Model adds
+var gormModelFields = []struct {
+	name, kind, tag string
+}{
+	{"ID", kindUint64, "primaryKey"},
+	{"CreatedAt", kindTime, ""},
+	{"UpdatedAt", kindTime, ""},
+	{"DeletedAt", kindTime, "index"},
+}
+
+var checkName = regexp.MustCompile(`^[A-Za-z_-]+$`)
+
+type gormReader struct {
+	types   map[string]string
+	dialect string
+	structs map[string]*ast.StructType
+	named   map[string]ast.Expr
+	methods map[string]map[string]*ast.FuncDecl
+	models  map[string]*gormModel
+	schema  *schema.Schema
+	// Join tables of many-to-many relations, written once for both sides
+	joinTables map[string]bool
+}
+
+type gormModel struct {
+	name      string
+	table     *schema.Table
+	fields    []*gormField
+	relations []*gormRelation
+	indexes   []*gormIndex
+}
+
+type gormField struct {
+	// goName is the name of the field in its struct
+	goName string
+	column *schema.Column
+	kind   string
+}
+
+type gormRelation struct {
+	field  string
+	target string
+	many   bool
+	tags   map[string]string
+}
+
+type gormIndex struct {
+	name     string
+	unique   bool
+	method   string
+	where    string
+	priority int
+	order    int
+	key      string
+}
+
+// GORM reads the tables AutoMigrate would create for the GORM models declared
+// in dir: structs embedding gorm.Model, or with a gorm tag or an ID field.
+// Associations become foreign keys, and many2many ones join tables
+func GORM(dir, dialect string) (*schema.Schema, error) {
+	types, err := dialectTypes(dialect)
+	if err != nil {
+		return nil, err
+	}
+	_, files, err := parseDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names, structs := structs(files)
+	r := &gormReader{
+		types:      types,
+		dialect:    dialect,
+		structs:    structs,
+		named:      namedTypes(files),
+		methods:    methods(files),
+		models:     map[string]*gormModel{},
+		schema:     &schema.Schema{},
+		joinTables: map[string]bool{},
+	}
+
+	var models []*gormModel
+	for _, name := range names {
+		if !r.isModel(name) {
+			continue
+		}
+		model := &gormModel{name: name, table: &schema.Table{Name: r.tableName(name)}}
+		r.models[name] = model
+		models = append(models, model)
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no GORM models found in %s", dir)
+	}
+
+	for _, model := range models {
+		if err := r.addFields(model, r.structs[model.name], ""); err != nil {
+			return nil, fmt.Errorf("%s: %w", model.name, err)
+		}
+		r.addPrimaryKey(model)
+		r.addIndexes(model)
+		r.schema.Tables = append(r.schema.Tables, model.table)
+	}
+	// Belongs-to relations come last, as GORM leaves out their foreign key
+	// when the other side declares it
+	for _, belongsTo := range []bool{false, true} {
+		for _, model := range models {
+			for _, rel := range model.relations {
+				if err := r.addRelation(model, rel, belongsTo); err != nil {
+					return nil, fmt.Errorf("%s.%s: %w", model.name, rel.field, err)
+				}
+			}
+		}
+	}
+	return r.schema, nil
+}
+
+// Reports whether the struct is a model rather than a struct embedded in
+// models
+func (r *gormReader) isModel(name string) bool {
+	if !ast.IsExported(name) {
+		return false
+	}
+	for _, st := range r.structs {
+		for _, field := range st.Fields.List {
+			if exprName(field.Type) == name || exprName(field.Type) == "*"+name {
+				if len(field.Names) == 0 || gormTag(field)["EMBEDDED"] != "" {
+					return false
+				}
+			}
+		}
+	}
+	for _, field := range r.structs[name].Fields.List {
+		if len(field.Names) == 0 && exprName(field.Type) == "gorm.Model" {
+			return true
+		}
+		if field.Tag != nil && reflect.StructTag(strings.Trim(field.Tag.Value, "`")).Get("gorm") != "" {
+			return true
+		}
+		for _, ident := range field.Names {
+			if ident.Name == "ID" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the table of the model: what its TableName method returns, or its
+// name in snake case and plural
+func (r *gormReader) tableName(name string) string {
+	if value, ok := literal(returned(r.methods[name]["TableName"])); ok {
+		return value
+	}
+	return naming.Plural(naming.SnakeCase(name))
+}
+
+// Parses a gorm struct tag into its settings, keyed in upper case like GORM
+// does, e.g. NOT NULL or PRIMARYKEY. Settings without a value map to their key
+func gormTag(field *ast.Field) map[string]string {
+	settings := map[string]string{}
+	for _, setting := range gormSettings(field) {
+		key, value, ok := strings.Cut(setting, ":")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		if !ok {
+			value = key
+		}
+		settings[key] = value
+	}
+	return settings
+}
+
+func gormSettings(field *ast.Field) []string {
+	if field.Tag == nil {
+		return nil
+	}
+	tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`")).Get("gorm")
+	var settings []string
+	for _, setting := range strings.Split(tag, ";") {
+		if strings.TrimSpace(setting) != "" {
+			settings = append(settings, strings.TrimSpace(setting))
+		}
+	}
+	return settings
+}
+
+// Adds the columns of the fields of st to the model, with the fields of
+// embedded structs in place
+func (r *gormReader) addFields(model *gormModel, st *ast.StructType, prefix string) error {
+	for _, field := range st.Fields.List {
+		tags := gormTag(field)
+		if _, ignored := tags["-"]; ignored {
+			continue
+		}
+
+		typeName := strings.TrimPrefix(exprName(field.Type), "*")
+		if len(field.Names) == 0 || tags["EMBEDDED"] != "" {
+			if typeName == "gorm.Model" {
+				for _, f := range gormModelFields {
+					tags, settings := map[string]string{}, []string{}
+					if f.tag != "" {
+						tags[strings.ToUpper(f.tag)] = strings.ToUpper(f.tag)
+						settings = append(settings, f.tag)
+					}
+					column := &schema.Column{Name: prefix + naming.SnakeCase(f.name)}
+					if err := r.addColumn(model, f.name, column, f.kind, tags, settings); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			embedded, ok := r.structs[typeName]
+			if !ok {
+				if len(field.Names) == 0 {
+					continue
+				}
+				return fmt.Errorf("embedded field %s has type %s, which isn't a struct of the package", field.Names[0].Name, typeName)
+			}
+			if err := r.addFields(model, embedded, prefix+tags["EMBEDDEDPREFIX"]); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, ident := range field.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			if err := r.addField(model, ident.Name, field, tags, prefix); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *gormReader) addField(model *gormModel, name string, field *ast.Field, tags map[string]string, prefix string) error {
+	kind, target, many, err := r.fieldKind(field.Type)
+	if target != "" {
+		model.relations = append(model.relations, &gormRelation{field: name, target: target, many: many, tags: tags})
+		return nil
+	}
+	if err != nil {
+		if tags["TYPE"] == "" && tags["SERIALIZER"] == "" {
+			return fmt.Errorf("field %s: %w, set its column type with a type tag", name, err)
+		}
+		kind = kindJSON
+	}
+
+	columnName := prefix + naming.SnakeCase(name)
+	if tags["COLUMN"] != "" {
+		columnName = tags["COLUMN"]
+	}
+	return r.addColumn(model, name, &schema.Column{Name: columnName}, kind, tags, gormSettings(field))
+}
+
+// Returns the kind of a field's type, or the model it's an association
+// with, and whether it's a slice of them
+func (r *gormReader) fieldKind(expr ast.Expr) (kind, target string, many bool, err error) {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return r.fieldKind(e.X)
+	case *ast.ArrayType:
+		if exprName(e.Elt) == "byte" {
+			return kindBytes, "", false, nil
+		}
+		elt := strings.TrimPrefix(exprName(e.Elt), "*")
+		if _, ok := r.models[elt]; ok {
+			return "", elt, true, nil
+		}
+	case *ast.Ident:
+		if kind, ok := basicKinds[e.Name]; ok {
+			return kind, "", false, nil
+		}
+		if _, ok := r.models[e.Name]; ok {
+			return "", e.Name, false, nil
+		}
+		if underlying, ok := r.named[e.Name]; ok {
+			return r.fieldKind(underlying)
+		}
+	case *ast.SelectorExpr:
+		if kind, ok := gormTypes[exprName(e)]; ok {
+			return kind, "", false, nil
+		}
+	}
+	return "", "", false, fmt.Errorf("type %s can't be mapped to a column type", exprName(expr))
+}
+
+func (r *gormReader) addColumn(model *gormModel, goName string, column *schema.Column, kind string, tags map[string]string, settings []string) error {
+	_, primary := tags["PRIMARYKEY"]
+	if _, ok := tags["PRIMARY_KEY"]; ok {
+		primary = true
+	}
+	_, unique := tags["UNIQUE"]
+	_, notNull := tags["NOT NULL"]
+	column.NotNull = notNull || primary
+
+	size, _ := strconv.Atoi(tags["SIZE"])
+	indexed := slices.ContainsFunc(settings, func(s string) bool {
+		key, _, _ := strings.Cut(strings.ToUpper(s), ":")
+		return key == "INDEX" || key == "UNIQUEINDEX"
+	})
+	// MySQL can't index longtext, so GORM sizes these strings
+	if r.dialect == "mysql" && kind == kindString && size == 0 && (primary || unique || indexed || tags["DEFAULT"] != "") {
+		size = 191
+	}
+	column.Type = columnType(r.types, kind, size)
+	if precision := tags["PRECISION"]; kind == kindDecimal && precision != "" {
+		column.Type += "(" + precision
+		if scale := tags["SCALE"]; scale != "" {
+			column.Type += "," + scale
+		}
+		column.Type += ")"
+	}
+	if tags["TYPE"] != "" {
+		column.Type = tags["TYPE"]
+	}
+
+	if value, ok := tags["DEFAULT"]; ok && value != "(-)" {
+		column.Default = gormDefault(value, kind)
+	}
+	column.Comment = tags["COMMENT"]
+
+	model.fields = append(model.fields, &gormField{goName: goName, column: column, kind: kind})
+	model.table.Columns = append(model.table.Columns, column)
+	if primary {
+		r.addToPrimaryKey(model.table, column.Name)
+	}
+	if unique {
+		model.table.Constraints = append(model.table.Constraints, &schema.Constraint{
+			Name:    "uni_" + model.table.Name + "_" + column.Name,
+			Type:    schema.Unique,
+			Columns: []string{column.Name},
+		})
+	}
+	if check := tags["CHECK"]; check != "" {
+		name, expr, ok := strings.Cut(check, ",")
+		if !ok || !checkName.MatchString(name) {
+			name, expr = "chk_"+model.table.Name+"_"+column.Name, check
+		}
+		model.table.Constraints = append(model.table.Constraints, &schema.Constraint{Name: name, Type: schema.Check, Expression: expr})
+	}
+	for _, setting := range settings {
+		index, ok := parseGormIndex(setting, model.table.Name, column.Name)
+		if ok {
+			index.order = len(model.indexes)
+			model.indexes = append(model.indexes, index)
+		}
+	}
+	return nil
+}
+
+// Strings are quoted the way GORM does, unless they call a function. Other
+// defaults are written as is
+func gormDefault(value, kind string) string {
+	if kind != kindString || strings.Contains(value, "(") && strings.Contains(value, ")") {
+		return value
+	}
+	value = strings.Trim(strings.Trim(value, "'"), `"`)
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func (r *gormReader) addToPrimaryKey(table *schema.Table, column string) {
+	if pk := table.PrimaryKey(); pk != nil {
+		pk.Columns = append(pk.Columns, column)
+		return
+	}
+	table.Constraints = append(table.Constraints, &schema.Constraint{
+		Name:    table.Name + "_pkey",
+		Type:    schema.PrimaryKey,
+		Columns: []string{column},
+	})
+}
+
+// Makes ID the primary key when no field is tagged as one, and makes a
+// single integer primary key auto-increment
+func (r *gormReader) addPrimaryKey(model *gormModel) {
+	table := model.table
+	if table.PrimaryKey() == nil {
+		for _, field := range model.fields {
+			if field.goName == "ID" {
+				field.column.NotNull = true
+				r.addToPrimaryKey(table, field.column.Name)
+			}
+		}
+	}
+	pk := table.PrimaryKey()
+	if pk == nil || len(pk.Columns) != 1 {
+		return
+	}
+	for _, field := range model.fields {
+		if field.column.Name != pk.Columns[0] || !isInteger(field.kind) || field.column.Default != "" {
+			continue
+		}
+		r.autoIncrement(field.column, field.kind)
+	}
+}
+
+func (r *gormReader) autoIncrement(column *schema.Column, kind string) {
+	switch r.dialect {
+	case "mysql":
+		column.AutoIncrement = true
+	case "sqlite":
+		column.Type = "integer"
+		column.AutoIncrement = true
+	default:
+		switch kind {
+		case kindInt8, kindInt16, kindUint8:
+			column.Type = "smallserial"
+		case kindInt32, kindUint16:
+			column.Type = "serial"
+		default:
+			column.Type = "bigserial"
+		}
+	}
+}
+
+// Parses an index or uniqueIndex setting, like index:idx_name,sort:desc
+func parseGormIndex(setting, table, column string) (*gormIndex, bool) {
+	key, value, _ := strings.Cut(setting, ":")
+	key = strings.ToUpper(strings.TrimSpace(key))
+	if key != "INDEX" && key != "UNIQUEINDEX" {
+		return nil, false
+	}
+
+	options := strings.Split(value, ",")
+	index := &gormIndex{name: strings.TrimSpace(options[0]), unique: key == "UNIQUEINDEX", priority: 10, key: column}
+	for _, option := range options[1:] {
+		name, value, _ := strings.Cut(option, ":")
+		switch strings.ToUpper(strings.TrimSpace(name)) {
+		case "UNIQUE":
+			index.unique = true
+		case "CLASS":
+			index.unique = strings.EqualFold(value, "UNIQUE")
+		case "TYPE", "USING":
+			index.method = strings.ToLower(value)
+		case "WHERE":
+			index.where = value
+		case "SORT":
+			index.key += " " + strings.ToUpper(value)
+		case "EXPRESSION":
+			index.key = "(" + value + ")"
+		case "PRIORITY":
+			index.priority, _ = strconv.Atoi(value)
+		case "COMPOSITE":
+			index.name = "idx_" + table + "_" + value
+		}
+	}
+	if index.name == "" {
+		index.name = "idx_" + table + "_" + column
+	}
+	return index, true
+}
+
+// Adds the indexes, grouping the fields that share an index name in the
+// order of their priority
+func (r *gormReader) addIndexes(model *gormModel) {
+	slices.SortStableFunc(model.indexes, func(a, b *gormIndex) int { return a.priority - b.priority })
+	for _, index := range model.indexes {
+		if existing := model.table.Index(index.name); existing != nil {
+			existing.Keys = append(existing.Keys, index.key)
+			existing.Unique = existing.Unique || index.unique
+			continue
+		}
+		model.table.Indexes = append(model.table.Indexes, &schema.Index{
+			Name:   index.name,
+			Unique: index.unique,
+			Method: index.method,
+			Keys:   []string{index.key},
+			Where:  index.where,
+		})
+	}
+	// Indexes are declared in field order
+	slices.SortStableFunc(model.table.Indexes, func(a, b *schema.Index) int {
+		return firstIndexOrder(model, a.Name) - firstIndexOrder(model, b.Name)
+	})
+}
+
+func firstIndexOrder(model *gormModel, name string) int {
+	order := len(model.indexes)
+	for _, index := range model.indexes {
+		if index.name == name {
+			order = min(order, index.order)
+		}
+	}
+	return order
+}
+
+// Adds the foreign key of an association, or the join table of a
+// many-to-many one. belongsTo selects whether belongs-to associations, whose
+// foreign key is on the model, or the others are added
+func (r *gormReader) addRelation(model *gormModel, rel *gormRelation, belongsTo bool) error {
+	if rel.tags["POLYMORPHIC"] != "" {
+		return nil
+	}
+	target := r.models[rel.target]
+	if joinTable := rel.tags["MANY2MANY"]; joinTable != "" {
+		if !belongsTo {
+			return r.addJoinTable(model, rel, target, joinTable)
+		}
+		return nil
+	}
+
+	foreignKey := rel.tags["FOREIGNKEY"]
+	if !rel.many {
+		name := foreignKey
+		if name == "" {
+			name = rel.field + "ID"
+		}
+		if field := model.field(name); field != nil {
+			if !belongsTo {
+				return nil
+			}
+			refs, err := r.references(target, rel.tags["REFERENCES"])
+			if err != nil {
+				return err
+			}
+			return r.addForeignKey(model.table, model.table, rel, []string{field.column.Name}, target.table, refs)
+		}
+	}
+	if belongsTo {
+		return nil
+	}
+
+	// Has one or has many, with the foreign key on the target
+	name := foreignKey
+	if name == "" {
+		name = model.name + "ID"
+	}
+	field := target.field(name)
+	if field == nil {
+		return fmt.Errorf("%s has no %s field to reference %s by, set it with a foreignKey tag", rel.target, name, model.name)
+	}
+	refs, err := r.references(model, rel.tags["REFERENCES"])
+	if err != nil {
+		return err
+	}
+	return r.addForeignKey(model.table, target.table, rel, []string{field.column.Name}, model.table, refs)
+}
+
+func (m *gormModel) field(goName string) *gormField {
+	for _, f := range m.fields {
+		if f.goName == goName {
+			return f
+		}
+	}
+	return nil
+}
+
+// Returns the columns a foreign key references: the one of the field named
+// by the references tag, or the primary key
+func (r *gormReader) references(model *gormModel, references string) ([]string, error) {
+	if references != "" {
+		field := model.field(references)
+		if field == nil {
+			return nil, fmt.Errorf("%s has no %s field to reference", model.name, references)
+		}
+		return []string{field.column.Name}, nil
+	}
+	pk := model.table.PrimaryKey()
+	if pk == nil {
+		return nil, fmt.Errorf("%s has no primary key to reference", model.name)
+	}
+	return pk.Columns, nil
+}
+
+// Adds the foreign key of an association declared on owner to table, named
+// like GORM names it, unless the other side already added it
+func (r *gormReader) addForeignKey(owner, table *schema.Table, rel *gormRelation, columns []string, refTable *schema.Table, refColumns []string) error {
+	for _, c := range table.Constraints {
+		if c.Type == schema.ForeignKey && c.RefTable == refTable.Name && slices.Equal(c.Columns, columns) {
+			return nil
+		}
+	}
+	fk := &schema.Constraint{
+		Name:       "fk_" + owner.Name + "_" + naming.SnakeCase(rel.field),
+		Type:       schema.ForeignKey,
+		Columns:    columns,
+		RefTable:   refTable.Name,
+		RefColumns: refColumns,
+	}
+	for _, action := range strings.Split(rel.tags["CONSTRAINT"], ",") {
+		name, value, _ := strings.Cut(action, ":")
+		switch strings.ToUpper(strings.TrimSpace(name)) {
+		case "ONUPDATE":
+			fk.OnUpdate = strings.ToUpper(strings.TrimSpace(value))
+		case "ONDELETE":
+			fk.OnDelete = strings.ToUpper(strings.TrimSpace(value))
+		}
+	}
+	table.Constraints = append(table.Constraints, fk)
+	return nil
+}
+
+// Adds the join table of a many-to-many association, with a column
+// referencing each side's primary key
+func (r *gormReader) addJoinTable(model *gormModel, rel *gormRelation, target *gormModel, name string) error {
+	if r.joinTables[name] {
+		return nil
+	}
+	r.joinTables[name] = true
+
+	ownPK, targetPK := model.table.PrimaryKey(), target.table.PrimaryKey()
+	if ownPK == nil || targetPK == nil || len(ownPK.Columns) != 1 || len(targetPK.Columns) != 1 {
+		return fmt.Errorf("many2many associations need single-column primary keys on %s and %s", model.name, target.name)
+	}
+	ownColumn := naming.SnakeCase(model.name) + "_" + ownPK.Columns[0]
+	targetPrefix := naming.SnakeCase(target.name)
+	if model == target {
+		targetPrefix = strings.TrimSuffix(naming.SnakeCase(rel.field), "s")
+	}
+	targetColumn := targetPrefix + "_" + targetPK.Columns[0]
+	if key := rel.tags["JOINFOREIGNKEY"]; key != "" {
+		ownColumn = naming.SnakeCase(key)
+	}
+	if key := rel.tags["JOINREFERENCES"]; key != "" {
+		targetColumn = naming.SnakeCase(key)
+	}
+
+	table := &schema.Table{Name: name}
+	for _, side := range []struct {
+		column string
+		model  *gormModel
+		pk     string
+	}{{ownColumn, model, ownPK.Columns[0]}, {targetColumn, target, targetPK.Columns[0]}} {
+		table.Columns = append(table.Columns, &schema.Column{Name: side.column, Type: referenceType(side.model.table.Column(side.pk)), NotNull: true})
+		table.Constraints = append(table.Constraints, &schema.Constraint{
+			Name:       "fk_" + name + "_" + naming.SnakeCase(side.model.name),
+			Type:       schema.ForeignKey,
+			Columns:    []string{side.column},
+			RefTable:   side.model.table.Name,
+			RefColumns: []string{side.pk},
+		})
+	}
+	if model == target {
+		table.Constraints[len(table.Constraints)-1].Name = "fk_" + name + "_" + naming.SnakeCase(rel.field)
+	}
+	table.Constraints = append([]*schema.Constraint{{
+		Name:    name + "_pkey",
+		Type:    schema.PrimaryKey,
+		Columns: []string{ownColumn, targetColumn},
+	}}, table.Constraints...)
+	r.schema.Tables = append(r.schema.Tables, table)
+	return nil
+}
+
+// Serial types are integers with a sequence, which columns referencing them
+// don't have
+var serialBases = map[string]string{
+	"smallserial": "smallint",
+	"serial":      "integer",
+	"bigserial":   "bigint",
+}
+
+// Returns the type of a column referencing column
+func referenceType(column *schema.Column) string {
+	if base, ok := serialBases[column.Type]; ok {
+		return base
+	}
+	return column.Type
+}
@@ -0,0 +1,141 @@
+package orm
+
+import "testing"
+
+func TestGORM(t *testing.T) {
+	testFixtures(t, GORM, []fixtureTest{
+		{
+			name: "tags",
+			source: `package models
+
+type User struct {
+	ID      uint
+	Email   string  ` + "`gorm:\"size:255;not null;uniqueIndex\"`" + `
+	Name    *string ` + "`gorm:\"column:full_name;default:anonymous\"`" + `
+	Age     int     ` + "`gorm:\"check:age >= 0\"`" + `
+	Score   float64 ` + "`gorm:\"index:idx_score\"`" + `
+	Code    string  ` + "`gorm:\"unique\"`" + `
+	Ignored string  ` + "`gorm:\"-\"`" + `
+}
+`,
+			want: `
+CREATE TABLE users (
+  id bigserial PRIMARY KEY,
+  email varchar(255) NOT NULL,
+  full_name text DEFAULT 'anonymous',
+  age bigint CONSTRAINT chk_users_age CHECK (age >= 0),
+  score double precision,
+  code text CONSTRAINT uni_users_code UNIQUE
+);
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+CREATE INDEX idx_score ON users (score);
+`,
+		},
+		{
+			name: "embedded structs",
+			source: `package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Timestamps struct {
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Post struct {
+	gorm.Model
+	Title string
+	Audit Timestamps ` + "`gorm:\"embedded;embeddedPrefix:audit_\"`" + `
+}
+
+type Comment struct {
+	ID   uint
+	Body string
+	Timestamps
+}
+`,
+			want: `
+CREATE TABLE posts (
+  id bigserial PRIMARY KEY,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  title text,
+  audit_created_at timestamptz,
+  audit_updated_at timestamptz
+);
+CREATE INDEX idx_posts_deleted_at ON posts (deleted_at);
+CREATE TABLE comments (
+  id bigserial PRIMARY KEY,
+  body text,
+  created_at timestamptz,
+  updated_at timestamptz
+);
+`,
+		},
+		{
+			name: "associations",
+			source: `package models
+
+type User struct {
+	ID    uint
+	Posts []Post
+}
+
+type Post struct {
+	ID     uint
+	UserID uint
+	Tags   []Tag ` + "`gorm:\"many2many:post_tags\"`" + `
+}
+
+type Tag struct {
+	ID uint
+}
+`,
+			want: `
+CREATE TABLE users (id bigserial PRIMARY KEY);
+CREATE TABLE posts (
+  id bigserial PRIMARY KEY,
+  user_id bigint CONSTRAINT fk_users_posts REFERENCES users (id)
+);
+CREATE TABLE tags (id bigserial PRIMARY KEY);
+CREATE TABLE post_tags (
+  post_id bigint CONSTRAINT fk_post_tags_post REFERENCES posts (id),
+  tag_id bigint CONSTRAINT fk_post_tags_tag REFERENCES tags (id),
+  PRIMARY KEY (post_id, tag_id)
+);
+`,
+		},
+		{
+			name: "unknown field type",
+			source: `package models
+
+type User struct {
+	ID   uint
+	Data map[string]any
+}
+`,
+			wantErr: "User: field Data: type map[string]any can't be mapped to a column type, set its column type with a type tag",
+		},
+		{
+			name: "unknown field type with a type tag",
+			source: `package models
+
+type User struct {
+	ID   uint
+	Data map[string]any ` + "`gorm:\"type:jsonb\"`" + `
+}
+`,
+			want: "CREATE TABLE users (id bigserial PRIMARY KEY, data jsonb);",
+		},
+		{
+			name:    "no models",
+			source:  "package models\n\ntype options struct {\n\tverbose bool\n}\n",
+			wantErr: "no GORM models found",
+		},
+	})
+}
@@ -0,0 +1,239 @@
+// Package orm reads the desired schema from the models of Go ORMs, GORM
+// structs and ent schemas, so teams defining their tables in Go can diff and
+// generate migrations without keeping a schema.sql by hand. The source is
+// parsed rather than compiled, so the models don't have to build on their own,
+// but only what's written literally in them, like struct tags and the
+// arguments of ent's builders, is understood.
+package orm
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Kinds of Go values mapped to column types
+const (
+	kindBool    = "bool"
+	kindInt8    = "int8"
+	kindInt16   = "int16"
+	kindInt32   = "int32"
+	kindInt64   = "int64"
+	kindUint8   = "uint8"
+	kindUint16  = "uint16"
+	kindUint32  = "uint32"
+	kindUint64  = "uint64"
+	kindFloat32 = "float32"
+	kindFloat64 = "float64"
+	kindString  = "string"
+	kindText    = "text"
+	kindTime    = "time"
+	kindDate    = "date"
+	kindBytes   = "bytes"
+	kindUUID    = "uuid"
+	kindJSON    = "json"
+	kindDecimal = "decimal"
+)
+
+// Column types of the kinds in each dialect, close to the ones the ORMs
+// create. varchar is the type of strings with a size
+var columnTypes = map[string]map[string]string{
+	"postgres": {
+		kindBool: "boolean", kindInt8: "smallint", kindInt16: "smallint", kindInt32: "integer", kindInt64: "bigint",
+		kindUint8: "smallint", kindUint16: "integer", kindUint32: "bigint", kindUint64: "bigint",
+		kindFloat32: "real", kindFloat64: "double precision", kindString: "text", kindText: "text",
+		kindTime: "timestamp with time zone", kindDate: "date", kindBytes: "bytea", kindUUID: "uuid",
+		kindJSON: "jsonb", kindDecimal: "numeric", "varchar": "character varying(%d)",
+	},
+	"mysql": {
+		kindBool: "boolean", kindInt8: "tinyint", kindInt16: "smallint", kindInt32: "int", kindInt64: "bigint",
+		kindUint8: "tinyint unsigned", kindUint16: "smallint unsigned", kindUint32: "int unsigned", kindUint64: "bigint unsigned",
+		kindFloat32: "float", kindFloat64: "double", kindString: "longtext", kindText: "longtext",
+		kindTime: "datetime(3)", kindDate: "date", kindBytes: "longblob", kindUUID: "char(36)",
+		kindJSON: "json", kindDecimal: "decimal", "varchar": "varchar(%d)",
+	},
+	"sqlite": {
+		kindBool: "numeric", kindInt8: "integer", kindInt16: "integer", kindInt32: "integer", kindInt64: "integer",
+		kindUint8: "integer", kindUint16: "integer", kindUint32: "integer", kindUint64: "integer",
+		kindFloat32: "real", kindFloat64: "real", kindString: "text", kindText: "text",
+		kindTime: "datetime", kindDate: "date", kindBytes: "blob", kindUUID: "text",
+		kindJSON: "text", kindDecimal: "numeric", "varchar": "varchar(%d)",
+	},
+}
+
+// Returns the types of the named dialect. CockroachDB takes Postgres types
+func dialectTypes(dialect string) (map[string]string, error) {
+	if dialect == "cockroachdb" || dialect == "cockroach" {
+		dialect = "postgres"
+	}
+	types, ok := columnTypes[dialect]
+	if !ok {
+		return nil, fmt.Errorf("models can't be read for the %s dialect", dialect)
+	}
+	return types, nil
+}
+
+// Returns the column type of kind, a varchar when size is set on a string
+func columnType(types map[string]string, kind string, size int) string {
+	if kind == kindString && size > 0 {
+		return fmt.Sprintf(types["varchar"], size)
+	}
+	return types[kind]
+}
+
+func isInteger(kind string) bool {
+	return strings.HasPrefix(kind, "int") || strings.HasPrefix(kind, "uint")
+}
+
+// Parses the Go files of the package in dir, test files aside, in name order
+func parseDir(dir string) (*token.FileSet, []*ast.File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read models directory %s: %w", dir, err)
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no Go files found in %s", dir)
+	}
+	return fset, files, nil
+}
+
+// Returns the methods of the package's types, by type and method name
+func methods(files []*ast.File) map[string]map[string]*ast.FuncDecl {
+	found := map[string]map[string]*ast.FuncDecl{}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 || fn.Body == nil {
+				continue
+			}
+			recv := fn.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			ident, ok := recv.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			if found[ident.Name] == nil {
+				found[ident.Name] = map[string]*ast.FuncDecl{}
+			}
+			found[ident.Name][fn.Name.Name] = fn
+		}
+	}
+	return found
+}
+
+// Returns the expression fn returns, if its body is a single return
+// statement
+func returned(fn *ast.FuncDecl) ast.Expr {
+	if fn == nil {
+		return nil
+	}
+	for _, stmt := range fn.Body.List {
+		if ret, ok := stmt.(*ast.ReturnStmt); ok && len(ret.Results) == 1 {
+			return ret.Results[0]
+		}
+	}
+	return nil
+}
+
+// Returns the value of a string, number or boolean literal, as written for
+// numbers and booleans
+func literal(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind == token.STRING {
+			s, err := strconv.Unquote(e.Value)
+			return s, err == nil
+		}
+		return e.Value, e.Kind == token.INT || e.Kind == token.FLOAT
+	case *ast.Ident:
+		return e.Name, e.Name == "true" || e.Name == "false"
+	case *ast.UnaryExpr:
+		if value, ok := literal(e.X); ok && e.Op == token.SUB {
+			return "-" + value, true
+		}
+	case *ast.ParenExpr:
+		return literal(e.X)
+	}
+	return "", false
+}
+
+// Returns the name an expression is written with, like time.Time or User
+func exprName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return exprName(e.X) + "." + e.Sel.Name
+	case *ast.StarExpr:
+		return "*" + exprName(e.X)
+	case *ast.ArrayType:
+		return "[]" + exprName(e.Elt)
+	}
+	return types.ExprString(expr)
+}
+
+// Names of the types declared in files, in declaration order, with their
+// struct definitions
+func structs(files []*ast.File) ([]string, map[string]*ast.StructType) {
+	var names []string
+	found := map[string]*ast.StructType{}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok && !slices.Contains(names, ts.Name.Name) {
+					names = append(names, ts.Name.Name)
+					found[ts.Name.Name] = st
+				}
+			}
+		}
+	}
+	return names, found
+}
+
+// Returns the underlying types of the named non-struct types declared in
+// files, like Status for type Status string
+func namedTypes(files []*ast.File) map[string]ast.Expr {
+	found := map[string]ast.Expr{}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if _, ok := ts.Type.(*ast.StructType); !ok {
+					found[ts.Name.Name] = ts.Type
+				}
+			}
+		}
+	}
+	return found
+}
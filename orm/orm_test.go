@@ -0,0 +1,61 @@
+package orm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"styx/diff"
+	"styx/schema"
+)
+
+type fixtureTest struct {
+	name string
+	// source is the Go file the models are declared in
+	source string
+	// want is the schema they stand for, or wantErr the start of the error
+	want    string
+	wantErr string
+}
+
+// Reads the models of each test's source with read, and compares them with
+// the schema it wants
+func testFixtures(t *testing.T, read func(dir, dialect string) (*schema.Schema, error), tests []fixtureTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "models.go"), []byte(tt.source), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := read(dir, "postgres")
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The models go through SQL too, so types like bigserial are
+			// expanded the same way on both sides
+			var sql []string
+			for _, change := range diff.Diff(&schema.Schema{}, got, diff.Options{}) {
+				sql = append(sql, change.SQL)
+			}
+			read, err := schema.Parse(strings.Join(sql, "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := schema.Parse(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changes := diff.Diff(want, read, diff.Options{}); len(changes) > 0 {
+				t.Errorf("got a schema differing by:\n%s\nread:\n%s", changes[0].SQL, strings.Join(sql, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package scaffold
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"styx/internal/naming"
+	"styx/schema"
+)
+
+// jsonSchema is the part of a JSON Schema describing a value that's mapped to
+// columns
+type jsonSchema struct {
+	Type            any            `json:"type"`
+	Format          string         `json:"format"`
+	Title           string         `json:"title"`
+	Description     string         `json:"description"`
+	Enum            []any          `json:"enum"`
+	Default         any            `json:"default"`
+	MaxLength       int            `json:"maxLength"`
+	ContentEncoding string         `json:"contentEncoding"`
+	Ref             string         `json:"$ref"`
+	Items           *jsonSchema    `json:"items"`
+	AnyOf           []*jsonSchema  `json:"anyOf"`
+	OneOf           []*jsonSchema  `json:"oneOf"`
+	Properties      jsonProperties `json:"properties"`
+	Required        []string       `json:"required"`
+	Definitions     jsonProperties `json:"definitions"`
+	Defs            jsonProperties `json:"$defs"`
+}
+
+// jsonProperties are named schemas, in the order they're written in
+type jsonProperties struct {
+	names   []string
+	schemas map[string]*jsonSchema
+}
+
+func (p *jsonProperties) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if token, err := dec.Token(); err != nil || token != json.Delim('{') {
+		return fmt.Errorf("expected an object of schemas")
+	}
+	p.schemas = map[string]*jsonSchema{}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := token.(string)
+		var s jsonSchema
+		if err := dec.Decode(&s); err != nil {
+			return err
+		}
+		if _, ok := p.schemas[name]; !ok {
+			p.names = append(p.names, name)
+		}
+		p.schemas[name] = &s
+	}
+	return nil
+}
+
+// JSONSchema drafts tables from the JSON Schema at path, for the named
+// dialect. An object schema with properties is a single table, named after
+// its title or the file, and the definitions are the types of its values.
+// Otherwise each object in the definitions is a table. Required properties
+// are NOT NULL, unless their type allows null
+func JSONSchema(path, dialect string) (*schema.Schema, error) {
+	b, err := newBuilder(dialect)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var root jsonSchema
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	r := &jsonReader{builder: b, definitions: root.Definitions}
+	for _, name := range root.Defs.names {
+		if r.definitions.schemas == nil {
+			r.definitions.schemas = map[string]*jsonSchema{}
+		}
+		r.definitions.names = append(r.definitions.names, name)
+		r.definitions.schemas[name] = root.Defs.schemas[name]
+	}
+
+	if len(root.Properties.names) > 0 {
+		name := root.Title
+		if name == "" {
+			name = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), ".schema")
+		}
+		if err := r.addObject(name, &root); err != nil {
+			return nil, err
+		}
+		return b.schema, nil
+	}
+	for _, name := range r.definitions.names {
+		if def := r.definitions.schemas[name]; len(def.Properties.names) > 0 {
+			if err := r.addObject(name, def); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(b.schema.Tables) == 0 {
+		return nil, fmt.Errorf("no objects with properties found in %s", path)
+	}
+	return b.schema, nil
+}
+
+type jsonReader struct {
+	*builder
+	definitions jsonProperties
+}
+
+func (r *jsonReader) addObject(name string, object *jsonSchema) error {
+	name = strings.NewReplacer(" ", "", "-", "_").Replace(name)
+	table, err := r.addTable(tableName(name))
+	if err != nil {
+		return err
+	}
+	table.Comment = r.comment(object.Description)
+	for _, property := range object.Properties.names {
+		value := object.Properties.schemas[property]
+		column := &schema.Column{Name: naming.SnakeCase(property), Comment: r.comment(value.Description)}
+		defaultValue := value.Default
+		if table.Column(column.Name) != nil {
+			return fmt.Errorf("property %s of %s is defined twice", property, name)
+		}
+		value, enumName, nullable := r.resolve(value)
+		if enumName == "" {
+			enumName = naming.SnakeCase(name) + "_" + column.Name
+		}
+		column.Type = r.valueType(table, column.Name, enumName, value)
+		column.NotNull = !nullable && slices.Contains(object.Required, property)
+		if defaultValue == nil {
+			defaultValue = value.Default
+		}
+		column.Default = jsonDefault(defaultValue)
+		table.Columns = append(table.Columns, column)
+	}
+	r.addPrimaryKey(table)
+	return nil
+}
+
+// Resolves a reference to the definitions, and an anyOf or oneOf of a type
+// and null, returning the schema of the value, the name of its definition if
+// it has one, and whether it can be null
+func (r *jsonReader) resolve(value *jsonSchema) (*jsonSchema, string, bool) {
+	nullable := false
+	name := ""
+	for {
+		if alternatives := slices.Concat(value.AnyOf, value.OneOf); len(alternatives) > 0 {
+			var others []*jsonSchema
+			for _, alternative := range alternatives {
+				if alternative.Type == "null" {
+					nullable = true
+				} else {
+					others = append(others, alternative)
+				}
+			}
+			if len(others) != 1 {
+				return &jsonSchema{Type: "object"}, name, nullable
+			}
+			value = others[0]
+			continue
+		}
+		if value.Ref != "" {
+			ref := value.Ref[strings.LastIndex(value.Ref, "/")+1:]
+			def, ok := r.definitions.schemas[ref]
+			if !ok {
+				return &jsonSchema{Type: "object"}, name, nullable
+			}
+			name = naming.SnakeCase(ref)
+			value = def
+			continue
+		}
+		break
+	}
+	if types, ok := value.Type.([]any); ok {
+		for _, typ := range types {
+			if typ == "null" {
+				nullable = true
+			}
+		}
+	}
+	return value, name, nullable
+}
+
+// Returns the first type of value besides null, string for enums without one
+func jsonType(value *jsonSchema) string {
+	switch typ := value.Type.(type) {
+	case string:
+		return typ
+	case []any:
+		for _, t := range typ {
+			if t, ok := t.(string); ok && t != "null" {
+				return t
+			}
+		}
+	}
+	if len(value.Enum) > 0 {
+		return "string"
+	}
+	return "object"
+}
+
+func (r *jsonReader) valueType(table *schema.Table, column, enumName string, value *jsonSchema) string {
+	if jsonType(value) == "string" && len(value.Enum) > 0 {
+		var values []string
+		for _, v := range value.Enum {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return r.enumType(table, column, enumName, values)
+	}
+	if jsonType(value) == "array" {
+		if value.Items == nil {
+			return r.types[kindJSON]
+		}
+		items, _, _ := r.resolve(value.Items)
+		return r.arrayType(jsonKind(items))
+	}
+	return r.columnType(jsonKind(value), value.MaxLength)
+}
+
+// Returns the kind of a value's type, taking its format into account
+func jsonKind(value *jsonSchema) string {
+	switch jsonType(value) {
+	case "string":
+		switch {
+		case value.Format == "date-time":
+			return kindTime
+		case value.Format == "date":
+			return kindDate
+		case value.Format == "duration":
+			return kindDuration
+		case value.Format == "uuid":
+			return kindUUID
+		case value.ContentEncoding == "base64":
+			return kindBytes
+		}
+		return kindString
+	case "integer":
+		if value.Format == "int32" {
+			return kindInt32
+		}
+		return kindInt64
+	case "number":
+		if value.Format == "float" {
+			return kindFloat
+		}
+		return kindDouble
+	case "boolean":
+		return kindBool
+	}
+	return kindJSON
+}
+
+// Returns the SQL literal of a default value. Objects and arrays have none
+func jsonDefault(value any) string {
+	switch v := value.(type) {
+	case string:
+		return quoteString(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
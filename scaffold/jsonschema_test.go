@@ -0,0 +1,182 @@
+package scaffold
+
+import "testing"
+
+func TestJSONSchema(t *testing.T) {
+	testScaffold(t, "user.schema.json", JSONSchema, []scaffoldTest{
+		{
+			name: "numeric types",
+			source: `{
+  "title": "Product",
+  "type": "object",
+  "properties": {
+    "id": {"type": "string", "format": "uuid"},
+    "stock": {"type": "integer", "format": "int32"},
+    "views": {"type": "integer"},
+    "weight": {"type": "number", "format": "float"},
+    "price": {"type": "number", "default": 9.99},
+    "active": {"type": "boolean", "default": true}
+  },
+  "required": ["id", "stock", "views", "weight", "price", "active"]
+}
+`,
+			want: `
+CREATE TABLE products (
+    id uuid NOT NULL,
+    stock integer NOT NULL,
+    views bigint NOT NULL,
+    weight real NOT NULL,
+    price double precision DEFAULT 9.99 NOT NULL,
+    active boolean DEFAULT true NOT NULL,
+    CONSTRAINT products_pkey PRIMARY KEY (id)
+);
+`,
+		},
+		{
+			name: "nullable properties",
+			source: `{
+  "type": "object",
+  "description": "A person using the service",
+  "properties": {
+    "email": {"type": "string", "maxLength": 255, "description": "Unique address to reach them at"},
+    "nickname": {"type": "string"},
+    "bio": {"type": ["string", "null"]},
+    "born_on": {"anyOf": [{"type": "string", "format": "date"}, {"type": "null"}]},
+    "createdAt": {"type": "string", "format": "date-time"},
+    "avatar": {"type": "string", "contentEncoding": "base64"},
+    "settings": {"type": "object"}
+  },
+  "required": ["email", "bio", "born_on", "createdAt"]
+}
+`,
+			want: `
+CREATE TABLE users (
+    id bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    email character varying(255) NOT NULL,
+    nickname text,
+    bio text,
+    born_on date,
+    created_at timestamp with time zone NOT NULL,
+    avatar bytea,
+    settings jsonb,
+    CONSTRAINT users_pkey PRIMARY KEY (id)
+);
+COMMENT ON TABLE users IS 'A person using the service';
+COMMENT ON COLUMN users.email IS 'Unique address to reach them at';
+`,
+		},
+		{
+			name: "enums",
+			source: `{
+  "$defs": {
+    "Status": {"type": "string", "enum": ["active", "banned"]},
+    "Order": {
+      "type": "object",
+      "properties": {
+        "status": {"$ref": "#/$defs/Status", "default": "active"},
+        "kind": {"enum": ["retail", "wholesale"]},
+        "previous_status": {"oneOf": [{"$ref": "#/$defs/Status"}, {"type": "null"}]}
+      },
+      "required": ["status", "kind", "previous_status"]
+    }
+  }
+}
+`,
+			want: `
+CREATE TYPE status AS ENUM ('active', 'banned');
+CREATE TYPE order_kind AS ENUM ('retail', 'wholesale');
+CREATE TABLE orders (
+    id bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    status status DEFAULT 'active' NOT NULL,
+    kind order_kind NOT NULL,
+    previous_status status,
+    CONSTRAINT orders_pkey PRIMARY KEY (id)
+);
+`,
+		},
+		{
+			name:    "enums in SQLite",
+			dialect: "sqlite",
+			source: `{
+  "title": "Order",
+  "type": "object",
+  "properties": {
+    "status": {"type": "string", "enum": ["active", "banned"]}
+  },
+  "required": ["status"]
+}
+`,
+			want: `
+CREATE TABLE orders (
+    id integer PRIMARY KEY AUTOINCREMENT NOT NULL,
+    status text NOT NULL,
+    CONSTRAINT orders_status_check CHECK (status IN ('active', 'banned'))
+);
+`,
+		},
+		{
+			name: "arrays",
+			source: `{
+  "definitions": {
+    "Link": {
+      "type": "object",
+      "properties": {"url": {"type": "string"}}
+    },
+    "Team": {
+      "type": "object",
+      "properties": {
+        "tags": {"type": "array", "items": {"type": "string"}},
+        "member_ids": {"type": "array", "items": {"type": "integer"}},
+        "links": {"type": "array", "items": {"$ref": "#/definitions/Link"}},
+        "anything": {"type": "array"}
+      },
+      "required": ["tags"]
+    }
+  }
+}
+`,
+			want: `
+CREATE TABLE links (
+    id bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    url text,
+    CONSTRAINT links_pkey PRIMARY KEY (id)
+);
+CREATE TABLE teams (
+    id bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    tags text[] NOT NULL,
+    member_ids bigint[],
+    links jsonb,
+    anything jsonb,
+    CONSTRAINT teams_pkey PRIMARY KEY (id)
+);
+`,
+		},
+		{
+			name:    "arrays in MySQL",
+			dialect: "mysql",
+			source: `{
+  "title": "Team",
+  "type": "object",
+  "properties": {
+    "tags": {"type": "array", "items": {"type": "string"}}
+  }
+}
+`,
+			want: "CREATE TABLE `teams` (\n" +
+				"    `id` bigint NOT NULL AUTO_INCREMENT,\n" +
+				"    `tags` json,\n" +
+				"    PRIMARY KEY (`id`)\n" +
+				");",
+		},
+		{
+			name:    "no objects",
+			source:  `{"type": "string"}`,
+			wantErr: "no objects with properties found in",
+		},
+		{
+			name:    "invalid JSON",
+			source:  `{"type": "object",`,
+			wantErr: "failed to parse",
+		},
+	})
+}
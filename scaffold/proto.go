@@ -0,0 +1,560 @@
+package scaffold
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"styx/internal/naming"
+	"styx/schema"
+)
+
+// Kinds of the scalar types of protobuf. Unsigned 32-bit values don't fit
+// signed integers, so they take 64 bits
+var protoScalars = map[string]string{
+	"double": kindDouble, "float": kindFloat, "bool": kindBool, "string": kindString, "bytes": kindBytes,
+	"int32": kindInt32, "sint32": kindInt32, "sfixed32": kindInt32, "uint32": kindInt64, "fixed32": kindInt64,
+	"int64": kindInt64, "sint64": kindInt64, "sfixed64": kindInt64, "uint64": kindInt64, "fixed64": kindInt64,
+}
+
+// Kinds of the well-known message types. Wrappers hold a nullable scalar
+var protoWellKnown = map[string]string{
+	"google.protobuf.Timestamp":   kindTime,
+	"google.protobuf.Duration":    kindDuration,
+	"google.protobuf.Struct":      kindJSON,
+	"google.protobuf.Value":       kindJSON,
+	"google.protobuf.ListValue":   kindJSON,
+	"google.protobuf.Any":         kindJSON,
+	"google.protobuf.DoubleValue": kindDouble,
+	"google.protobuf.FloatValue":  kindFloat,
+	"google.protobuf.Int64Value":  kindInt64,
+	"google.protobuf.UInt64Value": kindInt64,
+	"google.protobuf.Int32Value":  kindInt32,
+	"google.protobuf.UInt32Value": kindInt64,
+	"google.protobuf.BoolValue":   kindBool,
+	"google.protobuf.StringValue": kindString,
+	"google.protobuf.BytesValue":  kindBytes,
+	"google.type.Date":            kindDate,
+	"google.type.Decimal":         kindDecimal,
+}
+
+type protoFile struct {
+	syntax string
+	pkg    string
+	// messages and enums are keyed by their name within the package, like
+	// User.Address for nested ones
+	messages map[string]*protoMessage
+	enums    map[string]*protoEnum
+	// order has the names of the messages in declaration order
+	order []string
+}
+
+type protoMessage struct {
+	name    string
+	comment string
+	nested  bool
+	fields  []*protoField
+}
+
+type protoField struct {
+	name     string
+	typ      string
+	comment  string
+	label    string
+	isMap    bool
+	oneof    bool
+	defValue string
+}
+
+type protoEnum struct {
+	name   string
+	values []string
+}
+
+// Proto drafts a table for each message of the .proto file at path, for the
+// named dialect. Messages are picked by name, by default the top-level ones
+// besides requests and responses. Scalar fields are NOT NULL, as they're
+// never missing in proto3, unless marked optional or in a oneof. A field
+// named id is the primary key, tables without one get a generated id
+func Proto(path, dialect string, messages []string) (*schema.Schema, error) {
+	b, err := newBuilder(dialect)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	p := &protoParser{tokens: tokenizeProto(string(data)), file: &protoFile{
+		syntax:   "proto2",
+		messages: map[string]*protoMessage{},
+		enums:    map[string]*protoEnum{},
+	}}
+	if err := p.parseFile(); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	file := p.file
+
+	if len(messages) == 0 {
+		for _, name := range file.order {
+			message := file.messages[name]
+			if !message.nested && !strings.HasSuffix(name, "Request") && !strings.HasSuffix(name, "Response") {
+				messages = append(messages, name)
+			}
+		}
+		if len(messages) == 0 {
+			return nil, fmt.Errorf("no messages to scaffold tables from found in %s", path)
+		}
+	}
+	for _, name := range messages {
+		message, ok := file.messages[strings.TrimPrefix(name, file.pkg+".")]
+		if !ok {
+			return nil, fmt.Errorf("message %s not found in %s", name, path)
+		}
+		if err := b.addMessage(file, message); err != nil {
+			return nil, err
+		}
+	}
+	return b.schema, nil
+}
+
+func (b *builder) addMessage(file *protoFile, message *protoMessage) error {
+	table, err := b.addTable(tableName(strings.ReplaceAll(message.name, ".", "")))
+	if err != nil {
+		return err
+	}
+	table.Comment = b.comment(message.comment)
+	for _, field := range message.fields {
+		column := &schema.Column{Name: naming.SnakeCase(field.name), Comment: b.comment(field.comment)}
+		if table.Column(column.Name) != nil {
+			return fmt.Errorf("field %s of message %s is defined twice", field.name, message.name)
+		}
+		nullable := field.oneof || field.label == "optional"
+		if file.syntax == "proto2" {
+			nullable = field.label != "required"
+		}
+
+		kind, enum := file.resolve(message.name, field.typ)
+		switch {
+		case field.isMap:
+			column.Type = b.types[kindJSON]
+			nullable = true
+		case field.label == "repeated":
+			if enum != nil {
+				kind = kindString
+			}
+			column.Type = b.arrayType(kind)
+			nullable = true
+		case enum != nil:
+			column.Type = b.enumType(table, column.Name, naming.SnakeCase(strings.ReplaceAll(enum.name, ".", "")), enum.values)
+		default:
+			column.Type = b.columnType(kind, 0)
+			// Messages, the well-known ones included, can be unset
+			if _, scalar := protoScalars[field.typ]; !scalar {
+				nullable = true
+			}
+		}
+		column.NotNull = !nullable
+		if field.defValue != "" && kind != kindJSON {
+			column.Default = field.defValue
+		}
+		table.Columns = append(table.Columns, column)
+	}
+	b.addPrimaryKey(table)
+	return nil
+}
+
+// Returns the kind of a field type, and the enum it is if it's one. Names
+// are resolved from the innermost scope of the message out, and messages not
+// declared in the file are stored as JSON
+func (f *protoFile) resolve(scope, typ string) (string, *protoEnum) {
+	if kind, ok := protoScalars[typ]; ok {
+		return kind, nil
+	}
+	name := strings.TrimPrefix(typ, ".")
+	if kind, ok := protoWellKnown[name]; ok {
+		return kind, nil
+	}
+	if f.pkg != "" {
+		name = strings.TrimPrefix(name, f.pkg+".")
+	}
+	for {
+		candidate := name
+		if scope != "" && !strings.HasPrefix(typ, ".") {
+			candidate = scope + "." + name
+		}
+		if enum, ok := f.enums[candidate]; ok {
+			return kindString, enum
+		}
+		if _, ok := f.messages[candidate]; ok || scope == "" {
+			return kindJSON, nil
+		}
+		i := strings.LastIndex(scope, ".")
+		if i < 0 {
+			scope = ""
+		} else {
+			scope = scope[:i]
+		}
+	}
+}
+
+type protoToken struct {
+	text string
+	// comment is the comment on the lines right above the token
+	comment string
+}
+
+// Splits a .proto file into identifiers, numbers, strings and punctuation,
+// keeping the comments written above them
+func tokenizeProto(src string) []protoToken {
+	var tokens []protoToken
+	var comment []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			// A blank line detaches a comment from what follows
+			if lineStart := strings.LastIndexByte(src[:i], '\n') + 1; strings.TrimSpace(src[lineStart:i]) == "" {
+				comment = nil
+			}
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			text := strings.TrimSpace(src[i+2 : i+end])
+			// Comments after a token on the same line aren't about the next one
+			lineStart := strings.LastIndexByte(src[:i], '\n') + 1
+			if strings.TrimSpace(src[lineStart:i]) == "" {
+				comment = append(comment, text)
+			}
+			i += end
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += end + 4
+			}
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(src))
+			tokens = append(tokens, protoToken{text: src[i:j]})
+			i = j
+		case isProtoWordByte(c) || c == '-' || c == '+':
+			j := i + 1
+			for j < len(src) && isProtoWordByte(src[j]) {
+				j++
+			}
+			tokens = append(tokens, protoToken{text: src[i:j], comment: strings.Join(comment, " ")})
+			comment = nil
+			i = j
+		default:
+			tokens = append(tokens, protoToken{text: string(c)})
+			comment = nil
+			i++
+		}
+	}
+	return tokens
+}
+
+func isProtoWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.'
+}
+
+type protoParser struct {
+	tokens []protoToken
+	pos    int
+	file   *protoFile
+}
+
+func (p *protoParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+func (p *protoParser) next() (protoToken, error) {
+	if p.pos >= len(p.tokens) {
+		return protoToken{}, fmt.Errorf("unexpected end of file")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *protoParser) expect(text string) error {
+	token, err := p.next()
+	if err != nil {
+		return err
+	}
+	if token.text != text {
+		return fmt.Errorf("expected %q, found %q", text, token.text)
+	}
+	return nil
+}
+
+// Skips a statement up to its semicolon, or a block like a service's
+func (p *protoParser) skipStatement() error {
+	depth := 0
+	for {
+		token, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch token.text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth == 0 {
+				if p.peek() == ";" {
+					p.pos++
+				}
+				return nil
+			}
+		case ";":
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+func (p *protoParser) parseFile() error {
+	for p.pos < len(p.tokens) {
+		token, _ := p.next()
+		switch token.text {
+		case "syntax", "edition":
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			value, err := p.next()
+			if err != nil {
+				return err
+			}
+			p.file.syntax = strings.Trim(value.text, `"'`)
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case "package":
+			name, err := p.next()
+			if err != nil {
+				return err
+			}
+			p.file.pkg = name.text
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case "message":
+			if err := p.parseMessage("", token.comment); err != nil {
+				return err
+			}
+		case "enum":
+			if err := p.parseEnum(""); err != nil {
+				return err
+			}
+		case ";":
+		default:
+			// Imports, options, services and extensions
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *protoParser) parseMessage(scope, comment string) error {
+	name, err := p.next()
+	if err != nil {
+		return err
+	}
+	message := &protoMessage{name: name.text, comment: comment, nested: scope != ""}
+	if scope != "" {
+		message.name = scope + "." + name.text
+	}
+	p.file.messages[message.name] = message
+	p.file.order = append(p.file.order, message.name)
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	return p.parseMessageBody(message, false)
+}
+
+// Parses the fields of a message, or of a oneof in it, up to the closing
+// brace
+func (p *protoParser) parseMessageBody(message *protoMessage, oneof bool) error {
+	for {
+		token, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch token.text {
+		case "}":
+			return nil
+		case ";":
+		case "message":
+			if err := p.parseMessage(message.name, token.comment); err != nil {
+				return err
+			}
+		case "enum":
+			if err := p.parseEnum(message.name); err != nil {
+				return err
+			}
+		case "oneof":
+			if _, err := p.next(); err != nil {
+				return err
+			}
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			if err := p.parseMessageBody(message, true); err != nil {
+				return err
+			}
+		case "option", "reserved", "extensions", "extend":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		default:
+			field, err := p.parseField(token)
+			if err != nil {
+				return fmt.Errorf("message %s: %w", message.name, err)
+			}
+			field.oneof = oneof
+			message.fields = append(message.fields, field)
+		}
+	}
+}
+
+// Parses a field starting with first, its label or type, like
+// repeated string tags = 3 [deprecated = true];
+func (p *protoParser) parseField(first protoToken) (*protoField, error) {
+	field := &protoField{typ: first.text, comment: first.comment}
+	if slices.Contains([]string{"optional", "required", "repeated"}, first.text) {
+		field.label = first.text
+		typ, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		field.typ = typ.text
+	}
+	if field.typ == "map" {
+		field.isMap = true
+		for p.peek() != ">" && p.peek() != "" {
+			p.pos++
+		}
+		p.pos++
+	}
+	name, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	field.name = name.text
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	if _, err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.peek() == "[" {
+		if err := p.parseFieldOptions(field); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+// Parses the options in brackets after a field, keeping the default value
+// of proto2 fields
+func (p *protoParser) parseFieldOptions(field *protoField) error {
+	p.pos++
+	for {
+		token, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch token.text {
+		case "]":
+			return nil
+		case "default":
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			value, err := p.next()
+			if err != nil {
+				return err
+			}
+			field.defValue = protoDefault(value.text)
+		}
+	}
+}
+
+// Returns the SQL literal of a default value, empty for enum values
+func protoDefault(value string) string {
+	if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+		if s, err := strconv.Unquote(`"` + value[1:len(value)-1] + `"`); err == nil {
+			return quoteString(s)
+		}
+		return ""
+	}
+	if value == "true" || value == "false" {
+		return value
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	return ""
+}
+
+// Parses an enum, naming its values after the labels without the prefix
+// they repeat, e.g. active for STATUS_ACTIVE in enum Status
+func (p *protoParser) parseEnum(scope string) error {
+	name, err := p.next()
+	if err != nil {
+		return err
+	}
+	enum := &protoEnum{name: name.text}
+	if scope != "" {
+		enum.name = scope + "." + name.text
+	}
+	p.file.enums[enum.name] = enum
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	prefix := strings.ToUpper(naming.SnakeCase(name.text)) + "_"
+	for {
+		token, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch token.text {
+		case "}":
+			return nil
+		case ";":
+		case "option", "reserved":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		default:
+			enum.values = append(enum.values, strings.ToLower(strings.TrimPrefix(token.text, prefix)))
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		}
+	}
+}
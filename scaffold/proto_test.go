@@ -0,0 +1,258 @@
+package scaffold
+
+import (
+	"testing"
+
+	"styx/schema"
+)
+
+func TestProto(t *testing.T) {
+	proto := func(path, dialect string) (*schema.Schema, error) {
+		return Proto(path, dialect, nil)
+	}
+	testScaffold(t, "user.proto", proto, []scaffoldTest{
+		{
+			name: "numeric types",
+			source: `syntax = "proto3";
+
+package shop.v1;
+
+message Product {
+  string id = 1;
+  int32 stock = 2;
+  uint32 sold = 3;
+  sint64 views = 4;
+  fixed64 checksum = 5;
+  float weight = 6;
+  double price = 7;
+  bool active = 8;
+  bytes image = 9;
+}
+`,
+			want: `
+CREATE TABLE products (
+    id text NOT NULL,
+    stock integer NOT NULL,
+    sold bigint NOT NULL,
+    views bigint NOT NULL,
+    checksum bigint NOT NULL,
+    weight real NOT NULL,
+    price double precision NOT NULL,
+    active boolean NOT NULL,
+    image bytea NOT NULL,
+    CONSTRAINT products_pkey PRIMARY KEY (id)
+);
+`,
+		},
+		{
+			name: "nullable fields",
+			source: `syntax = "proto3";
+
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/wrappers.proto";
+
+// A person using the service
+message User {
+  // Unique address to reach them at
+  string email = 1;
+  optional string nickname = 2;
+  google.protobuf.Timestamp created_at = 3;
+  google.protobuf.Int64Value referrer_id = 4;
+  Address address = 5;
+  oneof contact {
+    string phone = 6;
+    string telegram = 7;
+  }
+
+  message Address {
+    string street = 1;
+  }
+}
+
+message GetUserRequest {
+  string email = 1;
+}
+`,
+			want: `
+CREATE TABLE users (
+    id bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    email text NOT NULL,
+    nickname text,
+    created_at timestamp with time zone,
+    referrer_id bigint,
+    address jsonb,
+    phone text,
+    telegram text,
+    CONSTRAINT users_pkey PRIMARY KEY (id)
+);
+COMMENT ON TABLE users IS 'A person using the service';
+COMMENT ON COLUMN users.email IS 'Unique address to reach them at';
+`,
+		},
+		{
+			name: "proto2 labels and defaults",
+			source: `syntax = "proto2";
+
+message Account {
+  required int64 id = 1;
+  optional string currency = 2 [default = "EUR"];
+  optional int32 limit = 3 [default = 100];
+  optional bool frozen = 4 [default = false];
+}
+`,
+			want: `
+CREATE TABLE accounts (
+    id bigint NOT NULL,
+    currency text DEFAULT 'EUR',
+    "limit" integer DEFAULT 100,
+    frozen boolean DEFAULT false,
+    CONSTRAINT accounts_pkey PRIMARY KEY (id)
+);
+`,
+		},
+		{
+			name: "enums",
+			source: `syntax = "proto3";
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_ACTIVE = 1;
+  STATUS_BANNED = 2;
+}
+
+message Order {
+  Status status = 1;
+  Kind kind = 2;
+  Status previous_status = 3;
+
+  enum Kind {
+    KIND_RETAIL = 0;
+    KIND_WHOLESALE = 1;
+  }
+}
+`,
+			want: `
+CREATE TYPE status AS ENUM ('unspecified', 'active', 'banned');
+CREATE TYPE order_kind AS ENUM ('retail', 'wholesale');
+CREATE TABLE orders (
+    id bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    status status NOT NULL,
+    kind order_kind NOT NULL,
+    previous_status status NOT NULL,
+    CONSTRAINT orders_pkey PRIMARY KEY (id)
+);
+`,
+		},
+		{
+			name:    "enums in MySQL",
+			dialect: "mysql",
+			source: `syntax = "proto3";
+
+enum Status {
+  STATUS_ACTIVE = 0;
+  STATUS_BANNED = 1;
+}
+
+message Order {
+  Status status = 1;
+}
+`,
+			want: "CREATE TABLE `orders` (\n" +
+				"    `id` bigint NOT NULL AUTO_INCREMENT,\n" +
+				"    `status` enum('active', 'banned') NOT NULL,\n" +
+				"    PRIMARY KEY (`id`)\n" +
+				");",
+		},
+		{
+			name:    "enums in SQLite",
+			dialect: "sqlite",
+			source: `syntax = "proto3";
+
+enum Status {
+  STATUS_ACTIVE = 0;
+  STATUS_BANNED = 1;
+}
+
+message Order {
+  Status status = 1;
+}
+`,
+			want: `
+CREATE TABLE orders (
+    id integer PRIMARY KEY AUTOINCREMENT NOT NULL,
+    status text NOT NULL,
+    CONSTRAINT orders_status_check CHECK (status IN ('active', 'banned'))
+);
+`,
+		},
+		{
+			name: "repeated and map fields",
+			source: `syntax = "proto3";
+
+enum Role {
+  ROLE_ADMIN = 0;
+  ROLE_MEMBER = 1;
+}
+
+message Team {
+  repeated string tags = 1;
+  repeated int64 member_ids = 2;
+  repeated Role roles = 3;
+  repeated Link links = 4;
+  map<string, string> labels = 5;
+}
+
+message Link {
+  string url = 1;
+}
+`,
+			want: `
+CREATE TABLE teams (
+    id bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    tags text[],
+    member_ids bigint[],
+    roles text[],
+    links jsonb,
+    labels jsonb,
+    CONSTRAINT teams_pkey PRIMARY KEY (id)
+);
+CREATE TABLE links (
+    id bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    url text NOT NULL,
+    CONSTRAINT links_pkey PRIMARY KEY (id)
+);
+`,
+		},
+		{
+			name:    "repeated fields in MySQL",
+			dialect: "mysql",
+			source: `syntax = "proto3";
+
+message Team {
+  repeated string tags = 1;
+}
+`,
+			want: "CREATE TABLE `teams` (\n" +
+				"    `id` bigint NOT NULL AUTO_INCREMENT,\n" +
+				"    `tags` json,\n" +
+				"    PRIMARY KEY (`id`)\n" +
+				");",
+		},
+		{
+			name:    "only requests and responses",
+			source:  "syntax = \"proto3\";\n\nmessage GetUserRequest {\n  string id = 1;\n}\n",
+			wantErr: "no messages to scaffold tables from found in",
+		},
+		{
+			name:    "unterminated message",
+			source:  "syntax = \"proto3\";\n\nmessage User {\n  string id = 1;\n",
+			wantErr: "failed to parse",
+		},
+		{
+			name:    "unknown dialect",
+			dialect: "oracle",
+			source:  "syntax = \"proto3\";\n\nmessage User {\n  string id = 1;\n}\n",
+			wantErr: "tables can't be scaffolded for the oracle dialect",
+		},
+	})
+}
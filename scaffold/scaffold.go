@@ -0,0 +1,170 @@
+// Package scaffold drafts tables from the message definitions of other
+// formats, Protocol Buffers and JSON Schema, as a starting point for the
+// schema of a service whose API is already written down. Types are mapped to
+// the closest column types of the dialect, values without one, like nested
+// messages and maps, are stored as JSON.
+package scaffold
+
+import (
+	"fmt"
+	"strings"
+
+	"styx/internal/naming"
+	"styx/schema"
+)
+
+// Kinds of values mapped to column types
+const (
+	kindBool     = "bool"
+	kindInt32    = "int32"
+	kindInt64    = "int64"
+	kindFloat    = "float"
+	kindDouble   = "double"
+	kindString   = "string"
+	kindBytes    = "bytes"
+	kindTime     = "time"
+	kindDate     = "date"
+	kindDuration = "duration"
+	kindUUID     = "uuid"
+	kindDecimal  = "decimal"
+	kindJSON     = "json"
+)
+
+// Column types of the kinds in each dialect. varchar is the type of strings
+// with a maximum length
+var columnTypes = map[string]map[string]string{
+	"postgres": {
+		kindBool: "boolean", kindInt32: "integer", kindInt64: "bigint", kindFloat: "real", kindDouble: "double precision",
+		kindString: "text", kindBytes: "bytea", kindTime: "timestamp with time zone", kindDate: "date",
+		kindDuration: "interval", kindUUID: "uuid", kindDecimal: "numeric", kindJSON: "jsonb", "varchar": "character varying(%d)",
+	},
+	"mysql": {
+		kindBool: "boolean", kindInt32: "int", kindInt64: "bigint", kindFloat: "float", kindDouble: "double",
+		kindString: "text", kindBytes: "blob", kindTime: "datetime(6)", kindDate: "date",
+		kindDuration: "time(6)", kindUUID: "char(36)", kindDecimal: "decimal(65,30)", kindJSON: "json", "varchar": "varchar(%d)",
+	},
+	"sqlite": {
+		kindBool: "boolean", kindInt32: "integer", kindInt64: "integer", kindFloat: "real", kindDouble: "real",
+		kindString: "text", kindBytes: "blob", kindTime: "datetime", kindDate: "date",
+		kindDuration: "text", kindUUID: "text", kindDecimal: "numeric", kindJSON: "text", "varchar": "varchar(%d)",
+	},
+}
+
+// builder collects the tables and enum types drafted from the definitions
+type builder struct {
+	dialect string
+	types   map[string]string
+	schema  *schema.Schema
+}
+
+func newBuilder(dialect string) (*builder, error) {
+	if dialect == "cockroachdb" || dialect == "cockroach" {
+		dialect = "postgres"
+	}
+	types, ok := columnTypes[dialect]
+	if !ok {
+		return nil, fmt.Errorf("tables can't be scaffolded for the %s dialect", dialect)
+	}
+	return &builder{dialect: dialect, types: types, schema: &schema.Schema{}}, nil
+}
+
+// Returns the table name of a message or object name, like users for User
+func tableName(name string) string {
+	return naming.Plural(naming.SnakeCase(name))
+}
+
+func (b *builder) addTable(name string) (*schema.Table, error) {
+	if b.schema.Table(name) != nil {
+		return nil, fmt.Errorf("table %s is defined twice", name)
+	}
+	table := &schema.Table{Name: name}
+	b.schema.Tables = append(b.schema.Tables, table)
+	return table, nil
+}
+
+// Returns the column type of kind, a varchar when maxLength is set on a
+// string
+func (b *builder) columnType(kind string, maxLength int) string {
+	if kind == kindString && maxLength > 0 {
+		return fmt.Sprintf(b.types["varchar"], maxLength)
+	}
+	return b.types[kind]
+}
+
+// Returns the type of arrays of kind. Only Postgres has array types, the
+// other dialects store the values as JSON
+func (b *builder) arrayType(kind string) string {
+	if b.dialect != "postgres" || kind == kindJSON {
+		return b.types[kindJSON]
+	}
+	return b.types[kind] + "[]"
+}
+
+// Returns the type of a column taking one of values. Postgres gets an enum
+// type named name, created once, MySQL an inline enum and SQLite a text
+// column with a check constraint
+func (b *builder) enumType(table *schema.Table, column, name string, values []string) string {
+	switch b.dialect {
+	case "postgres":
+		if b.schema.Enum(name) == nil {
+			b.schema.Enums = append(b.schema.Enums, &schema.Enum{Name: name, Values: values})
+		}
+		return name
+	case "mysql":
+		return "enum(" + quotedList(values) + ")"
+	}
+	table.Constraints = append(table.Constraints, &schema.Constraint{
+		Name:       table.Name + "_" + column + "_check",
+		Type:       schema.Check,
+		Expression: fmt.Sprintf("%s IN (%s)", schema.QuoteIdent(column), quotedList(values)),
+	})
+	return b.types[kindString]
+}
+
+// Makes the id column the primary key, adding a generated one to tables
+// without it
+func (b *builder) addPrimaryKey(table *schema.Table) {
+	column := table.Column("id")
+	if column == nil {
+		column = &schema.Column{Name: "id", Type: b.types[kindInt64]}
+		switch b.dialect {
+		case "postgres":
+			column.Identity = "BY DEFAULT"
+		case "mysql", "sqlite":
+			column.AutoIncrement = true
+		}
+		table.Columns = append([]*schema.Column{column}, table.Columns...)
+	}
+	// MySQL can't index text without a prefix length
+	if b.dialect == "mysql" && column.Type == b.types[kindString] {
+		column.Type = fmt.Sprintf(b.types["varchar"], 255)
+	}
+	column.NotNull = true
+	column.Default = ""
+	table.Constraints = append([]*schema.Constraint{{
+		Name:    table.Name + "_pkey",
+		Type:    schema.PrimaryKey,
+		Columns: []string{"id"},
+	}}, table.Constraints...)
+}
+
+// Returns the comment of a table or column, set with COMMENT ON, which only
+// Postgres has
+func (b *builder) comment(text string) string {
+	if b.dialect != "postgres" {
+		return ""
+	}
+	return text
+}
+
+func quotedList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = quoteString(value)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func quoteString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,63 @@
+package scaffold
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"styx/diff"
+	"styx/schema"
+)
+
+type scaffoldTest struct {
+	name    string
+	dialect string
+	// source is the file the definitions are read from
+	source string
+	// want is the SQL of the tables drafted, or wantErr the start of the error
+	want    string
+	wantErr string
+}
+
+var sqlDialects = map[string]diff.Dialect{
+	"postgres": diff.Postgres,
+	"mysql":    diff.MySQL,
+	"sqlite":   diff.SQLite,
+}
+
+// Drafts tables from each test's source, written to a file named file, with
+// read, and compares their SQL with the one it wants
+func testScaffold(t *testing.T, file string, read func(path, dialect string) (*schema.Schema, error), tests []scaffoldTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), file)
+			if err := os.WriteFile(path, []byte(tt.source), 0644); err != nil {
+				t.Fatal(err)
+			}
+			dialect := tt.dialect
+			if dialect == "" {
+				dialect = "postgres"
+			}
+			s, err := read(path, dialect)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sql []string
+			for _, change := range diff.Diff(&schema.Schema{}, s, diff.Options{Dialect: sqlDialects[dialect]}) {
+				sql = append(sql, change.SQL)
+			}
+			got := strings.Join(sql, "\n")
+			if want := strings.TrimSpace(tt.want); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
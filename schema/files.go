@@ -0,0 +1,215 @@
+package schema
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Names of the objects a file creates
+var createdObject = regexp.MustCompile(`(?is)\bcreate\s+(?:or\s+replace\s+)?(?:(?:unique|materialized|temp|temporary|unlogged|recursive)\s+)*(?:table|view|type|domain|sequence|function|procedure|schema|extension)\s+(?:if\s+not\s+exists\s+)?((?:"[^"]+"|[\w$]+)(?:\s*\.\s*(?:"[^"]+"|[\w$]+))?)`)
+
+// Names a file can refer to objects by, qualified or not
+var referencedName = regexp.MustCompile(`(?:"[^"]+"|[\w$]+)(?:\.(?:"[^"]+"|[\w$]+))*`)
+
+// Comments and string literals, left out when looking for references
+var commentOrString = regexp.MustCompile(`(?s)--[^\n]*|/\*.*?\*/|'(?:[^']|'')*'`)
+
+// ReadDir reads the .sql files under dir, in subdirectories too, as a single
+// schema. Files come after the ones creating the objects they refer to, so
+// foreign keys, indexes and views can be split from the tables they're on,
+// and otherwise in path order. Files referring to each other keep their path
+// order
+func ReadDir(dir string) (string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".sql" {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read schema directory %s: %w", dir, err)
+	}
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no .sql files found in %s", dir)
+	}
+	slices.Sort(paths)
+
+	contents := make([]string, len(paths))
+	creators := map[string][]int{}
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		contents[i] = string(data)
+		for _, match := range createdObject.FindAllStringSubmatch(stripCommentsAndStrings(contents[i]), -1) {
+			// Objects are referred to by their bare name too
+			parts := nameParts(match[1])
+			names := []string{strings.Join(parts, ".")}
+			if len(parts) > 1 {
+				names = append(names, parts[len(parts)-1])
+			}
+			for _, name := range names {
+				creators[name] = append(creators[name], i)
+			}
+		}
+	}
+
+	dependencies := make([]map[int]bool, len(paths))
+	for i, content := range contents {
+		dependencies[i] = map[int]bool{}
+		for _, ref := range referencedName.FindAllString(stripCommentsAndStrings(content), -1) {
+			// A qualified name refers to its schema too
+			parts := nameParts(ref)
+			names := append([]string{strings.Join(parts, ".")}, parts...)
+			for _, name := range names {
+				for _, creator := range creators[name] {
+					if creator != i {
+						dependencies[i][creator] = true
+					}
+				}
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, i := range dependencyOrder(dependencies) {
+		content := strings.TrimSpace(contents[i])
+		if content == "" {
+			continue
+		}
+		// A missing semicolon would merge the last statement with the next
+		// file's first
+		if !strings.HasSuffix(content, ";") {
+			content += ";"
+		}
+		rel, err := filepath.Rel(dir, paths[i])
+		if err != nil {
+			rel = paths[i]
+		}
+		fmt.Fprintf(&b, "-- %s\n%s\n\n", filepath.ToSlash(rel), content)
+	}
+	return b.String(), nil
+}
+
+// LocateInDir points the *SourceErrors in err, located in the SQL ReadDir
+// returned for dir, at the file of dir and the line they come from
+func LocateInDir(err error, dir, sql string) {
+	// Where the content of each file starts in the SQL, and how many lines
+	// and columns of it were trimmed
+	type file struct {
+		path          string
+		content       []string
+		line, skipped int
+		indent        int
+	}
+	var files []file
+	for offset := 0; strings.HasPrefix(sql[offset:], "-- "); {
+		end := strings.IndexByte(sql[offset:], '\n')
+		if end < 0 {
+			break
+		}
+		path := filepath.Join(dir, filepath.FromSlash(sql[offset+3:offset+end]))
+		data, readErr := os.ReadFile(path)
+		if readErr != nil {
+			break
+		}
+		offset += end + 1
+		original := string(data)
+		leading := original[:len(original)-len(strings.TrimLeftFunc(original, unicode.IsSpace))]
+		files = append(files, file{
+			path:    path,
+			content: strings.Split(original, "\n"),
+			line:    strings.Count(sql[:offset], "\n") + 1,
+			skipped: strings.Count(leading, "\n"),
+			indent:  utf8.RuneCountInString(leading[strings.LastIndexByte(leading, '\n')+1:]),
+		})
+		content := strings.TrimSpace(original)
+		if !strings.HasSuffix(content, ";") {
+			content += ";"
+		}
+		offset = min(offset+len(content)+2, len(sql))
+	}
+
+	for _, e := range SourceErrors(err) {
+		i := len(files) - 1
+		for i >= 0 && files[i].line > e.Line {
+			i--
+		}
+		if e.Line == 0 || i < 0 {
+			continue
+		}
+		f := files[i]
+		if e.Line == f.line {
+			e.Column += f.indent
+		}
+		e.File = f.path
+		e.Line += f.skipped - f.line + 1
+		if e.Line <= len(f.content) {
+			e.Source = strings.TrimRight(f.content[e.Line-1], "\r")
+		}
+	}
+}
+
+func stripCommentsAndStrings(sql string) string {
+	return commentOrString.ReplaceAllString(sql, " ")
+}
+
+// Splits a possibly qualified name, folding the parts that aren't quoted to
+// lower case
+func nameParts(name string) []string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, `"`) {
+			parts[i] = strings.Trim(part, `"`)
+		} else {
+			parts[i] = strings.ToLower(part)
+		}
+	}
+	return parts
+}
+
+// Orders the files so each comes after the ones it depends on, taking the
+// first in path order whenever several are ready. When the rest depend on
+// each other, the first of them goes next
+func dependencyOrder(dependencies []map[int]bool) []int {
+	done := make([]bool, len(dependencies))
+	order := make([]int, 0, len(dependencies))
+	for len(order) < len(dependencies) {
+		next := -1
+		for i, deps := range dependencies {
+			if done[i] {
+				continue
+			}
+			if next == -1 {
+				next = i
+			}
+			ready := true
+			for dep := range deps {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		done[next] = true
+		order = append(order, next)
+	}
+	return order
+}
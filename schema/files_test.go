@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocateInDir(t *testing.T) {
+	files := map[string]string{
+		"tables/a.sql": "\n\nCREATE TABLE a (id int PRIMARY KEY);\n",
+		"tables/b.sql": "\n  CREATE TABLE a (id int);\n",
+		"views.sql":    "CREATE VIEW v AS SELECT id FROM a;\nCREATE TABLE c (\n  id int,\n  a_id int REFERENCES a (nope)\n)",
+	}
+	tests := []struct {
+		name         string
+		remove       string
+		file         string
+		line, column int
+	}{
+		{"error in a later file", "tables/b.sql", "views.sql", 4, 12},
+		{"error on a trimmed first line", "", "tables/b.sql", 2, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range files {
+				if name == tt.remove {
+					continue
+				}
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			sql, err := ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s, err := Parse(sql)
+			if err == nil {
+				err = s.Validate(nil)
+			}
+			LocateInDir(err, dir, sql)
+			errs := SourceErrors(err)
+			if len(errs) != 1 {
+				t.Fatalf("got %v, want one *SourceError", err)
+			}
+			got := errs[0]
+			if got.File != filepath.Join(dir, filepath.FromSlash(tt.file)) || got.Line != tt.line || got.Column != tt.column {
+				t.Errorf("got %s:%d:%d, want %s:%d:%d", got.File, got.Line, got.Column, tt.file, tt.line, tt.column)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Objects outside the public schema are named "schema.name" in the model
+const defaultSchema = "public"
+
+// QualifiedName returns the model name of an object in the given Postgres
+// schema: its bare name in the public schema, otherwise "schema.name"
+func QualifiedName(schemaName, name string) string {
+	if schemaName == "" || schemaName == defaultSchema {
+		return name
+	}
+	return schemaName + "." + name
+}
+
+// SplitName splits a model name into its Postgres schema and bare name
+func SplitName(name string) (schemaName, object string) {
+	if i := strings.Index(name, "."); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return defaultSchema, name
+}
+
+// Postgres truncates identifiers to NAMEDATALEN - 1 bytes
+const maxIdentifierLength = 63
+
+// Builds an object name the same way Postgres' makeObjectName() does: the
+// two name parts are truncated (longest first) so the result, including the
+// label, fits in an identifier
+func makeObjectName(name1, name2, label string) string {
+	overhead := 0
+	if label != "" {
+		overhead += len(label) + 1
+	}
+	if name2 != "" {
+		overhead++
+	}
+
+	available := maxIdentifierLength - overhead
+	n1, n2 := len(name1), len(name2)
+	for n1+n2 > available {
+		if n1 > n2 {
+			n1--
+		} else {
+			n2--
+		}
+	}
+
+	name := name1[:n1]
+	if name2 != "" {
+		name += "_" + name2[:n2]
+	}
+	if label != "" {
+		name += "_" + label
+	}
+
+	return name
+}
+
+// Picks the first name not already in used, appending a counter to the label
+// on collisions like ChooseConstraintName() does. The chosen name is added to used
+func chooseName(used map[string]bool, name1, name2, label string) string {
+	name := makeObjectName(name1, name2, label)
+	for pass := 1; used[name]; pass++ {
+		name = makeObjectName(name1, name2, fmt.Sprintf("%s%d", label, pass))
+	}
+	used[name] = true
+
+	return name
+}
+
+// Joins column names for use in a generated name, like ChooseIndexNameAddition()
+func nameAddition(columns []string) string {
+	var b strings.Builder
+	for _, column := range columns {
+		if b.Len() > 0 {
+			b.WriteString("_")
+		}
+		b.WriteString(column)
+		if b.Len() >= maxIdentifierLength {
+			break
+		}
+	}
+
+	return b.String()
+}
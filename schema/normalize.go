@@ -0,0 +1,156 @@
+package schema
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var number = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// NormalizeQuery rewrites a query into a form that's only meant for
+// comparisons. Postgres stores queries in a different shape than they were
+// written: pg_get_viewdef() qualifies column names with their table, adds
+// casts to literals and columns, and expands shorthands like IN and BETWEEN.
+// All of these are undone so equivalent queries compare equal. Casts of
+// literals and columns are dropped even when they were written by hand, so
+// changing only such a cast goes unnoticed. Likewise, CURRENT_TIMESTAMP and
+// now() compare equal.
+func NormalizeQuery(query string) string {
+	tree, err := pg_query.Parse(query)
+	if err != nil {
+		return query
+	}
+
+	walk(tree.ProtoReflect(), func(m protoreflect.Message) {
+		switch n := m.Interface().(type) {
+		case *pg_query.ColumnRef:
+			n.Fields = n.Fields[len(n.Fields)-1:]
+		case *pg_query.Node:
+			// Unwrap casts, repeatedly for nested casts
+			for {
+				cast := n.GetTypeCast()
+				if cast == nil || cast.Arg.GetAConst() == nil && cast.Arg.GetColumnRef() == nil && cast.Arg.GetAArrayExpr() == nil {
+					break
+				}
+				n.Node = cast.Arg.Node
+			}
+			expandShorthand(n)
+			canonicalize(n)
+		}
+	})
+
+	normalized, err := pg_query.Deparse(tree)
+	if err != nil {
+		return query
+	}
+	return normalized
+}
+
+// NormalizeExpr rewrites an expression, like a column default, into a form
+// that's only meant for comparisons, the same way NormalizeQuery does.
+// pg_get_expr() wraps expressions in parentheses and casts literals to the
+// column's type, which would make them differ from the ones in schema.sql
+func NormalizeExpr(expr string) string {
+	normalized, ok := strings.CutPrefix(NormalizeQuery("SELECT "+expr), "SELECT ")
+	if !ok {
+		return expr
+	}
+	return normalized
+}
+
+// NormalizeConstraint rewrites a constraint definition, like the one of an
+// exclusion constraint, into a form that's only meant for comparisons, the
+// same way NormalizeQuery does
+func NormalizeConstraint(definition string) string {
+	const prefix = "ALTER TABLE t ADD "
+	normalized, ok := strings.CutPrefix(NormalizeQuery(prefix+definition), prefix)
+	if !ok {
+		return definition
+	}
+	return normalized
+}
+
+// Rewrites an operator into the form Postgres stores it in: IN lists become
+// = ANY (ARRAY[...]), LIKE becomes the ~~ operator, and BETWEEN becomes a
+// pair of comparisons
+func expandShorthand(n *pg_query.Node) {
+	expr := n.GetAExpr()
+	if expr == nil {
+		return
+	}
+
+	switch expr.Kind {
+	case pg_query.A_Expr_Kind_AEXPR_IN:
+		list := expr.Rexpr.GetList()
+		if list == nil {
+			return
+		}
+		expr.Kind = pg_query.A_Expr_Kind_AEXPR_OP_ANY
+		if operatorName(expr) == "<>" {
+			expr.Kind = pg_query.A_Expr_Kind_AEXPR_OP_ALL
+		}
+		expr.Rexpr = &pg_query.Node{Node: &pg_query.Node_AArrayExpr{AArrayExpr: &pg_query.A_ArrayExpr{Elements: list.Items}}}
+	case pg_query.A_Expr_Kind_AEXPR_LIKE, pg_query.A_Expr_Kind_AEXPR_ILIKE:
+		expr.Kind = pg_query.A_Expr_Kind_AEXPR_OP
+	case pg_query.A_Expr_Kind_AEXPR_BETWEEN, pg_query.A_Expr_Kind_AEXPR_NOT_BETWEEN:
+		bounds := expr.Rexpr.GetList().GetItems()
+		if len(bounds) != 2 {
+			return
+		}
+		low, high, op := ">=", "<=", pg_query.BoolExprType_AND_EXPR
+		if expr.Kind == pg_query.A_Expr_Kind_AEXPR_NOT_BETWEEN {
+			low, high, op = "<", ">", pg_query.BoolExprType_OR_EXPR
+		}
+		n.Node = &pg_query.Node_BoolExpr{BoolExpr: &pg_query.BoolExpr{
+			Boolop: op,
+			Args: []*pg_query.Node{
+				pg_query.MakeAExprNode(pg_query.A_Expr_Kind_AEXPR_OP, []*pg_query.Node{pg_query.MakeStrNode(low)}, expr.Lexpr, bounds[0], 0),
+				pg_query.MakeAExprNode(pg_query.A_Expr_Kind_AEXPR_OP, []*pg_query.Node{pg_query.MakeStrNode(high)}, expr.Lexpr, bounds[1], 0),
+			},
+		}}
+	}
+}
+
+// Rewrites equivalent spellings into one: CURRENT_TIMESTAMP and
+// transaction_timestamp() become now(), and numbers in quotes, like the
+// '-1'::integer pg_get_expr() renders negative numbers as once the cast is
+// dropped, become plain numbers
+func canonicalize(n *pg_query.Node) {
+	now := &pg_query.Node_FuncCall{FuncCall: &pg_query.FuncCall{
+		Funcname:   []*pg_query.Node{pg_query.MakeStrNode("now")},
+		Funcformat: pg_query.CoercionForm_COERCE_EXPLICIT_CALL,
+	}}
+	if f := n.GetSqlvalueFunction(); f != nil && f.Op == pg_query.SQLValueFunctionOp_SVFOP_CURRENT_TIMESTAMP {
+		n.Node = now
+		return
+	}
+	if call := n.GetFuncCall(); call != nil && len(call.Args) == 0 && len(call.Funcname) > 0 {
+		name := call.Funcname[len(call.Funcname)-1].GetString_().GetSval()
+		if name == "transaction_timestamp" || name == "now" {
+			n.Node = now
+		}
+		return
+	}
+
+	c := n.GetAConst()
+	if c == nil || c.GetSval() == nil || !number.MatchString(c.GetSval().Sval) {
+		return
+	}
+	value := c.GetSval().Sval
+	if i, err := strconv.ParseInt(value, 10, 32); err == nil {
+		c.Val = &pg_query.A_Const_Ival{Ival: &pg_query.Integer{Ival: int32(i)}}
+	} else {
+		c.Val = &pg_query.A_Const_Fval{Fval: &pg_query.Float{Fval: value}}
+	}
+}
+
+func operatorName(expr *pg_query.A_Expr) string {
+	if len(expr.Name) == 0 {
+		return ""
+	}
+	return expr.Name[len(expr.Name)-1].GetString_().GetSval()
+}
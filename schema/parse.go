@@ -0,0 +1,657 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+	pg_query_parser "github.com/pganalyze/pg_query_go/v6/parser"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Maps the internal names used by the parser to the names rendered by
+// format_type(), so parsed types compare equal to introspected ones
+var typeNames = map[string]string{
+	"bool":        "boolean",
+	"int2":        "smallint",
+	"int4":        "integer",
+	"int8":        "bigint",
+	"float4":      "real",
+	"float8":      "double precision",
+	"varchar":     "character varying",
+	"bpchar":      "character",
+	"varbit":      "bit varying",
+	"timestamp":   "timestamp without time zone",
+	"timestamptz": "timestamp with time zone",
+	"time":        "time without time zone",
+	"timetz":      "time with time zone",
+}
+
+// Built-in types whose internal array names, like _text, schema.sql may use,
+// besides the ones of typeNames
+var arrayElementTypes = []string{
+	"text", "name", "numeric", "money", "uuid", "json", "jsonb", "xml", "bytea",
+	"date", "interval", "inet", "cidr", "macaddr", "macaddr8", "bit", "oid",
+	"tsvector", "tsquery", "point", "box", "int4range", "int8range", "numrange",
+	"tsrange", "tstzrange", "daterange",
+}
+
+// Renders the interval fields by their bitmask, made of the bits of year
+// (4), month (2), day (8), hour (1024), minute (2048) and second (4096). All
+// of them, the full range, render as nothing
+var intervalFields = map[int32]string{
+	4:                      " year",
+	2:                      " month",
+	8:                      " day",
+	1024:                   " hour",
+	2048:                   " minute",
+	4096:                   " second",
+	4 | 2:                  " year to month",
+	8 | 1024:               " day to hour",
+	8 | 1024 | 2048:        " day to minute",
+	8 | 1024 | 2048 | 4096: " day to second",
+	1024 | 2048:            " hour to minute",
+	1024 | 2048 | 4096:     " hour to second",
+	2048 | 4096:            " minute to second",
+}
+
+// Serial types are shorthands for an integer column backed by a sequence
+var serialTypes = map[string]string{
+	"smallserial": "smallint",
+	"serial2":     "smallint",
+	"serial":      "integer",
+	"serial4":     "integer",
+	"bigserial":   "bigint",
+	"serial8":     "bigint",
+}
+
+// ParseFile reads and parses a schema.sql file
+func ParseFile(path string) (*Schema, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	s, err := Parse(string(content))
+	var sourceErr *SourceError
+	if errors.As(err, &sourceErr) {
+		sourceErr.File = path
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.source.file = path
+	return s, nil
+}
+
+// Parse builds a schema model from a set of SQL DDL statements. Errors are
+// *SourceErrors locating the statement they're about
+func Parse(sql string) (*Schema, error) {
+	src := &source{sql: sql, locations: map[any]int{}}
+	tree, err := pg_query.Parse(sql)
+	if err != nil {
+		// Syntax errors point at the character they're at, counting from 1
+		var syntaxErr *pg_query_parser.Error
+		if errors.As(err, &syntaxErr) && syntaxErr.Cursorpos > 0 {
+			offset := 0
+			for i := 1; i < syntaxErr.Cursorpos && offset < len(sql); i++ {
+				_, size := utf8.DecodeRuneInString(sql[offset:])
+				offset += size
+			}
+			return nil, src.errorAt(offset, err)
+		}
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	p := &parser{
+		schema:      &Schema{source: src},
+		relations:   map[string]map[string]bool{},
+		constraints: map[string]map[string]bool{},
+	}
+	for _, raw := range tree.Stmts {
+		var err error
+		switch stmt := raw.Stmt.Node.(type) {
+		case *pg_query.Node_CreateSchemaStmt:
+			err = p.createSchema(stmt.CreateSchemaStmt)
+		case *pg_query.Node_CreateExtensionStmt:
+			err = p.createExtension(stmt.CreateExtensionStmt)
+		case *pg_query.Node_CreateStmt:
+			err = p.createTable(stmt.CreateStmt)
+		case *pg_query.Node_AlterTableStmt:
+			err = p.alterTable(stmt.AlterTableStmt)
+		case *pg_query.Node_IndexStmt:
+			err = p.createIndex(stmt.IndexStmt)
+		case *pg_query.Node_ViewStmt:
+			err = p.createView(stmt.ViewStmt)
+		case *pg_query.Node_CreateTableAsStmt:
+			err = p.createMaterializedView(stmt.CreateTableAsStmt)
+		case *pg_query.Node_CreateEnumStmt:
+			err = p.createEnum(stmt.CreateEnumStmt)
+		case *pg_query.Node_AlterEnumStmt:
+			err = p.alterEnum(stmt.AlterEnumStmt)
+		case *pg_query.Node_CompositeTypeStmt:
+			err = p.createCompositeType(stmt.CompositeTypeStmt)
+		case *pg_query.Node_CreateDomainStmt:
+			err = p.createDomain(stmt.CreateDomainStmt)
+		case *pg_query.Node_AlterDomainStmt:
+			err = p.alterDomain(stmt.AlterDomainStmt)
+		case *pg_query.Node_CreateSeqStmt:
+			err = p.createSequence(stmt.CreateSeqStmt)
+		case *pg_query.Node_AlterSeqStmt:
+			err = p.alterSequence(stmt.AlterSeqStmt)
+		case *pg_query.Node_CreateFunctionStmt:
+			err = p.createFunction(stmt.CreateFunctionStmt)
+		case *pg_query.Node_CreatePolicyStmt:
+			err = p.createPolicy(stmt.CreatePolicyStmt)
+		case *pg_query.Node_GrantStmt:
+			err = p.grant(stmt.GrantStmt)
+		case *pg_query.Node_CommentStmt:
+			err = p.comment(stmt.CommentStmt)
+		case *pg_query.Node_CreateTrigStmt:
+			err = p.createTrigger(stmt.CreateTrigStmt)
+		case *pg_query.Node_CreateForeignServerStmt:
+			err = p.createServer(stmt.CreateForeignServerStmt)
+		case *pg_query.Node_CreateUserMappingStmt:
+			err = p.createUserMapping(stmt.CreateUserMappingStmt)
+		case *pg_query.Node_CreateForeignTableStmt:
+			err = p.createForeignTable(stmt.CreateForeignTableStmt)
+		case *pg_query.Node_CreatePublicationStmt:
+			err = p.createPublication(stmt.CreatePublicationStmt)
+		default:
+			err = fmt.Errorf("unsupported statement: %s", nodeName(raw.Stmt))
+		}
+		if err != nil {
+			return nil, src.errorAt(statementStart(sql, int(raw.StmtLocation)), err)
+		}
+	}
+
+	// Foreign keys without a column list reference the primary key
+	for _, table := range p.schema.Tables {
+		for _, c := range table.Constraints {
+			if c.Type != ForeignKey || len(c.RefColumns) > 0 {
+				continue
+			}
+			if ref := p.schema.Table(c.RefTable); ref != nil && ref.PrimaryKey() != nil {
+				c.RefColumns = ref.PrimaryKey().Columns
+			}
+		}
+	}
+
+	return p.schema, nil
+}
+
+type parser struct {
+	schema *Schema
+	// Tables, indexes and sequences share a namespace, so generated index
+	// names have to avoid all of them. Relation names are kept per schema
+	relations map[string]map[string]bool
+	// Constraint names in use, per table
+	constraints map[string]map[string]bool
+}
+
+// Records where an object was declared, from the location of a node of its
+// statement. Nodes the parser made up have none
+func (p *parser) locate(o any, location int32) {
+	if location >= 0 {
+		p.schema.source.locations[o] = int(location)
+	}
+}
+
+// Returns the relation names in use in the schema of the named object,
+// along with its bare name
+func (p *parser) namespace(name string) (map[string]bool, string) {
+	schemaName, object := SplitName(name)
+	if p.relations[schemaName] == nil {
+		p.relations[schemaName] = map[string]bool{}
+	}
+	return p.relations[schemaName], object
+}
+
+// Returns the model name of a relation referenced by a statement
+func relationName(relation *pg_query.RangeVar) string {
+	return QualifiedName(relation.Schemaname, relation.Relname)
+}
+
+// Looks up a table referenced by a statement
+func (p *parser) table(relation *pg_query.RangeVar) (*Table, error) {
+	table := p.schema.Table(relationName(relation))
+	if table == nil {
+		return nil, fmt.Errorf("table %s does not exist", relationName(relation))
+	}
+	return table, nil
+}
+
+func (p *parser) createSchema(stmt *pg_query.CreateSchemaStmt) error {
+	if len(stmt.SchemaElts) > 0 {
+		return fmt.Errorf("schema %s: elements in CREATE SCHEMA are not supported, create them separately", stmt.Schemaname)
+	}
+	if stmt.Schemaname == defaultSchema || slices.Contains(p.schema.Schemas, stmt.Schemaname) {
+		if stmt.IfNotExists {
+			return nil
+		}
+		return fmt.Errorf("schema %s already exists", stmt.Schemaname)
+	}
+
+	p.schema.Schemas = append(p.schema.Schemas, stmt.Schemaname)
+	return nil
+}
+
+func (p *parser) createTable(stmt *pg_query.CreateStmt) error {
+	table := &Table{Name: relationName(stmt.Relation)}
+	// Temporary tables only last for the session creating them
+	if stmt.Relation.Relpersistence == "t" {
+		return fmt.Errorf("table %s: temporary tables aren't part of a schema", table.Name)
+	}
+	table.Unlogged = stmt.Relation.Relpersistence == "u"
+	relations, name := p.namespace(table.Name)
+	if relations[name] {
+		return fmt.Errorf("table %s is defined more than once", table.Name)
+	}
+	relations[name] = true
+	p.constraints[table.Name] = map[string]bool{}
+	p.locate(table, stmt.Relation.Location)
+
+	// Constraints are named once all columns are known, in the same order
+	// Postgres does: column constraints first, then table constraints
+	var constraints []pendingConstraint
+	for _, elt := range stmt.TableElts {
+		switch n := elt.Node.(type) {
+		case *pg_query.Node_ColumnDef:
+			pending, err := p.addColumn(table, n.ColumnDef)
+			if err != nil {
+				return fmt.Errorf("table %s: %w", table.Name, err)
+			}
+			constraints = append(constraints, pending...)
+		case *pg_query.Node_Constraint:
+			constraints = append(constraints, pendingConstraint{n.Constraint, ""})
+		default:
+			return fmt.Errorf("table %s: unsupported table element: %s", table.Name, nodeName(elt))
+		}
+	}
+
+	if err := p.addConstraints(table, constraints); err != nil {
+		return fmt.Errorf("table %s: %w", table.Name, err)
+	}
+
+	options, err := setOptions(nil, stmt.Options)
+	if err != nil {
+		return fmt.Errorf("table %s: %w", table.Name, err)
+	}
+	table.Options = options
+	table.Tablespace = tablespace(stmt.Tablespacename)
+
+	p.schema.Tables = append(p.schema.Tables, table)
+	return nil
+}
+
+// A constraint waiting to be converted. For column constraints, column is
+// the name of the column the constraint was declared on
+type pendingConstraint struct {
+	constraint *pg_query.Constraint
+	column     string
+}
+
+// Adds a column definition to the table, returning its constraints
+func (p *parser) addColumn(table *Table, def *pg_query.ColumnDef) ([]pendingConstraint, error) {
+	if table.Column(def.Colname) != nil {
+		return nil, fmt.Errorf("column %s specified more than once", def.Colname)
+	}
+
+	column, err := parseColumn(def)
+	if err != nil {
+		return nil, err
+	}
+	table.Columns = append(table.Columns, column)
+	p.locate(column, def.TypeName.Location)
+
+	// Serial columns get a sequence owned by the column
+	if _, ok := serialTypes[formatType(def.TypeName)]; ok {
+		relations, tableName := p.namespace(table.Name)
+		schemaName, _ := SplitName(table.Name)
+		seq := newSequence(QualifiedName(schemaName, chooseName(relations, tableName, column.Name, "seq")), column.Type)
+		seq.OwnedBy = table.Name + "." + column.Name
+		p.schema.Sequences = append(p.schema.Sequences, seq)
+		column.Default = fmt.Sprintf("nextval(%s::regclass)", QuoteLiteral(QuoteName(seq.Name)))
+	}
+
+	var constraints []pendingConstraint
+	for _, node := range def.Constraints {
+		switch node.GetConstraint().Contype {
+		case pg_query.ConstrType_CONSTR_PRIMARY, pg_query.ConstrType_CONSTR_UNIQUE,
+			pg_query.ConstrType_CONSTR_CHECK, pg_query.ConstrType_CONSTR_FOREIGN:
+			constraints = append(constraints, pendingConstraint{node.GetConstraint(), column.Name})
+		}
+	}
+
+	return constraints, nil
+}
+
+func (p *parser) addConstraints(table *Table, constraints []pendingConstraint) error {
+	for _, c := range constraints {
+		constraint, err := p.constraint(table, c.constraint, c.column)
+		if err != nil {
+			return err
+		}
+		if table.Constraint(constraint.Name) != nil {
+			return fmt.Errorf("constraint %s already exists", constraint.Name)
+		}
+		table.Constraints = append(table.Constraints, constraint)
+	}
+	return nil
+}
+
+// Converts a parsed constraint, naming it if needed
+func (p *parser) constraint(table *Table, c *pg_query.Constraint, column string) (*Constraint, error) {
+	constraint := &Constraint{
+		Name:              c.Conname,
+		Deferrable:        c.Deferrable,
+		InitiallyDeferred: c.Initdeferred,
+	}
+	p.locate(constraint, c.Location)
+
+	columns := stringList(c.Keys)
+	if c.Contype == pg_query.ConstrType_CONSTR_FOREIGN {
+		columns = stringList(c.FkAttrs)
+	}
+	if len(columns) == 0 && column != "" {
+		columns = []string{column}
+	}
+
+	switch c.Contype {
+	case pg_query.ConstrType_CONSTR_PRIMARY:
+		constraint.Type = PrimaryKey
+		constraint.Columns = columns
+		// Primary key columns are implicitly NOT NULL
+		for _, name := range columns {
+			if col := table.Column(name); col != nil {
+				col.NotNull = true
+			}
+		}
+	case pg_query.ConstrType_CONSTR_UNIQUE:
+		constraint.Type = Unique
+		constraint.Columns = columns
+	case pg_query.ConstrType_CONSTR_FOREIGN:
+		constraint.Type = ForeignKey
+		constraint.Columns = columns
+		constraint.RefTable = relationName(c.Pktable)
+		constraint.RefColumns = stringList(c.PkAttrs)
+		constraint.OnUpdate = foreignKeyAction(c.FkUpdAction)
+		constraint.OnDelete = foreignKeyAction(c.FkDelAction)
+		switch c.FkMatchtype {
+		case "f":
+			constraint.Match = "FULL"
+		case "p":
+			constraint.Match = "PARTIAL"
+		}
+	case pg_query.ConstrType_CONSTR_CHECK:
+		expr, err := deparseExpr(c.RawExpr)
+		if err != nil {
+			return nil, err
+		}
+		constraint.Type = Check
+		constraint.Expression = expr
+	case pg_query.ConstrType_CONSTR_EXCLUSION:
+		def, err := exclusionDefinition(c)
+		if err != nil {
+			return nil, err
+		}
+		constraint.Type = Exclusion
+		constraint.Definition = def
+		for _, node := range exclusionElements(c) {
+			if name := node.GetIndexElem().Name; name != "" {
+				constraint.Columns = append(constraint.Columns, name)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported constraint type %s", c.Contype)
+	}
+
+	used := p.constraints[table.Name]
+	relations, tableName := p.namespace(table.Name)
+	if constraint.Name != "" {
+		used[constraint.Name] = true
+		if constraint.Type == PrimaryKey || constraint.Type == Unique || constraint.Type == Exclusion {
+			relations[constraint.Name] = true
+		}
+		return constraint, nil
+	}
+
+	switch constraint.Type {
+	case PrimaryKey:
+		constraint.Name = chooseName(relations, tableName, "", "pkey")
+		used[constraint.Name] = true
+	case Unique:
+		constraint.Name = chooseName(relations, tableName, nameAddition(columns), "key")
+		used[constraint.Name] = true
+	case Exclusion:
+		constraint.Name = chooseName(relations, tableName, nameAddition(indexColumnNames(exclusionElements(c))), "excl")
+		used[constraint.Name] = true
+	case ForeignKey:
+		constraint.Name = chooseName(used, tableName, nameAddition(columns), "fkey")
+	case Check:
+		// Postgres names check constraints after the column they reference,
+		// but only if they reference exactly one
+		refs := columnRefs(c.RawExpr)
+		name := ""
+		if len(refs) == 1 {
+			name = refs[0]
+		}
+		constraint.Name = chooseName(used, tableName, name, "check")
+	}
+
+	return constraint, nil
+}
+
+func parseColumn(def *pg_query.ColumnDef) (*Column, error) {
+	column := &Column{
+		Name:    def.Colname,
+		Type:    formatType(def.TypeName),
+		NotNull: def.IsNotNull,
+	}
+
+	if base, ok := serialTypes[column.Type]; ok {
+		column.Type = base
+		column.NotNull = true
+	}
+
+	for _, node := range def.Constraints {
+		constraint := node.GetConstraint()
+		switch constraint.Contype {
+		case pg_query.ConstrType_CONSTR_NOTNULL, pg_query.ConstrType_CONSTR_PRIMARY:
+			column.NotNull = true
+		case pg_query.ConstrType_CONSTR_NULL:
+			column.NotNull = false
+		case pg_query.ConstrType_CONSTR_DEFAULT:
+			expr, err := deparseDefault(constraint.RawExpr)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", def.Colname, err)
+			}
+			column.Default = expr
+		case pg_query.ConstrType_CONSTR_GENERATED:
+			expr, err := deparseExpr(constraint.RawExpr)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", def.Colname, err)
+			}
+			column.Generated = expr
+		case pg_query.ConstrType_CONSTR_IDENTITY:
+			column.NotNull = true
+			if constraint.GeneratedWhen == "a" {
+				column.Identity = "ALWAYS"
+			} else {
+				column.Identity = "BY DEFAULT"
+			}
+		}
+	}
+
+	return column, nil
+}
+
+func foreignKeyAction(action string) string {
+	switch action {
+	case "r":
+		return "RESTRICT"
+	case "c":
+		return "CASCADE"
+	case "n":
+		return "SET NULL"
+	case "d":
+		return "SET DEFAULT"
+	}
+	return ""
+}
+
+// Renders a parsed type name the same way format_type() does
+func formatType(typeName *pg_query.TypeName) string {
+	var names []string
+	for _, n := range typeName.Names {
+		names = append(names, n.GetString_().Sval)
+	}
+	if len(names) > 1 && (names[0] == "pg_catalog" || names[0] == "public") {
+		names = names[1:]
+	}
+
+	// Arrays can be written with the internal name of their type too, like
+	// _int4 for integer[]
+	arrays := len(typeName.ArrayBounds)
+	if len(names) == 1 && arrays == 0 {
+		if element, ok := strings.CutPrefix(names[0], "_"); ok && (typeNames[element] != "" || slices.Contains(arrayElementTypes, element)) {
+			names[0], arrays = element, 1
+		}
+	}
+
+	var name string
+	if canonical, ok := typeNames[strings.Join(names, ".")]; ok {
+		name = canonical
+	} else {
+		// format_type() quotes the names of user types that need it, like
+		// "Mood"
+		for i, n := range names {
+			names[i] = QuoteIdent(n)
+		}
+		name = strings.Join(names, ".")
+	}
+
+	var mods []int32
+	for _, m := range typeName.Typmods {
+		if c := m.GetAConst(); c != nil && c.GetIval() != nil {
+			mods = append(mods, c.GetIval().Ival)
+		}
+	}
+	// numeric(p) is short for numeric(p,0)
+	if name == "numeric" && len(mods) == 1 {
+		mods = append(mods, 0)
+	}
+	if name == "interval" {
+		name += intervalModifier(mods)
+	} else if len(mods) > 0 {
+		var values []string
+		for _, m := range mods {
+			values = append(values, fmt.Sprint(m))
+		}
+		modifier := "(" + strings.Join(values, ",") + ")"
+		// Precision goes between the name and the time zone qualifier
+		if before, after, found := strings.Cut(name, " with"); found && strings.HasPrefix(name, "time") {
+			name = before + modifier + " with" + after
+		} else {
+			name += modifier
+		}
+	}
+
+	// Postgres doesn't enforce the number of dimensions, or their size, and
+	// format_type() renders any array with a single pair of brackets
+	if arrays > 0 {
+		name += "[]"
+	}
+
+	return name
+}
+
+// Renders the fields and precision of an interval type, which the parser
+// gives as a bitmask of the fields followed by the precision, the way
+// format_type() does, e.g. " day to second(3)"
+func intervalModifier(mods []int32) string {
+	if len(mods) == 0 {
+		return ""
+	}
+	modifier := intervalFields[mods[0]]
+	if len(mods) > 1 {
+		modifier += fmt.Sprintf("(%d)", mods[1])
+	}
+	return modifier
+}
+
+// Renders an expression node back into SQL
+func deparseExpr(node *pg_query.Node) (string, error) {
+	tree := &pg_query.ParseResult{
+		Stmts: []*pg_query.RawStmt{{
+			Stmt: &pg_query.Node{Node: &pg_query.Node_SelectStmt{SelectStmt: &pg_query.SelectStmt{
+				TargetList: []*pg_query.Node{pg_query.MakeResTargetNodeWithVal(node, 0)},
+			}}},
+		}},
+	}
+
+	sql, err := pg_query.Deparse(tree)
+	if err != nil {
+		return "", fmt.Errorf("failed to deparse expression: %w", err)
+	}
+
+	return strings.TrimPrefix(sql, "SELECT "), nil
+}
+
+// Renders a statement node back into SQL
+func deparseStmt(node *pg_query.Node) (string, error) {
+	sql, err := pg_query.Deparse(&pg_query.ParseResult{Stmts: []*pg_query.RawStmt{{Stmt: node}}})
+	if err != nil {
+		return "", fmt.Errorf("failed to deparse statement: %w", err)
+	}
+	return sql, nil
+}
+
+// Extracts the values of a list of String nodes, e.g. a column list
+func stringList(nodes []*pg_query.Node) []string {
+	var values []string
+	for _, n := range nodes {
+		values = append(values, n.GetString_().Sval)
+	}
+	return values
+}
+
+// Lists the distinct columns referenced by an expression, in order of appearance
+func columnRefs(node *pg_query.Node) []string {
+	var refs []string
+	walk(node.ProtoReflect(), func(m protoreflect.Message) {
+		if ref, ok := m.Interface().(*pg_query.ColumnRef); ok {
+			fields := ref.Fields
+			name := fields[len(fields)-1].GetString_().Sval
+			if !slices.Contains(refs, name) {
+				refs = append(refs, name)
+			}
+		}
+	})
+	return refs
+}
+
+// Calls fn for every message in the tree rooted at m
+func walk(m protoreflect.Message, fn func(protoreflect.Message)) {
+	fn(m)
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				walk(list.Get(i).Message(), fn)
+			}
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			walk(v.Message(), fn)
+		}
+		return true
+	})
+}
+
+func nodeName(node *pg_query.Node) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node.Node), "*pg_query.Node_")
+}
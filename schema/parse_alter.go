@@ -0,0 +1,142 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+func (p *parser) alterTable(stmt *pg_query.AlterTableStmt) error {
+	if stmt.Objtype == pg_query.ObjectType_OBJECT_TYPE {
+		return p.alterCompositeType(stmt)
+	}
+	if stmt.Objtype == pg_query.ObjectType_OBJECT_INDEX {
+		return p.alterIndex(stmt)
+	}
+	if stmt.Objtype != pg_query.ObjectType_OBJECT_TABLE {
+		return fmt.Errorf("unsupported statement: ALTER %s", stmt.Objtype)
+	}
+
+	table, err := p.table(stmt.Relation)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range stmt.Cmds {
+		if err := p.alterTableCmd(table, node.GetAlterTableCmd()); err != nil {
+			return fmt.Errorf("table %s: %w", table.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) alterTableCmd(table *Table, cmd *pg_query.AlterTableCmd) error {
+	switch cmd.Subtype {
+	case pg_query.AlterTableType_AT_AddColumn:
+		constraints, err := p.addColumn(table, cmd.Def.GetColumnDef())
+		if err != nil {
+			return err
+		}
+		return p.addConstraints(table, constraints)
+	case pg_query.AlterTableType_AT_DropColumn:
+		if table.Column(cmd.Name) == nil {
+			return fmt.Errorf("column %s does not exist", cmd.Name)
+		}
+		table.Columns = slices.DeleteFunc(table.Columns, func(c *Column) bool { return c.Name == cmd.Name })
+		// Constraints on the column go away with it
+		table.Constraints = slices.DeleteFunc(table.Constraints, func(c *Constraint) bool {
+			return slices.Contains(c.Columns, cmd.Name)
+		})
+		// And so do the sequences it owns
+		p.schema.Sequences = slices.DeleteFunc(p.schema.Sequences, func(s *Sequence) bool {
+			return s.OwnedBy == table.Name+"."+cmd.Name
+		})
+		return nil
+	case pg_query.AlterTableType_AT_AddConstraint:
+		return p.addConstraints(table, []pendingConstraint{{cmd.Def.GetConstraint(), ""}})
+	case pg_query.AlterTableType_AT_DropConstraint:
+		if table.Constraint(cmd.Name) == nil {
+			return fmt.Errorf("constraint %s does not exist", cmd.Name)
+		}
+		table.Constraints = slices.DeleteFunc(table.Constraints, func(c *Constraint) bool { return c.Name == cmd.Name })
+		return nil
+	case pg_query.AlterTableType_AT_ColumnDefault, pg_query.AlterTableType_AT_SetNotNull,
+		pg_query.AlterTableType_AT_DropNotNull, pg_query.AlterTableType_AT_AlterColumnType,
+		pg_query.AlterTableType_AT_AddIdentity, pg_query.AlterTableType_AT_DropIdentity,
+		pg_query.AlterTableType_AT_DropExpression:
+		return alterColumn(table, cmd)
+	case pg_query.AlterTableType_AT_EnableRowSecurity:
+		table.RowSecurity = true
+		return nil
+	case pg_query.AlterTableType_AT_DisableRowSecurity:
+		table.RowSecurity = false
+		return nil
+	case pg_query.AlterTableType_AT_ForceRowSecurity:
+		table.ForceRowSecurity = true
+		return nil
+	case pg_query.AlterTableType_AT_NoForceRowSecurity:
+		table.ForceRowSecurity = false
+		return nil
+	case pg_query.AlterTableType_AT_SetRelOptions:
+		options, err := setOptions(table.Options, cmd.Def.GetList().Items)
+		if err != nil {
+			return err
+		}
+		table.Options = options
+		return nil
+	case pg_query.AlterTableType_AT_ResetRelOptions:
+		table.Options = resetOptions(table.Options, cmd.Def.GetList().Items)
+		return nil
+	case pg_query.AlterTableType_AT_SetTableSpace:
+		table.Tablespace = tablespace(cmd.Name)
+		return nil
+	case pg_query.AlterTableType_AT_SetLogged:
+		table.Unlogged = false
+		return nil
+	case pg_query.AlterTableType_AT_SetUnLogged:
+		table.Unlogged = true
+		return nil
+	}
+
+	return fmt.Errorf("unsupported ALTER TABLE command: %s", cmd.Subtype)
+}
+
+func alterColumn(table *Table, cmd *pg_query.AlterTableCmd) error {
+	column := table.Column(cmd.Name)
+	if column == nil {
+		return fmt.Errorf("column %s does not exist", cmd.Name)
+	}
+
+	switch cmd.Subtype {
+	case pg_query.AlterTableType_AT_ColumnDefault:
+		column.Default = ""
+		if cmd.Def != nil {
+			expr, err := deparseDefault(cmd.Def)
+			if err != nil {
+				return err
+			}
+			column.Default = expr
+		}
+	case pg_query.AlterTableType_AT_SetNotNull:
+		column.NotNull = true
+	case pg_query.AlterTableType_AT_DropNotNull:
+		column.NotNull = false
+	case pg_query.AlterTableType_AT_AlterColumnType:
+		column.Type = formatType(cmd.Def.GetColumnDef().TypeName)
+	case pg_query.AlterTableType_AT_AddIdentity:
+		column.NotNull = true
+		column.Identity = "BY DEFAULT"
+		if cmd.Def.GetConstraint().GeneratedWhen == "a" {
+			column.Identity = "ALWAYS"
+		}
+	case pg_query.AlterTableType_AT_DropIdentity:
+		column.Identity = ""
+	case pg_query.AlterTableType_AT_DropExpression:
+		// The column keeps its values, but isn't computed anymore
+		column.Generated = ""
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package schema
+
+import (
+	"fmt"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+// Sets the comment of a table or column. COMMENT ... IS NULL removes it
+func (p *parser) comment(stmt *pg_query.CommentStmt) error {
+	nodes := stmt.Object.GetList().GetItems()
+	switch stmt.Objtype {
+	case pg_query.ObjectType_OBJECT_TABLE:
+		name := typeName(nodes)
+		table := p.schema.Table(name)
+		if table == nil {
+			return fmt.Errorf("table %s does not exist", name)
+		}
+		table.Comment = stmt.Comment
+	case pg_query.ObjectType_OBJECT_COLUMN:
+		names := stringList(nodes)
+		if len(names) == 3 {
+			names = []string{QualifiedName(names[0], names[1]), names[2]}
+		}
+		if len(names) != 2 {
+			return fmt.Errorf("column name %s must be qualified with its table", names[0])
+		}
+		table := p.schema.Table(names[0])
+		if table == nil {
+			return fmt.Errorf("table %s does not exist", names[0])
+		}
+		column := table.Column(names[1])
+		if column == nil {
+			return fmt.Errorf("column %s of table %s does not exist", names[1], names[0])
+		}
+		column.Comment = stmt.Comment
+	default:
+		return fmt.Errorf("unsupported statement: only comments on tables and columns are supported")
+	}
+	return nil
+}
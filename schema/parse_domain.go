@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+func (p *parser) createDomain(stmt *pg_query.CreateDomainStmt) error {
+	domain := &Domain{
+		Name: typeName(stmt.Domainname),
+		Type: formatType(stmt.TypeName),
+	}
+	if p.schema.Domain(domain.Name) != nil || p.schema.Enum(domain.Name) != nil || p.schema.CompositeType(domain.Name) != nil {
+		return fmt.Errorf("type %s already exists", domain.Name)
+	}
+
+	for _, node := range stmt.Constraints {
+		if err := domain.addConstraint(node.GetConstraint()); err != nil {
+			return fmt.Errorf("domain %s: %w", domain.Name, err)
+		}
+	}
+
+	p.schema.Domains = append(p.schema.Domains, domain)
+	p.locate(domain, stmt.TypeName.Location)
+	return nil
+}
+
+func (p *parser) alterDomain(stmt *pg_query.AlterDomainStmt) error {
+	name := typeName(stmt.TypeName)
+	domain := p.schema.Domain(name)
+	if domain == nil {
+		return fmt.Errorf("type %s does not exist", name)
+	}
+
+	switch stmt.Subtype {
+	case "T":
+		domain.Default = ""
+		if stmt.Def != nil {
+			expr, err := deparseExpr(stmt.Def)
+			if err != nil {
+				return fmt.Errorf("domain %s: %w", name, err)
+			}
+			domain.Default = expr
+		}
+	case "O":
+		domain.NotNull = true
+	case "N":
+		domain.NotNull = false
+	case "C":
+		if err := domain.addConstraint(stmt.Def.GetConstraint()); err != nil {
+			return fmt.Errorf("domain %s: %w", name, err)
+		}
+	case "X":
+		if domain.Check(stmt.Name) == nil {
+			if stmt.MissingOk {
+				return nil
+			}
+			return fmt.Errorf("constraint %s of domain %s does not exist", stmt.Name, name)
+		}
+		domain.Checks = slices.DeleteFunc(domain.Checks, func(c *Constraint) bool { return c.Name == stmt.Name })
+	default:
+		return fmt.Errorf("domain %s: unsupported ALTER DOMAIN command", name)
+	}
+	return nil
+}
+
+// Applies a constraint of CREATE DOMAIN or ALTER DOMAIN ... ADD. Unnamed
+// checks are named like Postgres does, after the domain
+func (d *Domain) addConstraint(c *pg_query.Constraint) error {
+	switch c.Contype {
+	case pg_query.ConstrType_CONSTR_NOTNULL:
+		d.NotNull = true
+	case pg_query.ConstrType_CONSTR_NULL:
+		d.NotNull = false
+	case pg_query.ConstrType_CONSTR_DEFAULT:
+		expr, err := deparseExpr(c.RawExpr)
+		if err != nil {
+			return err
+		}
+		d.Default = expr
+	case pg_query.ConstrType_CONSTR_CHECK:
+		expr, err := deparseExpr(c.RawExpr)
+		if err != nil {
+			return err
+		}
+
+		name := c.Conname
+		if name == "" {
+			used := map[string]bool{}
+			for _, check := range d.Checks {
+				used[check.Name] = true
+			}
+			_, bare := SplitName(d.Name)
+			name = chooseName(used, bare, "", "check")
+		}
+		if d.Check(name) != nil {
+			return fmt.Errorf("constraint %s already exists", name)
+		}
+		d.Checks = append(d.Checks, &Constraint{Name: name, Type: Check, Expression: expr})
+	default:
+		return fmt.Errorf("unsupported constraint type %s", c.Contype)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package schema
+
+import (
+	"fmt"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+func (p *parser) createExtension(stmt *pg_query.CreateExtensionStmt) error {
+	if p.schema.Extension(stmt.Extname) != nil {
+		if stmt.IfNotExists {
+			return nil
+		}
+		return fmt.Errorf("extension %s already exists", stmt.Extname)
+	}
+
+	extension := &Extension{Name: stmt.Extname}
+	// The version is left to the database, and CASCADE only matters when
+	// the extension is created
+	for _, option := range stmt.Options {
+		def := option.GetDefElem()
+		if def.Defname != "schema" {
+			continue
+		}
+		if name := def.Arg.GetString_().GetSval(); name != defaultSchema {
+			extension.Schema = name
+		}
+	}
+
+	p.schema.Extensions = append(p.schema.Extensions, extension)
+	return nil
+}
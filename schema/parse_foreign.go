@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+func (p *parser) createServer(stmt *pg_query.CreateForeignServerStmt) error {
+	if p.schema.Server(stmt.Servername) != nil {
+		if stmt.IfNotExists {
+			return nil
+		}
+		return fmt.Errorf("server %s already exists", stmt.Servername)
+	}
+
+	options, err := foreignOptions(stmt.Options)
+	if err != nil {
+		return fmt.Errorf("server %s: %w", stmt.Servername, err)
+	}
+	p.schema.Servers = append(p.schema.Servers, &Server{
+		Name:    stmt.Servername,
+		Wrapper: stmt.Fdwname,
+		Type:    stmt.Servertype,
+		Version: stmt.Version,
+		Options: options,
+	})
+	return nil
+}
+
+func (p *parser) createUserMapping(stmt *pg_query.CreateUserMappingStmt) error {
+	if p.schema.Server(stmt.Servername) == nil {
+		return fmt.Errorf("server %s does not exist", stmt.Servername)
+	}
+	user, err := granteeName(stmt.User)
+	if err != nil {
+		return fmt.Errorf("user mapping for server %s: %w", stmt.Servername, err)
+	}
+	if p.schema.UserMapping(stmt.Servername, user) != nil {
+		if stmt.IfNotExists {
+			return nil
+		}
+		return fmt.Errorf("user mapping for %s already exists for server %s", user, stmt.Servername)
+	}
+
+	options, err := foreignOptions(stmt.Options)
+	if err != nil {
+		return fmt.Errorf("user mapping for %s on server %s: %w", user, stmt.Servername, err)
+	}
+	p.schema.UserMappings = append(p.schema.UserMappings, &UserMapping{
+		Server:  stmt.Servername,
+		User:    user,
+		Options: options,
+	})
+	return nil
+}
+
+// Adds a foreign table. Only columns with their type, NOT NULL and options
+// are supported, since the rows live on the foreign server
+func (p *parser) createForeignTable(stmt *pg_query.CreateForeignTableStmt) error {
+	base := stmt.BaseStmt
+	table := &ForeignTable{Name: relationName(base.Relation), Server: stmt.Servername}
+	if p.schema.Server(table.Server) == nil {
+		return fmt.Errorf("foreign table %s: server %s does not exist", table.Name, table.Server)
+	}
+	if len(base.InhRelations) > 0 || base.Partbound != nil {
+		return fmt.Errorf("foreign table %s: inheritance and partitions are not supported", table.Name)
+	}
+	relations, name := p.namespace(table.Name)
+	if relations[name] {
+		if base.IfNotExists {
+			return nil
+		}
+		return fmt.Errorf("relation %s already exists", table.Name)
+	}
+	relations[name] = true
+
+	for _, elt := range base.TableElts {
+		def := elt.GetColumnDef()
+		if def == nil {
+			return fmt.Errorf("foreign table %s: unsupported table element: %s", table.Name, nodeName(elt))
+		}
+		if table.Column(def.Colname) != nil {
+			return fmt.Errorf("foreign table %s: column %s specified more than once", table.Name, def.Colname)
+		}
+		column := &ForeignColumn{Name: def.Colname, Type: formatType(def.TypeName), NotNull: def.IsNotNull}
+		for _, node := range def.Constraints {
+			switch constraint := node.GetConstraint(); constraint.Contype {
+			case pg_query.ConstrType_CONSTR_NOTNULL:
+				column.NotNull = true
+			case pg_query.ConstrType_CONSTR_NULL:
+				column.NotNull = false
+			default:
+				return fmt.Errorf("foreign table %s: column %s: unsupported constraint: %s", table.Name, def.Colname, constraint.Contype)
+			}
+		}
+		options, err := foreignOptions(def.Fdwoptions)
+		if err != nil {
+			return fmt.Errorf("foreign table %s: column %s: %w", table.Name, def.Colname, err)
+		}
+		column.Options = options
+		table.Columns = append(table.Columns, column)
+		p.locate(column, def.TypeName.Location)
+	}
+
+	options, err := foreignOptions(stmt.Options)
+	if err != nil {
+		return fmt.Errorf("foreign table %s: %w", table.Name, err)
+	}
+	table.Options = options
+
+	p.schema.ForeignTables = append(p.schema.ForeignTables, table)
+	return nil
+}
+
+// Returns the options of an OPTIONS (...) clause as sorted name=value pairs,
+// the way the catalogs hold them
+func foreignOptions(defs []*pg_query.Node) ([]string, error) {
+	var options []string
+	var names []string
+	for _, node := range defs {
+		def := node.GetDefElem()
+		if slices.Contains(names, def.Defname) {
+			return nil, fmt.Errorf("option %s provided more than once", def.Defname)
+		}
+		names = append(names, def.Defname)
+		options = append(options, def.Defname+"="+def.Arg.GetString_().GetSval())
+	}
+	slices.Sort(options)
+	return options, nil
+}
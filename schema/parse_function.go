@@ -0,0 +1,196 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func (p *parser) createFunction(stmt *pg_query.CreateFunctionStmt) error {
+	function, err := parseFunction(stmt)
+	if err != nil {
+		return err
+	}
+	if p.schema.Function(function.Name, function.Args) != nil {
+		return fmt.Errorf("function %s(%s) is defined more than once", function.Name, function.Args)
+	}
+
+	p.schema.Functions = append(p.schema.Functions, function)
+	return nil
+}
+
+// ParseFunction builds a function from its definition, such as the output of
+// pg_get_functiondef()
+func ParseFunction(definition string) (*Function, error) {
+	tree, err := pg_query.Parse(definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse function: %w", err)
+	}
+	if len(tree.Stmts) != 1 || tree.Stmts[0].Stmt.GetCreateFunctionStmt() == nil {
+		return nil, fmt.Errorf("not a CREATE FUNCTION statement: %s", definition)
+	}
+
+	return parseFunction(tree.Stmts[0].Stmt.GetCreateFunctionStmt())
+}
+
+func parseFunction(stmt *pg_query.CreateFunctionStmt) (*Function, error) {
+	stmt.Funcname = unqualified(stmt.Funcname)
+	function := &Function{
+		Name:      typeName(stmt.Funcname),
+		Procedure: stmt.IsProcedure,
+	}
+
+	var args, columns []string
+	for _, param := range stmt.Parameters {
+		param := param.GetFunctionParameter()
+		normalizeTypeName(param.ArgType)
+		switch param.Mode {
+		case pg_query.FunctionParameterMode_FUNC_PARAM_OUT:
+		case pg_query.FunctionParameterMode_FUNC_PARAM_TABLE:
+			columns = append(columns, QuoteIdent(param.Name)+" "+formatType(param.ArgType))
+		case pg_query.FunctionParameterMode_FUNC_PARAM_VARIADIC:
+			args = append(args, "VARIADIC "+formatType(param.ArgType))
+		default:
+			args = append(args, formatType(param.ArgType))
+		}
+	}
+	function.Args = strings.Join(args, ", ")
+
+	if stmt.ReturnType != nil {
+		normalizeTypeName(stmt.ReturnType)
+		switch {
+		case len(columns) > 0:
+			function.Returns = "TABLE(" + strings.Join(columns, ", ") + ")"
+		case stmt.ReturnType.Setof:
+			function.Returns = "SETOF " + formatType(stmt.ReturnType)
+		default:
+			function.Returns = formatType(stmt.ReturnType)
+		}
+	}
+
+	// Postgres only keeps options that differ from their defaults, and
+	// renders them in its own order
+	stmt.Options = slices.DeleteFunc(stmt.Options, func(n *pg_query.Node) bool {
+		return defaultFunctionOption(n.GetDefElem())
+	})
+	slices.SortStableFunc(stmt.Options, func(a, b *pg_query.Node) int {
+		return strings.Compare(a.GetDefElem().Defname, b.GetDefElem().Defname)
+	})
+	stmt.Replace = true
+
+	definition, err := deparseStmt(&pg_query.Node{Node: &pg_query.Node_CreateFunctionStmt{CreateFunctionStmt: stmt}})
+	if err != nil {
+		return nil, fmt.Errorf("function %s: %w", function.Name, err)
+	}
+	function.Definition = definition
+
+	return function, nil
+}
+
+// Reports whether a function option is set to the value Postgres uses when
+// it's left out
+func defaultFunctionOption(opt *pg_query.DefElem) bool {
+	switch opt.Defname {
+	case "volatility":
+		return opt.Arg.GetString_().Sval == "volatile"
+	case "parallel":
+		return opt.Arg.GetString_().Sval == "unsafe"
+	case "strict", "security", "leakproof":
+		return !opt.Arg.GetBoolean().Boolval
+	case "cost":
+		return numericOption(opt) == 100
+	case "rows":
+		return numericOption(opt) == 1000
+	}
+	return false
+}
+
+func numericOption(opt *pg_query.DefElem) float64 {
+	switch n := opt.Arg.Node.(type) {
+	case *pg_query.Node_Integer:
+		return float64(n.Integer.Ival)
+	case *pg_query.Node_Float:
+		var value float64
+		fmt.Sscan(n.Float.Fval, &value)
+		return value
+	}
+	return -1
+}
+
+func (p *parser) createTrigger(stmt *pg_query.CreateTrigStmt) error {
+	table, err := p.table(stmt.Relation)
+	if err != nil {
+		return err
+	}
+	if table.Trigger(stmt.Trigname) != nil {
+		return fmt.Errorf("trigger %s on table %s already exists", stmt.Trigname, table.Name)
+	}
+
+	trigger, err := parseTrigger(stmt)
+	if err != nil {
+		return err
+	}
+
+	table.Triggers = append(table.Triggers, trigger)
+	return nil
+}
+
+// ParseTrigger builds a trigger from its definition, such as the output of
+// pg_get_triggerdef()
+func ParseTrigger(definition string) (*Trigger, error) {
+	tree, err := pg_query.Parse(definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trigger: %w", err)
+	}
+	if len(tree.Stmts) != 1 || tree.Stmts[0].Stmt.GetCreateTrigStmt() == nil {
+		return nil, fmt.Errorf("not a CREATE TRIGGER statement: %s", definition)
+	}
+
+	return parseTrigger(tree.Stmts[0].Stmt.GetCreateTrigStmt())
+}
+
+func parseTrigger(stmt *pg_query.CreateTrigStmt) (*Trigger, error) {
+	if stmt.Relation.Schemaname == defaultSchema {
+		stmt.Relation.Schemaname = ""
+	}
+	stmt.Funcname = unqualified(stmt.Funcname)
+	stmt.Replace = false
+
+	definition, err := deparseStmt(&pg_query.Node{Node: &pg_query.Node_CreateTrigStmt{CreateTrigStmt: stmt}})
+	if err != nil {
+		return nil, fmt.Errorf("trigger %s: %w", stmt.Trigname, err)
+	}
+
+	return &Trigger{
+		Name:       stmt.Trigname,
+		Function:   typeName(stmt.Funcname),
+		Definition: definition,
+	}, nil
+}
+
+// Strips the public schema from a qualified name
+func unqualified(nodes []*pg_query.Node) []*pg_query.Node {
+	if len(nodes) > 1 && nodes[0].GetString_().Sval == "public" {
+		return nodes[1:]
+	}
+	return nodes
+}
+
+// Qualifies the built-in type aliases, so int4 and integer are deparsed the same
+func normalizeTypeName(typeName *pg_query.TypeName) {
+	walk(typeName.ProtoReflect(), func(m protoreflect.Message) {
+		tn, ok := m.Interface().(*pg_query.TypeName)
+		if !ok {
+			return
+		}
+		tn.Names = unqualified(tn.Names)
+		if len(tn.Names) == 1 {
+			if _, ok := typeNames[tn.Names[0].GetString_().Sval]; ok {
+				tn.Names = append([]*pg_query.Node{pg_query.MakeStrNode("pg_catalog")}, tn.Names...)
+			}
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+// The privileges ALL stands for on tables and views
+var tablePrivileges = []string{"DELETE", "INSERT", "REFERENCES", "SELECT", "TRIGGER", "TRUNCATE", "UPDATE"}
+
+// Applies a GRANT or REVOKE on tables and views. Only plain privileges of
+// named relations are supported, without column lists or grant options
+func (p *parser) grant(stmt *pg_query.GrantStmt) error {
+	if stmt.Targtype != pg_query.GrantTargetType_ACL_TARGET_OBJECT || stmt.Objtype != pg_query.ObjectType_OBJECT_TABLE {
+		return fmt.Errorf("unsupported statement: only GRANT and REVOKE on tables and views are supported")
+	}
+	if stmt.GrantOption {
+		return fmt.Errorf("unsupported statement: WITH GRANT OPTION")
+	}
+
+	privileges := tablePrivileges
+	if len(stmt.Privileges) > 0 {
+		privileges = nil
+		for _, node := range stmt.Privileges {
+			priv := node.GetAccessPriv()
+			if len(priv.Cols) > 0 {
+				return fmt.Errorf("unsupported statement: column privileges")
+			}
+			privilege := strings.ToUpper(priv.PrivName)
+			if !slices.Contains(tablePrivileges, privilege) {
+				return fmt.Errorf("invalid privilege type %s for table", privilege)
+			}
+			privileges = append(privileges, privilege)
+		}
+	}
+
+	for _, object := range stmt.Objects {
+		name := relationName(object.GetRangeVar())
+		if p.schema.Table(name) == nil && p.schema.View(name) == nil {
+			return fmt.Errorf("relation %s does not exist", name)
+		}
+
+		for _, grantee := range stmt.Grantees {
+			role, err := granteeName(grantee.GetRoleSpec())
+			if err != nil {
+				return err
+			}
+			p.schema.grant(name, role, privileges, stmt.IsGrant)
+		}
+	}
+	return nil
+}
+
+// Adds or removes privileges of the role on the object
+func (s *Schema) grant(object, role string, privileges []string, add bool) {
+	g := s.Grant(object, role)
+	if g == nil {
+		if !add {
+			return
+		}
+		g = &Grant{Object: object, Role: role}
+		s.Grants = append(s.Grants, g)
+	}
+
+	for _, privilege := range privileges {
+		if add && !slices.Contains(g.Privileges, privilege) {
+			g.Privileges = append(g.Privileges, privilege)
+		}
+		if !add {
+			g.Privileges = slices.DeleteFunc(g.Privileges, func(p string) bool { return p == privilege })
+		}
+	}
+	slices.Sort(g.Privileges)
+
+	if len(g.Privileges) == 0 {
+		s.Grants = slices.DeleteFunc(s.Grants, func(other *Grant) bool { return other == g })
+	}
+}
+
+func granteeName(role *pg_query.RoleSpec) (string, error) {
+	switch role.Roletype {
+	case pg_query.RoleSpecType_ROLESPEC_PUBLIC:
+		return "public", nil
+	case pg_query.RoleSpecType_ROLESPEC_CSTRING:
+		return role.Rolename, nil
+	}
+	return "", fmt.Errorf("unsupported grantee %s, name the role instead", roleName(role))
+}
@@ -0,0 +1,247 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+func (p *parser) createIndex(stmt *pg_query.IndexStmt) error {
+	table, err := p.table(stmt.Relation)
+	if err != nil {
+		return err
+	}
+
+	index, err := parseIndex(stmt)
+	if err != nil {
+		return fmt.Errorf("index on %s: %w", table.Name, err)
+	}
+
+	// Indexes live in the schema of their table, and are named without it
+	relations, tableName := p.namespace(table.Name)
+	if index.Name == "" {
+		params := append(slices.Clone(stmt.IndexParams), stmt.IndexIncludingParams...)
+		index.Name = chooseName(relations, tableName, nameAddition(indexColumnNames(params)), "idx")
+	} else if relations[index.Name] {
+		return fmt.Errorf("relation %s already exists", index.Name)
+	}
+	relations[index.Name] = true
+
+	table.Indexes = append(table.Indexes, index)
+	return nil
+}
+
+// ParseIndex parses a single CREATE INDEX statement, such as the output of
+// pg_get_indexdef()
+func ParseIndex(definition string) (*Index, error) {
+	tree, err := pg_query.Parse(definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse index definition: %w", err)
+	}
+	if len(tree.Stmts) != 1 || tree.Stmts[0].Stmt.GetIndexStmt() == nil {
+		return nil, fmt.Errorf("not a CREATE INDEX statement: %s", definition)
+	}
+
+	return parseIndex(tree.Stmts[0].Stmt.GetIndexStmt())
+}
+
+func parseIndex(stmt *pg_query.IndexStmt) (*Index, error) {
+	index := &Index{
+		Name:   stmt.Idxname,
+		Unique: stmt.Unique,
+		Method: stmt.AccessMethod,
+	}
+
+	for _, param := range stmt.IndexParams {
+		key, err := indexKey(param.GetIndexElem(), stmt.AccessMethod)
+		if err != nil {
+			return nil, err
+		}
+		index.Keys = append(index.Keys, key)
+	}
+	for _, param := range stmt.IndexIncludingParams {
+		index.Include = append(index.Include, param.GetIndexElem().Name)
+	}
+
+	if stmt.WhereClause != nil {
+		where, err := deparseExpr(stmt.WhereClause)
+		if err != nil {
+			return nil, err
+		}
+		index.Where = where
+	}
+
+	options, err := setOptions(nil, stmt.Options)
+	if err != nil {
+		return nil, err
+	}
+	index.Options = options
+	index.Tablespace = tablespace(stmt.TableSpace)
+
+	return index, nil
+}
+
+// Operator classes that are the default of their access method for every
+// type they support, which pg_get_indexdef() leaves out
+var defaultOpclasses = map[string][]string{
+	"gin":  {"array_ops", "jsonb_ops", "tsvector_ops"},
+	"gist": {"range_ops", "multirange_ops", "tsvector_ops"},
+}
+
+// Renders an index column of the access method. Default orderings and
+// operator classes are left out, the same way pg_get_indexdef() does
+func indexKey(elem *pg_query.IndexElem, method string) (string, error) {
+	key := QuoteIdent(elem.Name)
+	if elem.Expr != nil {
+		expr, err := deparseExpr(elem.Expr)
+		if err != nil {
+			return "", err
+		}
+		key = "(" + expr + ")"
+	}
+
+	if len(elem.Collation) > 0 {
+		key += " COLLATE " + qualifiedName(elem.Collation)
+	}
+	if opclass := stringList(elem.Opclass); len(opclass) > 0 && !slices.Contains(defaultOpclasses[method], opclass[len(opclass)-1]) {
+		key += " " + qualifiedName(elem.Opclass)
+	}
+
+	desc := elem.Ordering == pg_query.SortByDir_SORTBY_DESC
+	if desc {
+		key += " DESC"
+	}
+	switch elem.NullsOrdering {
+	case pg_query.SortByNulls_SORTBY_NULLS_FIRST:
+		if !desc {
+			key += " NULLS FIRST"
+		}
+	case pg_query.SortByNulls_SORTBY_NULLS_LAST:
+		if desc {
+			key += " NULLS LAST"
+		}
+	}
+
+	return key, nil
+}
+
+// Renders an exclusion constraint the way pg_get_constraintdef() does, e.g.
+// EXCLUDE USING gist (room WITH =, during WITH &&)
+func exclusionDefinition(c *pg_query.Constraint) (string, error) {
+	method := c.AccessMethod
+	if method == "" {
+		method = "btree"
+	}
+
+	var elements []string
+	for _, node := range c.Exclusions {
+		pair := node.GetList().Items
+		key, err := indexKey(pair[0].GetIndexElem(), method)
+		if err != nil {
+			return "", err
+		}
+		operator := stringList(pair[1].GetList().Items)
+		if len(operator) > 1 {
+			key += fmt.Sprintf(" WITH OPERATOR(%s.%s)", QuoteIdent(operator[0]), operator[1])
+		} else {
+			key += " WITH " + operator[0]
+		}
+		elements = append(elements, key)
+	}
+
+	def := fmt.Sprintf("EXCLUDE USING %s (%s)", method, strings.Join(elements, ", "))
+	if including := stringList(c.Including); len(including) > 0 {
+		quoted := make([]string, len(including))
+		for i, name := range including {
+			quoted[i] = QuoteIdent(name)
+		}
+		def += " INCLUDE (" + strings.Join(quoted, ", ") + ")"
+	}
+	if c.WhereClause != nil {
+		where, err := deparseExpr(c.WhereClause)
+		if err != nil {
+			return "", err
+		}
+		def += " WHERE (" + where + ")"
+	}
+	if c.Deferrable {
+		def += " DEFERRABLE"
+		if c.Initdeferred {
+			def += " INITIALLY DEFERRED"
+		}
+	}
+	return def, nil
+}
+
+// Returns the index elements of an exclusion constraint, without their
+// operators
+func exclusionElements(c *pg_query.Constraint) []*pg_query.Node {
+	var elements []*pg_query.Node
+	for _, node := range c.Exclusions {
+		elements = append(elements, node.GetList().Items[0])
+	}
+	return elements
+}
+
+// Picks a name for each index column, like ChooseIndexColumnNames():
+// expressions are named after their function or column, and duplicate names
+// get a numeric suffix
+func indexColumnNames(params []*pg_query.Node) []string {
+	var names []string
+	for _, param := range params {
+		elem := param.GetIndexElem()
+		name := elem.Name
+		if name == "" {
+			name = figureColname(elem.Expr)
+		}
+
+		candidate := name
+		for i := 1; slices.Contains(names, candidate); i++ {
+			suffix := fmt.Sprint(i)
+			candidate = name[:min(len(name), maxIdentifierLength-len(suffix))] + suffix
+		}
+		names = append(names, candidate)
+	}
+	return names
+}
+
+// Approximates FigureColname(), which names an expression after its most
+// significant part
+func figureColname(node *pg_query.Node) string {
+	switch n := node.GetNode().(type) {
+	case *pg_query.Node_ColumnRef:
+		fields := n.ColumnRef.Fields
+		if name := fields[len(fields)-1].GetString_(); name != nil {
+			return name.Sval
+		}
+	case *pg_query.Node_FuncCall:
+		names := n.FuncCall.Funcname
+		return names[len(names)-1].GetString_().Sval
+	case *pg_query.Node_TypeCast:
+		if name := figureColname(n.TypeCast.Arg); name != "expr" {
+			return name
+		}
+		names := n.TypeCast.TypeName.Names
+		return names[len(names)-1].GetString_().Sval
+	case *pg_query.Node_CoalesceExpr:
+		return "coalesce"
+	case *pg_query.Node_CaseExpr:
+		return "case"
+	case *pg_query.Node_MinMaxExpr:
+		if n.MinMaxExpr.Op == pg_query.MinMaxOp_IS_GREATEST {
+			return "greatest"
+		}
+		return "least"
+	}
+	return "expr"
+}
+
+func qualifiedName(nodes []*pg_query.Node) string {
+	var parts []string
+	for _, name := range stringList(nodes) {
+		parts = append(parts, QuoteIdent(name))
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+func (p *parser) createPolicy(stmt *pg_query.CreatePolicyStmt) error {
+	table, err := p.table(stmt.Table)
+	if err != nil {
+		return err
+	}
+	if table.Policy(stmt.PolicyName) != nil {
+		return fmt.Errorf("policy %s on table %s already exists", stmt.PolicyName, table.Name)
+	}
+
+	policy, err := parsePolicy(stmt)
+	if err != nil {
+		return err
+	}
+
+	table.Policies = append(table.Policies, policy)
+	return nil
+}
+
+// ParsePolicy builds a row-level security policy from its CREATE POLICY
+// statement
+func ParsePolicy(definition string) (*Policy, error) {
+	tree, err := pg_query.Parse(definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	if len(tree.Stmts) != 1 || tree.Stmts[0].Stmt.GetCreatePolicyStmt() == nil {
+		return nil, fmt.Errorf("not a CREATE POLICY statement: %s", definition)
+	}
+
+	return parsePolicy(tree.Stmts[0].Stmt.GetCreatePolicyStmt())
+}
+
+// Normalizes the statement, so policies written by hand and the ones read
+// back from the catalogs compare equal
+func parsePolicy(stmt *pg_query.CreatePolicyStmt) (*Policy, error) {
+	if stmt.Table.Schemaname == defaultSchema {
+		stmt.Table.Schemaname = ""
+	}
+	// Policies apply to PUBLIC unless given roles, which Postgres doesn't
+	// keep in order
+	if len(stmt.Roles) == 0 {
+		stmt.Roles = []*pg_query.Node{{Node: &pg_query.Node_RoleSpec{RoleSpec: &pg_query.RoleSpec{Roletype: pg_query.RoleSpecType_ROLESPEC_PUBLIC}}}}
+	}
+	slices.SortFunc(stmt.Roles, func(a, b *pg_query.Node) int {
+		return strings.Compare(roleName(a.GetRoleSpec()), roleName(b.GetRoleSpec()))
+	})
+
+	definition, err := deparseStmt(&pg_query.Node{Node: &pg_query.Node_CreatePolicyStmt{CreatePolicyStmt: stmt}})
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %w", stmt.PolicyName, err)
+	}
+
+	return &Policy{Name: stmt.PolicyName, Definition: definition}, nil
+}
+
+func roleName(role *pg_query.RoleSpec) string {
+	if role.Roletype == pg_query.RoleSpecType_ROLESPEC_CSTRING {
+		return role.Rolename
+	}
+	return role.Roletype.String()
+}
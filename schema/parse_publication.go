@@ -0,0 +1,100 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+func (p *parser) createPublication(stmt *pg_query.CreatePublicationStmt) error {
+	if p.schema.Publication(stmt.Pubname) != nil {
+		return fmt.Errorf("publication %s already exists", stmt.Pubname)
+	}
+
+	pub := &Publication{Name: stmt.Pubname, AllTables: stmt.ForAllTables, Publish: PublishOperations}
+	for _, node := range stmt.Pubobjects {
+		obj := node.GetPublicationObjSpec()
+		switch obj.Pubobjtype {
+		case pg_query.PublicationObjSpecType_PUBLICATIONOBJ_TABLE:
+			table, err := p.publicationTable(obj.Pubtable)
+			if err != nil {
+				return fmt.Errorf("publication %s: %w", pub.Name, err)
+			}
+			if pub.Table(table.Table) != nil {
+				return fmt.Errorf("publication %s: table %s specified more than once", pub.Name, table.Table)
+			}
+			pub.Tables = append(pub.Tables, table)
+		case pg_query.PublicationObjSpecType_PUBLICATIONOBJ_TABLES_IN_SCHEMA:
+			pub.Schemas = append(pub.Schemas, obj.Name)
+		case pg_query.PublicationObjSpecType_PUBLICATIONOBJ_TABLES_IN_CUR_SCHEMA:
+			pub.Schemas = append(pub.Schemas, defaultSchema)
+		default:
+			return fmt.Errorf("publication %s: unsupported object %s", pub.Name, obj.Pubobjtype)
+		}
+	}
+	slices.SortFunc(pub.Tables, func(a, b *PublicationTable) int { return strings.Compare(a.Table, b.Table) })
+	slices.Sort(pub.Schemas)
+	pub.Schemas = slices.Compact(pub.Schemas)
+
+	for _, node := range stmt.Options {
+		def := node.GetDefElem()
+		value, err := optionValue(def.Arg)
+		if err != nil {
+			return fmt.Errorf("publication %s: option %s: %w", pub.Name, def.Defname, err)
+		}
+		switch def.Defname {
+		case "publish":
+			publish, err := publishOperations(value)
+			if err != nil {
+				return fmt.Errorf("publication %s: %w", pub.Name, err)
+			}
+			pub.Publish = publish
+		case "publish_via_partition_root":
+			pub.ViaPartitionRoot = slices.Contains([]string{"true", "on", "1"}, strings.ToLower(value))
+		default:
+			return fmt.Errorf("publication %s: unrecognized option %s", pub.Name, def.Defname)
+		}
+	}
+
+	p.schema.Publications = append(p.schema.Publications, pub)
+	return nil
+}
+
+func (p *parser) publicationTable(pubtable *pg_query.PublicationTable) (*PublicationTable, error) {
+	table, err := p.table(pubtable.Relation)
+	if err != nil {
+		return nil, err
+	}
+	entry := &PublicationTable{Table: table.Name, Columns: stringList(pubtable.Columns)}
+	for _, column := range entry.Columns {
+		if table.Column(column) == nil {
+			return nil, fmt.Errorf("column %s of table %s does not exist", column, table.Name)
+		}
+	}
+	slices.Sort(entry.Columns)
+	if pubtable.WhereClause != nil {
+		entry.Where, err = deparseExpr(pubtable.WhereClause)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", table.Name, err)
+		}
+	}
+	return entry, nil
+}
+
+// Splits the publish option, e.g. 'insert, update', into its operations in
+// the order of PublishOperations
+func publishOperations(value string) ([]string, error) {
+	var operations []string
+	for _, operation := range strings.Split(value, ",") {
+		operation = strings.ToLower(strings.TrimSpace(operation))
+		if !slices.Contains(PublishOperations, operation) {
+			return nil, fmt.Errorf("unrecognized publish operation %q", operation)
+		}
+		operations = append(operations, operation)
+	}
+	return slices.DeleteFunc(slices.Clone(PublishOperations), func(operation string) bool {
+		return !slices.Contains(operations, operation)
+	}), nil
+}
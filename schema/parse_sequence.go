@@ -0,0 +1,235 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func (p *parser) createSequence(stmt *pg_query.CreateSeqStmt) error {
+	name := relationName(stmt.Sequence)
+	relations, bare := p.namespace(name)
+	if relations[bare] {
+		if stmt.IfNotExists && p.schema.Sequence(name) != nil {
+			return nil
+		}
+		return fmt.Errorf("relation %s already exists", name)
+	}
+
+	seq := newSequence(name, "bigint")
+	if err := p.sequenceOptions(seq, stmt.Options, true); err != nil {
+		return fmt.Errorf("sequence %s: %w", name, err)
+	}
+
+	relations[bare] = true
+	p.schema.Sequences = append(p.schema.Sequences, seq)
+	return nil
+}
+
+func (p *parser) alterSequence(stmt *pg_query.AlterSeqStmt) error {
+	name := relationName(stmt.Sequence)
+	seq := p.schema.Sequence(name)
+	if seq == nil {
+		if stmt.MissingOk {
+			return nil
+		}
+		return fmt.Errorf("sequence %s does not exist", name)
+	}
+
+	if err := p.sequenceOptions(seq, stmt.Options, false); err != nil {
+		return fmt.Errorf("sequence %s: %w", name, err)
+	}
+	return nil
+}
+
+// Renders a column default. The sequence of a nextval() call is spelled the
+// way pg_get_expr() does, nextval('seq'::regclass), however schema.sql wrote
+// it: without the cast, qualified with public or with needless quotes
+func deparseDefault(node *pg_query.Node) (string, error) {
+	walk(node.ProtoReflect(), func(m protoreflect.Message) {
+		call, ok := m.Interface().(*pg_query.FuncCall)
+		if !ok || len(call.Args) != 1 || len(call.Funcname) == 0 || call.Funcname[len(call.Funcname)-1].GetString_().GetSval() != "nextval" {
+			return
+		}
+		arg := call.Args[0]
+		if cast := arg.GetTypeCast(); cast != nil {
+			arg = cast.Arg
+		}
+		if arg.GetAConst().GetSval() == nil {
+			return
+		}
+		// The name is read like regclass does, as an identifier
+		tree, err := pg_query.Parse("SELECT FROM " + arg.GetAConst().GetSval().Sval)
+		if err != nil || len(tree.Stmts) != 1 {
+			return
+		}
+		from := tree.Stmts[0].Stmt.GetSelectStmt().GetFromClause()
+		if len(from) != 1 || from[0].GetRangeVar() == nil {
+			return
+		}
+		name := QuoteName(relationName(from[0].GetRangeVar()))
+		call.Args[0] = &pg_query.Node{Node: &pg_query.Node_TypeCast{TypeCast: &pg_query.TypeCast{
+			Arg:      pg_query.MakeAConstStrNode(name, -1),
+			TypeName: &pg_query.TypeName{Names: []*pg_query.Node{pg_query.MakeStrNode("regclass")}, Location: -1},
+			Location: -1,
+		}}}
+	})
+	return deparseExpr(node)
+}
+
+// Returns a sequence with the settings Postgres picks when none are given
+func newSequence(name, typ string) *Sequence {
+	_, max := sequenceRange(typ)
+	return &Sequence{
+		Name:      name,
+		Type:      typ,
+		Start:     1,
+		Increment: 1,
+		MinValue:  1,
+		MaxValue:  max,
+		Cache:     1,
+	}
+}
+
+// Applies the options of a CREATE or ALTER SEQUENCE statement. Like
+// Postgres, bounds and start value that weren't set explicitly follow the
+// type and the direction of the sequence
+func (p *parser) sequenceOptions(seq *Sequence, options []*pg_query.Node, create bool) error {
+	oldMin, oldMax := sequenceRange(seq.Type)
+	set, reset := map[string]bool{}, map[string]bool{}
+	for _, node := range options {
+		opt := node.GetDefElem()
+		set[opt.Defname] = true
+
+		// NO MINVALUE and NO MAXVALUE go back to the defaults
+		if opt.Arg == nil && (opt.Defname == "minvalue" || opt.Defname == "maxvalue") {
+			set[opt.Defname] = false
+			reset[opt.Defname] = true
+			continue
+		}
+
+		var err error
+		switch opt.Defname {
+		case "as":
+			seq.Type = formatType(opt.Arg.GetTypeName())
+			if seq.Type != "smallint" && seq.Type != "integer" && seq.Type != "bigint" {
+				return fmt.Errorf("sequence type must be smallint, integer, or bigint")
+			}
+		case "increment":
+			seq.Increment, err = sequenceValue(opt)
+		case "start":
+			seq.Start, err = sequenceValue(opt)
+		case "minvalue":
+			seq.MinValue, err = sequenceValue(opt)
+		case "maxvalue":
+			seq.MaxValue, err = sequenceValue(opt)
+		case "cache":
+			seq.Cache, err = sequenceValue(opt)
+		case "cycle":
+			seq.Cycle = opt.Arg.GetBoolean().Boolval
+		case "owned_by":
+			err = p.sequenceOwner(seq, stringList(opt.Arg.GetList().Items))
+		case "restart":
+			// Only affects the current value, which isn't part of the schema
+		default:
+			err = fmt.Errorf("unsupported option %s", opt.Defname)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if seq.Increment == 0 {
+		return fmt.Errorf("INCREMENT must not be zero")
+	}
+
+	// Changing the type moves bounds that were at the limits of the old type
+	typeMin, typeMax := sequenceRange(seq.Type)
+	if !set["minvalue"] && (create || reset["minvalue"] || set["as"] && seq.MinValue == oldMin) {
+		seq.MinValue = 1
+		if seq.Increment < 0 {
+			seq.MinValue = typeMin
+		}
+	}
+	if !set["maxvalue"] && (create || reset["maxvalue"] || set["as"] && seq.MaxValue == oldMax) {
+		seq.MaxValue = typeMax
+		if seq.Increment < 0 {
+			seq.MaxValue = -1
+		}
+	}
+	if create && !set["start"] {
+		seq.Start = seq.MinValue
+		if seq.Increment < 0 {
+			seq.Start = seq.MaxValue
+		}
+	}
+
+	if seq.MinValue >= seq.MaxValue {
+		return fmt.Errorf("MINVALUE (%d) must be less than MAXVALUE (%d)", seq.MinValue, seq.MaxValue)
+	}
+	if seq.MinValue < typeMin || seq.MaxValue > typeMax {
+		return fmt.Errorf("bounds out of range for type %s", seq.Type)
+	}
+	if seq.Start < seq.MinValue || seq.Start > seq.MaxValue {
+		return fmt.Errorf("START value (%d) must be between MINVALUE and MAXVALUE", seq.Start)
+	}
+
+	return nil
+}
+
+// Parses a numeric sequence option. Values too large for an Integer node
+// come out of the parser as a Float
+func sequenceValue(opt *pg_query.DefElem) (int64, error) {
+	if opt.Arg == nil {
+		return 0, fmt.Errorf("%s requires a value", opt.Defname)
+	}
+	switch n := opt.Arg.Node.(type) {
+	case *pg_query.Node_Integer:
+		return int64(n.Integer.Ival), nil
+	case *pg_query.Node_Float:
+		value, err := strconv.ParseInt(n.Float.Fval, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s value %s", opt.Defname, n.Float.Fval)
+		}
+		return value, nil
+	}
+	return 0, fmt.Errorf("invalid %s value", opt.Defname)
+}
+
+func (p *parser) sequenceOwner(seq *Sequence, names []string) error {
+	if len(names) == 1 && names[0] == "none" {
+		seq.OwnedBy = ""
+		return nil
+	}
+	if len(names) == 3 {
+		names = []string{QualifiedName(names[0], names[1]), names[2]}
+	}
+	if len(names) != 2 {
+		return fmt.Errorf("invalid OWNED BY option")
+	}
+
+	table := p.schema.Table(names[0])
+	if table == nil {
+		return fmt.Errorf("table %s does not exist", names[0])
+	}
+	if table.Column(names[1]) == nil {
+		return fmt.Errorf("column %s of table %s does not exist", names[1], names[0])
+	}
+
+	seq.OwnedBy = names[0] + "." + names[1]
+	return nil
+}
+
+// Returns the range of values of a sequence type
+func sequenceRange(typ string) (int64, int64) {
+	switch typ {
+	case "smallint":
+		return math.MinInt16, math.MaxInt16
+	case "integer":
+		return math.MinInt32, math.MaxInt32
+	}
+	return math.MinInt64, math.MaxInt64
+}
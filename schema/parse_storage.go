@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+// Postgres records the database's default tablespace as no tablespace
+const defaultTablespace = "pg_default"
+
+// Returns the storage parameters of a WITH (...) or SET (...) clause added to
+// options, as name=value pairs like pg_class.reloptions holds them
+func setOptions(options []string, defs []*pg_query.Node) ([]string, error) {
+	options = slices.Clone(options)
+	for _, node := range defs {
+		def := node.GetDefElem()
+		value, err := optionValue(def.Arg)
+		if err != nil {
+			return nil, fmt.Errorf("storage parameter %s: %w", def.Defname, err)
+		}
+		name := optionName(def)
+		options = slices.DeleteFunc(options, func(option string) bool { return strings.HasPrefix(option, name+"=") })
+		options = append(options, name+"="+value)
+	}
+	slices.Sort(options)
+	return options, nil
+}
+
+// Returns options without the storage parameters of a RESET (...) clause
+func resetOptions(options []string, defs []*pg_query.Node) []string {
+	options = slices.Clone(options)
+	for _, node := range defs {
+		name := optionName(node.GetDefElem())
+		options = slices.DeleteFunc(options, func(option string) bool { return strings.HasPrefix(option, name+"=") })
+	}
+	return options
+}
+
+func optionName(def *pg_query.DefElem) string {
+	if def.Defnamespace != "" {
+		return def.Defnamespace + "." + def.Defname
+	}
+	return def.Defname
+}
+
+// Renders the value of a storage parameter the way it's stored: as written,
+// with parameters given without one being true
+func optionValue(node *pg_query.Node) (string, error) {
+	if node == nil {
+		return "true", nil
+	}
+	switch n := node.Node.(type) {
+	case *pg_query.Node_Integer:
+		return strconv.Itoa(int(n.Integer.Ival)), nil
+	case *pg_query.Node_Float:
+		return n.Float.Fval, nil
+	case *pg_query.Node_String_:
+		return n.String_.Sval, nil
+	case *pg_query.Node_Boolean:
+		return strconv.FormatBool(n.Boolean.Boolval), nil
+	case *pg_query.Node_TypeName:
+		// Keywords like on and off are parsed as type names
+		names := stringList(n.TypeName.Names)
+		return names[len(names)-1], nil
+	}
+	return "", fmt.Errorf("unsupported value %s", nodeName(node))
+}
+
+func tablespace(name string) string {
+	if name == defaultTablespace {
+		return ""
+	}
+	return name
+}
+
+func (p *parser) alterIndex(stmt *pg_query.AlterTableStmt) error {
+	index := p.index(relationName(stmt.Relation))
+	if index == nil {
+		return fmt.Errorf("index %s does not exist", relationName(stmt.Relation))
+	}
+
+	for _, node := range stmt.Cmds {
+		cmd := node.GetAlterTableCmd()
+		switch cmd.Subtype {
+		case pg_query.AlterTableType_AT_SetRelOptions:
+			options, err := setOptions(index.Options, cmd.Def.GetList().Items)
+			if err != nil {
+				return fmt.Errorf("index %s: %w", index.Name, err)
+			}
+			index.Options = options
+		case pg_query.AlterTableType_AT_ResetRelOptions:
+			index.Options = resetOptions(index.Options, cmd.Def.GetList().Items)
+		case pg_query.AlterTableType_AT_SetTableSpace:
+			index.Tablespace = tablespace(cmd.Name)
+		default:
+			return fmt.Errorf("index %s: unsupported ALTER INDEX command: %s", index.Name, cmd.Subtype)
+		}
+	}
+	return nil
+}
+
+// Returns the index with the given name, which is qualified with the schema
+// of its table, or nil if there's none
+func (p *parser) index(name string) *Index {
+	schemaName, indexName := SplitName(name)
+	for _, table := range p.schema.Tables {
+		if tableSchema, _ := SplitName(table.Name); tableSchema != schemaName {
+			continue
+		}
+		if index := table.Index(indexName); index != nil {
+			return index
+		}
+	}
+	return nil
+}
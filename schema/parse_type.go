@@ -0,0 +1,128 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+func (p *parser) createEnum(stmt *pg_query.CreateEnumStmt) error {
+	enum := &Enum{
+		Name:   typeName(stmt.TypeName),
+		Values: stringList(stmt.Vals),
+	}
+	if p.schema.Enum(enum.Name) != nil || p.schema.Domain(enum.Name) != nil || p.schema.CompositeType(enum.Name) != nil {
+		return fmt.Errorf("type %s already exists", enum.Name)
+	}
+
+	p.schema.Enums = append(p.schema.Enums, enum)
+	return nil
+}
+
+func (p *parser) alterEnum(stmt *pg_query.AlterEnumStmt) error {
+	name := typeName(stmt.TypeName)
+	enum := p.schema.Enum(name)
+	if enum == nil {
+		return fmt.Errorf("type %s does not exist", name)
+	}
+	if stmt.OldVal != "" {
+		return fmt.Errorf("type %s: renaming enum values is not supported", name)
+	}
+
+	if slices.Contains(enum.Values, stmt.NewVal) {
+		if stmt.SkipIfNewValExists {
+			return nil
+		}
+		return fmt.Errorf("type %s: enum label %q already exists", name, stmt.NewVal)
+	}
+
+	position := len(enum.Values)
+	if stmt.NewValNeighbor != "" {
+		position = slices.Index(enum.Values, stmt.NewValNeighbor)
+		if position < 0 {
+			return fmt.Errorf("type %s: %q is not an existing enum label", name, stmt.NewValNeighbor)
+		}
+		if stmt.NewValIsAfter {
+			position++
+		}
+	}
+	enum.Values = slices.Insert(enum.Values, position, stmt.NewVal)
+
+	return nil
+}
+
+// Resolves a possibly schema-qualified type or function name to its model name
+func typeName(nodes []*pg_query.Node) string {
+	names := stringList(nodes)
+	if len(names) > 1 {
+		return QualifiedName(names[len(names)-2], names[len(names)-1])
+	}
+	return names[0]
+}
+
+func (p *parser) createCompositeType(stmt *pg_query.CompositeTypeStmt) error {
+	typ := &CompositeType{Name: relationName(stmt.Typevar)}
+	// Composite types get a pg_class entry, like tables
+	relations, name := p.namespace(typ.Name)
+	if relations[name] || p.schema.Enum(typ.Name) != nil || p.schema.Domain(typ.Name) != nil {
+		return fmt.Errorf("type %s already exists", typ.Name)
+	}
+	relations[name] = true
+
+	for _, node := range stmt.Coldeflist {
+		if err := p.addAttribute(typ, node.GetColumnDef()); err != nil {
+			return fmt.Errorf("type %s: %w", typ.Name, err)
+		}
+	}
+
+	p.schema.CompositeTypes = append(p.schema.CompositeTypes, typ)
+	return nil
+}
+
+// Applies ALTER TYPE ... ADD, DROP or ALTER ATTRIBUTE, which Postgres parses
+// as an ALTER TABLE
+func (p *parser) alterCompositeType(stmt *pg_query.AlterTableStmt) error {
+	name := relationName(stmt.Relation)
+	typ := p.schema.CompositeType(name)
+	if typ == nil {
+		return fmt.Errorf("type %s does not exist", name)
+	}
+
+	for _, node := range stmt.Cmds {
+		cmd := node.GetAlterTableCmd()
+		switch cmd.Subtype {
+		case pg_query.AlterTableType_AT_AddColumn:
+			if err := p.addAttribute(typ, cmd.Def.GetColumnDef()); err != nil {
+				return fmt.Errorf("type %s: %w", name, err)
+			}
+		case pg_query.AlterTableType_AT_DropColumn:
+			if typ.Attribute(cmd.Name) == nil {
+				if cmd.MissingOk {
+					continue
+				}
+				return fmt.Errorf("type %s: attribute %s does not exist", name, cmd.Name)
+			}
+			typ.Attributes = slices.DeleteFunc(typ.Attributes, func(a *Attribute) bool { return a.Name == cmd.Name })
+		case pg_query.AlterTableType_AT_AlterColumnType:
+			attribute := typ.Attribute(cmd.Name)
+			if attribute == nil {
+				return fmt.Errorf("type %s: attribute %s does not exist", name, cmd.Name)
+			}
+			attribute.Type = formatType(cmd.Def.GetColumnDef().TypeName)
+		default:
+			return fmt.Errorf("type %s: unsupported ALTER TYPE command: %s", name, cmd.Subtype)
+		}
+	}
+	return nil
+}
+
+func (p *parser) addAttribute(t *CompositeType, def *pg_query.ColumnDef) error {
+	if t.Attribute(def.Colname) != nil {
+		return fmt.Errorf("attribute %s specified more than once", def.Colname)
+	}
+	attribute := &Attribute{Name: def.Colname, Type: formatType(def.TypeName)}
+	t.Attributes = append(t.Attributes, attribute)
+	p.locate(attribute, def.TypeName.Location)
+	return nil
+}
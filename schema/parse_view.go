@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func (p *parser) createView(stmt *pg_query.ViewStmt) error {
+	if len(stmt.Aliases) > 0 {
+		return fmt.Errorf("view %s: column lists are not supported", stmt.View.Relname)
+	}
+	return p.addView(relationName(stmt.View), false, stmt.Query)
+}
+
+func (p *parser) createMaterializedView(stmt *pg_query.CreateTableAsStmt) error {
+	if stmt.Objtype != pg_query.ObjectType_OBJECT_MATVIEW {
+		return fmt.Errorf("unsupported statement: CREATE TABLE AS")
+	}
+	if len(stmt.Into.ColNames) > 0 {
+		return fmt.Errorf("materialized view %s: column lists are not supported", stmt.Into.Rel.Relname)
+	}
+	return p.addView(relationName(stmt.Into.Rel), true, stmt.Query)
+}
+
+func (p *parser) addView(name string, materialized bool, query *pg_query.Node) error {
+	relations, bare := p.namespace(name)
+	if relations[bare] {
+		return fmt.Errorf("relation %s already exists", name)
+	}
+
+	sql, err := deparseStmt(query)
+	if err != nil {
+		return fmt.Errorf("view %s: %w", name, err)
+	}
+
+	relations[bare] = true
+	p.schema.Views = append(p.schema.Views, &View{Name: name, Materialized: materialized, Query: sql})
+	return nil
+}
+
+// ParseView builds a view from its query, such as the output of pg_get_viewdef()
+func ParseView(name string, materialized bool, query string) (*View, error) {
+	tree, err := pg_query.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse view %s: %w", name, err)
+	}
+	if len(tree.Stmts) != 1 || tree.Stmts[0].Stmt.GetSelectStmt() == nil {
+		return nil, fmt.Errorf("view %s is not defined by a SELECT statement", name)
+	}
+
+	sql, err := deparseStmt(tree.Stmts[0].Stmt)
+	if err != nil {
+		return nil, fmt.Errorf("view %s: %w", name, err)
+	}
+
+	return &View{Name: name, Materialized: materialized, Query: sql}, nil
+}
+
+// References lists the tables and views the view reads from. Names of
+// common table expressions are included too, as they look the same
+func (v *View) References() []string {
+	tree, err := pg_query.Parse(v.Query)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	walk(tree.ProtoReflect(), func(m protoreflect.Message) {
+		if rv, ok := m.Interface().(*pg_query.RangeVar); ok && !slices.Contains(names, relationName(rv)) {
+			names = append(names, relationName(rv))
+		}
+	})
+	return names
+}
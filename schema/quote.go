@@ -0,0 +1,62 @@
+package schema
+
+import "strings"
+
+// Reserved keywords that can't be used as bare identifiers
+var reservedWords = map[string]bool{
+	"all": true, "analyse": true, "analyze": true, "and": true, "any": true,
+	"array": true, "as": true, "asc": true, "asymmetric": true, "both": true,
+	"case": true, "cast": true, "check": true, "collate": true, "column": true,
+	"constraint": true, "create": true, "current_catalog": true, "current_date": true,
+	"current_role": true, "current_time": true, "current_timestamp": true,
+	"current_user": true, "default": true, "deferrable": true, "desc": true,
+	"distinct": true, "do": true, "else": true, "end": true, "except": true,
+	"false": true, "fetch": true, "for": true, "foreign": true, "from": true,
+	"grant": true, "group": true, "having": true, "in": true, "initially": true,
+	"intersect": true, "into": true, "lateral": true, "leading": true, "limit": true,
+	"localtime": true, "localtimestamp": true, "not": true, "null": true,
+	"offset": true, "on": true, "only": true, "or": true, "order": true,
+	"placing": true, "primary": true, "references": true, "returning": true,
+	"select": true, "session_user": true, "some": true, "symmetric": true,
+	"system_user": true, "table": true, "then": true, "to": true, "trailing": true,
+	"true": true, "union": true, "unique": true, "user": true, "using": true,
+	"variadic": true, "when": true, "where": true, "window": true, "with": true,
+}
+
+// QuoteIdent quotes an identifier if it can't be used as-is
+func QuoteIdent(name string) string {
+	safe := name != "" && !reservedWords[name] && (name[0] < '0' || name[0] > '9')
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return name
+	}
+
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// QuoteName quotes a model name, which may be qualified with its schema
+func QuoteName(name string) string {
+	if schemaName, object, ok := strings.Cut(name, "."); ok {
+		return QuoteIdent(schemaName) + "." + QuoteIdent(object)
+	}
+	return QuoteIdent(name)
+}
+
+// QuoteRole quotes a role name, the way the catalogs list it. The public
+// pseudo-role stands for every role
+func QuoteRole(role string) string {
+	if role == "public" {
+		return "PUBLIC"
+	}
+	return QuoteIdent(role)
+}
+
+// QuoteLiteral quotes a string as an SQL literal
+func QuoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,578 @@
+// Package schema holds the in-memory model of a database schema. Both the
+// desired state (parsed from schema.sql) and the current state (introspected
+// from a live database) are described with the same types so they can be
+// compared by the diff engine.
+package schema
+
+import (
+	"slices"
+	"strings"
+)
+
+// Schema is the set of objects that make up a database
+type Schema struct {
+	// Schemas are the Postgres schemas besides public
+	Schemas    []string
+	Extensions []*Extension
+	Enums      []*Enum
+	Domains    []*Domain
+	// CompositeTypes are the types created with CREATE TYPE ... AS (...)
+	CompositeTypes []*CompositeType
+	Tables         []*Table
+	Views          []*View
+	Sequences      []*Sequence
+	Functions      []*Function
+	Grants         []*Grant
+	// Servers, UserMappings and ForeignTables are the objects of foreign
+	// data wrappers, like postgres_fdw
+	Servers       []*Server
+	UserMappings  []*UserMapping
+	ForeignTables []*ForeignTable
+	// Publications are the tables whose changes are sent to logical
+	// replication subscribers
+	Publications []*Publication
+
+	// source is the SQL a parsed schema was read from, for Validate to point
+	// into
+	source *source
+}
+
+// Filter removes the objects of the Postgres schemas keep rejects. The public
+// schema is passed as "public"
+func (s *Schema) Filter(keep func(schemaName string) bool) {
+	s.Schemas = slices.DeleteFunc(s.Schemas, func(name string) bool { return !keep(name) })
+	s.FilterObjects(func(name string) bool {
+		schemaName, _ := SplitName(name)
+		return keep(schemaName)
+	})
+}
+
+// FilterObjects removes the enums, domains, composite types, tables, views,
+// sequences, functions and foreign tables whose name keep rejects. Sequences
+// owned by a removed table, and the privileges on removed tables and views,
+// go with them
+func (s *Schema) FilterObjects(keep func(name string) bool) {
+	s.Enums = slices.DeleteFunc(s.Enums, func(e *Enum) bool { return !keep(e.Name) })
+	s.Domains = slices.DeleteFunc(s.Domains, func(d *Domain) bool { return !keep(d.Name) })
+	s.CompositeTypes = slices.DeleteFunc(s.CompositeTypes, func(t *CompositeType) bool { return !keep(t.Name) })
+	s.Tables = slices.DeleteFunc(s.Tables, func(t *Table) bool { return !keep(t.Name) })
+	s.Views = slices.DeleteFunc(s.Views, func(v *View) bool { return !keep(v.Name) })
+	s.Sequences = slices.DeleteFunc(s.Sequences, func(seq *Sequence) bool {
+		if i := strings.LastIndex(seq.OwnedBy, "."); i >= 0 && s.Table(seq.OwnedBy[:i]) == nil {
+			return true
+		}
+		return !keep(seq.Name)
+	})
+	s.Functions = slices.DeleteFunc(s.Functions, func(f *Function) bool { return !keep(f.Name) })
+	s.ForeignTables = slices.DeleteFunc(s.ForeignTables, func(t *ForeignTable) bool { return !keep(t.Name) })
+	s.Grants = slices.DeleteFunc(s.Grants, func(g *Grant) bool {
+		return s.Table(g.Object) == nil && s.View(g.Object) == nil
+	})
+}
+
+// Grant returns the privileges of the role on the table or view, or nil if
+// it has none
+func (s *Schema) Grant(object, role string) *Grant {
+	for _, g := range s.Grants {
+		if g.Object == object && g.Role == role {
+			return g
+		}
+	}
+	return nil
+}
+
+// Extension returns the extension with the given name, or nil if it isn't
+// installed
+func (s *Schema) Extension(name string) *Extension {
+	for _, e := range s.Extensions {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// Enum returns the enum type with the given name, or nil if it doesn't exist
+func (s *Schema) Enum(name string) *Enum {
+	for _, e := range s.Enums {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// Domain returns the domain with the given name, or nil if it doesn't exist
+func (s *Schema) Domain(name string) *Domain {
+	for _, d := range s.Domains {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// CompositeType returns the composite type with the given name, or nil if it
+// doesn't exist
+func (s *Schema) CompositeType(name string) *CompositeType {
+	for _, t := range s.CompositeTypes {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Table returns the table with the given name, or nil if it doesn't exist
+func (s *Schema) Table(name string) *Table {
+	for _, t := range s.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// View returns the view with the given name, or nil if it doesn't exist
+func (s *Schema) View(name string) *View {
+	for _, v := range s.Views {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// Sequence returns the sequence with the given name, or nil if it doesn't exist
+func (s *Schema) Sequence(name string) *Sequence {
+	for _, seq := range s.Sequences {
+		if seq.Name == name {
+			return seq
+		}
+	}
+	return nil
+}
+
+// Function returns the function or procedure with the given name and
+// argument types, or nil if it doesn't exist
+func (s *Schema) Function(name, args string) *Function {
+	for _, f := range s.Functions {
+		if f.Name == name && f.Args == args {
+			return f
+		}
+	}
+	return nil
+}
+
+// Server returns the foreign server with the given name, or nil if it doesn't
+// exist
+func (s *Schema) Server(name string) *Server {
+	for _, srv := range s.Servers {
+		if srv.Name == name {
+			return srv
+		}
+	}
+	return nil
+}
+
+// UserMapping returns the mapping of the user on the foreign server, or nil
+// if there's none
+func (s *Schema) UserMapping(server, user string) *UserMapping {
+	for _, m := range s.UserMappings {
+		if m.Server == server && m.User == user {
+			return m
+		}
+	}
+	return nil
+}
+
+// ForeignTable returns the foreign table with the given name, or nil if it
+// doesn't exist
+func (s *Schema) ForeignTable(name string) *ForeignTable {
+	for _, t := range s.ForeignTables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Publication returns the publication with the given name, or nil if it
+// doesn't exist
+func (s *Schema) Publication(name string) *Publication {
+	for _, pub := range s.Publications {
+		if pub.Name == name {
+			return pub
+		}
+	}
+	return nil
+}
+
+// Extension is a Postgres extension, e.g. pgcrypto
+type Extension struct {
+	Name string
+	// Schema is the schema the extension's objects are created in, empty for
+	// public
+	Schema string
+}
+
+type Enum struct {
+	Name string
+	// Values are the enum labels, in sort order
+	Values []string
+}
+
+// Domain is a type based on another one, with constraints on its values
+type Domain struct {
+	Name string
+	// Type is the base type, as rendered by format_type()
+	Type    string
+	NotNull bool
+	// Default is the default expression, or empty if the domain has none
+	Default string
+	// Checks are the domain's CHECK constraints. Their expressions refer to
+	// the checked value as VALUE
+	Checks []*Constraint
+}
+
+// Check returns the check constraint with the given name, or nil if it
+// doesn't exist
+func (d *Domain) Check(name string) *Constraint {
+	for _, c := range d.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// CompositeType is a row type that isn't backed by a table, e.g. the result
+// of a function returning several values
+type CompositeType struct {
+	Name string
+	// Attributes are the fields of the type, in order
+	Attributes []*Attribute
+}
+
+type Attribute struct {
+	Name string
+	// Type is the canonical type name, as rendered by format_type()
+	Type string
+}
+
+// Attribute returns the attribute with the given name, or nil if it doesn't
+// exist
+func (t *CompositeType) Attribute(name string) *Attribute {
+	for _, a := range t.Attributes {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+type Table struct {
+	Name        string
+	Columns     []*Column
+	Constraints []*Constraint
+	// Indexes holds the indexes that don't back a constraint
+	Indexes  []*Index
+	Triggers []*Trigger
+	// RowSecurity tells whether row-level security is enabled, and
+	// ForceRowSecurity whether it applies to the table owner too
+	RowSecurity      bool
+	ForceRowSecurity bool
+	Policies         []*Policy
+	// Comment is set with COMMENT ON TABLE, empty if there's none
+	Comment string
+	// Options are the storage parameters, like fillfactor or autovacuum
+	// settings, as sorted name=value pairs. Those of the TOAST table start
+	// with toast.
+	Options []string
+	// Tablespace is empty for the database's default tablespace
+	Tablespace string
+	// Unlogged tables skip the write-ahead log, so they're faster to write to
+	// but emptied after a crash and not replicated
+	Unlogged bool
+}
+
+// Column returns the column with the given name, or nil if it doesn't exist
+func (t *Table) Column(name string) *Column {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Constraint returns the constraint with the given name, or nil if it doesn't exist
+func (t *Table) Constraint(name string) *Constraint {
+	for _, c := range t.Constraints {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// PrimaryKey returns the table's primary key, or nil if it doesn't have one
+func (t *Table) PrimaryKey() *Constraint {
+	for _, c := range t.Constraints {
+		if c.Type == PrimaryKey {
+			return c
+		}
+	}
+	return nil
+}
+
+// Index returns the index with the given name, or nil if it doesn't exist
+func (t *Table) Index(name string) *Index {
+	for _, i := range t.Indexes {
+		if i.Name == name {
+			return i
+		}
+	}
+	return nil
+}
+
+// Trigger returns the trigger with the given name, or nil if it doesn't exist
+func (t *Table) Trigger(name string) *Trigger {
+	for _, tr := range t.Triggers {
+		if tr.Name == name {
+			return tr
+		}
+	}
+	return nil
+}
+
+// Policy returns the row-level security policy with the given name, or nil
+// if it doesn't exist
+func (t *Table) Policy(name string) *Policy {
+	for _, p := range t.Policies {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+type Column struct {
+	Name string
+	// Type is the canonical type name, as rendered by Postgres' format_type()
+	Type    string
+	NotNull bool
+	// Default is the default expression, or empty if the column has none
+	Default string
+	// Identity is "ALWAYS" or "BY DEFAULT" for identity columns, otherwise empty
+	Identity string
+	// Generated is the expression of a GENERATED ALWAYS AS (...) STORED
+	// column, or empty if the column isn't generated
+	Generated string
+	// AutoIncrement is MySQL's take on identity columns
+	AutoIncrement bool
+	// Comment is set with COMMENT ON COLUMN, empty if there's none
+	Comment string
+}
+
+type ConstraintType string
+
+const (
+	PrimaryKey ConstraintType = "PRIMARY KEY"
+	Unique     ConstraintType = "UNIQUE"
+	ForeignKey ConstraintType = "FOREIGN KEY"
+	Check      ConstraintType = "CHECK"
+	Exclusion  ConstraintType = "EXCLUDE"
+)
+
+type Constraint struct {
+	Name    string
+	Type    ConstraintType
+	Columns []string
+
+	// Foreign keys only. Actions and match type are empty when they are the
+	// Postgres defaults (NO ACTION, MATCH SIMPLE)
+	RefTable   string
+	RefColumns []string
+	OnUpdate   string
+	OnDelete   string
+	Match      string
+
+	Deferrable        bool
+	InitiallyDeferred bool
+
+	// Expression is the boolean expression of a check constraint
+	Expression string
+	// Definition is the constraint as rendered by pg_get_constraintdef(). It's
+	// only used for constraint types that aren't modelled field by field
+	Definition string
+}
+
+type Index struct {
+	Name   string
+	Unique bool
+	// Method is the index access method, e.g. btree or gin
+	Method string
+	// Keys are the indexed columns or parenthesized expressions, including
+	// their collation, operator class and ordering
+	Keys []string
+	// Include lists the non-key columns of a covering index
+	Include []string
+	// Where is the predicate of a partial index
+	Where string
+	// Options are the storage parameters, as sorted name=value pairs
+	Options []string
+	// Tablespace is empty for the database's default tablespace
+	Tablespace string
+}
+
+type View struct {
+	Name         string
+	Materialized bool
+	// Query is the SELECT statement the view is defined by
+	Query string
+}
+
+type Sequence struct {
+	Name      string
+	Type      string
+	Start     int64
+	Increment int64
+	MinValue  int64
+	MaxValue  int64
+	Cache     int64
+	Cycle     bool
+	// OwnedBy is the "table.column" the sequence belongs to, if any. The
+	// table may be schema-qualified
+	OwnedBy string
+}
+
+type Function struct {
+	Name      string
+	Procedure bool
+	// Args are the types of the input arguments, which together with the name
+	// identify the function, e.g. "integer, text"
+	Args string
+	// Returns is the result type, or empty for procedures
+	Returns string
+	// Definition is the CREATE OR REPLACE statement, normalized so that
+	// parsed and introspected functions compare equal
+	Definition string
+}
+
+// Grant holds the privileges of a role on a table or view
+type Grant struct {
+	// Object is the name of the table or view
+	Object string
+	// Role is the grantee, "public" for every role
+	Role string
+	// Privileges are the privilege types, e.g. SELECT, in sorted order
+	Privileges []string
+}
+
+type Policy struct {
+	Name string
+	// Definition is the CREATE POLICY statement
+	Definition string
+}
+
+type Trigger struct {
+	Name string
+	// Function is the name of the function the trigger executes
+	Function string
+	// Definition is the CREATE TRIGGER statement
+	Definition string
+}
+
+// Server is a foreign server, the remote data source of foreign tables
+type Server struct {
+	Name string
+	// Wrapper is the foreign data wrapper, e.g. postgres_fdw
+	Wrapper string
+	// Type and Version are empty unless the server declares them
+	Type    string
+	Version string
+	// Options are the options of the wrapper, like host and dbname, as
+	// sorted name=value pairs
+	Options []string
+}
+
+// UserMapping holds the options, like the remote user and password, a role
+// connects to a foreign server with
+type UserMapping struct {
+	Server string
+	// User is the mapped role, public for every role
+	User    string
+	Options []string
+}
+
+// ForeignTable is a table whose rows are read from a foreign server
+type ForeignTable struct {
+	Name    string
+	Server  string
+	Columns []*ForeignColumn
+	// Options tell the wrapper where the rows are, like schema_name and
+	// table_name, as sorted name=value pairs
+	Options []string
+}
+
+type ForeignColumn struct {
+	Name string
+	// Type is the canonical type name, as rendered by format_type()
+	Type    string
+	NotNull bool
+	// Options are the options of the wrapper for the column, like
+	// column_name
+	Options []string
+}
+
+// Column returns the column with the given name, or nil if it doesn't exist
+func (t *ForeignTable) Column(name string) *ForeignColumn {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// PublishOperations are the operations a publication can publish, in the
+// order Postgres lists them
+var PublishOperations = []string{"insert", "update", "delete", "truncate"}
+
+// Publication is a set of tables whose changes are replicated
+type Publication struct {
+	Name string
+	// AllTables publishes every table of the database, including future
+	// ones, instead of Tables and Schemas
+	AllTables bool
+	Tables    []*PublicationTable
+	// Schemas publish all their tables, as TABLES IN SCHEMA does
+	Schemas []string
+	// Publish lists the published operations, in the order of
+	// PublishOperations
+	Publish []string
+	// ViaPartitionRoot publishes changes of partitions as changes of their
+	// partitioned table
+	ViaPartitionRoot bool
+}
+
+// PublicationTable is a table of a publication
+type PublicationTable struct {
+	Table string
+	// Columns are the published columns, sorted, or all of them when empty
+	Columns []string
+	// Where is the row filter, empty if every row is published
+	Where string
+}
+
+// Table returns the entry of the table, or nil if the publication doesn't
+// list it
+func (p *Publication) Table(name string) *PublicationTable {
+	for _, t := range p.Tables {
+		if t.Table == name {
+			return t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Sort orders the objects by name, comparing bytes rather than following a
+// collation, so a schema reads the same no matter which database or locale it
+// was introspected from. Columns and attributes keep their position, and enum
+// values their sort order
+func (s *Schema) Sort() {
+	slices.Sort(s.Schemas)
+	sortByName(s.Extensions, func(e *Extension) string { return e.Name })
+	sortByName(s.Enums, func(e *Enum) string { return e.Name })
+	sortByName(s.Domains, func(d *Domain) string { return d.Name })
+	for _, domain := range s.Domains {
+		sortByName(domain.Checks, func(c *Constraint) string { return c.Name })
+	}
+	sortByName(s.CompositeTypes, func(t *CompositeType) string { return t.Name })
+	sortByName(s.Tables, func(t *Table) string { return t.Name })
+	for _, table := range s.Tables {
+		sortByName(table.Constraints, func(c *Constraint) string { return c.Name })
+		sortByName(table.Indexes, func(i *Index) string { return i.Name })
+		sortByName(table.Triggers, func(t *Trigger) string { return t.Name })
+		sortByName(table.Policies, func(p *Policy) string { return p.Name })
+	}
+	sortByName(s.Views, func(v *View) string { return v.Name })
+	sortByName(s.Sequences, func(seq *Sequence) string { return seq.Name })
+	// Overloads share a name and differ by their arguments
+	slices.SortStableFunc(s.Functions, func(a, b *Function) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Args, b.Args))
+	})
+	slices.SortStableFunc(s.Grants, func(a, b *Grant) int {
+		return cmp.Or(cmp.Compare(a.Object, b.Object), cmp.Compare(a.Role, b.Role))
+	})
+	for _, grant := range s.Grants {
+		slices.Sort(grant.Privileges)
+	}
+	sortByName(s.Servers, func(srv *Server) string { return srv.Name })
+	slices.SortStableFunc(s.UserMappings, func(a, b *UserMapping) int {
+		return cmp.Or(cmp.Compare(a.Server, b.Server), cmp.Compare(a.User, b.User))
+	})
+	sortByName(s.ForeignTables, func(t *ForeignTable) string { return t.Name })
+	sortByName(s.Publications, func(p *Publication) string { return p.Name })
+	for _, pub := range s.Publications {
+		sortByName(pub.Tables, func(t *PublicationTable) string { return t.Table })
+		slices.Sort(pub.Schemas)
+	}
+}
+
+func sortByName[T any](objects []T, name func(T) string) {
+	slices.SortStableFunc(objects, func(a, b T) int {
+		return cmp.Compare(name(a), name(b))
+	})
+}
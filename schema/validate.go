@@ -0,0 +1,271 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Built-in types columns can have, as format_type() renders them without
+// their modifiers
+var builtinTypes = []string{
+	"boolean", "smallint", "integer", "bigint", "real", "double precision",
+	"numeric", "money", "character varying", "character", "text", "char", `"char"`,
+	"name", "bytea", "bit", "bit varying", "date", "timestamp without time zone",
+	"timestamp with time zone", "time without time zone", "time with time zone",
+	"interval", "uuid", "json", "jsonb", "jsonpath", "xml", "inet", "cidr",
+	"macaddr", "macaddr8", "tsvector", "tsquery", "point", "line", "lseg", "box",
+	"path", "polygon", "circle", "int4range", "int8range", "numrange", "tsrange",
+	"tstzrange", "daterange", "int4multirange", "int8multirange",
+	"nummultirange", "tsmultirange", "tstzmultirange", "datemultirange", "oid",
+	"xid", "xid8", "cid", "tid", "pg_lsn", "pg_snapshot", "txid_snapshot",
+	"regclass", "regcollation", "regconfig", "regdictionary", "regnamespace",
+	"regoper", "regoperator", "regproc", "regprocedure", "regrole", "regtype",
+	"refcursor", "aclitem",
+}
+
+// Matches the modifiers of a type, like the (10) of character varying(10)
+// or the (3) of timestamp(3) with time zone
+var typeModifier = regexp.MustCompile(`\(\d+(,\d+)?\)`)
+
+// SourceError is an error in the SQL a schema was parsed from, located at
+// the line and column of the statement or clause it's about
+type SourceError struct {
+	// File is the path of the schema file, if it was read from one
+	File   string
+	Line   int
+	Column int
+	// Source is the line of SQL the error is on
+	Source string
+	Err    error
+}
+
+func (e *SourceError) Error() string {
+	if e.Line == 0 && e.File != "" {
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	}
+	if e.Line == 0 {
+		return e.Err.Error()
+	}
+	location := fmt.Sprintf("%d:%d", e.Line, e.Column)
+	if e.File != "" {
+		location = e.File + ":" + location
+	}
+
+	// Tabs before the column are kept so the caret lines up with it
+	var indent strings.Builder
+	for i, r := range []rune(e.Source) {
+		if i == e.Column-1 {
+			break
+		}
+		if r == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+	gutter := fmt.Sprintf("%5d | ", e.Line)
+	return fmt.Sprintf("%s: %v\n%s%s\n%*s| %s^", location, e.Err, gutter, e.Source, len(gutter)-2, "", indent.String())
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// SourceErrors returns the *SourceErrors err wraps or joins
+func SourceErrors(err error) []*SourceError {
+	switch wrapped := err.(type) {
+	case nil:
+		return nil
+	case *SourceError:
+		return []*SourceError{wrapped}
+	case interface{ Unwrap() []error }:
+		var errs []*SourceError
+		for _, err := range wrapped.Unwrap() {
+			errs = append(errs, SourceErrors(err)...)
+		}
+		return errs
+	}
+	return SourceErrors(errors.Unwrap(err))
+}
+
+// The SQL a schema was parsed from, with where its objects were declared
+type source struct {
+	file string
+	sql  string
+	// locations are the byte offsets of tables, columns, constraints, domains,
+	// attributes and foreign columns, pointing at their name or type
+	locations map[any]int
+}
+
+// Returns err located at the byte offset of the SQL
+func (src *source) errorAt(offset int, err error) *SourceError {
+	offset = min(max(offset, 0), len(src.sql))
+	start := strings.LastIndexByte(src.sql[:offset], '\n') + 1
+	end := strings.IndexByte(src.sql[offset:], '\n')
+	if end < 0 {
+		end = len(src.sql)
+	} else {
+		end += offset
+	}
+	return &SourceError{
+		File:   src.file,
+		Line:   strings.Count(src.sql[:start], "\n") + 1,
+		Column: utf8.RuneCountInString(src.sql[start:offset]) + 1,
+		Source: strings.TrimRight(src.sql[start:end], "\r"),
+		Err:    err,
+	}
+}
+
+// Returns err located where the first of objects with a known location was
+// declared, or without a location
+func (src *source) errorOn(err error, objects ...any) *SourceError {
+	if src != nil {
+		for _, o := range objects {
+			if offset, ok := src.locations[o]; ok {
+				return src.errorAt(offset, err)
+			}
+		}
+	}
+	return &SourceError{Err: err}
+}
+
+// Skips the whitespace and comments before a statement, which Postgres
+// counts as part of it
+func statementStart(sql string, offset int) int {
+	for offset < len(sql) {
+		switch {
+		case strings.ContainsRune(" \t\r\n\f", rune(sql[offset])):
+			offset++
+		case strings.HasPrefix(sql[offset:], "--"):
+			end := strings.IndexByte(sql[offset:], '\n')
+			if end < 0 {
+				return len(sql)
+			}
+			offset += end + 1
+		case strings.HasPrefix(sql[offset:], "/*"):
+			end := strings.Index(sql[offset+2:], "*/")
+			if end < 0 {
+				return len(sql)
+			}
+			offset += end + 4
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// Validate checks what parsing schema.sql can't catch on its own, but would
+// fail once it's loaded into Postgres: foreign keys referencing tables or
+// columns that don't exist, and columns of types that don't. Each problem is
+// a *SourceError pointing into the parsed SQL.
+//
+// Extensions can add types of their own, so types aren't checked when the
+// schema creates extensions or extensions are installed before it's loaded
+func (s *Schema) Validate(extensions []string) error {
+	var errs []*SourceError
+	for _, table := range s.Tables {
+		for _, constraint := range table.Constraints {
+			if constraint.Type != ForeignKey {
+				continue
+			}
+			if err := s.validateForeignKey(table, constraint); err != nil {
+				errs = append(errs, s.source.errorOn(fmt.Errorf("table %s: foreign key %s %w", table.Name, constraint.Name, err), constraint, table))
+			}
+		}
+	}
+
+	if len(s.Extensions) == 0 && len(extensions) == 0 {
+		check := func(typeName string, what string, objects ...any) {
+			if !s.hasType(typeName) {
+				errs = append(errs, s.source.errorOn(fmt.Errorf("%s: type %s does not exist", what, typeName), objects...))
+			}
+		}
+		for _, domain := range s.Domains {
+			check(domain.Type, "domain "+domain.Name, domain)
+		}
+		for _, typ := range s.CompositeTypes {
+			for _, attribute := range typ.Attributes {
+				check(attribute.Type, fmt.Sprintf("type %s: attribute %s", typ.Name, attribute.Name), attribute)
+			}
+		}
+		for _, table := range s.Tables {
+			for _, column := range table.Columns {
+				check(column.Type, fmt.Sprintf("table %s: column %s", table.Name, column.Name), column, table)
+			}
+		}
+		for _, table := range s.ForeignTables {
+			for _, column := range table.Columns {
+				check(column.Type, fmt.Sprintf("foreign table %s: column %s", table.Name, column.Name), column)
+			}
+		}
+	}
+
+	// Errors come in the order of the SQL, those without a location last
+	slices.SortStableFunc(errs, func(a, b *SourceError) int {
+		if a.Line == 0 || b.Line == 0 {
+			return b.Line - a.Line
+		}
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Column - b.Column
+	})
+	joined := make([]error, len(errs))
+	for i, err := range errs {
+		joined[i] = err
+	}
+	return errors.Join(joined...)
+}
+
+// Returns why a foreign key of table can't be created, completing "foreign
+// key name ...", or nil if it can
+func (s *Schema) validateForeignKey(table *Table, fk *Constraint) error {
+	for _, name := range fk.Columns {
+		if table.Column(name) == nil {
+			return fmt.Errorf("is on column %s, which does not exist", name)
+		}
+	}
+	ref := s.Table(fk.RefTable)
+	if ref == nil {
+		return fmt.Errorf("references table %s, which does not exist", fk.RefTable)
+	}
+	// Foreign keys without a column list got the primary key's when parsed
+	if len(fk.RefColumns) == 0 {
+		return fmt.Errorf("references table %s, which has no primary key", fk.RefTable)
+	}
+	for _, name := range fk.RefColumns {
+		if ref.Column(name) == nil {
+			return fmt.Errorf("references column %s.%s, which does not exist", fk.RefTable, name)
+		}
+	}
+	if len(fk.Columns) != len(fk.RefColumns) {
+		return fmt.Errorf("has %d columns but references %d", len(fk.Columns), len(fk.RefColumns))
+	}
+	return nil
+}
+
+// Reports whether a type rendered like format_type() does is built in, or
+// defined by the schema. Rows of tables and views are types too
+func (s *Schema) hasType(typeName string) bool {
+	typeName = strings.TrimSuffix(typeName, "[]")
+	typeName = typeModifier.ReplaceAllString(typeName, "")
+	if strings.HasPrefix(typeName, "interval") || slices.Contains(builtinTypes, typeName) {
+		return true
+	}
+
+	// User types are quoted where they need it, the model names them as is
+	parts := strings.Split(typeName, ".")
+	for i, part := range parts {
+		if unquoted, ok := strings.CutPrefix(part, `"`); ok {
+			parts[i] = strings.ReplaceAll(strings.TrimSuffix(unquoted, `"`), `""`, `"`)
+		}
+	}
+	name := strings.Join(parts, ".")
+	return s.Enum(name) != nil || s.Domain(name) != nil || s.CompositeType(name) != nil ||
+		s.Table(name) != nil || s.View(name) != nil || s.ForeignTable(name) != nil
+}
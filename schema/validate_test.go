@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		sql        string
+		extensions []string
+		want       []string
+	}{
+		{
+			name: "valid",
+			sql:  "CREATE TYPE mood AS ENUM ('a'); CREATE TABLE t (id int PRIMARY KEY, m mood[], at timestamp(3) with time zone, v varchar(3));",
+		},
+		{
+			name: "foreign key to a missing column",
+			sql:  "CREATE TABLE users (id int PRIMARY KEY);\nCREATE TABLE orders (\n  user_id int REFERENCES users (uid)\n);",
+			want: []string{"3:15: table orders: foreign key orders_user_id_fkey references column users.uid, which does not exist"},
+		},
+		{
+			name: "foreign key to a missing table",
+			sql:  "CREATE TABLE orders (user_id int REFERENCES users);",
+			want: []string{"1:34: table orders: foreign key orders_user_id_fkey references table users, which does not exist"},
+		},
+		{
+			name: "unknown types",
+			sql:  "CREATE DOMAIN d AS foo;\nCREATE TABLE t (a txt);",
+			want: []string{"1:20: domain d: type foo does not exist", "2:19: table t: column a: type txt does not exist"},
+		},
+		{
+			name:       "types of extensions",
+			sql:        "CREATE TABLE t (g geometry);",
+			extensions: []string{"postgis"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.sql)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.Validate(tt.extensions)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("no error, want %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error doesn't contain %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrorLocation(t *testing.T) {
+	tests := []struct {
+		name         string
+		sql          string
+		line, column int
+	}{
+		{"syntax error", "CREATE TABLE t (\n  a tex t\n);", 2, 9},
+		{"table defined twice", "CREATE TABLE t (a int);\n\n-- again\nCREATE TABLE t (a int);", 4, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.sql)
+			var sourceErr *SourceError
+			if !errors.As(err, &sourceErr) {
+				t.Fatalf("got %v, want a *SourceError", err)
+			}
+			if sourceErr.Line != tt.line || sourceErr.Column != tt.column {
+				t.Errorf("got %d:%d, want %d:%d", sourceErr.Line, sourceErr.Column, tt.line, tt.column)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+// Package schemadoc generates a documentation site of a schema, in HTML or
+// Markdown: an index of its objects with a diagram of the tables, and a page
+// for each table and view describing its columns, comments, constraints,
+// indexes and the foreign keys to and from it.
+package schemadoc
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"styx/erd"
+	"styx/schema"
+)
+
+// Formats the site can be generated in
+const (
+	HTML     = "html"
+	Markdown = "markdown"
+)
+
+//go:embed site.html
+var htmlTemplates string
+
+//go:embed site.md
+var markdownTemplates string
+
+var unsafeFileChars = regexp.MustCompile(`[^\w.-]`)
+
+// Render generates the pages of the site documenting s, by path relative to
+// the root of the site, e.g. tables/users.html
+func Render(s *schema.Schema, format string) (map[string]string, error) {
+	// The templates of both formats share their names and data
+	var t interface {
+		ExecuteTemplate(w io.Writer, name string, data any) error
+	}
+	var err error
+	switch format {
+	case HTML:
+		t, err = htmltemplate.New("site").Parse(htmlTemplates)
+	case Markdown:
+		t, err = template.New("site").Funcs(template.FuncMap{"cell": markdownCell}).Parse(markdownTemplates)
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected html or markdown", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	execute := func(name string, data any) (string, error) {
+		var b bytes.Buffer
+		err := t.ExecuteTemplate(&b, name, data)
+		return b.String(), err
+	}
+
+	ext := ".html"
+	if format == Markdown {
+		ext = ".md"
+	}
+	site := newSite(s, ext)
+	pages := map[string]string{}
+	page, err := execute("index", site)
+	if err != nil {
+		return nil, err
+	}
+	pages["index"+ext] = page
+	for _, table := range site.Tables {
+		if pages[table.File], err = execute("table", table); err != nil {
+			return nil, err
+		}
+	}
+	for _, view := range site.Views {
+		if pages[view.File], err = execute("view", view); err != nil {
+			return nil, err
+		}
+	}
+	return pages, nil
+}
+
+// site is what the index page shows
+type site struct {
+	Tables    []*tableDoc
+	Views     []*viewDoc
+	Enums     []*schema.Enum
+	Domains   []*schema.Domain
+	Sequences []*schema.Sequence
+	Functions []*schema.Function
+	// Diagram is the Mermaid ER diagram of all the tables
+	Diagram string
+}
+
+type tableDoc struct {
+	Name    string
+	File    string
+	Comment string
+	Columns []columnDoc
+	// Constraints are the primary key, unique, check and exclusion
+	// constraints, with foreign keys in References
+	Constraints  []constraintDoc
+	Indexes      []constraintDoc
+	References   []referenceDoc
+	ReferencedBy []referenceDoc
+	Triggers     []*schema.Trigger
+	Policies     []*schema.Policy
+	// Diagram is the Mermaid ER diagram of the table and those it's related
+	// to
+	Diagram string
+}
+
+type columnDoc struct {
+	Name    string
+	Type    string
+	NotNull bool
+	Default string
+	Keys    string
+	Comment string
+}
+
+// constraintDoc is a constraint or index with its definition, e.g. (email)
+// WHERE deleted_at IS NULL
+type constraintDoc struct {
+	Name       string
+	Type       string
+	Definition string
+}
+
+// referenceDoc is a foreign key, seen from the table of the page it's on
+type referenceDoc struct {
+	Name    string
+	Columns string
+	// Table is the table on the other side of the foreign key, and File its
+	// page, relative to the page of the table, or empty if it isn't
+	// documented
+	Table      string
+	File       string
+	RefColumns string
+	OnDelete   string
+}
+
+type viewDoc struct {
+	Name         string
+	File         string
+	Materialized bool
+	Query        string
+}
+
+func newSite(s *schema.Schema, ext string) *site {
+	st := &site{Enums: s.Enums, Domains: s.Domains, Sequences: s.Sequences, Functions: s.Functions}
+	st.Diagram, _ = erd.Render(s, erd.Mermaid)
+	for _, table := range s.Tables {
+		st.Tables = append(st.Tables, newTableDoc(s, table, ext))
+	}
+	for _, view := range s.Views {
+		st.Views = append(st.Views, &viewDoc{
+			Name:         view.Name,
+			File:         "views/" + fileName(view.Name) + ext,
+			Materialized: view.Materialized,
+			Query:        view.Query,
+		})
+	}
+	return st
+}
+
+func newTableDoc(s *schema.Schema, table *schema.Table, ext string) *tableDoc {
+	doc := &tableDoc{
+		Name:     table.Name,
+		File:     "tables/" + fileName(table.Name) + ext,
+		Comment:  table.Comment,
+		Triggers: table.Triggers,
+		Policies: table.Policies,
+	}
+	for _, c := range table.Columns {
+		doc.Columns = append(doc.Columns, columnDoc{
+			Name:    c.Name,
+			Type:    c.Type,
+			NotNull: c.NotNull,
+			Default: columnDefault(c),
+			Keys:    strings.Join(columnKeys(table, c.Name), ", "),
+			Comment: c.Comment,
+		})
+	}
+
+	// The diagram shows the tables this one references and is referenced by
+	related := &schema.Schema{Tables: []*schema.Table{table}}
+	for _, con := range table.Constraints {
+		if con.Type != schema.ForeignKey {
+			doc.Constraints = append(doc.Constraints, constraintDoc{Name: con.Name, Type: string(con.Type), Definition: constraintDefinition(con)})
+			continue
+		}
+		ref := s.Table(con.RefTable)
+		doc.References = append(doc.References, newReferenceDoc(con, con.RefTable, ref != nil, ext))
+		if ref != nil && related.Table(ref.Name) == nil {
+			related.Tables = append(related.Tables, ref)
+		}
+	}
+	for _, other := range s.Tables {
+		for _, con := range other.Constraints {
+			if con.Type == schema.ForeignKey && con.RefTable == table.Name {
+				doc.ReferencedBy = append(doc.ReferencedBy, newReferenceDoc(con, other.Name, true, ext))
+				if related.Table(other.Name) == nil {
+					related.Tables = append(related.Tables, other)
+				}
+			}
+		}
+	}
+	for _, index := range table.Indexes {
+		typ := "INDEX"
+		if index.Unique {
+			typ = "UNIQUE INDEX"
+		}
+		doc.Indexes = append(doc.Indexes, constraintDoc{Name: index.Name, Type: typ, Definition: indexDefinition(index)})
+	}
+	doc.Diagram, _ = erd.Render(related, erd.Mermaid)
+	return doc
+}
+
+// Returns the foreign key con as listed on the page of a table, with table
+// the one on the other side, which has a page when documented is set
+func newReferenceDoc(con *schema.Constraint, table string, documented bool, ext string) referenceDoc {
+	ref := referenceDoc{
+		Name:       con.Name,
+		Columns:    strings.Join(con.Columns, ", "),
+		Table:      table,
+		RefColumns: strings.Join(con.RefColumns, ", "),
+		OnDelete:   con.OnDelete,
+	}
+	if documented {
+		ref.File = fileName(table) + ext
+	}
+	return ref
+}
+
+// Returns the default of a column, or how it's generated
+func columnDefault(c *schema.Column) string {
+	switch {
+	case c.Generated != "":
+		return "GENERATED ALWAYS AS (" + c.Generated + ") STORED"
+	case c.Identity != "":
+		return "GENERATED " + c.Identity + " AS IDENTITY"
+	case c.AutoIncrement:
+		return "AUTO_INCREMENT"
+	}
+	return c.Default
+}
+
+// Returns the keys the column is part of: PK, FK and UK for single-column
+// unique constraints and indexes
+func columnKeys(table *schema.Table, column string) []string {
+	var primary, foreign, unique bool
+	for _, con := range table.Constraints {
+		if !slices.Contains(con.Columns, column) {
+			continue
+		}
+		switch con.Type {
+		case schema.PrimaryKey:
+			primary = true
+		case schema.ForeignKey:
+			foreign = true
+		case schema.Unique:
+			unique = unique || len(con.Columns) == 1
+		}
+	}
+	for _, index := range table.Indexes {
+		if index.Unique && index.Where == "" && len(index.Keys) == 1 && index.Keys[0] == column {
+			unique = true
+		}
+	}
+
+	var keys []string
+	if primary {
+		keys = append(keys, "PK")
+	}
+	if foreign {
+		keys = append(keys, "FK")
+	}
+	if unique {
+		keys = append(keys, "UK")
+	}
+	return keys
+}
+
+func constraintDefinition(con *schema.Constraint) string {
+	switch {
+	case con.Definition != "":
+		return con.Definition
+	case con.Type == schema.Check:
+		return "CHECK (" + con.Expression + ")"
+	}
+	return "(" + strings.Join(con.Columns, ", ") + ")"
+}
+
+func indexDefinition(index *schema.Index) string {
+	def := "(" + strings.Join(index.Keys, ", ") + ")"
+	if index.Method != "" && index.Method != "btree" {
+		def = "USING " + index.Method + " " + def
+	}
+	if len(index.Include) > 0 {
+		def += " INCLUDE (" + strings.Join(index.Include, ", ") + ")"
+	}
+	if index.Where != "" {
+		def += " WHERE " + index.Where
+	}
+	return def
+}
+
+// Returns the file name of an object's page, with the characters that
+// aren't safe in paths replaced
+func fileName(name string) string {
+	return unsafeFileChars.ReplaceAllString(name, "_")
+}
+
+// Escapes a value for a cell of a Markdown table
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>").Replace(s)
+}
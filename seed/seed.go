@@ -0,0 +1,131 @@
+// Package seed loads reference data, like the rows of lookup tables, from a
+// directory of SQL and CSV files.
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"styx/dialect"
+)
+
+// Files lists the seed files in dir in the order they're loaded, by name.
+// A missing directory has none
+func Files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read seeds directory %s: %w", dir, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if !entry.IsDir() && (ext == ".sql" || ext == ".csv") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// Load runs the seed files of dir against db, each in a transaction of its
+// own, and returns the files it loaded. SQL files are executed as is. CSV
+// files are inserted into the table named after the file, e.g. countries.csv
+// or billing.currencies.csv, with the column names in their first row. Empty
+// fields are NULL
+func Load(ctx context.Context, db *sql.DB, d *dialect.Dialect, dir string) ([]string, error) {
+	files, err := Files(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range files {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Ext(path) == ".csv" {
+			err = loadCSV(ctx, tx, d, path)
+		} else {
+			err = loadSQL(ctx, tx, path)
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", path, err)
+		}
+	}
+	return files, nil
+}
+
+func loadSQL(ctx context.Context, tx *sql.Tx, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, string(data))
+	return err
+}
+
+func loadCSV(ctx context.Context, tx *sql.Tx, d *dialect.Dialect, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	table := strings.TrimSuffix(filepath.Base(path), ".csv")
+	columns := make([]string, len(header))
+	placeholders := make([]string, len(header))
+	for i, name := range header {
+		columns[i] = d.QuoteName(strings.TrimSpace(name))
+		placeholders[i] = d.Placeholder(i + 1)
+	}
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		d.QuoteName(table), strings.Join(columns, ", "), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		values := make([]any, len(record))
+		for i, field := range record {
+			if field != "" {
+				values[i] = field
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			line, _ := r.FieldPos(0)
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}